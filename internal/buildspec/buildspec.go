@@ -0,0 +1,267 @@
+// Package buildspec describes how to build an app image. The same Spec is
+// read from a Pierfile's build block, a compose service's build key and
+// orchestrator.AppSpec.
+package buildspec
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Spec is a BuildKit build. In a Pierfile:
+//
+//	build:
+//	  dockerfile: docker/Dockerfile.dev
+//	  target: runtime
+//	  args:
+//	    NODE_VERSION: "20"
+//	  secrets:
+//	    - id=npmrc,src=~/.npmrc
+//	  ssh: [default]
+//	  platform: linux/amd64
+//	  cache_from: [type=registry,ref=ghcr.io/acme/shop:cache]
+//	  cache_to: [type=inline]
+//
+// `build: true` and compose's `build: ./dir` are accepted too.
+type Spec struct {
+	Context    string            `yaml:"context,omitempty" json:"context,omitempty"`
+	Dockerfile string            `yaml:"dockerfile,omitempty" json:"dockerfile,omitempty"`
+	Args       map[string]string `yaml:"args,omitempty" json:"args,omitempty"`
+	Target     string            `yaml:"target,omitempty" json:"target,omitempty"`
+	Secrets    []string          `yaml:"secrets,omitempty" json:"secrets,omitempty"` // id=ID[,src=PATH|,env=VAR]
+	SSH        []string          `yaml:"ssh,omitempty" json:"ssh,omitempty"`         // default, or ID=PATH[,PATH]
+	Platform   string            `yaml:"platform,omitempty" json:"platform,omitempty"`
+	CacheFrom  []string          `yaml:"cache_from,omitempty" json:"cacheFrom,omitempty"`
+	CacheTo    []string          `yaml:"cache_to,omitempty" json:"cacheTo,omitempty"`
+}
+
+// UnmarshalYAML accepts `true`, a context path, or the full map. Args may
+// be a map or a KEY=VALUE list, as in compose.
+func (s *Spec) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		var enabled bool
+		if value.Tag == "!!bool" {
+			if err := value.Decode(&enabled); err != nil {
+				return err
+			}
+			if !enabled {
+				return fmt.Errorf("build: false isn't supported; remove the build key instead")
+			}
+			*s = Spec{}
+			return nil
+		}
+		*s = Spec{Context: value.Value}
+		return nil
+	}
+	if value.Kind != yaml.MappingNode {
+		return fmt.Errorf("build must be true, a path or a map, got %v", value.Kind)
+	}
+
+	var raw struct {
+		Context    string    `yaml:"context"`
+		Dockerfile string    `yaml:"dockerfile"`
+		Args       yaml.Node `yaml:"args"`
+		Target     string    `yaml:"target"`
+		Secrets    yaml.Node `yaml:"secrets"`
+		SSH        []string  `yaml:"ssh"`
+		Platform   string    `yaml:"platform"`
+		Platforms  []string  `yaml:"platforms"` // compose; the first is built
+		CacheFrom  []string  `yaml:"cache_from"`
+		CacheTo    []string  `yaml:"cache_to"`
+	}
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+	args, err := decodeArgs(&raw.Args)
+	if err != nil {
+		return err
+	}
+	secrets, err := decodeSecrets(&raw.Secrets)
+	if err != nil {
+		return err
+	}
+	*s = Spec{
+		Context:    raw.Context,
+		Dockerfile: raw.Dockerfile,
+		Args:       args,
+		Target:     raw.Target,
+		Secrets:    secrets,
+		SSH:        raw.SSH,
+		Platform:   raw.Platform,
+		CacheFrom:  raw.CacheFrom,
+		CacheTo:    raw.CacheTo,
+	}
+	if s.Platform == "" && len(raw.Platforms) > 0 {
+		s.Platform = raw.Platforms[0]
+	}
+	return nil
+}
+
+// MarshalYAML writes an empty spec back as `build: true`
+func (s Spec) MarshalYAML() (interface{}, error) {
+	if s.IsZero() {
+		return true, nil
+	}
+	type raw Spec
+	return raw(s), nil
+}
+
+// IsZero reports whether nothing beyond the defaults is set
+func (s Spec) IsZero() bool {
+	return s.Context == "" && s.Dockerfile == "" && len(s.Args) == 0 && s.Target == "" &&
+		len(s.Secrets) == 0 && len(s.SSH) == 0 && s.Platform == "" &&
+		len(s.CacheFrom) == 0 && len(s.CacheTo) == 0
+}
+
+// decodeArgs reads build args as a map or a KEY=VALUE list. A bare KEY
+// takes its value from the environment, like `docker build --build-arg KEY`.
+func decodeArgs(node *yaml.Node) (map[string]string, error) {
+	switch node.Kind {
+	case 0:
+		return nil, nil
+	case yaml.MappingNode:
+		var m map[string]string
+		if err := node.Decode(&m); err != nil {
+			return nil, fmt.Errorf("build args: %w", err)
+		}
+		return m, nil
+	case yaml.SequenceNode:
+		var list []string
+		if err := node.Decode(&list); err != nil {
+			return nil, fmt.Errorf("build args: %w", err)
+		}
+		m := make(map[string]string, len(list))
+		for _, item := range list {
+			k, v, ok := strings.Cut(item, "=")
+			if !ok {
+				v = os.Getenv(k)
+			}
+			m[k] = v
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("build args must be a map or a list")
+}
+
+// decodeSecrets reads a list of secrets. Compose's long form
+// ({source: NAME}) is reduced to the name it refers to.
+func decodeSecrets(node *yaml.Node) ([]string, error) {
+	if node.Kind == 0 {
+		return nil, nil
+	}
+	if node.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("build secrets must be a list")
+	}
+	var secrets []string
+	for _, item := range node.Content {
+		if item.Kind == yaml.MappingNode {
+			var long struct {
+				Source string `yaml:"source"`
+			}
+			if err := item.Decode(&long); err != nil {
+				return nil, fmt.Errorf("build secrets: %w", err)
+			}
+			secrets = append(secrets, long.Source)
+			continue
+		}
+		secrets = append(secrets, item.Value)
+	}
+	return secrets, nil
+}
+
+// Secret is a build secret, mounted with RUN --mount=type=secret,id=ID
+type Secret struct {
+	ID  string
+	Src string // file on the host
+	Env string // or environment variable
+}
+
+// ParseSecret reads docker's --secret syntax: id=ID[,src=PATH|,env=VAR].
+// Without src or env, the variable named ID is used if set, else the file.
+func ParseSecret(s string) (Secret, error) {
+	var sec Secret
+	for _, field := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(field, "=")
+		if !ok {
+			return Secret{}, fmt.Errorf("invalid secret %q: expected key=value fields", s)
+		}
+		switch strings.ToLower(k) {
+		case "id":
+			sec.ID = v
+		case "src", "source":
+			sec.Src = expandHome(v)
+		case "env":
+			sec.Env = v
+		case "type":
+			if v != "file" && v != "env" {
+				return Secret{}, fmt.Errorf("invalid secret %q: unknown type %q", s, v)
+			}
+		default:
+			return Secret{}, fmt.Errorf("invalid secret %q: unknown field %q", s, k)
+		}
+	}
+	if sec.ID == "" {
+		return Secret{}, fmt.Errorf("invalid secret %q: id is required", s)
+	}
+	if sec.Src == "" && sec.Env == "" {
+		if _, ok := os.LookupEnv(sec.ID); ok {
+			sec.Env = sec.ID
+		} else {
+			sec.Src = sec.ID
+		}
+	}
+	return sec, nil
+}
+
+// SSHAgent is an SSH agent socket or keys forwarded to the build
+type SSHAgent struct {
+	ID    string   // "default" for RUN --mount=type=ssh
+	Paths []string // sockets or keys; empty means $SSH_AUTH_SOCK
+}
+
+// ParseSSH reads docker's --ssh syntax: default, or ID=PATH[,PATH]
+func ParseSSH(s string) (SSHAgent, error) {
+	id, paths, _ := strings.Cut(s, "=")
+	if id == "" {
+		return SSHAgent{}, fmt.Errorf("invalid ssh %q: id is required", s)
+	}
+	agent := SSHAgent{ID: id}
+	if paths != "" {
+		for _, p := range strings.Split(paths, ",") {
+			agent.Paths = append(agent.Paths, expandHome(p))
+		}
+	}
+	return agent, nil
+}
+
+// Resolve returns the build context and Dockerfile as absolute paths,
+// relative ones taken from dir
+func (s Spec) Resolve(dir string) (contextDir, dockerfile string) {
+	contextDir = dir
+	if s.Context != "" {
+		contextDir = s.Context
+		if !filepath.IsAbs(contextDir) {
+			contextDir = filepath.Join(dir, contextDir)
+		}
+	}
+	if s.Dockerfile != "" {
+		dockerfile = s.Dockerfile
+		if !filepath.IsAbs(dockerfile) {
+			dockerfile = filepath.Join(contextDir, dockerfile)
+		}
+	}
+	return contextDir, dockerfile
+}
+
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
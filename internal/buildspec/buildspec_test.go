@@ -0,0 +1,111 @@
+package buildspec
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestSpec_UnmarshalYAML(t *testing.T) {
+	t.Setenv("GIT_TOKEN", "from-env")
+
+	tests := []struct {
+		name string
+		yaml string
+		want Spec
+	}{
+		{"true", "build: true", Spec{}},
+		{"context path", "build: ./api", Spec{Context: "./api"}},
+		{
+			"map",
+			"build:\n  context: api\n  dockerfile: Dockerfile.prod\n  target: runtime\n  args:\n    NODE_VERSION: \"20\"\n  platform: linux/arm64\n  cache_to: [type=inline]\n",
+			Spec{Context: "api", Dockerfile: "Dockerfile.prod", Target: "runtime", Args: map[string]string{"NODE_VERSION": "20"}, Platform: "linux/arm64", CacheTo: []string{"type=inline"}},
+		},
+		{
+			"compose list args and platforms",
+			"build:\n  args:\n    - NODE_VERSION=20\n    - GIT_TOKEN\n  platforms: [linux/amd64, linux/arm64]\n",
+			Spec{Args: map[string]string{"NODE_VERSION": "20", "GIT_TOKEN": "from-env"}, Platform: "linux/amd64"},
+		},
+		{
+			"compose long secrets",
+			"build:\n  secrets:\n    - npmrc\n    - source: github\n      target: gh\n",
+			Spec{Secrets: []string{"npmrc", "github"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc struct {
+				Build *Spec `yaml:"build"`
+			}
+			if err := yaml.Unmarshal([]byte(tt.yaml), &doc); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if doc.Build == nil || !reflect.DeepEqual(*doc.Build, tt.want) {
+				t.Errorf("got %+v, want %+v", doc.Build, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpec_MarshalYAML(t *testing.T) {
+	out, err := yaml.Marshal(struct {
+		Build *Spec `yaml:"build"`
+	}{&Spec{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "build: true\n" {
+		t.Errorf("got %q, want build: true", out)
+	}
+}
+
+func TestParseSecret(t *testing.T) {
+	t.Setenv("NPM_TOKEN", "x")
+
+	tests := []struct {
+		in      string
+		want    Secret
+		wantErr bool
+	}{
+		{"id=npmrc,src=.npmrc", Secret{ID: "npmrc", Src: ".npmrc"}, false},
+		{"id=token,env=GH_TOKEN", Secret{ID: "token", Env: "GH_TOKEN"}, false},
+		{"id=NPM_TOKEN", Secret{ID: "NPM_TOKEN", Env: "NPM_TOKEN"}, false},
+		{"id=creds", Secret{ID: "creds", Src: "creds"}, false},
+		{"src=.npmrc", Secret{}, true},
+		{"npmrc", Secret{}, true},
+		{"id=x,mode=0400", Secret{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSecret(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSecret(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseSecret(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseSSH(t *testing.T) {
+	got, err := ParseSSH("default")
+	if err != nil || got.ID != "default" || len(got.Paths) != 0 {
+		t.Errorf("ParseSSH(default) = %+v, %v", got, err)
+	}
+	got, err = ParseSSH("github=/keys/a,/keys/b")
+	if err != nil || got.ID != "github" || !reflect.DeepEqual(got.Paths, []string{"/keys/a", "/keys/b"}) {
+		t.Errorf("ParseSSH(github=...) = %+v, %v", got, err)
+	}
+}
+
+func TestSpec_Resolve(t *testing.T) {
+	ctx, df := Spec{}.Resolve("/src/shop")
+	if ctx != "/src/shop" || df != "" {
+		t.Errorf("defaults = %q, %q", ctx, df)
+	}
+	ctx, df = Spec{Context: "api", Dockerfile: "docker/Dockerfile"}.Resolve("/src/shop")
+	if ctx != "/src/shop/api" || df != "/src/shop/api/docker/Dockerfile" {
+		t.Errorf("relative = %q, %q", ctx, df)
+	}
+}
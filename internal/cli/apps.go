@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/eshe-huli/pier/internal/docker"
+	"github.com/eshe-huli/pier/internal/infra"
+	"github.com/eshe-huli/pier/internal/registry"
+)
+
+// appOwner labels a project's service container
+func appOwner(project, service string) docker.Owner {
+	return docker.Owner{Role: docker.RoleApp, Project: project, Service: service}
+}
+
+// appContainer resolves a project or service name to its container name,
+// preferring the service named name in multi-service projects. Containers
+// from before namespacing are still found under the bare name.
+func appContainer(ctx context.Context, name string) string {
+	containers, _ := docker.AppContainers(ctx, name)
+	for _, c := range containers {
+		if c.Labels[docker.LabelService] == name {
+			return c.Name
+		}
+	}
+	if len(containers) > 0 {
+		return containers[0].Name
+	}
+	return name
+}
+
+// containerRole classifies a container on the pier network by its
+// pier.role label. Containers created before Pier labelled them are
+// classified by name and the registry; anything else isn't Pier's and
+// gets "".
+func containerRole(c docker.ContainerInfo) string {
+	if c.Managed {
+		return c.Owner.Role
+	}
+	switch {
+	case c.Name == "pier-traefik" || c.Name == infra.DBAdminContainer:
+		return docker.RoleSystem
+	case infra.IsInfraContainer(c.Name):
+		return docker.RoleInfra
+	case isLegacyApp(c.Name):
+		return docker.RoleApp
+	}
+	return ""
+}
+
+// isLegacyApp reports whether name is an app container Pier created under
+// the bare project or service name, before app containers were namespaced
+func isLegacyApp(name string) bool {
+	if docker.IsAppContainerName(name) {
+		return false
+	}
+	projects, _ := registry.Load()
+	for _, p := range projects {
+		if p.Type == "link" {
+			continue
+		}
+		if p.Name == name {
+			return true
+		}
+		for _, c := range p.Containers {
+			if c == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// retireLegacyApp removes the unlabelled container an earlier Pier ran a
+// project's service under, so it can be recreated in the pier-app-*
+// namespace. Containers that merely share the name are left alone.
+func retireLegacyApp(ctx context.Context, project, service string) {
+	c, err := docker.GetContainer(ctx, service)
+	if err != nil {
+		return
+	}
+	if _, managed := docker.OwnerOf(c.Labels); managed {
+		return
+	}
+	_, routed := c.Labels[fmt.Sprintf("traefik.http.routers.%s.rule", service)]
+	if !routed && !isLegacyApp(service) {
+		return
+	}
+	if err := docker.StopAndRemoveContainer(ctx, service); err != nil {
+		warn(fmt.Sprintf("Could not remove old container %s: %s", service, err))
+		return
+	}
+	info(fmt.Sprintf("Migrated %s → %s", service, docker.AppContainerName(project, service)))
+}
@@ -0,0 +1,188 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/eshe-huli/pier/internal/config"
+	"github.com/eshe-huli/pier/internal/daemon"
+)
+
+var daemonInstallNoStart bool
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Manage pierd, the background reconciler",
+	Long: `pierd watches container events and keeps Pier's state honest: routes to
+containers that are gone are removed, routes whose backend is down are
+marked stale, dead .pier/dev.pid files are cleared, and crashed linked dev
+servers are restarted. ` + "`pier ls`" + ` and the dashboard read its state instead of
+re-scanning everything.
+
+Examples:
+  pier daemon install     Run pierd at login (systemd user unit / launchd agent)
+  pier daemon status
+  pier daemon run         Run in the foreground`,
+}
+
+var daemonRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Run pierd in the foreground",
+	Args:  cobra.NoArgs,
+	RunE:  runDaemonRun,
+}
+
+var daemonInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install pierd as a login service and start it",
+	Args:  cobra.NoArgs,
+	RunE:  runDaemonInstall,
+}
+
+var daemonUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Stop pierd and remove its login service",
+	Args:  cobra.NoArgs,
+	RunE:  runDaemonUninstall,
+}
+
+var daemonStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether pierd is running and what it last saw",
+	Args:  cobra.NoArgs,
+	RunE:  runDaemonStatus,
+}
+
+func init() {
+	daemonInstallCmd.Flags().BoolVar(&daemonInstallNoStart, "no-start", false, "Write the service file without starting it")
+	daemonCmd.AddCommand(daemonRunCmd, daemonInstallCmd, daemonUninstallCmd, daemonStatusCmd)
+	rootCmd.AddCommand(daemonCmd)
+}
+
+func runDaemonRun(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	if err := config.EnsureDirectories(); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	logger := log.New(os.Stderr, "pierd ", log.LstdFlags)
+	return daemon.New(cfg, logger).Run(ctx)
+}
+
+func runDaemonInstall(cmd *cobra.Command, args []string) error {
+	unit, err := daemon.HostUnit()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+	if err := os.MkdirAll(filepath.Dir(unit.Path), 0755); err != nil {
+		return fmt.Errorf("creating %s: %w", filepath.Dir(unit.Path), err)
+	}
+	if err := os.WriteFile(unit.Path, []byte(unit.Content), 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", unit.Path, err)
+	}
+	success(fmt.Sprintf("Wrote %s", unit.Path))
+
+	if daemonInstallNoStart {
+		info("Start it with:")
+		manual(strings.Join(unit.Enable, " "))
+		fmt.Println()
+		return nil
+	}
+
+	if unit.Enable[0] == "systemctl" {
+		_ = exec.Command("systemctl", "--user", "daemon-reload").Run()
+	}
+	if out, err := exec.Command(unit.Enable[0], unit.Enable[1:]...).CombinedOutput(); err != nil {
+		warn(fmt.Sprintf("Could not start pierd: %s", strings.TrimSpace(string(out))))
+		info("Start it manually with:")
+		manual(strings.Join(unit.Enable, " "))
+		fmt.Println()
+		return nil
+	}
+	success("pierd started")
+	fmt.Printf("  %s %s\n", dim("Logs:"), dim(unit.Logs))
+	fmt.Println()
+	return nil
+}
+
+func runDaemonUninstall(cmd *cobra.Command, args []string) error {
+	unit, err := daemon.HostUnit()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+	if _, err := os.Stat(unit.Path); os.IsNotExist(err) {
+		info("pierd is not installed")
+		fmt.Println()
+		return nil
+	}
+	_ = exec.Command(unit.Disable[0], unit.Disable[1:]...).Run()
+	if err := os.Remove(unit.Path); err != nil {
+		return fmt.Errorf("removing %s: %w", unit.Path, err)
+	}
+	success(fmt.Sprintf("Removed %s", unit.Path))
+	fmt.Println()
+	return nil
+}
+
+func runDaemonStatus(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	fmt.Println()
+	snap, err := daemon.Status(ctx)
+	if err != nil {
+		fail("pierd is not running")
+		info(fmt.Sprintf("Install it with %s", cyan("pier daemon install")))
+		fmt.Println()
+		return nil
+	}
+
+	success(fmt.Sprintf("pierd is running (last reconcile %s)", snap.UpdatedAt.Format("15:04:05")))
+	printDaemonSnapshot(os.Stdout, snap)
+	fmt.Println()
+	return nil
+}
+
+func printDaemonSnapshot(w io.Writer, snap *daemon.Snapshot) {
+	counts := map[string]int{}
+	for _, r := range snap.Routes {
+		counts[r.Status]++
+	}
+	fmt.Fprintf(w, "  %s %d containers, %d routes (%d stale)\n", dim("•"),
+		len(snap.Containers), len(snap.Routes)-counts[daemon.RouteRemoved], counts[daemon.RouteStale])
+	for _, l := range snap.Links {
+		status := l.Status
+		if l.Restarts > 0 {
+			status += fmt.Sprintf(", %d restarts", l.Restarts)
+		}
+		fmt.Fprintf(w, "  %s %s %s\n", dim("•"), bold(l.Name), dim("("+status+")"))
+	}
+}
+
+// daemonSnapshot returns pierd's view when it is running, so commands can
+// skip their own scans
+func daemonSnapshot(ctx context.Context) *daemon.Snapshot {
+	snap, err := daemon.Status(ctx)
+	if err != nil {
+		return nil
+	}
+	return snap
+}
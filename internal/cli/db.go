@@ -0,0 +1,332 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/eshe-huli/pier/internal/config"
+	"github.com/eshe-huli/pier/internal/docker"
+	"github.com/eshe-huli/pier/internal/infra"
+	"github.com/eshe-huli/pier/internal/pierfile"
+	"github.com/eshe-huli/pier/internal/registry"
+)
+
+var dbService string
+var dbYes bool
+var dbUIStop bool
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Work with the current project's database",
+	Long: `Open, inspect, and manage project databases in shared infrastructure.
+
+The project is resolved from the Pierfile (or directory name), and the
+database service from the Pierfile services or dependency detection. A
+project named on the command line uses its own registered services.
+
+Examples:
+  pier db shell                       Open psql/mysql/mongosh for this project
+  pier db ls                          List databases per shared service
+  pier db url                         Print connection strings
+  pier db url shop                    Print connection strings for shop
+  pier db reset                       Drop and recreate the database
+  pier db drop --service mysql:8      Drop the database in a specific service
+  pier db ui                          Open a database admin UI at db.<tld>`,
+}
+
+var dbShellCmd = &cobra.Command{
+	Use:   "shell [project]",
+	Short: "Open a database shell for the project",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  runDBShell,
+}
+
+var dbLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List databases in every running shared service",
+	Args:  cobra.NoArgs,
+	RunE:  runDBLs,
+}
+
+var dbDropCmd = &cobra.Command{
+	Use:   "drop [project]",
+	Short: "Drop the project database",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  runDBDrop,
+}
+
+var dbResetCmd = &cobra.Command{
+	Use:   "reset [project]",
+	Short: "Drop and recreate the project database",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  runDBReset,
+}
+
+var dbURLCmd = &cobra.Command{
+	Use:   "url [project]",
+	Short: "Print connection strings for the project database",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  runDBURL,
+}
+
+var dbUICmd = &cobra.Command{
+	Use:   "ui",
+	Short: "Start a database admin UI (Adminer) at db.<tld>",
+	Long: `Starts Adminer on the pier network, preconfigured with every running
+shared postgres and mysql, and routes it at db.<tld>. Adminer can't open
+mongo; use pier db shell for it.
+
+Run it again after starting a new database service to refresh the list.`,
+	Args: cobra.NoArgs,
+	RunE: runDBUI,
+}
+
+func init() {
+	dbUICmd.Flags().BoolVar(&dbUIStop, "stop", false, "Stop the admin UI and remove its route")
+	for _, c := range []*cobra.Command{dbShellCmd, dbDropCmd, dbResetCmd, dbURLCmd} {
+		c.Flags().StringVar(&dbService, "service", "", "Database service to use (e.g. postgres:16)")
+	}
+	dbDropCmd.Flags().BoolVarP(&dbYes, "yes", "y", false, "Skip confirmation")
+	dbResetCmd.Flags().BoolVarP(&dbYes, "yes", "y", false, "Skip confirmation")
+
+	dbCmd.AddCommand(dbShellCmd, dbLsCmd, dbDropCmd, dbResetCmd, dbURLCmd, dbUICmd)
+	rootCmd.AddCommand(dbCmd)
+}
+
+// resolveDatabaseService picks the database service for the project named in
+// args, or the current one: --service wins, then the first postgres/mysql/mongo
+// in the project services.
+func resolveDatabaseService(args []string) (pierfile.ServiceDep, error) {
+	specs := []string{dbService}
+	if dbService == "" {
+		var err error
+		if specs, err = databaseProjectServices(args); err != nil {
+			return pierfile.ServiceDep{}, err
+		}
+	}
+
+	for _, spec := range specs {
+		dep := pierfile.ParseService(spec)
+		if dep.Version == "" {
+			dep.Version = defaultVersion(dep.Name)
+		}
+		if infra.SupportsDatabases(dep.Name) {
+			return dep, nil
+		}
+	}
+
+	if dbService != "" {
+		return pierfile.ServiceDep{}, fmt.Errorf("%s does not host databases (supported: postgres, mysql, mongo)", dbService)
+	}
+	return pierfile.ServiceDep{}, fmt.Errorf("no database service found for this project. Use --service or add one to the Pierfile")
+}
+
+// databaseProjectServices lists the services of the project named in args, as
+// recorded by its last up or read from its directory, or those of the current
+// directory when no project is named.
+func databaseProjectServices(args []string) ([]string, error) {
+	if len(args) == 0 {
+		dir, err := os.Getwd()
+		if err != nil {
+			return nil, fmt.Errorf("getting working directory: %w", err)
+		}
+		return resolveProjectServices(dir), nil
+	}
+
+	p, err := registry.Get(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("%w. Use --service to pick the database service", err)
+	}
+	if len(p.Services) > 0 {
+		return p.Services, nil
+	}
+	return resolveProjectServices(p.Dir), nil
+}
+
+func runDBShell(cmd *cobra.Command, args []string) error {
+	project, err := resolveProjectName(args)
+	if err != nil {
+		return err
+	}
+	svc, err := resolveDatabaseService(args)
+	if err != nil {
+		return err
+	}
+
+	dockerArgs, err := infra.ShellArgs(svc.Name, svc.Version, project)
+	if err != nil {
+		return err
+	}
+
+	c := exec.Command(docker.CLIBinary(), dockerArgs...)
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	return c.Run()
+}
+
+func runDBLs(cmd *cobra.Command, args []string) error {
+	// Map database names back to the registry projects that own them
+	owners := map[string]string{}
+	projects, _ := registry.Load()
+	for _, p := range projects {
+		owners[infra.DatabaseName(p.Name)] = p.Name
+	}
+
+	fmt.Println()
+	found := false
+	for _, svc := range infra.ListRunning() {
+		if !infra.SupportsDatabases(svc.Name) {
+			continue
+		}
+		found = true
+
+		color.New(color.Bold).Printf("  📦 %s\n", svc.Container)
+		dbs, err := infra.ListDatabases(svc.Name, svc.Version)
+		if err != nil {
+			warn(fmt.Sprintf("Could not list databases: %s", err))
+			continue
+		}
+		if len(dbs) == 0 {
+			fmt.Printf("    %s\n", dim("(no databases)"))
+			continue
+		}
+		for _, db := range dbs {
+			owner := owners[db]
+			if owner == "" {
+				owner = dim("—")
+			} else {
+				owner = cyan(owner)
+			}
+			fmt.Printf("    %-28s %s\n", db, owner)
+		}
+	}
+
+	if !found {
+		info("No shared database services are running.")
+	}
+	fmt.Println()
+	return nil
+}
+
+func runDBDrop(cmd *cobra.Command, args []string) error {
+	project, err := resolveProjectName(args)
+	if err != nil {
+		return err
+	}
+	svc, err := resolveDatabaseService(args)
+	if err != nil {
+		return err
+	}
+
+	dbName := infra.DatabaseName(project)
+	fmt.Println()
+	if !dbYes && !confirm(fmt.Sprintf("Drop database %s in %s? All data will be lost.", bold(dbName), pierfile.FormatService(svc.Name, svc.Version))) {
+		info("Aborted")
+		fmt.Println()
+		return nil
+	}
+
+	if err := infra.DropDatabase(svc.Name, svc.Version, project); err != nil {
+		return err
+	}
+	success(fmt.Sprintf("Database %s dropped", bold(dbName)))
+	fmt.Println()
+	return nil
+}
+
+func runDBReset(cmd *cobra.Command, args []string) error {
+	project, err := resolveProjectName(args)
+	if err != nil {
+		return err
+	}
+	svc, err := resolveDatabaseService(args)
+	if err != nil {
+		return err
+	}
+
+	dbName := infra.DatabaseName(project)
+	fmt.Println()
+	if !dbYes && !confirm(fmt.Sprintf("Reset database %s in %s? All data will be lost.", bold(dbName), pierfile.FormatService(svc.Name, svc.Version))) {
+		info("Aborted")
+		fmt.Println()
+		return nil
+	}
+
+	if err := infra.ResetDatabase(svc.Name, svc.Version, project); err != nil {
+		return err
+	}
+	success(fmt.Sprintf("Database %s reset", bold(dbName)))
+	fmt.Println()
+	return nil
+}
+
+func runDBURL(cmd *cobra.Command, args []string) error {
+	project, err := resolveProjectName(args)
+	if err != nil {
+		return err
+	}
+	svc, err := resolveDatabaseService(args)
+	if err != nil {
+		return err
+	}
+
+	resolved, err := infra.ResolveService(svc.Name, svc.Version)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+	fmt.Printf("  %s %s\n", dim("Network:"), infra.ConnectionURL(svc.Name, project, resolved.Container, resolved.Port))
+	if hostPort, err := infra.HostPort(svc.Name, svc.Version); err == nil {
+		fmt.Printf("  %s %s\n", dim("Host:   "), infra.ConnectionURL(svc.Name, project, "127.0.0.1", hostPort))
+	} else {
+		fmt.Printf("  %s %s\n", dim("Host:   "), yellow(err.Error()))
+	}
+	fmt.Println()
+	return nil
+}
+
+func runDBUI(cmd *cobra.Command, args []string) error {
+	fmt.Println()
+	if dbUIStop {
+		if err := infra.StopDBAdmin(); err != nil {
+			return err
+		}
+		success("Database admin UI stopped")
+		fmt.Println()
+		return nil
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	dbs, err := infra.EnsureDBAdmin()
+	if err != nil {
+		return err
+	}
+
+	domain := fmt.Sprintf("%s.%s", infra.DBAdminHost, cfg.TLD)
+	success(fmt.Sprintf("Adminer running → %s", cyan("http://"+domain)))
+	fmt.Println()
+
+	if len(dbs) == 0 {
+		info("No shared postgres or mysql is running. Start one, then run pier db ui again.")
+		fmt.Println()
+		return nil
+	}
+
+	fmt.Println("  Servers:")
+	for _, svc := range dbs {
+		user, password := infra.DBCredentials(svc.Name)
+		fmt.Printf("    📦 %-20s %s\n", svc.Spec(), dim(fmt.Sprintf("%s / %s", user, password)))
+	}
+	fmt.Println()
+	return nil
+}
@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/eshe-huli/pier/internal/registry"
+)
+
+func TestResolveDatabaseService_NamedProject(t *testing.T) {
+	setupUp(t, map[string]string{"Pierfile": "name: shop\nservices:\n  - postgres:16\n"})
+	dbService = ""
+
+	// Another project elsewhere, on mysql: naming it must not pick up
+	// the current directory's postgres
+	other := filepath.Join(t.TempDir(), "blog")
+	if err := os.MkdirAll(other, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(other, "Pierfile"), []byte("name: blog\nservices:\n  - mysql:8\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Register(registry.Project{Name: "blog", Dir: other, Type: "docker"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, "postgres:16"},
+		{[]string{"blog"}, "mysql:8"},
+	}
+	for _, tt := range tests {
+		svc, err := resolveDatabaseService(tt.args)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.args, err)
+		}
+		if got := svc.Name + ":" + svc.Version; got != tt.want {
+			t.Errorf("%v: got %s, want %s", tt.args, got, tt.want)
+		}
+	}
+
+	// Services recorded by the project's last up win over its Pierfile
+	if err := registry.RecordServices("blog", []string{"mongo:7"}); err != nil {
+		t.Fatal(err)
+	}
+	if svc, err := resolveDatabaseService([]string{"blog"}); err != nil || svc.Name != "mongo" {
+		t.Errorf("got %v, %v; want the recorded mongo", svc, err)
+	}
+
+	if _, err := resolveDatabaseService([]string{"ghost"}); err == nil {
+		t.Error("an unregistered project should be an error, not the current directory's service")
+	}
+}
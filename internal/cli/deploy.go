@@ -0,0 +1,336 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/eshe-huli/pier/internal/config"
+	"github.com/eshe-huli/pier/internal/docker"
+	"github.com/eshe-huli/pier/internal/pierfile"
+	"github.com/eshe-huli/pier/internal/proxy"
+	"github.com/eshe-huli/pier/internal/registry"
+)
+
+// routeGrace is how long the old containers keep serving after the route
+// moves, while Traefik reloads it. Overridable in tests.
+var routeGrace = time.Second
+
+// appDeploy is an app service's containers being started: one, or
+// <name>-1..n when scaled. When the service is already running, each new
+// container whose name is taken starts as <name>-next, labelled with the
+// name it takes over (docker.LabelStaging). New containers get a
+// route of their own to be probed on, unless a lone one is probed on the
+// app's. Once they're all ready the app's route moves to them, the old
+// containers are removed and the new ones take over their names. Without a
+// port the route is Traefik's docker labels, which old and new share, so
+// both serve until the old ones are removed.
+type appDeploy struct {
+	spec      docker.RunSpec     // Name is the service's container name
+	route     string             // the route, and its domain's first label
+	port      int                // 0 routes by Docker labels, leaving Traefik to guess the port
+	pf        *pierfile.Pierfile // source of the healthcheck block, if any
+	instances []appInstance
+	old       []string // running containers being replaced
+	settled   bool     // finished or abandoned: nothing left to clean up
+}
+
+// appInstance is one container of a deploy
+type appInstance struct {
+	name      string // its final name
+	container string // where it runs until it takes over the name
+}
+
+// domain is where the app is served
+func (d *appDeploy) domain(tld string) string {
+	return d.route + "." + tld
+}
+
+// staged reports whether the new containers run alongside old ones
+func (d *appDeploy) staged() bool {
+	return len(d.old) > 0
+}
+
+// ownRoutes reports whether each new container has a route of its own:
+// alongside old ones, or when the app's route spreads over several
+func (d *appDeploy) ownRoutes() bool {
+	return d.port > 0 && (d.staged() || len(d.instances) > 1)
+}
+
+// probeDomain is where inst answers before it takes over
+func (d *appDeploy) probeDomain(inst appInstance, tld string) string {
+	if d.ownRoutes() {
+		return inst.container + "." + tld
+	}
+	return d.domain(tld)
+}
+
+// dropRoutes removes the new containers' own routes
+func (d *appDeploy) dropRoutes() {
+	if !d.ownRoutes() {
+		return
+	}
+	for _, inst := range d.instances {
+		_ = proxy.RemoveFileProxy(inst.container)
+	}
+}
+
+// containers are the new containers, as they're currently named
+func (d *appDeploy) containers() []string {
+	var names []string
+	for _, inst := range d.instances {
+		names = append(names, inst.container)
+	}
+	return names
+}
+
+// hookContainer is where migrations run: the first new container
+func (d *appDeploy) hookContainer() string {
+	return d.instances[0].container
+}
+
+// startApp starts replicas containers of spec as the app served at route.
+// Running containers of the service keep serving until finishApp.
+func startApp(ctx context.Context, cfg *config.Config, spec docker.RunSpec, route string, port, replicas int, pf *pierfile.Pierfile) (*appDeploy, error) {
+	d := &appDeploy{spec: spec, route: route, port: port, pf: pf}
+	// Redeploys from a running container carry the owner in its labels
+	owner := spec.Owner
+	if owner.Role == "" {
+		owner, _ = docker.OwnerOf(spec.Labels)
+	}
+	existing, err := docker.Replicas(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	// Without a port there's no file route to move: Traefik finds the
+	// containers by their labels and serves old and new side by side
+	running := map[string]bool{}
+	for _, c := range existing {
+		if c.Running && !docker.IsStaging(c) {
+			running[c.Name] = true
+			d.old = append(d.old, c.Name)
+			continue
+		}
+		// Stopped containers, and a failed deploy's leftovers, are in the way
+		_ = docker.StopAndRemoveContainer(ctx, c.Name)
+	}
+	switch {
+	case d.staged() && port > 0:
+		info(fmt.Sprintf("%s keeps serving until the new version is ready", cyan(d.domain(cfg.TLD))))
+	case d.staged():
+		info(fmt.Sprintf("%s keeps serving alongside the new version until it is ready", cyan(d.domain(cfg.TLD))))
+	}
+
+	names := []string{spec.Name}
+	if replicas > 1 {
+		names = names[:0]
+		for n := 1; n <= replicas; n++ {
+			names = append(names, docker.ReplicaName(spec.Name, n))
+		}
+	}
+	for i, name := range names {
+		inst := appInstance{name: name, container: name}
+		if running[name] {
+			inst.container = docker.StagingName(name)
+		}
+		s := spec
+		s.Name = inst.container
+		s.Labels = instanceLabels(spec.Labels, route, cfg.TLD, port, replicas, i+1)
+		if inst.container != inst.name {
+			s.Labels[docker.LabelStaging] = inst.name
+		}
+		if err := runContainer(ctx, s); err != nil {
+			abandonApps(ctx, cfg, []*appDeploy{d})
+			return nil, err
+		}
+		d.instances = append(d.instances, inst)
+	}
+	if d.ownRoutes() {
+		for _, inst := range d.instances {
+			if err := proxy.CreateContainerProxy(inst.container, inst.container, port, cfg.TLD); err != nil {
+				abandonApps(ctx, cfg, []*appDeploy{d})
+				return nil, fmt.Errorf("routing %s: %w", inst.container, err)
+			}
+		}
+	}
+	if port > 0 && !d.staged() {
+		if err := proxy.CreateServiceProxy(route, d.containers(), port, cfg.TLD); err != nil {
+			abandonApps(ctx, cfg, []*appDeploy{d})
+			return nil, fmt.Errorf("routing %s: %w", d.domain(cfg.TLD), err)
+		}
+	}
+	return d, nil
+}
+
+// instanceLabels are the labels of replica n: the service's own, its
+// replica number when scaled, and Traefik's router labels when there's no
+// file route
+func instanceLabels(base map[string]string, route, tld string, port, replicas, n int) map[string]string {
+	labels := map[string]string{}
+	if port == 0 {
+		labels = proxy.RouterLabels(route, tld, 0)
+	}
+	for k, v := range base {
+		labels[k] = v
+	}
+	delete(labels, docker.LabelReplica)
+	delete(labels, docker.LabelStaging)
+	if replicas > 1 {
+		labels[docker.LabelReplica] = strconv.Itoa(n)
+	}
+	return labels
+}
+
+// finishApp waits for the new containers to become ready, then hands them
+// the app's route. If one never does, the old version keeps serving.
+func finishApp(ctx context.Context, cfg *config.Config, d *appDeploy) error {
+	for _, inst := range d.instances {
+		if err := waitForApp(ctx, cfg, d, inst); err != nil {
+			abandonApps(ctx, cfg, []*appDeploy{d})
+			return err
+		}
+	}
+	if !d.staged() {
+		d.dropRoutes()
+		d.settled = true
+		return nil
+	}
+
+	if d.port > 0 {
+		if err := proxy.CreateServiceProxy(d.route, d.containers(), d.port, cfg.TLD); err != nil {
+			return fmt.Errorf("moving %s to the new version: %w", d.domain(cfg.TLD), err)
+		}
+		d.dropRoutes()
+		time.Sleep(routeGrace)
+	}
+
+	for _, name := range d.old {
+		if err := docker.StopAndRemoveContainer(ctx, name); err != nil {
+			return fmt.Errorf("stopping the previous version: %w", err)
+		}
+	}
+	d.old = nil
+	for i, inst := range d.instances {
+		if inst.container == inst.name {
+			continue
+		}
+		if err := docker.RenameContainer(ctx, inst.container, inst.name); err != nil {
+			return fmt.Errorf("renaming %s: %w", inst.container, err)
+		}
+		d.instances[i].container = inst.name
+	}
+	d.settled = true
+	if d.port == 0 {
+		return nil
+	}
+	return proxy.CreateServiceProxy(d.route, d.containers(), d.port, cfg.TLD)
+}
+
+// abandonApps removes new containers that won't take over, and their
+// routes. The previous ones keep serving; with none, the app is left down.
+func abandonApps(ctx context.Context, cfg *config.Config, deploys []*appDeploy) {
+	for _, d := range deploys {
+		if d.settled {
+			continue
+		}
+		d.settled = true
+		d.dropRoutes()
+		for _, inst := range d.instances {
+			_ = docker.StopAndRemoveContainer(ctx, inst.container)
+		}
+		if d.staged() {
+			warn(fmt.Sprintf("%s still serves the previous version", d.domain(cfg.TLD)))
+			continue
+		}
+		if d.port > 0 && proxy.FileProxyExists(d.route) {
+			_ = proxy.RemoveFileProxy(d.route)
+		}
+		if len(d.instances) > 0 {
+			warn(fmt.Sprintf("%s is not running: its new containers were removed", d.domain(cfg.TLD)))
+		}
+	}
+}
+
+// runTask runs a compose service that others wait on to complete, such as
+// migrations, and waits for it to exit successfully. Its container is
+// removed either way; the last log lines of a failure are shown.
+func runTask(ctx context.Context, spec docker.RunSpec, name string) error {
+	_ = docker.StopAndRemoveContainer(ctx, spec.Name)
+	if err := runContainer(ctx, spec); err != nil {
+		return fmt.Errorf("running %s: %w", name, err)
+	}
+	defer func() { _ = docker.StopAndRemoveContainer(ctx, spec.Name) }()
+
+	info(fmt.Sprintf("Waiting for %s to complete...", cyan(name)))
+	code, err := docker.WaitExit(ctx, spec.Name, upWaitTimeout, 0)
+	if err == nil && code == 0 {
+		success(fmt.Sprintf("%s completed", name))
+		return nil
+	}
+	if err == nil {
+		err = fmt.Errorf("exited with code %d", code)
+	}
+	fail(fmt.Sprintf("%s %s", bold(name), err))
+	printLastLogs(ctx, spec.Name, failLogLines)
+	return fmt.Errorf("%s did not complete", name)
+}
+
+// runningService is an app service as it runs now, for redeploying it from
+// its containers rather than from its project
+type runningService struct {
+	spec     docker.RunSpec // from one of its containers; Name is the service's container name
+	route    string
+	port     int
+	replicas int
+}
+
+// runningServices returns the app services of project name, or the app
+// service called name
+func runningServices(ctx context.Context, cfg *config.Config, name string) ([]*runningService, error) {
+	containers, err := docker.AppContainers(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	routes, _ := proxy.ListFileProxies(cfg.TLD)
+
+	var services []*runningService
+	byName := map[string]*runningService{}
+	for _, listed := range containers {
+		if docker.IsStaging(listed) {
+			continue
+		}
+		o, _ := docker.OwnerOf(listed.Labels)
+		base := docker.AppContainerName(o.Project, o.Service)
+		svc, ok := byName[base]
+		if !ok {
+			c, err := docker.GetContainer(ctx, listed.Name)
+			if err != nil {
+				return nil, err
+			}
+			svc = &runningService{spec: c.Spec(), route: o.Service}
+			svc.spec.Name = base
+			for _, r := range routes {
+				if r.Name == svc.route {
+					svc.port = r.ContainerPort
+				}
+			}
+			byName[base] = svc
+			services = append(services, svc)
+		}
+		svc.replicas++
+	}
+	return services, nil
+}
+
+// registeredPierfile loads the Pierfile of a registered project, for its
+// healthcheck; nil if there is none
+func registeredPierfile(name string) *pierfile.Pierfile {
+	p, err := registry.Get(name)
+	if err != nil || p == nil || !pierfile.Exists(p.Dir) {
+		return nil
+	}
+	pf, _ := pierfile.Load(p.Dir)
+	return pf
+}
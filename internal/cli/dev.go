@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"fmt"
+	"path"
+	goruntime "runtime"
+	"strings"
+	"time"
+
+	"github.com/eshe-huli/pier/internal/detect"
+	"github.com/eshe-huli/pier/internal/docker"
+)
+
+// devWaitTimeout replaces the default --wait-timeout under --dev: the
+// first start installs dependencies before the dev server listens
+const devWaitTimeout = 5 * time.Minute
+
+// devApp is how `pier up --dev` runs a project: the framework's dev server
+// in a stock image, with the source bind-mounted
+type devApp struct {
+	image      string
+	cmd        []string
+	binds      []string
+	env        []string
+	workingDir string
+}
+
+// prepareDev works out the dev container for the project in dir. A zero
+// port is filled in from the framework.
+func prepareDev(dir, projectName string, port int) (*devApp, int, error) {
+	fw, err := detect.DetectFramework(dir)
+	if err != nil {
+		return nil, 0, fmt.Errorf("--dev needs a detected framework: %w", err)
+	}
+	if port == 0 {
+		port = fw.Port
+	}
+	dc, ok := detect.DevContainerFor(fw, port)
+	if !ok {
+		return nil, 0, fmt.Errorf("--dev isn't supported for %s yet; run `pier link` to use the host's dev server", fw.Name)
+	}
+
+	cmdline := dc.Command
+	if dc.Install != "" {
+		cmdline = dc.Install + " && exec " + dc.Command
+	}
+	dev := &devApp{
+		image:      dc.Image,
+		cmd:        []string{"sh", "-c", cmdline},
+		binds:      []string{dir + ":" + detect.SourceDir},
+		env:        dc.Env,
+		workingDir: detect.SourceDir,
+	}
+	// Dependencies live in volumes so the host's (built for another OS, or
+	// absent) don't shadow the container's, and survive restarts
+	for _, d := range dc.DepDirs {
+		target := d
+		if !path.IsAbs(target) {
+			target = path.Join(detect.SourceDir, d)
+		}
+		dev.binds = append(dev.binds, devVolumeName(projectName, d)+":"+target)
+	}
+	// Docker Desktop's bind mounts don't deliver inotify events
+	if goruntime.GOOS == "darwin" || goruntime.GOOS == "windows" {
+		dev.env = append(dev.env, dc.PollEnv...)
+	}
+
+	info(fmt.Sprintf("Dev server for %s: %s", cyan(fw.Name), dim(dc.Command)))
+	return dev, port, nil
+}
+
+// devVolumeName is the named volume holding one of a project's dependency
+// directories
+func devVolumeName(projectName, dir string) string {
+	slug := strings.Trim(strings.NewReplacer("/", "-", ".", "").Replace(dir), "-")
+	return "pier-dev-" + projectName + "-" + slug
+}
+
+// apply runs spec as the dev container. A nil devApp leaves the built
+// image as is.
+func (d *devApp) apply(spec *docker.RunSpec) {
+	if d == nil {
+		return
+	}
+	spec.Image = d.image
+	spec.Cmd = d.cmd
+	spec.WorkingDir = d.workingDir
+	spec.Binds = append(spec.Binds, d.binds...)
+	// Shared-infra and Pierfile env come later and win
+	spec.Env = append(append([]string{}, d.env...), spec.Env...)
+}
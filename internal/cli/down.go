@@ -11,7 +11,6 @@ import (
 
 	"github.com/eshe-huli/pier/internal/config"
 	"github.com/eshe-huli/pier/internal/docker"
-	"github.com/eshe-huli/pier/internal/infra"
 	"github.com/eshe-huli/pier/internal/pierfile"
 	"github.com/eshe-huli/pier/internal/proxy"
 )
@@ -66,20 +65,40 @@ func runDown(cmd *cobra.Command, args []string) error {
 
 	step(1, fmt.Sprintf("Stopping %s...", cyan(name)))
 
-	// Stop the container
-	if docker.IsContainerRunning(ctx, name) {
-		if err := docker.StopAndRemoveContainer(ctx, name); err != nil {
+	// Every service container of the project, plus one left under the bare
+	// name by a Pier that predates namespacing
+	services := map[string]string{} // container → routed service
+	apps, _ := docker.AppContainers(ctx, name)
+	for _, c := range apps {
+		services[c.Name] = c.Labels[docker.LabelService]
+	}
+	if isLegacyApp(name) {
+		services[name] = name
+	}
+
+	stopped := false
+	for container, service := range services {
+		if !docker.IsContainerRunning(ctx, container) {
+			continue
+		}
+		if err := docker.StopAndRemoveContainer(ctx, container); err != nil {
 			fail(fmt.Sprintf("Failed to stop container: %s", err))
 			return err
 		}
-		success(fmt.Sprintf("Container %s stopped", bold(name)))
-	} else {
+		success(fmt.Sprintf("Container %s stopped", bold(service)))
+		stopped = true
+	}
+	if !stopped {
 		info(fmt.Sprintf("Container %s is not running", name))
 	}
 
-	// Remove Traefik route
-	if proxy.FileProxyExists(name) {
-		if err := proxy.RemoveFileProxy(name); err != nil {
+	// Remove Traefik routes
+	services[name] = name
+	for _, service := range services {
+		if !proxy.FileProxyExists(service) {
+			continue
+		}
+		if err := proxy.RemoveFileProxy(service); err != nil {
 			warn(fmt.Sprintf("Could not remove route: %s", err))
 		} else {
 			success("Route removed")
@@ -102,31 +121,36 @@ func runDownAll(ctx context.Context) error {
 		return fmt.Errorf("listing containers: %w", err)
 	}
 
-	// Stop project containers (non-infra, non-traefik)
+	// Stop project containers; containers Pier didn't create are left alone
 	step(1, "Stopping project containers...")
 	for _, c := range containers {
-		if c.Name == "pier-traefik" || infra.IsInfraContainer(c.Name) {
+		if containerRole(c) != docker.RoleApp {
 			continue
 		}
 		if c.State != "running" {
 			continue
 		}
-		fmt.Printf("    → %s ", cyan(c.Name))
+		route := c.Name
+		if c.Managed {
+			route = c.Owner.Service
+		}
+		fmt.Printf("    → %s ", cyan(route))
 		if err := docker.StopAndRemoveContainer(ctx, c.Name); err != nil {
 			fmt.Println(red("✗"))
 		} else {
 			fmt.Println(green("✓"))
 		}
 		// Remove route
-		if proxy.FileProxyExists(c.Name) {
-			_ = proxy.RemoveFileProxy(c.Name)
+		if proxy.FileProxyExists(route) {
+			_ = proxy.RemoveFileProxy(route)
 		}
 	}
 
-	// Stop infra containers
+	// Stop infra containers and Pier's own helpers (Traefik comes last)
 	step(2, "Stopping shared infrastructure...")
 	for _, c := range containers {
-		if !infra.IsInfraContainer(c.Name) {
+		role := containerRole(c)
+		if role != docker.RoleInfra && (role != docker.RoleSystem || c.Name == "pier-traefik") {
 			continue
 		}
 		if c.State != "running" {
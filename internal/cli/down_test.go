@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"context"
+	"testing"
+
+	"github.com/eshe-huli/pier/internal/docker"
+)
+
+func TestRunDownAll_StopsOnlyPierContainers(t *testing.T) {
+	fake, _ := setupUp(t, nil)
+	ctx := context.Background()
+	fake.Images["img"] = true
+	for _, spec := range []docker.RunSpec{
+		{Name: "pier-app-shop", Owner: appOwner("shop", "shop")},
+		{Name: "pier-redis-7", Owner: docker.Owner{Role: docker.RoleInfra, Service: "redis", Version: "7"}},
+		{Name: "pier-adminer", Owner: docker.Owner{Role: docker.RoleSystem, Service: "adminer"}},
+		{Name: "my-tool"}, // joined to the network by hand
+	} {
+		spec.Image, spec.Network = "img", "pier"
+		if _, err := docker.Run(ctx, spec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := runDownAll(ctx); err != nil {
+		t.Fatalf("runDownAll: %v", err)
+	}
+	for _, name := range []string{"pier-app-shop", "pier-redis-7", "pier-adminer"} {
+		if _, ok := fake.Containers[name]; ok {
+			t.Errorf("%s was not stopped", name)
+		}
+	}
+	if c, ok := fake.Containers["my-tool"]; !ok || !c.Running {
+		t.Error("a container Pier didn't create was stopped")
+	}
+}
@@ -33,7 +33,7 @@ func runEnv(cmd *cobra.Command, args []string) error {
 	}
 
 	ctx := context.Background()
-	info, err := docker.GetContainer(ctx, name)
+	info, err := docker.GetContainer(ctx, appContainer(ctx, name))
 	if err != nil {
 		return fmt.Errorf("container %s not found: %w", name, err)
 	}
@@ -42,7 +42,7 @@ func runEnv(cmd *cobra.Command, args []string) error {
 	fmt.Printf("  %s %s\n", bold("Environment:"), cyan(name))
 	fmt.Printf("  %s\n", dim(strings.Repeat("─", 50)))
 
-	for _, env := range info.Config.Env {
+	for _, env := range info.Env {
 		parts := strings.SplitN(env, "=", 2)
 		if len(parts) == 2 {
 			fmt.Printf("  %s=%s\n", green(parts[0]), parts[1])
@@ -1,11 +1,19 @@
 package cli
 
 import (
+	"bufio"
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
 
+	"github.com/eshe-huli/pier/internal/detect"
+	"github.com/eshe-huli/pier/internal/docker"
 	"github.com/eshe-huli/pier/internal/pierfile"
+	"github.com/eshe-huli/pier/internal/registry"
 )
 
 // resolveProjectName returns the project name from args, Pierfile, or directory name.
@@ -29,3 +37,68 @@ func resolveProjectName(args []string) (string, error) {
 
 	return name, nil
 }
+
+// resolveProjectServices returns the services of the project in dir as
+// name:version specs, from the Pierfile or dependency detection.
+func resolveProjectServices(dir string) []string {
+	if pierfile.Exists(dir) {
+		if pf, err := pierfile.Load(dir); err == nil && len(pf.Services) > 0 {
+			return pf.ServiceNames()
+		}
+	}
+
+	var services []string
+	detected, _ := detect.DetectServices(dir)
+	for _, d := range detected {
+		version := d.Version
+		if version == "" {
+			version = defaultVersion(d.Name)
+		}
+		services = append(services, d.Name+":"+version)
+	}
+	return services
+}
+
+// confirm asks a yes/no question on stdin. Defaults to no.
+func confirm(prompt string) bool {
+	fmt.Printf("  %s %s [y/N] ", yellow("?"), prompt)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// isProjectRunning reports whether a registered project is up: its container
+// for docker/run projects, or its dev server process for linked projects.
+func isProjectRunning(ctx context.Context, p registry.Project) bool {
+	if p.Type == "link" {
+		data, err := os.ReadFile(filepath.Join(p.Dir, ".pier", "dev.pid"))
+		if err != nil {
+			return false
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err != nil || pid <= 0 {
+			return false
+		}
+		return syscall.Kill(pid, 0) == nil
+	}
+	apps, _ := docker.AppContainers(ctx, p.Name)
+	for _, c := range apps {
+		if c.Running {
+			return true
+		}
+	}
+	// Containers from before app containers were namespaced
+	containers := p.Containers
+	if len(containers) == 0 {
+		containers = []string{p.Name}
+	}
+	for _, c := range containers {
+		if docker.IsContainerRunning(ctx, c) {
+			return true
+		}
+	}
+	return false
+}
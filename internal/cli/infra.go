@@ -0,0 +1,568 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/eshe-huli/pier/internal/config"
+	"github.com/eshe-huli/pier/internal/docker"
+	"github.com/eshe-huli/pier/internal/infra"
+	"github.com/eshe-huli/pier/internal/pierfile"
+	"github.com/eshe-huli/pier/internal/registry"
+)
+
+var infraUpgradeDryRun bool
+var infraUpgradeStopOld bool
+var infraUpgradeYes bool
+
+var infraGCPurge bool
+var infraGCDryRun bool
+var infraGCYes bool
+
+var infraRmPurge bool
+var infraRmYes bool
+var infraLogsFollow bool
+var infraLogsTail string
+
+var infraCmd = &cobra.Command{
+	Use:   "infra",
+	Short: "Manage shared infrastructure services",
+	Long: `Manage the shared infrastructure containers (postgres, redis, ...) that
+Pier starts for your projects.
+
+Services are addressed as name:version (version defaults like pier up).
+
+Examples:
+  pier infra ls
+  pier infra start postgres:16
+  pier infra logs redis:7 -f
+  pier infra rm mongo:6 --purge
+  pier infra gc --dry-run
+  pier infra upgrade postgres 15 16 --dry-run`,
+}
+
+var infraLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List shared services with image, data size, uptime and consumers",
+	Args:  cobra.NoArgs,
+	RunE:  runInfraLs,
+}
+
+var infraStartCmd = &cobra.Command{
+	Use:   "start <name:version>...",
+	Short: "Start shared services",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runInfraStart,
+}
+
+var infraStopCmd = &cobra.Command{
+	Use:   "stop <name:version>...",
+	Short: "Stop shared services (data is kept)",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runInfraStop,
+}
+
+var infraRestartCmd = &cobra.Command{
+	Use:   "restart <name:version>...",
+	Short: "Restart shared services",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runInfraRestart,
+}
+
+var infraLogsCmd = &cobra.Command{
+	Use:   "logs <name:version>",
+	Short: "View logs of a shared service",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runInfraLogs,
+}
+
+var infraRmCmd = &cobra.Command{
+	Use:   "rm <name:version>...",
+	Short: "Remove shared services, optionally deleting their data",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runInfraRm,
+}
+
+var infraGCCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Stop shared services no running project uses",
+	Long: `Stops shared infrastructure containers that no registered, running
+project depends on. Usage is recorded by pier up and pier run.
+
+With --purge, the service data under ~/.pier/data is deleted as well.
+
+Examples:
+  pier infra gc --dry-run
+  pier infra gc
+  pier infra gc --purge`,
+	Args: cobra.NoArgs,
+	RunE: runInfraGC,
+}
+
+var infraUpgradeCmd = &cobra.Command{
+	Use:   "upgrade <service> <from> <to>",
+	Short: "Move a shared database service to a new major version",
+	Long: `Dumps every database from the old container, starts the new version,
+restores the data, and rewrites Pierfile references.
+
+The old data directory is left untouched, and dumps are kept in ~/.pier/backups.
+
+Examples:
+  pier infra upgrade postgres 15 16 --dry-run
+  pier infra upgrade postgres 15 16 --stop-old
+  pier infra upgrade mysql 5.7 8`,
+	Args: cobra.ExactArgs(3),
+	RunE: runInfraUpgrade,
+}
+
+func init() {
+	infraUpgradeCmd.Flags().BoolVar(&infraUpgradeDryRun, "dry-run", false, "Show what would happen without changing anything")
+	infraUpgradeCmd.Flags().BoolVar(&infraUpgradeStopOld, "stop-old", false, "Stop the old instance after a successful upgrade")
+	infraUpgradeCmd.Flags().BoolVarP(&infraUpgradeYes, "yes", "y", false, "Skip confirmation prompts")
+
+	infraGCCmd.Flags().BoolVar(&infraGCPurge, "purge", false, "Also delete the data of stopped services")
+	infraGCCmd.Flags().BoolVar(&infraGCDryRun, "dry-run", false, "Show what would be stopped")
+	infraGCCmd.Flags().BoolVarP(&infraGCYes, "yes", "y", false, "Skip confirmation")
+
+	infraLogsCmd.Flags().BoolVarP(&infraLogsFollow, "follow", "f", false, "Follow log output")
+	infraLogsCmd.Flags().StringVar(&infraLogsTail, "tail", "100", "Number of lines to show from the end")
+	infraRmCmd.Flags().BoolVar(&infraRmPurge, "purge", false, "Delete the data dir under ~/.pier/data")
+	infraRmCmd.Flags().BoolVarP(&infraRmYes, "yes", "y", false, "Skip confirmation")
+
+	infraCmd.AddCommand(infraLsCmd, infraStartCmd, infraStopCmd, infraRestartCmd, infraLogsCmd, infraRmCmd, infraGCCmd, infraUpgradeCmd)
+	rootCmd.AddCommand(infraCmd)
+}
+
+// parseInfraSpec resolves a name[:version] argument to a shared service
+func parseInfraSpec(spec string) (*infra.SharedService, error) {
+	dep := pierfile.ParseService(spec)
+	if dep.Version == "" {
+		dep.Version = defaultVersion(dep.Name)
+	}
+	return infra.ResolveService(dep.Name, dep.Version)
+}
+
+func runInfraLs(cmd *cobra.Command, args []string) error {
+	instances := infra.ListInstances()
+
+	fmt.Println()
+	if len(instances) == 0 {
+		info("No shared services. They start automatically with `pier up`.")
+		fmt.Println()
+		return nil
+	}
+
+	header := color.New(color.Bold)
+	header.Printf("  %-22s %-24s %-16s %-9s %-18s %s\n", "SERVICE", "IMAGE", "STATUS", "DATA", "UPTIME", "USED BY")
+	fmt.Printf("  %s\n", dim(strings.Repeat("─", 105)))
+
+	for _, inst := range instances {
+		var consumers []string
+		for _, p := range registry.Consumers(inst.Spec()) {
+			consumers = append(consumers, p.Name)
+		}
+		usedBy := dim("—")
+		if len(consumers) > 0 {
+			usedBy = strings.Join(consumers, ", ")
+		}
+
+		status := formatContainerStatus(inst.State)
+		if inst.State == "absent" {
+			status = dim("⏹ data only")
+		}
+
+		fmt.Printf("  %-22s %-24s %-16s %-9s %-18s %s\n",
+			bold(inst.Container),
+			dim(inst.Image),
+			status,
+			humanBytes(infra.DataDirSize(inst.SharedService)),
+			dim(inst.Uptime),
+			usedBy,
+		)
+	}
+
+	fmt.Println()
+	return nil
+}
+
+func runInfraStart(cmd *cobra.Command, args []string) error {
+	fmt.Println()
+	for _, spec := range args {
+		svc, err := parseInfraSpec(spec)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("    → %s ", cyan(svc.Container))
+		if err := infra.EnsureService(svc.Name, svc.Version); err != nil {
+			fmt.Println(red("✗"))
+			return fmt.Errorf("starting %s: %w", svc.Container, err)
+		}
+		fmt.Println(green("✓"))
+	}
+	fmt.Println()
+	return nil
+}
+
+func runInfraStop(cmd *cobra.Command, args []string) error {
+	fmt.Println()
+	for _, spec := range args {
+		svc, err := parseInfraSpec(spec)
+		if err != nil {
+			return err
+		}
+		if users := registry.Consumers(svc.Spec()); len(users) > 0 {
+			warn(fmt.Sprintf("%s is used by %d project(s)", svc.Container, len(users)))
+		}
+		fmt.Printf("    → %s ", cyan(svc.Container))
+		if err := infra.StopService(svc.Name, svc.Version); err != nil {
+			fmt.Println(red("✗"))
+			return fmt.Errorf("stopping %s: %w", svc.Container, err)
+		}
+		fmt.Println(green("✓"))
+	}
+	fmt.Println()
+	return nil
+}
+
+func runInfraRestart(cmd *cobra.Command, args []string) error {
+	fmt.Println()
+	for _, spec := range args {
+		svc, err := parseInfraSpec(spec)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("    → %s ", cyan(svc.Container))
+		if err := infra.StopService(svc.Name, svc.Version); err != nil {
+			fmt.Println(red("✗"))
+			return fmt.Errorf("stopping %s: %w", svc.Container, err)
+		}
+		if err := infra.EnsureService(svc.Name, svc.Version); err != nil {
+			fmt.Println(red("✗"))
+			return fmt.Errorf("starting %s: %w", svc.Container, err)
+		}
+		fmt.Println(green("✓"))
+	}
+	fmt.Println()
+	return nil
+}
+
+func runInfraLogs(cmd *cobra.Command, args []string) error {
+	svc, err := parseInfraSpec(args[0])
+	if err != nil {
+		return err
+	}
+
+	opts := docker.LogOptions{Tail: infraLogsTail, Follow: infraLogsFollow}
+	return docker.Logs(cmd.Context(), svc.Container, opts, os.Stdout, os.Stderr)
+}
+
+func runInfraRm(cmd *cobra.Command, args []string) error {
+	var services []*infra.SharedService
+	for _, spec := range args {
+		svc, err := parseInfraSpec(spec)
+		if err != nil {
+			return err
+		}
+		services = append(services, svc)
+	}
+
+	fmt.Println()
+	if infraRmPurge && !infraRmYes {
+		for _, svc := range services {
+			fmt.Printf("    • %s %s\n", svc.DataDir, dim("("+humanBytes(infra.DataDirSize(*svc))+")"))
+		}
+		if !confirm("Delete these data directories? This cannot be undone.") {
+			info("Aborted")
+			fmt.Println()
+			return nil
+		}
+	}
+
+	for _, svc := range services {
+		fmt.Printf("    → %s ", cyan(svc.Container))
+		if err := infra.StopService(svc.Name, svc.Version); err != nil {
+			fmt.Println(red("✗"))
+			return fmt.Errorf("removing %s: %w", svc.Container, err)
+		}
+		if infraRmPurge {
+			if err := infra.PurgeData(svc.Name, svc.Version); err != nil {
+				fmt.Println(red("✗"))
+				return fmt.Errorf("deleting %s: %w", svc.DataDir, err)
+			}
+		}
+		fmt.Println(green("✓"))
+	}
+	fmt.Println()
+	return nil
+}
+
+// humanBytes formats a byte count as B/KB/MB/GB
+func humanBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func runInfraUpgrade(cmd *cobra.Command, args []string) error {
+	name, from, to := args[0], args[1], args[2]
+	if from == to {
+		return fmt.Errorf("source and target versions are both %s", from)
+	}
+	if !infra.SupportsDatabases(name) {
+		return fmt.Errorf("upgrade is only supported for postgres, mysql and mongo")
+	}
+
+	oldSvc, err := infra.ResolveService(name, from)
+	if err != nil {
+		return err
+	}
+	newSvc, err := infra.ResolveService(name, to)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+
+	// The old instance must be running to dump it
+	if _, err := os.Stat(oldSvc.DataDir); os.IsNotExist(err) {
+		return fmt.Errorf("no data for %s at %s", oldSvc.Container, oldSvc.DataDir)
+	}
+	step(1, fmt.Sprintf("Inspecting %s...", cyan(oldSvc.Container)))
+	oldRunning := docker.IsContainerRunning(context.Background(), oldSvc.Container)
+	var dbs []string
+	if oldRunning || !infraUpgradeDryRun {
+		if err := infra.EnsureService(name, from); err != nil {
+			return fmt.Errorf("starting %s: %w", oldSvc.Container, err)
+		}
+		if err := infra.WaitReady(name, from, 60*time.Second); err != nil {
+			return err
+		}
+		if dbs, err = infra.ListDatabases(name, from); err != nil {
+			return err
+		}
+	}
+
+	pierfiles := upgradeAffectedPierfiles(name, from)
+	backupDir := filepath.Join(config.BackupsDir(), fmt.Sprintf("%s-%s-%s", name, from, time.Now().Format("20060102-150405")))
+
+	// Dry-run summary
+	fmt.Println()
+	fmt.Printf("  %s %s → %s\n", bold("Upgrade:"), cyan(oldSvc.Image), cyan(newSvc.Image))
+	if oldRunning || !infraUpgradeDryRun {
+		fmt.Printf("  %s %d\n", bold("Databases:"), len(dbs))
+	} else {
+		fmt.Printf("  %s %s\n", bold("Databases:"), dim(fmt.Sprintf("unknown (%s is stopped; it will be started)", oldSvc.Container)))
+	}
+	for _, db := range dbs {
+		fmt.Printf("    • %s\n", db)
+	}
+	fmt.Printf("  %s %s\n", bold("Backups:"), dim(backupDir))
+	fmt.Printf("  %s %d\n", bold("Pierfiles to update:"), len(pierfiles))
+	for _, dir := range pierfiles {
+		fmt.Printf("    • %s\n", dim(filepath.Join(dir, pierfile.FileName)))
+	}
+	if infraUpgradeStopOld {
+		fmt.Printf("  %s %s will be stopped (data kept in %s)\n", bold("Old instance:"), oldSvc.Container, dim(oldSvc.DataDir))
+	}
+	fmt.Println()
+
+	if infraUpgradeDryRun {
+		info("Dry run — nothing changed.")
+		fmt.Println()
+		return nil
+	}
+
+	if !infraUpgradeYes && !confirm(fmt.Sprintf("Upgrade %s from %s to %s?", name, from, to)) {
+		info("Aborted")
+		fmt.Println()
+		return nil
+	}
+
+	// Dump
+	step(2, "Dumping databases...")
+	if err := os.MkdirAll(backupDir, 0755); err != nil {
+		return fmt.Errorf("creating backup dir: %w", err)
+	}
+	dumps := map[string]string{}
+	for _, db := range dbs {
+		path := filepath.Join(backupDir, db+"."+infra.DumpExtension(name))
+		fmt.Printf("    → %s ", db)
+		if err := dumpToFile(name, from, db, path); err != nil {
+			fmt.Println(red("✗"))
+			return err
+		}
+		fmt.Println(green("✓"))
+		dumps[db] = path
+	}
+
+	// Start the new version
+	step(3, fmt.Sprintf("Starting %s...", cyan(newSvc.Container)))
+	if err := infra.EnsureService(name, to); err != nil {
+		return fmt.Errorf("starting %s: %w", newSvc.Container, err)
+	}
+	if err := infra.WaitReady(name, to, 60*time.Second); err != nil {
+		return err
+	}
+
+	// Restore
+	step(4, "Restoring databases...")
+	for _, db := range dbs {
+		fmt.Printf("    → %s ", db)
+		if err := restoreFromFile(name, to, db, dumps[db]); err != nil {
+			fmt.Println(red("✗"))
+			return fmt.Errorf("%w\n  Dumps are kept in %s", err, backupDir)
+		}
+		fmt.Println(green("✓"))
+	}
+
+	// Rewrite Pierfiles
+	if len(pierfiles) > 0 {
+		step(5, "Updating Pierfiles...")
+		if infraUpgradeYes || confirm(fmt.Sprintf("Rewrite %d Pierfile(s) from %s:%s to %s:%s?", len(pierfiles), name, from, name, to)) {
+			for _, dir := range pierfiles {
+				if _, err := pierfile.ReplaceServiceVersion(dir, name, from, to); err != nil {
+					warn(fmt.Sprintf("%s: %s", dir, err))
+					continue
+				}
+				success(filepath.Join(dir, pierfile.FileName))
+			}
+		} else {
+			info("Pierfiles left unchanged")
+		}
+	}
+
+	if infraUpgradeStopOld {
+		if err := infra.StopService(name, from); err != nil {
+			warn(fmt.Sprintf("Could not stop %s: %s", oldSvc.Container, err))
+		} else {
+			success(fmt.Sprintf("%s stopped", oldSvc.Container))
+		}
+	}
+
+	fmt.Println()
+	success(fmt.Sprintf("%s upgraded %s → %s", bold(name), from, to))
+	info("Re-run `pier up` in affected projects to pick up the new service.")
+	fmt.Println()
+	return nil
+}
+
+// upgradeAffectedPierfiles returns registered project dirs whose Pierfile uses name:version
+func upgradeAffectedPierfiles(name, version string) []string {
+	var dirs []string
+	seen := map[string]bool{}
+	projects, _ := registry.Load()
+	for _, p := range projects {
+		if seen[p.Dir] || !pierfile.Exists(p.Dir) {
+			continue
+		}
+		pf, err := pierfile.Load(p.Dir)
+		if err != nil {
+			continue
+		}
+		for _, s := range pf.Services {
+			if s.Name == name && s.Version == version {
+				dirs = append(dirs, p.Dir)
+				seen[p.Dir] = true
+				break
+			}
+		}
+	}
+	return dirs
+}
+
+func dumpToFile(name, version, db, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating dump file: %w", err)
+	}
+	defer f.Close()
+	return infra.DumpDatabase(name, version, db, f)
+}
+
+func restoreFromFile(name, version, db, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening dump file: %w", err)
+	}
+	defer f.Close()
+	return infra.RestoreDatabase(name, version, db, f)
+}
+
+func runInfraGC(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	fmt.Println()
+
+	var unused []infra.SharedService
+	for _, svc := range infra.ListRunning() {
+		inUse := false
+		for _, p := range registry.Consumers(svc.Spec()) {
+			if isProjectRunning(ctx, p) {
+				inUse = true
+				break
+			}
+		}
+		if !inUse {
+			unused = append(unused, svc)
+		}
+	}
+
+	if len(unused) == 0 {
+		info("Every running shared service is in use. Nothing to collect.")
+		fmt.Println()
+		return nil
+	}
+
+	fmt.Println("  Unused shared services:")
+	for _, svc := range unused {
+		fmt.Printf("    • %s\n", cyan(svc.Container))
+	}
+	fmt.Println()
+
+	if infraGCDryRun {
+		info("Dry run — nothing stopped.")
+		fmt.Println()
+		return nil
+	}
+
+	prompt := fmt.Sprintf("Stop %d service(s)?", len(unused))
+	if infraGCPurge {
+		prompt = fmt.Sprintf("Stop %d service(s) and DELETE their data?", len(unused))
+	}
+	if !infraGCYes && !confirm(prompt) {
+		info("Aborted")
+		fmt.Println()
+		return nil
+	}
+
+	for _, svc := range unused {
+		fmt.Printf("    → %s ", cyan(svc.Container))
+		if err := infra.StopService(svc.Name, svc.Version); err != nil {
+			fmt.Println(red("✗"))
+			continue
+		}
+		if infraGCPurge {
+			if err := infra.PurgeData(svc.Name, svc.Version); err != nil {
+				fmt.Println(yellow("stopped, data kept: " + err.Error()))
+				continue
+			}
+		}
+		fmt.Println(green("✓"))
+	}
+
+	fmt.Println()
+	return nil
+}
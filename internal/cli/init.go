@@ -11,6 +11,7 @@ import (
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 
+	"github.com/eshe-huli/pier/internal/buildspec"
 	"github.com/eshe-huli/pier/internal/config"
 	"github.com/eshe-huli/pier/internal/detect"
 	"github.com/eshe-huli/pier/internal/gitignore"
@@ -146,7 +147,7 @@ func runProjectInitLogic(dir string) error {
 	projectName := filepath.Base(dir)
 	pf := &pierfile.Pierfile{
 		Name:  projectName,
-		Build: true,
+		Build: &buildspec.Spec{},
 	}
 	if fw != nil {
 		pf.Port = fw.Port
@@ -14,9 +14,11 @@ import (
 
 	"github.com/eshe-huli/pier/internal/config"
 	"github.com/eshe-huli/pier/internal/detect"
+	"github.com/eshe-huli/pier/internal/infra"
 	"github.com/eshe-huli/pier/internal/pierfile"
 	"github.com/eshe-huli/pier/internal/proxy"
 	"github.com/eshe-huli/pier/internal/registry"
+	"github.com/eshe-huli/pier/internal/runtime"
 )
 
 var linkPort int
@@ -97,7 +99,7 @@ func runLink(cmd *cobra.Command, args []string) error {
 			if port == 0 {
 				port = fw.Port
 			}
-			devCmd = detectDevCommand(fw, port)
+			devCmd = detect.DevCommand(fw, port)
 		}
 	}
 
@@ -105,6 +107,36 @@ func runLink(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("could not determine port. Use --port or set port in Pierfile")
 	}
 
+	// Pierfile env for the dev server; services are referenced, not started
+	var shared []infra.SharedService
+	if pf != nil {
+		for _, s := range pf.Services {
+			version := s.Version
+			if version == "" {
+				version = defaultVersion(s.Name)
+			}
+			if svc, err := infra.ResolveService(s.Name, version); err == nil {
+				shared = append(shared, *svc)
+			}
+		}
+	}
+	// Services are addressed through their loopback ports, in connection
+	// env and Pierfile templates alike, since the dev server runs on the host
+	connected := infra.WithTelemetry(shared)
+	hostPorts := map[string]int{}
+	for _, svc := range connected {
+		if hostPort, err := infra.HostPort(svc.Name, svc.Version); err == nil {
+			hostPorts[svc.Container] = hostPort
+		}
+	}
+	extraEnv, err := pierfileEnv(pf, name, cfg.TLD, shared, hostPorts)
+	if err != nil {
+		return err
+	}
+	opts := envOptions(dir, name, pf)
+	opts.HostPorts = hostPorts
+	envOverrides := runtime.BuildEnvOverrides(connected, opts)
+
 	fmt.Println()
 	step(1, fmt.Sprintf("Project: %s", cyan(name)))
 
@@ -149,6 +181,10 @@ func runLink(cmd *cobra.Command, args []string) error {
 	parts := strings.Fields(devCmd)
 	c := exec.Command(parts[0], parts[1:]...)
 	c.Dir = dir
+	c.Env = append(os.Environ(), envOverrides...)
+	for k, v := range extraEnv {
+		c.Env = append(c.Env, fmt.Sprintf("%s=%s", k, v))
+	}
 	c.Stdout = log
 	c.Stderr = log
 	c.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
@@ -177,37 +213,6 @@ func runLink(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func detectDevCommand(fw *detect.Framework, port int) string {
-	switch fw.Name {
-	case "nextjs":
-		return fmt.Sprintf("npx next dev -p %d", port)
-	case "nuxt":
-		return fmt.Sprintf("npx nuxi dev --port %d", port)
-	case "nestjs":
-		return "npm run start:dev"
-	case "express", "fastify":
-		return "npm run dev"
-	case "django":
-		return fmt.Sprintf("python manage.py runserver 0.0.0.0:%d", port)
-	case "fastapi":
-		return fmt.Sprintf("uvicorn main:app --reload --port %d", port)
-	case "flask":
-		return fmt.Sprintf("flask run --port %d", port)
-	case "go":
-		return "go run ."
-	case "rails":
-		return fmt.Sprintf("rails server -p %d", port)
-	case "phoenix":
-		return "mix phx.server"
-	case "laravel":
-		return fmt.Sprintf("php artisan serve --port=%d", port)
-	case "spring-boot":
-		return "./mvnw spring-boot:run"
-	default:
-		return ""
-	}
-}
-
 func saveLinkMeta(name, dir string, port int, command string, framework string) {
 	// Save to legacy links dir
 	linksDir := config.LinksDir()
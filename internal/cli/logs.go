@@ -2,9 +2,10 @@ package cli
 
 import (
 	"os"
-	"os/exec"
 
 	"github.com/spf13/cobra"
+
+	"github.com/eshe-huli/pier/internal/docker"
 )
 
 var logsFollow bool
@@ -35,14 +36,6 @@ func runLogs(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	dockerArgs := []string{"logs", "--tail", logsTail}
-	if logsFollow {
-		dockerArgs = append(dockerArgs, "-f")
-	}
-	dockerArgs = append(dockerArgs, name)
-
-	c := exec.Command("docker", dockerArgs...)
-	c.Stdout = os.Stdout
-	c.Stderr = os.Stderr
-	return c.Run()
+	opts := docker.LogOptions{Tail: logsTail, Follow: logsFollow}
+	return docker.Logs(cmd.Context(), appContainer(cmd.Context(), name), opts, os.Stdout, os.Stderr)
 }
@@ -9,11 +9,15 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/eshe-huli/pier/internal/config"
+	"github.com/eshe-huli/pier/internal/daemon"
 	"github.com/eshe-huli/pier/internal/docker"
 	"github.com/eshe-huli/pier/internal/infra"
 	"github.com/eshe-huli/pier/internal/proxy"
+	"github.com/eshe-huli/pier/internal/registry"
 )
 
+var lsStats bool
+
 var lsCmd = &cobra.Command{
 	Use:   "ls",
 	Short: "List all active services",
@@ -22,15 +26,20 @@ var lsCmd = &cobra.Command{
 }
 
 func init() {
+	lsCmd.Flags().BoolVar(&lsStats, "stats", false, "Show CPU, memory, network I/O and restarts of containers")
 	rootCmd.AddCommand(lsCmd)
 }
 
 type serviceEntry struct {
-	Name   string
-	Domain string
-	Type   string
-	Status string
-	Uptime string
+	Name      string
+	Domain    string
+	Type      string
+	Status    string
+	Uptime    string
+	Consumers []string
+	Container string   // backing container, for --stats
+	Replicas  []string // the containers of a scaled app, in place of Container
+	Running   int      // how many replicas are running
 }
 
 func runLs(cmd *cobra.Command, args []string) error {
@@ -42,58 +51,128 @@ func runLs(cmd *cobra.Command, args []string) error {
 	ctx := context.Background()
 	var entries []serviceEntry
 
+	// pierd already tracks containers and route health; scan only without it
+	snap := daemonSnapshot(ctx)
+
 	// Get Docker containers on pier network
-	containers, err := docker.ListContainers(ctx, cfg.Network, cfg.TLD)
+	var containers []docker.ContainerInfo
+	appContainers := map[string]bool{}
+	if snap != nil {
+		containers = snap.Containers
+	} else {
+		containers, err = docker.ListContainers(ctx, cfg.Network, cfg.TLD)
+	}
 	if err != nil {
 		warn(fmt.Sprintf("Could not list containers: %s", err))
 	} else {
+		replicaRows := map[string]int{} // project/service → entry of a scaled app
 		for _, c := range containers {
-			// Skip pier-traefik and infra containers (shown separately)
-			if c.Name == "pier-traefik" {
+			// Pier's own and infra containers are shown separately
+			name, kind := c.Name, "app"
+			switch containerRole(c) {
+			case docker.RoleInfra, docker.RoleSystem:
 				continue
+			case docker.RoleApp:
+				if c.Managed {
+					name = c.Owner.Service
+				}
+				appContainers[c.Name] = true
+			default:
+				// On the pier network but not started by Pier
+				kind = "container"
 			}
-			if infra.IsInfraContainer(c.Name) {
+
+			// A scaled app's replicas share one row
+			if c.Managed && c.Owner.Replica > 0 {
+				key := c.Owner.Project + "/" + c.Owner.Service
+				i, ok := replicaRows[key]
+				if !ok {
+					i = len(entries)
+					replicaRows[key] = i
+					entries = append(entries, serviceEntry{Name: name, Domain: c.Domain, Type: kind, Uptime: c.Status})
+				}
+				e := &entries[i]
+				e.Replicas = append(e.Replicas, c.Name)
+				if c.State == "running" {
+					e.Running++
+				}
+				e.Status = formatReplicaStatus(e.Running, len(e.Replicas))
 				continue
 			}
 
 			status := formatContainerStatus(c.State)
 			entries = append(entries, serviceEntry{
-				Name:   c.Name,
-				Domain: c.Domain,
-				Type:   "app",
-				Status: status,
-				Uptime: c.Status,
+				Name:      name,
+				Domain:    c.Domain,
+				Type:      kind,
+				Status:    status,
+				Uptime:    c.Status,
+				Container: c.Name,
 			})
 		}
 	}
 
+	// Shared services with a web UI are listed with their infra row
+	infraServices := infra.ListRunning()
+	uiRoutes := map[string]bool{}
+	for _, svc := range infraServices {
+		if svc.UIHost != "" {
+			uiRoutes[svc.UIHost] = true
+		}
+	}
+
 	// Get file-based proxies (check if backend is alive)
-	proxies, err := proxy.ListFileProxies(cfg.TLD)
-	if err != nil {
+	var routes []daemon.Route
+	if snap != nil {
+		routes = snap.Routes
+	} else if proxies, err := proxy.ListFileProxies(cfg.TLD); err != nil {
 		warn(fmt.Sprintf("Could not list proxies: %s", err))
 	} else {
 		for _, p := range proxies {
-			status := green("✅ active")
+			status := daemon.RouteActive
 			if !proxy.IsProxyBackendAlive(p.Port) {
-				status = yellow("⚠️  stale (port closed)")
+				status = daemon.RouteStale
 			}
-			entries = append(entries, serviceEntry{
-				Name:   p.Name,
-				Domain: p.Domain,
-				Type:   "proxy",
-				Status: status,
-			})
+			routes = append(routes, daemon.Route{Name: p.Name, Domain: p.Domain, Port: p.Port, Container: p.Container, Status: status})
+		}
+	}
+	for _, r := range routes {
+		// App routes are listed with their container
+		if uiRoutes[r.Name] || appContainers[r.Container] || r.Status == daemon.RouteRemoved {
+			continue
 		}
+		status := green("✅ active")
+		if r.Status == daemon.RouteStale {
+			status = yellow("⚠️  stale (port closed)")
+			if r.Container != "" {
+				status = yellow("⚠️  stale (stopped)")
+			}
+		}
+		entries = append(entries, serviceEntry{
+			Name:   r.Name,
+			Domain: r.Domain,
+			Type:   "proxy",
+			Status: status,
+		})
 	}
 
 	// Get shared infrastructure services
-	infraServices := infra.ListRunning()
 	for _, svc := range infraServices {
+		var consumers []string
+		for _, p := range registry.Consumers(svc.Spec()) {
+			consumers = append(consumers, p.Name)
+		}
+		domain := "—"
+		if svc.UIHost != "" {
+			domain = fmt.Sprintf("%s.%s", svc.UIHost, cfg.TLD)
+		}
 		entries = append(entries, serviceEntry{
-			Name:   svc.Container,
-			Domain: "—",
-			Type:   "infra",
-			Status: green("✅ running"),
+			Name:      svc.Container,
+			Domain:    domain,
+			Type:      "infra",
+			Status:    green("✅ running"),
+			Consumers: consumers,
+			Container: svc.Container,
 		})
 	}
 
@@ -107,6 +186,11 @@ func runLs(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
+	if lsStats {
+		printLsStats(ctx, entries)
+		return nil
+	}
+
 	// Print table
 	fmt.Println()
 	header := color.New(color.Bold)
@@ -121,12 +205,68 @@ func runLs(cmd *cobra.Command, args []string) error {
 			e.Status,
 			dim(e.Uptime),
 		)
+		if e.Type == "infra" {
+			usedBy := dim("unused")
+			if len(e.Consumers) > 0 {
+				usedBy = strings.Join(e.Consumers, ", ")
+			}
+			fmt.Printf("  %s %s\n", dim("  ↳ used by:"), usedBy)
+		}
 	}
 
 	fmt.Println()
 	return nil
 }
 
+// printLsStats prints the container entries with their resource usage
+func printLsStats(ctx context.Context, entries []serviceEntry) {
+	// Each replica of a scaled app gets a row of its own
+	var rows []serviceEntry
+	for _, e := range entries {
+		for i, c := range e.Replicas {
+			rows = append(rows, serviceEntry{Name: fmt.Sprintf("%s #%d", e.Name, i+1), Type: e.Type, Container: c})
+		}
+		if len(e.Replicas) == 0 {
+			rows = append(rows, e)
+		}
+	}
+	entries = rows
+
+	var names []string
+	for _, e := range entries {
+		if e.Container != "" {
+			names = append(names, e.Container)
+		}
+	}
+	stats := docker.CollectStats(ctx, names)
+
+	fmt.Println()
+	header := color.New(color.Bold)
+	header.Printf("  %-20s %-8s %8s  %-22s %-20s %s\n", "NAME", "TYPE", "CPU", "MEMORY", "NET I/O", "RESTARTS")
+	fmt.Printf("  %s\n", dim(strings.Repeat("─", 90)))
+	for _, e := range entries {
+		if e.Container == "" {
+			continue
+		}
+		s, ok := stats[e.Container]
+		if !ok {
+			fmt.Printf("  %-20s %-8s %8s  %s\n", bold(e.Name), dim(e.Type), "—", dim("not running"))
+			continue
+		}
+		fmt.Printf("  %-20s %-8s %8s  %-22s %-20s %d\n",
+			bold(e.Name), dim(e.Type), formatCPU(s), formatMem(s), formatNet(s), s.Restarts)
+	}
+	fmt.Println()
+}
+
+// formatReplicaStatus is the status of a scaled app's row
+func formatReplicaStatus(running, replicas int) string {
+	if running == replicas {
+		return green(fmt.Sprintf("✅ running ×%d", replicas))
+	}
+	return yellow(fmt.Sprintf("⚠️  %d/%d running", running, replicas))
+}
+
 func formatContainerStatus(state string) string {
 	switch state {
 	case "running":
@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/eshe-huli/pier/internal/config"
+	"github.com/eshe-huli/pier/internal/mock"
+	"github.com/eshe-huli/pier/internal/proxy"
+)
+
+var (
+	mockPort      int
+	mockOverrides string
+)
+
+var mockCmd = &cobra.Command{
+	Use:   "mock <name> <openapi-spec>",
+	Short: "Serve a mock API from an OpenAPI spec at <name>.<tld>",
+	Long: `Serves every path and method in an OpenAPI 3 spec, answering with the
+spec's examples or values generated from the response schemas. Requests are
+validated against parameters and request bodies (400 with details on mismatch).
+
+Per-endpoint responses can be pinned in an overrides file, keyed by
+"METHOD /path" (template or concrete path):
+
+  "GET /users/{id}":
+    status: 404
+    body: {error: not found}
+    headers: {X-Mock: "1"}
+    delay: 250ms
+
+By default the overrides file sits next to the spec: openapi.yaml →
+openapi.overrides.yaml. The spec and overrides reload when they change.
+
+Example:
+  pier mock payments ./openapi.yaml   → http://payments.dock`,
+	Args: cobra.ExactArgs(2),
+	RunE: runMock,
+}
+
+func init() {
+	mockCmd.Flags().IntVar(&mockPort, "port", 0, "Local port to listen on (default: any free port)")
+	mockCmd.Flags().StringVar(&mockOverrides, "overrides", "", "Per-endpoint overrides file")
+	rootCmd.AddCommand(mockCmd)
+}
+
+func runMock(cmd *cobra.Command, args []string) error {
+	name, specPath := args[0], args[1]
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	overrides := mockOverrides
+	if overrides == "" {
+		ext := filepath.Ext(specPath)
+		overrides = strings.TrimSuffix(specPath, ext) + ".overrides" + ext
+	}
+
+	srv, err := mock.NewServer(specPath, overrides)
+	if err != nil {
+		return fmt.Errorf("loading spec: %w", err)
+	}
+	srv.Log = func(format string, a ...interface{}) {
+		fmt.Printf("  %s %s\n", dim(time.Now().Format("15:04:05")), fmt.Sprintf(format, a...))
+	}
+
+	ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", mockPort))
+	if err != nil {
+		return fmt.Errorf("listening: %w", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	if proxy.FileProxyExists(name) {
+		warn(fmt.Sprintf("Proxy '%s' already exists, overwriting...", name))
+	}
+	if err := proxy.CreateFileProxy(name, port, cfg.TLD); err != nil {
+		ln.Close()
+		return fmt.Errorf("creating proxy: %w", err)
+	}
+
+	httpSrv := &http.Server{Handler: srv, ReadHeaderTimeout: 10 * time.Second}
+	go httpSrv.Serve(ln)
+
+	stop := make(chan struct{})
+	go srv.Watch(time.Second, stop)
+
+	spec := srv.Spec()
+	title := spec.Title
+	if title == "" {
+		title = filepath.Base(specPath)
+	}
+	domain := fmt.Sprintf("%s.%s", name, cfg.TLD)
+	fmt.Println()
+	success(fmt.Sprintf("Mocking %s (%d operations)", bold(title), len(spec.Operations)))
+	fmt.Printf("     %s\n", cyan("http://"+domain))
+	fmt.Printf("     Local: %s\n", dim(fmt.Sprintf("http://127.0.0.1:%d", port)))
+	if _, err := os.Stat(overrides); err == nil {
+		fmt.Printf("     Overrides: %s\n", dim(overrides))
+	}
+	fmt.Println()
+	info("Watching the spec for changes. Press Ctrl+C to stop")
+	fmt.Println()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	<-sigCh
+
+	close(stop)
+	httpSrv.Close()
+	_ = proxy.RemoveFileProxy(name)
+
+	fmt.Println()
+	info("Mock stopped")
+	return nil
+}
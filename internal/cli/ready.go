@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/eshe-huli/pier/internal/config"
+	"github.com/eshe-huli/pier/internal/docker"
+	"github.com/eshe-huli/pier/internal/pierfile"
+	"github.com/eshe-huli/pier/internal/proxy"
+)
+
+// Overridable in tests: the HTTP probe used for apps without a HEALTHCHECK,
+// the check that Traefik has loaded the route it probes through, and how
+// long a container with neither a HEALTHCHECK nor a route must stay up to
+// count as started
+var (
+	httpProbe   = docker.HTTPProbe
+	routeLoaded = proxy.RouteLoaded
+	readySettle = 2 * time.Second
+)
+
+// failLogLines is how much of a failed container's log `pier up` shows
+const failLogLines = 30
+
+// appHealthcheck turns a Pierfile healthcheck command into a Docker
+// HEALTHCHECK; nil keeps the image's own
+func appHealthcheck(pf *pierfile.Pierfile) *docker.HealthConfig {
+	if pf == nil || pf.Healthcheck == nil || pf.Healthcheck.Command == "" {
+		return nil
+	}
+	hc := pf.Healthcheck
+	return &docker.HealthConfig{
+		Test:        []string{"CMD-SHELL", hc.Command},
+		Interval:    hc.Interval,
+		Timeout:     hc.Timeout,
+		StartPeriod: hc.StartPeriod,
+		Retries:     hc.Retries,
+	}
+}
+
+// waitForApp waits until a just-started app container is ready: healthy by
+// its HEALTHCHECK, or answering through its route when it has none. If it
+// exits or never becomes ready, its last log lines are printed.
+func waitForApp(ctx context.Context, cfg *config.Config, d *appDeploy, inst appInstance) error {
+	if !upWait {
+		return nil
+	}
+	container, domain, pf := inst.container, d.domain(cfg.TLD), d.pf
+	if len(d.instances) > 1 {
+		domain += " (" + inst.name + ")"
+	}
+
+	check := docker.ReadyCheck{Timeout: upWaitTimeout, Settle: readySettle}
+	var hc *pierfile.Healthcheck
+	if pf != nil {
+		hc = pf.Healthcheck
+	}
+	if hc != nil && hc.Wait > 0 {
+		check.Timeout = hc.Wait
+	}
+	if d.port > 0 {
+		path := "/"
+		if hc != nil && hc.Path != "" {
+			path = "/" + strings.TrimPrefix(hc.Path, "/")
+		}
+		host := d.probeDomain(inst, cfg.TLD)
+		probe := httpProbe(fmt.Sprintf("http://127.0.0.1:%d%s", cfg.Traefik.Port, path), host)
+		loaded := false
+		check.Probe = func(ctx context.Context) error {
+			if !loaded {
+				if err := routeLoaded(cfg.Traefik.Port+1, host); err != nil {
+					return err
+				}
+				loaded = true
+			}
+			return probe(ctx)
+		}
+	}
+
+	info(fmt.Sprintf("Waiting for %s to become ready...", cyan(domain)))
+	err := traced(ctx, "container.wait", container, func() error {
+		return docker.WaitReady(ctx, container, check)
+	})
+	if err == nil {
+		success("Ready")
+		return nil
+	}
+
+	var notReady *docker.NotReadyError
+	if !errors.As(err, &notReady) {
+		return err
+	}
+	fail(fmt.Sprintf("%s %s", bold(domain), notReady.Reason))
+	printLastLogs(ctx, container, failLogLines)
+	if !notReady.Exited {
+		fmt.Println("  To skip waiting:")
+		manual("pier up --wait=false")
+	}
+	return fmt.Errorf("%s did not become ready", domain)
+}
+
+// printLastLogs shows the tail of a container's output
+func printLastLogs(ctx context.Context, container string, lines int) {
+	fmt.Println()
+	fmt.Printf("  %s\n", dim(fmt.Sprintf("Last %d log lines of %s:", lines, container)))
+	var out strings.Builder
+	if err := docker.Logs(ctx, container, docker.LogOptions{Tail: fmt.Sprint(lines)}, &out, &out); err != nil {
+		fmt.Printf("    %s\n", dim(err.Error()))
+		return
+	}
+	for _, line := range strings.Split(strings.TrimRight(out.String(), "\n"), "\n") {
+		fmt.Printf("    %s\n", line)
+	}
+	fmt.Println()
+}
@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/eshe-huli/pier/internal/config"
+	"github.com/eshe-huli/pier/internal/docker"
+)
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback [name]",
+	Short: "Switch an app back to its previous build",
+	Long: `Switches an app's containers to the image they ran before the last
+build, with the same zero-downtime swap as pier up. Running it again
+switches forward.
+
+Examples:
+  pier rollback            Roll back the current project
+  pier rollback myapp      Roll back myapp`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runRollback,
+}
+
+func init() {
+	rootCmd.AddCommand(rollbackCmd)
+}
+
+func runRollback(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	name, err := resolveProjectName(args)
+	if err != nil {
+		return err
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	services, err := runningServices(ctx, cfg, name)
+	if err != nil {
+		return err
+	}
+	if len(services) == 0 {
+		return fmt.Errorf("no app containers for %s; start it with pier up", name)
+	}
+	// The Pierfile's healthcheck decides when the old build is ready again
+	pf := registeredPierfile(name)
+
+	fmt.Println()
+	var deploys []*appDeploy
+	for _, svc := range services {
+		target := docker.RollbackImage(svc.spec.Image)
+		if ok, err := docker.ImageExists(ctx, target); err != nil || !ok {
+			info(fmt.Sprintf("%s: no earlier build of %s to switch to", svc.route, svc.spec.Image))
+			continue
+		}
+
+		step(len(deploys)+1, fmt.Sprintf("Switching %s to %s...", cyan(svc.route), cyan(target)))
+		spec := svc.spec
+		spec.Image = target
+		d, err := startApp(ctx, cfg, spec, svc.route, svc.port, svc.replicas, pf)
+		if err != nil {
+			abandonApps(ctx, cfg, deploys)
+			return fmt.Errorf("starting %s: %w", target, err)
+		}
+		deploys = append(deploys, d)
+	}
+	if len(deploys) == 0 {
+		return fmt.Errorf("%s has no previous build; pier up keeps one each time it rebuilds", name)
+	}
+
+	for i, d := range deploys {
+		if err := finishApp(ctx, cfg, d); err != nil {
+			abandonApps(ctx, cfg, deploys[i+1:])
+			return err
+		}
+	}
+
+	fmt.Println()
+	for _, d := range deploys {
+		fmt.Printf("  %s %s %s\n", green("✅"), bold(d.domain(cfg.TLD)), dim("→ "+d.spec.Image))
+	}
+	fmt.Println()
+	return nil
+}
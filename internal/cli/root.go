@@ -1,11 +1,15 @@
 package cli
 
 import (
+	"context"
 	"fmt"
 	"os"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
+
+	"github.com/eshe-huli/pier/internal/config"
+	"github.com/eshe-huli/pier/internal/docker"
 )
 
 // Version is set at build time via -ldflags
@@ -32,7 +36,8 @@ Like Laravel Valet, but for everyone.
   pier down              Stop a project
   pier ls                List everything running
   pier proxy app 3000    Route app.dock → localhost:3000`,
-	Version: Version,
+	Version:          Version,
+	PersistentPreRun: preRun,
 }
 
 var versionCmd = &cobra.Command{
@@ -49,9 +54,19 @@ func init() {
 	rootCmd.SetVersionTemplate(fmt.Sprintf("🔩 Pier %s\n", Version))
 }
 
+// preRun selects the container runtime and starts tracing traced commands
+func preRun(cmd *cobra.Command, args []string) {
+	if cfg, err := config.Load(); err == nil {
+		docker.Prefer(cfg.Runtime)
+	}
+	startTracing(cmd, args)
+}
+
 // Execute runs the root command
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.ExecuteContext(context.Background())
+	stopTracing(err)
+	if err != nil {
 		os.Exit(1)
 	}
 }
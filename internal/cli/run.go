@@ -3,13 +3,14 @@ package cli
 import (
 	"fmt"
 	"os"
-	"os/exec"
 	"strings"
 
 	"github.com/spf13/cobra"
 
 	"github.com/eshe-huli/pier/internal/config"
+	"github.com/eshe-huli/pier/internal/docker"
 	"github.com/eshe-huli/pier/internal/infra"
+	"github.com/eshe-huli/pier/internal/pierfile"
 	"github.com/eshe-huli/pier/internal/proxy"
 	"github.com/eshe-huli/pier/internal/registry"
 	"github.com/eshe-huli/pier/internal/runtime"
@@ -57,10 +58,7 @@ func runRunCmd(cmd *cobra.Command, args []string) error {
 	image := runImage
 	if runBuild {
 		step(1, fmt.Sprintf("Building image %s...", cyan(name)))
-		buildCmd := exec.Command("docker", "build", "-t", name, ".")
-		buildCmd.Stdout = os.Stdout
-		buildCmd.Stderr = os.Stderr
-		if err := buildCmd.Run(); err != nil {
+		if err := buildImage(cmd.Context(), docker.BuildSpec{Context: ".", Tag: name}); err != nil {
 			return fmt.Errorf("docker build failed: %w", err)
 		}
 		image = name
@@ -77,6 +75,11 @@ func runRunCmd(cmd *cobra.Command, args []string) error {
 		services = loadPierFileServices()
 	}
 
+	var pf *pierfile.Pierfile
+	if pierfile.Exists(".") {
+		pf, _ = pierfile.Load(".")
+	}
+
 	// Step 3: Ensure shared services
 	var sharedServices []infra.SharedService
 	if len(services) > 0 {
@@ -88,8 +91,17 @@ func runRunCmd(cmd *cobra.Command, args []string) error {
 			}
 			svcName, svcVersion := parts[0], parts[1]
 
+			if svcName == "auth" {
+				if err := infra.ProvisionAuth(svcVersion, name, authConfig(pf)); err != nil {
+					warn(fmt.Sprintf("Could not register %s with auth: %s", name, err))
+				}
+			}
+
 			fmt.Printf("    → %s:%s ", cyan(svcName), svcVersion)
-			if err := infra.EnsureService(svcName, svcVersion); err != nil {
+			err := traced(cmd.Context(), "infra.start "+svcSpec, name, func() error {
+				return infra.EnsureService(svcName, svcVersion)
+			})
+			if err != nil {
 				fmt.Println(red("✗"))
 				return fmt.Errorf("starting %s: %w", svcSpec, err)
 			}
@@ -99,33 +111,50 @@ func runRunCmd(cmd *cobra.Command, args []string) error {
 			if svc != nil {
 				sharedServices = append(sharedServices, *svc)
 			}
+
+			if infra.IsolatesProjects(svcName) {
+				if err := infra.ProvisionProject(svcName, svcVersion, name); err != nil {
+					warn(fmt.Sprintf("Could not provision %s for %s: %s", svcName, name, err))
+				}
+			}
 		}
 	}
 
+	if len(sharedServices) > 0 {
+		recordServiceUsage(name, sharedServices)
+	}
+
 	// Step 4: Build env overrides
-	envOverrides := runtime.BuildEnvOverrides(sharedServices)
+	envOverrides := runtime.BuildEnvOverrides(infra.WithTelemetry(sharedServices), runtime.EnvOptions{Project: name})
+
+	extraEnv, err := pierfileEnv(pf, name, cfg.TLD, sharedServices, nil)
+	if err != nil {
+		return err
+	}
 
 	// Step 5: Run the container
 	step(3, fmt.Sprintf("Running %s...", cyan(name)))
 
-	dockerArgs := []string{"run", "-d", "--name", name, "--network", cfg.Network, "--restart", "unless-stopped"}
-
-	// Add env overrides from shared services
-	for _, e := range envOverrides {
-		dockerArgs = append(dockerArgs, "-e", e)
-	}
-
-	// Add user-specified env vars
-	for _, e := range runEnvs {
-		dockerArgs = append(dockerArgs, "-e", e)
+	// Shared service overrides, then Pierfile env, then user-specified env vars
+	env := append([]string{}, envOverrides...)
+	for k, v := range extraEnv {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
 	}
-
-	dockerArgs = append(dockerArgs, image)
-
-	dockerCmd := exec.Command("docker", dockerArgs...)
-	out, err := dockerCmd.CombinedOutput()
+	env = append(env, runEnvs...)
+
+	container := docker.AppContainerName(name, "")
+	retireLegacyApp(cmd.Context(), name, name)
+	err = runContainer(cmd.Context(), docker.RunSpec{
+		Name:    container,
+		Image:   image,
+		Network: cfg.Network,
+		Restart: "unless-stopped",
+		Env:     env,
+		Owner:   appOwner(name, name),
+		Aliases: []string{name},
+	})
 	if err != nil {
-		return fmt.Errorf("docker run failed: %s\n%s", err, string(out))
+		return fmt.Errorf("docker run failed: %w", err)
 	}
 
 	success(fmt.Sprintf("Container %s started", bold(name)))
@@ -133,7 +162,7 @@ func runRunCmd(cmd *cobra.Command, args []string) error {
 	// Step 6: Create Traefik route if port specified
 	if runPort > 0 {
 		step(4, "Creating route...")
-		if err := createContainerProxy(name, runPort, cfg.TLD); err != nil {
+		if err := proxy.CreateContainerProxy(name, container, runPort, cfg.TLD); err != nil {
 			warn(fmt.Sprintf("Could not create route: %s", err))
 		} else {
 			domain := fmt.Sprintf("%s.%s", name, cfg.TLD)
@@ -154,35 +183,6 @@ func runRunCmd(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-// createContainerProxy creates a Traefik route for a Docker container (uses container name, not host.docker.internal)
-func createContainerProxy(name string, port int, tld string) error {
-	domain := fmt.Sprintf("%s.%s", name, tld)
-	url := fmt.Sprintf("http://%s:%d", name, port)
-
-	cfg := map[string]interface{}{
-		"http": map[string]interface{}{
-			"routers": map[string]interface{}{
-				name: map[string]interface{}{
-					"rule":    fmt.Sprintf("Host(`%s`)", domain),
-					"service": name,
-					"entryPoints": []string{"web"},
-				},
-			},
-			"services": map[string]interface{}{
-				name: map[string]interface{}{
-					"loadBalancer": map[string]interface{}{
-						"servers": []map[string]interface{}{
-							{"url": url},
-						},
-					},
-				},
-			},
-		},
-	}
-
-	return proxy.WriteTraefikConfig(name, cfg)
-}
-
 // loadPierFileServices reads services from .pier file in current directory
 func loadPierFileServices() []string {
 	data, err := os.ReadFile("Pierfile")
@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/eshe-huli/pier/internal/config"
+)
+
+var scaleCmd = &cobra.Command{
+	Use:   "scale <name> <replicas>",
+	Short: "Run several containers of an app behind its domain",
+	Long: `Runs an app as <replicas> containers from the same image and env,
+load-balanced behind its domain. Handy for testing sessions, caches and
+websockets across instances. The swap has no downtime, like pier up.
+
+pier up goes back to the Pierfile's replicas (default 1).
+
+Examples:
+  pier scale myapp 3       Run three containers of myapp
+  pier scale myapp 1       Back to one`,
+	Args: cobra.ExactArgs(2),
+	RunE: runScale,
+}
+
+func init() {
+	rootCmd.AddCommand(scaleCmd)
+}
+
+func runScale(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	name := args[0]
+	replicas, err := strconv.Atoi(args[1])
+	if err != nil || replicas < 1 {
+		return fmt.Errorf("replicas must be a number of at least 1, got %q", args[1])
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	services, err := runningServices(ctx, cfg, name)
+	if err != nil {
+		return err
+	}
+	if len(services) == 0 {
+		return fmt.Errorf("no app containers for %s; start it with pier up", name)
+	}
+	// A compose project's services are scaled one at a time
+	target := services[0]
+	if len(services) > 1 {
+		var routes []string
+		target = nil
+		for _, s := range services {
+			routes = append(routes, s.route)
+			if s.route == name {
+				target = s
+			}
+		}
+		if target == nil {
+			return fmt.Errorf("%s has several services (%s); scale one of them", name, strings.Join(routes, ", "))
+		}
+	}
+
+	fmt.Println()
+	step(1, fmt.Sprintf("Scaling %s to %d...", cyan(target.route), replicas))
+	d, err := startApp(ctx, cfg, target.spec, target.route, target.port, replicas, registeredPierfile(name))
+	if err != nil {
+		return err
+	}
+	if err := finishApp(ctx, cfg, d); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	fmt.Printf("  %s %s %s\n", green("✅"), bold(d.domain(cfg.TLD)), dim(fmt.Sprintf("× %d", replicas)))
+	fmt.Println()
+	return nil
+}
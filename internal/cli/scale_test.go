@@ -0,0 +1,286 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/eshe-huli/pier/internal/config"
+	"github.com/eshe-huli/pier/internal/docker"
+	"github.com/eshe-huli/pier/internal/proxy"
+)
+
+// routeServers returns the load-balanced URLs of the file route called name
+func routeServers(t *testing.T, name string) []string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(config.TraefikDynamicDir(), name+".yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg struct {
+		HTTP struct {
+			Services map[string]struct {
+				LoadBalancer struct {
+					Servers []struct {
+						URL string `yaml:"url"`
+					} `yaml:"servers"`
+				} `yaml:"loadBalancer"`
+			} `yaml:"services"`
+		} `yaml:"http"`
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	var urls []string
+	for _, s := range cfg.HTTP.Services[name].LoadBalancer.Servers {
+		urls = append(urls, s.URL)
+	}
+	return urls
+}
+
+// appContainers returns the names of the fake's containers for project shop
+func appContainers(fake *docker.Fake) []string {
+	var names []string
+	for name := range fake.Containers {
+		if strings.HasPrefix(name, "pier-app-shop") {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestRunUp_Replicas(t *testing.T) {
+	fake, _ := setupUp(t, map[string]string{
+		"Dockerfile": "FROM node:20\n",
+		"Pierfile":   "name: shop\nport: 3000\nreplicas: 3\nenv:\n  GREETING: hi\n",
+	})
+	// Each replica is probed on its own route, not on the shared one
+	var probed []string
+	httpProbe = func(url, host string) func(context.Context) error {
+		probed = append(probed, host)
+		return func(context.Context) error { return nil }
+	}
+	if err := runUpCmd(t); err != nil {
+		t.Fatalf("runUp: %v", err)
+	}
+	if !slices.Equal(probed, []string{"pier-app-shop-1.dock", "pier-app-shop-2.dock", "pier-app-shop-3.dock"}) {
+		t.Errorf("probed %v, want each replica's own route", probed)
+	}
+
+	want := []string{"pier-app-shop-1", "pier-app-shop-2", "pier-app-shop-3"}
+	if got := appContainers(fake); !slices.Equal(got, want) {
+		t.Fatalf("containers = %v, want %v", got, want)
+	}
+	for i, name := range want {
+		spec := fake.Specs[name]
+		if spec.Image != "shop" || !hasEnv(spec.Env, "GREETING=hi") {
+			t.Errorf("%s = %+v, want the app's image and env", name, spec)
+		}
+		if o, _ := docker.OwnerOf(spec.Labels); o.Replica != i+1 || o.Service != "shop" {
+			t.Errorf("%s owner = %+v, want replica %d of shop", name, o, i+1)
+		}
+	}
+	urls := routeServers(t, "shop")
+	if !slices.Equal(urls, []string{"http://pier-app-shop-1:3000", "http://pier-app-shop-2:3000", "http://pier-app-shop-3:3000"}) {
+		t.Errorf("route servers = %v, want all three replicas", urls)
+	}
+	for _, name := range want {
+		if proxy.FileProxyExists(name) {
+			t.Errorf("probe route %s left behind", name)
+		}
+	}
+
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+	out := captureStdout(t, func() {
+		if err := runLs(cmd, nil); err != nil {
+			t.Errorf("runLs: %v", err)
+		}
+	})
+	if strings.Count(out, "shop.dock") != 1 || !strings.Contains(out, "running ×3") {
+		t.Errorf("ls should show one row with the replica count:\n%s", out)
+	}
+}
+
+func TestRunUp_FailedReplicaRemovesTheOthers(t *testing.T) {
+	fake, _ := setupUp(t, map[string]string{
+		"Dockerfile": "FROM node:20\n",
+		"Pierfile":   "name: shop\nport: 3000\nreplicas: 2\n",
+	})
+	fake.OnStart = func(c *docker.Container) {
+		if c.Name == "pier-app-shop-2" {
+			c.State, c.Running, c.ExitCode = "exited", false, 1
+		}
+	}
+	if err := runUpCmd(t); err == nil {
+		t.Fatal("runUp should fail when a replica crashes")
+	}
+
+	if got := appContainers(fake); len(got) != 0 {
+		t.Errorf("containers left: %v", got)
+	}
+	for _, name := range []string{"shop", "pier-app-shop-1", "pier-app-shop-2"} {
+		if proxy.FileProxyExists(name) {
+			t.Errorf("route %s left behind", name)
+		}
+	}
+}
+
+func TestScale(t *testing.T) {
+	fake, _ := setupUp(t, map[string]string{
+		"Dockerfile": "FROM node:20\n",
+		"Pierfile":   "name: shop\nport: 3000\n",
+	})
+	if err := runUpCmd(t); err != nil {
+		t.Fatalf("runUp: %v", err)
+	}
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+
+	// The new replicas are probed on their own routes while the old
+	// container keeps the app's
+	var probed []string
+	httpProbe = func(url, host string) func(context.Context) error {
+		probed = append(probed, host)
+		return func(context.Context) error {
+			if r := fileRoute(t, "shop"); r.Container != "pier-app-shop" {
+				t.Errorf("route moved before the replicas were ready: %+v", r)
+			}
+			return nil
+		}
+	}
+	if err := runScale(cmd, []string{"shop", "2"}); err != nil {
+		t.Fatalf("scale to 2: %v", err)
+	}
+	if !slices.Equal(probed, []string{"pier-app-shop-1.dock", "pier-app-shop-2.dock"}) {
+		t.Errorf("probed %v, want each replica's own route", probed)
+	}
+	if got := appContainers(fake); !slices.Equal(got, []string{"pier-app-shop-1", "pier-app-shop-2"}) {
+		t.Fatalf("containers = %v, want two replicas", got)
+	}
+	if urls := routeServers(t, "shop"); len(urls) != 2 {
+		t.Errorf("route servers = %v, want both replicas", urls)
+	}
+	for _, name := range []string{"pier-app-shop-1", "pier-app-shop-2"} {
+		if proxy.FileProxyExists(name) {
+			t.Errorf("staging route %s left behind", name)
+		}
+	}
+
+	httpProbe = func(url, host string) func(context.Context) error {
+		return func(context.Context) error { return nil }
+	}
+	if err := runScale(cmd, []string{"shop", "1"}); err != nil {
+		t.Fatalf("scale to 1: %v", err)
+	}
+	if got := appContainers(fake); !slices.Equal(got, []string{"pier-app-shop"}) {
+		t.Fatalf("containers = %v, want just pier-app-shop", got)
+	}
+	if o, _ := docker.OwnerOf(fake.Specs["pier-app-shop"].Labels); o.Replica != 0 {
+		t.Errorf("owner = %+v, want no replica number", o)
+	}
+	if r := fileRoute(t, "shop"); r.Container != "pier-app-shop" || r.ContainerPort != 3000 {
+		t.Errorf("route = %+v, want pier-app-shop:3000", r)
+	}
+
+	if err := runScale(cmd, []string{"shop", "0"}); err == nil {
+		t.Error("scaling to 0 should fail")
+	}
+}
+
+func TestRunDown_RemovesReplicas(t *testing.T) {
+	fake, _ := setupUp(t, map[string]string{
+		"Dockerfile": "FROM node:20\n",
+		"Pierfile":   "name: shop\nport: 3000\nreplicas: 3\n",
+	})
+	if err := runUpCmd(t); err != nil {
+		t.Fatalf("runUp: %v", err)
+	}
+	if err := runDown(&cobra.Command{}, []string{"shop"}); err != nil {
+		t.Fatalf("runDown: %v", err)
+	}
+	if got := appContainers(fake); len(got) != 0 {
+		t.Errorf("containers left: %v", got)
+	}
+	if proxy.FileProxyExists("shop") {
+		t.Error("route left behind")
+	}
+}
+
+func TestRunUp_LeavesSimilarlyNamedProjects(t *testing.T) {
+	fake, _ := setupUp(t, map[string]string{
+		"Dockerfile": "FROM node:20\n",
+		"Pierfile":   "name: shop\nport: 3000\n",
+	})
+	// Project shop-2's container is named like a replica of shop
+	fake.Images["other"] = true
+	other := appOwner("shop-2", "shop-2")
+	if _, err := docker.Run(context.Background(), docker.RunSpec{Name: "pier-app-shop-2", Image: "other", Labels: other.Labels()}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Deploy, then redeploy over the running container
+	for i := 0; i < 2; i++ {
+		if err := runUpCmd(t); err != nil {
+			t.Fatalf("runUp %d: %v", i+1, err)
+		}
+	}
+	if got := appContainers(fake); !slices.Equal(got, []string{"pier-app-shop", "pier-app-shop-2"}) {
+		t.Fatalf("containers = %v, want shop's and shop-2's", got)
+	}
+	if spec := fake.Specs["pier-app-shop-2"]; spec.Image != "other" {
+		t.Errorf("pier-app-shop-2 = %+v, want project shop-2's container untouched", spec)
+	}
+}
+
+func TestRunUp_ProjectNamedNext(t *testing.T) {
+	fake, _ := setupUp(t, map[string]string{
+		"Dockerfile": "FROM node:20\n",
+		"Pierfile":   "name: next\nport: 3000\n",
+	})
+	if err := runUpCmd(t); err != nil {
+		t.Fatalf("runUp: %v", err)
+	}
+
+	// pier-app-next is the live container, not a failed deploy's leftover
+	var probed []string
+	httpProbe = func(url, host string) func(context.Context) error {
+		probed = append(probed, host)
+		return func(context.Context) error {
+			if _, ok := fake.Containers["pier-app-next"]; !ok {
+				t.Error("the live container was removed before the new one was ready")
+			}
+			return nil
+		}
+	}
+	if err := runUpCmd(t); err != nil {
+		t.Fatalf("redeploy: %v", err)
+	}
+	if !slices.Equal(probed, []string{"pier-app-next-next.dock"}) {
+		t.Errorf("probed %v, want the staging container's route", probed)
+	}
+	if o, ok := docker.OwnerOf(fake.Specs["pier-app-next"].Labels); !ok || o.Project != "next" {
+		t.Fatalf("pier-app-next owner = %+v", o)
+	}
+
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+	if err := runScale(cmd, []string{"next", "2"}); err != nil {
+		t.Fatalf("scale: %v", err)
+	}
+	var names []string
+	for name := range fake.Containers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	if !slices.Equal(names, []string{"pier-app-next-1", "pier-app-next-2"}) {
+		t.Errorf("containers = %v, want two replicas", names)
+	}
+}
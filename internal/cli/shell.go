@@ -6,6 +6,8 @@ import (
 	"os/exec"
 
 	"github.com/spf13/cobra"
+
+	"github.com/eshe-huli/pier/internal/docker"
 )
 
 var shellCmd = &cobra.Command{
@@ -32,9 +34,11 @@ func runShell(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	container := appContainer(cmd.Context(), name)
+
 	// Try bash first, fall back to sh
 	for _, shell := range []string{"/bin/bash", "/bin/sh"} {
-		c := exec.Command("docker", "exec", "-it", name, shell)
+		c := exec.Command(docker.CLIBinary(), "exec", "-it", container, shell)
 		c.Stdin = os.Stdin
 		c.Stdout = os.Stdout
 		c.Stderr = os.Stderr
@@ -79,6 +79,17 @@ func runStatus(cmd *cobra.Command, args []string) error {
 		fmt.Printf("  Network:    %s\n", red(fmt.Sprintf("❌ %s not found", cfg.Network)))
 	}
 
+	// Resources used by app and infra containers
+	if rows, err := collectTopRows(ctx, cfg); err == nil && len(rows) > 0 {
+		var cpu float64
+		var mem uint64
+		for _, r := range rows {
+			cpu += r.Stats.CPUPercent
+			mem += r.Stats.MemUsage
+		}
+		fmt.Printf("  Resources:  %s\n", fmt.Sprintf("%d containers · %.1f%% CPU · %s memory", len(rows), cpu, humanBytes(int64(mem))))
+	}
+
 	// TLD
 	fmt.Printf("  TLD:        %s\n", cyan("."+cfg.TLD))
 
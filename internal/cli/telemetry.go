@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/eshe-huli/pier/internal/infra"
+	"github.com/eshe-huli/pier/internal/telemetry"
+)
+
+var (
+	commandSpan  trace.Span
+	flushTracing func()
+)
+
+// tracedCommands are the commands traced into the telemetry service: the
+// ones that build images, start infra and run containers. Looking for the
+// service costs every other command a runtime round trip for nothing.
+var tracedCommands = map[string]bool{
+	"pier up":       true,
+	"pier run":      true,
+	"pier scale":    true,
+	"pier rollback": true,
+}
+
+// telemetryTimeout bounds looking for the telemetry service, so a slow or
+// missing runtime never holds up a command
+const telemetryTimeout = 500 * time.Millisecond
+
+// startTracing traces the command into the telemetry service, when it runs
+func startTracing(cmd *cobra.Command, args []string) {
+	if !tracedCommands[cmd.CommandPath()] {
+		return
+	}
+	endpoint, ok := telemetryEndpoint(cmd.Context())
+	if !ok {
+		return
+	}
+	flush, err := telemetry.Start(endpoint, Version)
+	if err != nil {
+		return
+	}
+	flushTracing = flush
+
+	ctx, span := telemetry.Span(cmd.Context(), cmd.CommandPath(), attribute.StringSlice("pier.args", args))
+	commandSpan = span
+	cmd.SetContext(ctx)
+}
+
+// telemetryEndpoint returns the telemetry service's OTLP endpoint on the
+// host, if the service is up and answering
+func telemetryEndpoint(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, telemetryTimeout)
+	defer cancel()
+	port, ok := infra.RunningHostPort(ctx, "telemetry")
+	if !ok {
+		return "", false
+	}
+	addr := fmt.Sprintf("127.0.0.1:%d", port)
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return "", false
+	}
+	conn.Close()
+	return "http://" + addr, true
+}
+
+// stopTracing ends the command span and flushes everything recorded
+func stopTracing(err error) {
+	if commandSpan != nil {
+		telemetry.End(commandSpan, err)
+	}
+	if flushTracing != nil {
+		flushTracing()
+	}
+}
+
+// traced runs a pier operation (build, infra start, container run) in a span
+func traced(ctx context.Context, name, project string, fn func() error) error {
+	return telemetry.Trace(ctx, name, func(context.Context) error { return fn() },
+		attribute.String("pier.project", project))
+}
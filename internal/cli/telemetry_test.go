@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/eshe-huli/pier/internal/docker"
+)
+
+func TestTracedCommands(t *testing.T) {
+	for cmd, want := range map[string]bool{
+		upCmd.CommandPath():        true,
+		rollbackCmd.CommandPath():  true,
+		versionCmd.CommandPath():   false,
+		daemonRunCmd.CommandPath(): false,
+		proxyCmd.CommandPath():     false,
+	} {
+		if tracedCommands[cmd] != want {
+			t.Errorf("%s traced = %v, want %v", cmd, !want, want)
+		}
+	}
+}
+
+func TestTelemetryEndpoint(t *testing.T) {
+	fake := docker.UseFake()
+	t.Cleanup(func() { docker.SetRuntime(nil) })
+	ctx := context.Background()
+	if _, ok := telemetryEndpoint(ctx); ok {
+		t.Fatal("found an endpoint without the telemetry service")
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	fake.Images["jaegertracing/all-in-one:latest"] = true
+	if _, err := docker.Run(ctx, docker.RunSpec{
+		Name:   "pier-telemetry-latest",
+		Image:  "jaegertracing/all-in-one:latest",
+		Labels: docker.Owner{Role: docker.RoleInfra, Service: "telemetry", Version: "latest"}.Labels(),
+		Ports:  []docker.PortMapping{{Container: 4318, Host: port}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := fmt.Sprintf("http://127.0.0.1:%d", port)
+	if got, ok := telemetryEndpoint(ctx); !ok || got != want {
+		t.Errorf("endpoint = %q, %v; want %q", got, ok, want)
+	}
+	l.Close()
+	if _, ok := telemetryEndpoint(ctx); ok {
+		t.Error("found an endpoint nothing answers on")
+	}
+}
@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/eshe-huli/pier/internal/config"
+	"github.com/eshe-huli/pier/internal/docker"
+)
+
+var topInterval time.Duration
+
+var topCmd = &cobra.Command{
+	Use:   "top",
+	Short: "Live CPU, memory and network usage of app and infra containers",
+	Long: `Shows resource usage of Pier's app and infra containers, refreshed until
+interrupted.
+
+Examples:
+  pier top
+  pier top --interval 5s`,
+	Args: cobra.NoArgs,
+	RunE: runTop,
+}
+
+func init() {
+	topCmd.Flags().DurationVar(&topInterval, "interval", 2*time.Second, "Time between refreshes")
+	rootCmd.AddCommand(topCmd)
+}
+
+// topRow is one container in `pier top`
+type topRow struct {
+	Name  string
+	Role  string
+	Stats *docker.Stats
+}
+
+func runTop(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+	defer stop()
+
+	for {
+		rows, err := collectTopRows(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		// Clear the screen and redraw from the top
+		fmt.Print("\033[H\033[2J")
+		renderTop(os.Stdout, rows, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(topInterval):
+		}
+	}
+}
+
+// collectTopRows samples every running app and infra container
+func collectTopRows(ctx context.Context, cfg *config.Config) ([]topRow, error) {
+	containers, err := docker.ListContainers(ctx, cfg.Network, cfg.TLD)
+	if err != nil {
+		return nil, fmt.Errorf("listing containers: %w", err)
+	}
+
+	var rows []topRow
+	var names []string
+	for _, c := range containers {
+		role := containerRole(c)
+		if c.State != "running" || (role != docker.RoleApp && role != docker.RoleInfra) {
+			continue
+		}
+		name := c.Name
+		if c.Managed && role == docker.RoleApp {
+			name = c.Owner.Service
+		}
+		rows = append(rows, topRow{Name: name, Role: role, Stats: &docker.Stats{Name: c.Name}})
+		names = append(names, c.Name)
+	}
+
+	stats := docker.CollectStats(ctx, names)
+	for i := range rows {
+		if s, ok := stats[rows[i].Stats.Name]; ok {
+			rows[i].Stats = s
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Role != rows[j].Role {
+			return rows[i].Role < rows[j].Role
+		}
+		return rows[i].Name < rows[j].Name
+	})
+	return rows, nil
+}
+
+func renderTop(w io.Writer, rows []topRow, at time.Time) {
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "  %s  %s\n", bold("pier top"), dim(at.Format("15:04:05")+" · ctrl-c to quit"))
+	fmt.Fprintln(w)
+	if len(rows) == 0 {
+		fmt.Fprintf(w, "  %s No running app or infra containers.\n\n", dim("ℹ"))
+		return
+	}
+
+	header := color.New(color.Bold)
+	header.Fprintf(w, "  %-24s %-6s %8s  %-22s %-20s %s\n", "NAME", "ROLE", "CPU", "MEMORY", "NET I/O", "RESTARTS")
+	fmt.Fprintf(w, "  %s\n", dim(strings.Repeat("─", 92)))
+
+	var cpu float64
+	var mem uint64
+	for _, r := range rows {
+		fmt.Fprintf(w, "  %-24s %-6s %8s  %-22s %-20s %d\n",
+			r.Name, r.Role, formatCPU(r.Stats), formatMem(r.Stats), formatNet(r.Stats), r.Stats.Restarts)
+		cpu += r.Stats.CPUPercent
+		mem += r.Stats.MemUsage
+	}
+	fmt.Fprintf(w, "  %s\n", dim(strings.Repeat("─", 92)))
+	fmt.Fprintf(w, "  %-24s %-6s %7.1f%%  %s\n\n", "total", "", cpu, humanBytes(int64(mem)))
+}
+
+func formatCPU(s *docker.Stats) string {
+	return fmt.Sprintf("%.1f%%", s.CPUPercent)
+}
+
+func formatMem(s *docker.Stats) string {
+	if s.MemLimit == 0 {
+		return humanBytes(int64(s.MemUsage))
+	}
+	return fmt.Sprintf("%s / %s", humanBytes(int64(s.MemUsage)), humanBytes(int64(s.MemLimit)))
+}
+
+func formatNet(s *docker.Stats) string {
+	return fmt.Sprintf("%s / %s", humanBytes(int64(s.NetRx)), humanBytes(int64(s.NetTx)))
+}
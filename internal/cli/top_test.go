@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eshe-huli/pier/internal/config"
+	"github.com/eshe-huli/pier/internal/docker"
+)
+
+func TestCollectTopRows(t *testing.T) {
+	fake, _ := setupUp(t, nil)
+	ctx := context.Background()
+	fake.Images["img"] = true
+	for _, spec := range []docker.RunSpec{
+		{Name: "pier-app-shop", Owner: appOwner("shop", "shop")},
+		{Name: "pier-redis-7", Owner: docker.Owner{Role: docker.RoleInfra, Service: "redis", Version: "7"}},
+		{Name: "pier-traefik", Owner: docker.Owner{Role: docker.RoleSystem, Service: "traefik"}},
+		{Name: "my-tool"},
+	} {
+		spec.Image, spec.Network = "img", "pier"
+		if _, err := docker.Run(ctx, spec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fake.StatsData["pier-app-shop"] = docker.Stats{CPUPercent: 42, MemUsage: 128 << 20, MemLimit: 1 << 30}
+
+	rows, err := collectTopRows(ctx, config.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0].Name != "shop" || rows[1].Name != "pier-redis-7" {
+		t.Fatalf("rows = %+v, want app shop then infra pier-redis-7", rows)
+	}
+	if rows[0].Stats.CPUPercent != 42 {
+		t.Errorf("shop cpu = %v, want 42", rows[0].Stats.CPUPercent)
+	}
+
+	var out bytes.Buffer
+	renderTop(&out, rows, time.Now())
+	for _, want := range []string{"shop", "42.0%", "128.0 MB / 1.0 GB", "pier-redis-7"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
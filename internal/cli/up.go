@@ -2,20 +2,24 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
+	"github.com/eshe-huli/pier/internal/buildspec"
 	"github.com/eshe-huli/pier/internal/compose"
 	"github.com/eshe-huli/pier/internal/config"
 	"github.com/eshe-huli/pier/internal/detect"
 	"github.com/eshe-huli/pier/internal/docker"
-	"github.com/eshe-huli/pier/internal/infra"
 	"github.com/eshe-huli/pier/internal/gitignore"
+	"github.com/eshe-huli/pier/internal/infra"
 	"github.com/eshe-huli/pier/internal/pierfile"
 	"github.com/eshe-huli/pier/internal/registry"
 	"github.com/eshe-huli/pier/internal/runtime"
@@ -23,6 +27,12 @@ import (
 
 var upDetach bool
 var upBuild bool
+var upMigrate bool
+var upWait bool
+var upDev bool
+var upWaitTimeout time.Duration
+var upProfiles []string
+var upEnvFiles []string
 
 var upCmd = &cobra.Command{
 	Use:   "up",
@@ -31,21 +41,38 @@ var upCmd = &cobra.Command{
 
 Pier reads from Pierfile, docker-compose.yml, or auto-detects the framework.
 Shared infrastructure (postgres, redis, etc.) is started automatically.
+Pier then waits for the app to become ready: healthy by its HEALTHCHECK (or
+the Pierfile healthcheck block), otherwise answering on its route.
 
 Examples:
   pier up
   pier up --detach
-  pier up --build`,
+  pier up --build
+  pier up --dev
+  pier up --migrate
+  pier up --profile tools
+  pier up --env-file .env.staging
+  pier up --wait=false`,
 	RunE: runUp,
 }
 
 func init() {
 	upCmd.Flags().BoolVarP(&upDetach, "detach", "d", true, "Run in background (default true)")
-	upCmd.Flags().BoolVar(&upBuild, "build", false, "Force rebuild even if image exists")
+	upCmd.Flags().BoolVar(&upBuild, "build", false, "Rebuild even if the sources haven't changed")
+	upCmd.Flags().BoolVar(&upMigrate, "migrate", false, "Run database migrate/seed commands even if the database exists")
+	upCmd.Flags().BoolVar(&upDev, "dev", false, "Run the framework's dev server on the mounted source instead of building an image")
+	upCmd.Flags().BoolVar(&upWait, "wait", true, "Wait for the app to become ready")
+	upCmd.Flags().DurationVar(&upWaitTimeout, "wait-timeout", time.Minute, "How long to wait for the app to become ready")
+	upCmd.Flags().StringSliceVar(&upProfiles, "profile", nil, "Enable a compose profile (default $COMPOSE_PROFILES)")
+	upCmd.Flags().StringArrayVar(&upEnvFiles, "env-file", nil, "Interpolate the compose file with these variables instead of .env")
 	rootCmd.AddCommand(upCmd)
 }
 
 func runUp(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	if upDev && !cmd.Flags().Changed("wait-timeout") {
+		upWaitTimeout = devWaitTimeout
+	}
 	dir, err := os.Getwd()
 	if err != nil {
 		return fmt.Errorf("getting working directory: %w", err)
@@ -77,9 +104,12 @@ func runUp(cmd *cobra.Command, args []string) error {
 	step(1, fmt.Sprintf("Project: %s", cyan(projectName)))
 
 	// Check for docker-compose project
-	cf, composeErr := compose.Parse(dir)
+	cf, composeErr := compose.Parse(dir, upEnvFiles...)
 	if composeErr == nil {
-		return runUpCompose(cmd.Context(), dir, projectName, cf, cfg)
+		return runUpCompose(ctx, dir, projectName, cf, cfg)
+	}
+	if !errors.Is(composeErr, compose.ErrNotFound) {
+		return composeErr
 	}
 
 	// Step 2: Detect services
@@ -104,149 +134,89 @@ func runUp(cmd *cobra.Command, args []string) error {
 	var dbCreated bool
 	if len(services) > 0 {
 		step(2, "Starting shared infrastructure...")
+		var reqs []infraRequest
 		for _, svcSpec := range services {
 			parts := strings.SplitN(svcSpec, ":", 2)
 			if len(parts) != 2 {
 				return fmt.Errorf("invalid service spec '%s' (expected name:version)", svcSpec)
 			}
-			svcName, svcVersion := parts[0], parts[1]
-
-			fmt.Printf("    → %s:%s ", cyan(svcName), svcVersion)
-			if err := infra.EnsureService(svcName, svcVersion); err != nil {
-				fmt.Println(red("✗"))
-				return fmt.Errorf("starting %s: %w", svcSpec, err)
-			}
-			fmt.Println(green("✓"))
-
-			svc, _ := infra.ResolveService(svcName, svcVersion)
-			if svc != nil {
-				sharedServices = append(sharedServices, *svc)
-			}
-
-			// Auto-create database for postgres/mysql
-			if svcName == "postgres" || svcName == "mysql" {
-				if err := infra.CreateDatabase(svcName, svcVersion, projectName); err != nil {
-					warn(fmt.Sprintf("Could not create database: %s", err))
-				} else {
-					dbCreated = true
-				}
-			}
+			reqs = append(reqs, infraRequest{Name: parts[0], Version: parts[1]})
+		}
+		sharedServices, dbCreated, err = startInfra(ctx, reqs, projectName, pf)
+		if err != nil {
+			return err
 		}
 	}
 
+	// Render Pierfile env templates before spending time on a build
+	extraEnv, err := pierfileEnv(pf, projectName, cfg.TLD, sharedServices, nil)
+	if err != nil {
+		return err
+	}
+
 	// Step 4: Build the app
 	port := 0
 	if pf != nil && pf.Port > 0 {
 		port = pf.Port
 	}
 
-	step(3, "Building application...")
-	dockerfile := filepath.Join(dir, "Dockerfile")
-	if _, err := os.Stat(dockerfile); os.IsNotExist(err) {
-		// No Dockerfile — detect framework and generate one
-		fw, fwErr := detect.DetectFramework(dir)
-		if fwErr != nil {
-			return fmt.Errorf("no Dockerfile found and could not detect framework: %w", fwErr)
-		}
-		if port == 0 {
-			port = fw.Port
-		}
-
-		tmpl := detect.GenerateDockerfile(fw)
-		if tmpl == "" {
-			return fmt.Errorf("no Dockerfile template for framework: %s", fw.Name)
-		}
-
-		// Write to .pier/Dockerfile (don't touch project root)
-		pierDir := filepath.Join(dir, ".pier")
-		if err := os.MkdirAll(pierDir, 0755); err != nil {
-			return fmt.Errorf("creating .pier directory: %w", err)
-		}
-		genDockerfile := filepath.Join(pierDir, "Dockerfile")
-		if err := os.WriteFile(genDockerfile, []byte(tmpl), 0644); err != nil {
-			return fmt.Errorf("writing generated Dockerfile: %w", err)
-		}
-		info(fmt.Sprintf("Generated Dockerfile for %s → .pier/Dockerfile", cyan(fw.Name)))
-
-		// Build from generated Dockerfile with project dir as context
-		buildCmd := exec.Command("docker", "build", "-t", projectName, "-f", genDockerfile, ".")
-		buildCmd.Dir = dir
-		buildCmd.Stdout = os.Stdout
-		buildCmd.Stderr = os.Stderr
-		if err := buildCmd.Run(); err != nil {
-			return fmt.Errorf("docker build failed: %w", err)
-		}
+	var dev *devApp
+	if upDev {
+		step(3, "Preparing dev container...")
+		dev, port, err = prepareDev(dir, projectName, port)
 	} else {
-		// Dockerfile exists — detect port from framework if not set
-		if port == 0 {
-			if fw, fwErr := detect.DetectFramework(dir); fwErr == nil {
-				port = fw.Port
-			}
-		}
-
-		buildCmd := exec.Command("docker", "build", "-t", projectName, ".")
-		buildCmd.Dir = dir
-		buildCmd.Stdout = os.Stdout
-		buildCmd.Stderr = os.Stderr
-		if err := buildCmd.Run(); err != nil {
-			return fmt.Errorf("docker build failed: %w", err)
-		}
+		step(3, "Building application...")
+		port, err = buildApp(ctx, dir, projectName, pf, port)
+	}
+	if err != nil {
+		return err
 	}
-	success("Image built")
 
-	// Step 5: Stop old container if running
-	_ = docker.StopAndRemoveContainer(cmd.Context(), projectName)
+	// Step 5: Retire a container from before the pier-app-* names
+	container := docker.AppContainerName(projectName, "")
+	retireLegacyApp(ctx, projectName, projectName)
 
-	// Step 6: Run the app container
+	// Step 6: Run the app container; a running one serves until it's ready
 	step(4, fmt.Sprintf("Starting %s...", cyan(projectName)))
 
-	envOverrides := runtime.BuildEnvOverrides(sharedServices)
-
-	dockerArgs := []string{"run", "-d", "--name", projectName, "--network", cfg.Network, "--restart", "unless-stopped"}
+	envOverrides := runtime.BuildEnvOverrides(infra.WithTelemetry(sharedServices), envOptions(dir, projectName, pf))
 
-	// Env overrides from shared services
-	for _, e := range envOverrides {
-		dockerArgs = append(dockerArgs, "-e", e)
+	env := append([]string{}, envOverrides...)
+	for k, v := range extraEnv {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
 	}
-
-	// Inject DATABASE_NAME
-	dockerArgs = append(dockerArgs, "-e", fmt.Sprintf("DATABASE_NAME=%s", strings.ReplaceAll(projectName, "-", "_")))
-	dockerArgs = append(dockerArgs, "-e", fmt.Sprintf("DB_DATABASE=%s", strings.ReplaceAll(projectName, "-", "_")))
-
-	// Pierfile extra env
-	if pf != nil {
-		for k, v := range pf.Env {
-			dockerArgs = append(dockerArgs, "-e", fmt.Sprintf("%s=%s", k, v))
-		}
+	spec := docker.RunSpec{
+		Name:        container,
+		Image:       projectName,
+		Network:     cfg.Network,
+		Restart:     "unless-stopped",
+		Env:         env,
+		Owner:       appOwner(projectName, projectName),
+		Aliases:     []string{projectName},
+		Healthcheck: appHealthcheck(pf),
 	}
-
-	// Traefik labels
-	dockerArgs = append(dockerArgs,
-		"-l", "traefik.enable=true",
-		"-l", fmt.Sprintf("traefik.http.routers.%s.rule=Host(`%s.%s`)", projectName, projectName, cfg.TLD),
-	)
-	if port > 0 {
-		dockerArgs = append(dockerArgs,
-			"-l", fmt.Sprintf("traefik.http.services.%s.loadbalancer.server.port=%d", projectName, port),
-		)
+	dev.apply(&spec)
+	deploy, err := startApp(ctx, cfg, spec, projectName, port, pf.ReplicaCount(), pf)
+	if err != nil {
+		return fmt.Errorf("docker run failed: %w", err)
 	}
 
-	dockerArgs = append(dockerArgs, projectName)
+	// Register in project registry
+	_ = registry.Register(registry.Project{Name: projectName, Dir: dir, Port: port, Type: "docker"})
 
-	dockerCmd := exec.Command("docker", dockerArgs...)
-	out, err := dockerCmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("docker run failed: %s\n%s", err, string(out))
-	}
+	// Run migrations/seeds on a fresh database (or with --migrate), in the
+	// new version before it takes traffic
+	runDBHooks(dir, projectName, deploy.hookContainer(), pf, dbCreated)
 
-	// Step 7: Create Traefik route (file proxy as backup)
-	if port > 0 {
-		_ = createContainerProxy(projectName, port, cfg.TLD)
+	// Step 7: Many apps only turn healthy once migrated, so wait after the
+	// hooks, then move the route over
+	if err := finishApp(ctx, cfg, deploy); err != nil {
+		return err
 	}
+	domain := deploy.domain(cfg.TLD)
 
 	// Step 8: Print result
 	fmt.Println()
-	domain := fmt.Sprintf("%s.%s", projectName, cfg.TLD)
 	fmt.Printf("  %s %s\n", green("✅"), bold(domain))
 	fmt.Println()
 
@@ -268,50 +238,45 @@ func runUp(cmd *cobra.Command, args []string) error {
 
 // runUpCompose handles docker-compose.yml projects
 func runUpCompose(ctx context.Context, dir, projectName string, cf *compose.ComposeFile, cfg *config.Config) error {
-	infraSvcs, appSvcs := compose.SeparateServices(cf)
+	profiles := upProfiles
+	if len(profiles) == 0 && os.Getenv("COMPOSE_PROFILES") != "" {
+		profiles = strings.Split(os.Getenv("COMPOSE_PROFILES"), ",")
+	}
+	infraSvcs, appSvcs := compose.SeparateServices(cf, profiles...)
+
+	var pf *pierfile.Pierfile
+	if pierfile.Exists(dir) {
+		pf, _ = pierfile.Load(dir)
+	}
 
 	// Ensure shared infra
 	var sharedServices []infra.SharedService
 	var dbCreated bool
 	if len(infraSvcs) > 0 {
 		step(2, "Starting shared infrastructure (from compose)...")
+		var reqs []infraRequest
 		for _, is := range infraSvcs {
 			version := is.Version
 			if version == "" {
 				version = defaultVersion(is.Name)
 			}
-
-			fmt.Printf("    → %s:%s (replaces compose '%s') ", cyan(is.Name), version, is.ComposeName)
-			if err := infra.EnsureService(is.Name, version); err != nil {
-				fmt.Println(red("✗"))
-				return fmt.Errorf("starting %s: %w", is.Name, err)
-			}
-			fmt.Println(green("✓"))
-
-			svc, _ := infra.ResolveService(is.Name, version)
-			if svc != nil {
-				sharedServices = append(sharedServices, *svc)
-			}
-
-			if is.Name == "postgres" || is.Name == "mysql" {
-				if err := infra.CreateDatabase(is.Name, version, projectName); err != nil {
-					warn(fmt.Sprintf("Could not create database: %s", err))
-				} else {
-					dbCreated = true
-				}
-			}
+			reqs = append(reqs, infraRequest{
+				Name:    is.Name,
+				Version: version,
+				Note:    fmt.Sprintf("(replaces compose '%s')", is.ComposeName),
+			})
+		}
+		var err error
+		sharedServices, dbCreated, err = startInfra(ctx, reqs, projectName, pf)
+		if err != nil {
+			return err
 		}
 	}
 
-	envOverrides := runtime.BuildEnvOverrides(sharedServices)
+	envOverrides := runtime.BuildEnvOverrides(infra.WithTelemetry(sharedServices), envOptions(dir, projectName, pf))
 
 	// Generate .pier/env from project .env + pier overrides
-	dbName := strings.ReplaceAll(projectName, "-", "_")
-	allOverrides := append(envOverrides,
-		fmt.Sprintf("DATABASE_NAME=%s", dbName),
-		fmt.Sprintf("DB_DATABASE=%s", dbName),
-	)
-	pierEnvFile, envErr := runtime.GenerateEnvFile(dir, allOverrides)
+	pierEnvFile, envErr := runtime.GenerateEnvFile(dir, envOverrides)
 	if envErr != nil {
 		warn(fmt.Sprintf("Could not generate .pier/env: %s", envErr))
 	}
@@ -321,142 +286,108 @@ func runUpCompose(ctx context.Context, dir, projectName string, cf *compose.Comp
 		return runUpBuild(ctx, dir, projectName, cfg, sharedServices, dbCreated)
 	}
 
-	// Build and run app services
+	if upDev {
+		warn("--dev doesn't apply to compose app services yet; building them as usual")
+	}
+
+	// Build and run app services, in dependency order
+	hookContainer := ""
+	var deploys []*appDeploy
+	byService := map[string]*appDeploy{}
+	finished := map[*appDeploy]bool{}
 	for i, app := range appSvcs {
 		appName := projectName
 		if len(appSvcs) > 1 {
 			appName = app.ComposeName
 		}
+		for _, w := range app.Unsupported {
+			warn(fmt.Sprintf("compose %s: %s", app.ComposeName, w))
+		}
+
+		// service_healthy dependencies must be ready before this one starts;
+		// service_completed_successfully ones have already run
+		for _, dep := range slices.Sorted(maps.Keys(app.DependsOn)) {
+			if d := byService[dep]; d != nil && app.DependsOn[dep].Condition == compose.ServiceHealthy && !finished[d] {
+				if err := finishApp(ctx, cfg, d); err != nil {
+					abandonApps(ctx, cfg, deploys)
+					return err
+				}
+				finished[d] = true
+			}
+		}
 
 		step(3+i, fmt.Sprintf("Building %s...", cyan(appName)))
 
 		// Build
-		if app.Build != "" {
-			buildCtx := app.Build
-			if !filepath.IsAbs(buildCtx) {
-				buildCtx = filepath.Join(dir, buildCtx)
-			}
-			buildCmd := exec.Command("docker", "build", "-t", appName, buildCtx)
-			buildCmd.Stdout = os.Stdout
-			buildCmd.Stderr = os.Stderr
-			if err := buildCmd.Run(); err != nil {
+		if app.Build != nil {
+			if err := buildImage(ctx, docker.NewBuildSpec(dir, appName, app.Build)); err != nil {
+				abandonApps(ctx, cfg, deploys)
 				return fmt.Errorf("building %s: %w", appName, err)
 			}
 		}
 
-		image := appName
-		if app.Build == "" && app.Image != "" {
-			image = app.Image
-		}
-
-		// Stop old
-		_ = docker.StopAndRemoveContainer(ctx, appName)
-
-		// Determine port
-		port := parseFirstPort(app.Ports)
-
-		// Run
-		dockerArgs := []string{"run", "-d", "--name", appName, "--network", cfg.Network, "--restart", "unless-stopped"}
-
-		// For built apps: use .pier/env file (clean, no baked-in env)
-		// For sidecars (image-only): pass env vars individually
-		if app.Build != "" && pierEnvFile != "" {
-			dockerArgs = append(dockerArgs, "--env-file", pierEnvFile)
-		}
-
-		// Compose environment vars (lower priority)
-		for k, v := range app.Environment {
-			dockerArgs = append(dockerArgs, "-e", fmt.Sprintf("%s=%s", k, v))
-		}
+		retireLegacyApp(ctx, projectName, appName)
 
-		// Pier env overrides (highest priority)
-		for _, e := range envOverrides {
-			dockerArgs = append(dockerArgs, "-e", e)
+		// The Pierfile healthcheck describes the project's own (built) app
+		var appPf *pierfile.Pierfile
+		if app.Build != nil {
+			appPf = pf
 		}
-		dockerArgs = append(dockerArgs, "-e", fmt.Sprintf("DATABASE_NAME=%s", strings.ReplaceAll(projectName, "-", "_")))
-		dockerArgs = append(dockerArgs, "-e", fmt.Sprintf("DB_DATABASE=%s", strings.ReplaceAll(projectName, "-", "_")))
 
-		// Traefik labels
-		dockerArgs = append(dockerArgs,
-			"-l", "traefik.enable=true",
-			"-l", fmt.Sprintf("traefik.http.routers.%s.rule=Host(`%s.%s`)", appName, appName, cfg.TLD),
-		)
-		if port > 0 {
-			dockerArgs = append(dockerArgs,
-				"-l", fmt.Sprintf("traefik.http.services.%s.loadbalancer.server.port=%d", appName, port),
-			)
+		spec, err := composeAppSpec(dir, projectName, appName, app, cfg, pierEnvFile, envOverrides, appPf)
+		if err != nil {
+			abandonApps(ctx, cfg, deploys)
+			return err
 		}
-
-		// Volumes (resolve relative paths against project dir)
-		for _, v := range app.Volumes {
-			// Only pass bind mounts (contain :), skip named volumes
-			if strings.Contains(v, ":") {
-				parts := strings.SplitN(v, ":", 3)
-				hostPath := parts[0]
-				if !filepath.IsAbs(hostPath) {
-					hostPath = filepath.Join(dir, hostPath)
-				}
-				mount := hostPath + ":" + strings.Join(parts[1:], ":")
-				dockerArgs = append(dockerArgs, "-v", mount)
+		if app.Task {
+			if err := runTask(ctx, spec, appName); err != nil {
+				abandonApps(ctx, cfg, deploys)
+				return err
 			}
+			continue
 		}
 
-		// Entrypoint override (must be before IMAGE in docker run)
-		if app.Entrypoint != nil {
-			switch ep := app.Entrypoint.(type) {
-			case string:
-				dockerArgs = append(dockerArgs, "--entrypoint", ep)
-			case []interface{}:
-				if len(ep) > 0 {
-					dockerArgs = append(dockerArgs, "--entrypoint", fmt.Sprintf("%v", ep[0]))
-				}
-			}
+		deploy, err := startApp(ctx, cfg, spec, appName, app.ContainerPort(), 1, appPf)
+		if err != nil {
+			abandonApps(ctx, cfg, deploys)
+			return fmt.Errorf("running %s: %w", appName, err)
 		}
+		deploys = append(deploys, deploy)
+		byService[app.ComposeName] = deploy
 
-		dockerArgs = append(dockerArgs, image)
-
-		// Entrypoint args (remaining elements after first)
-		if app.Entrypoint != nil {
-			if ep, ok := app.Entrypoint.([]interface{}); ok && len(ep) > 1 {
-				for _, e := range ep[1:] {
-					dockerArgs = append(dockerArgs, fmt.Sprintf("%v", e))
-				}
-			}
+		// Migrations run in the first built app service
+		if hookContainer == "" && app.Build != nil {
+			hookContainer = deploy.hookContainer()
 		}
+	}
 
-		// Command override (goes after IMAGE)
-		if app.Command != nil {
-			switch cmd := app.Command.(type) {
-			case string:
-				dockerArgs = append(dockerArgs, cmd)
-			case []interface{}:
-				for _, c := range cmd {
-					dockerArgs = append(dockerArgs, fmt.Sprintf("%v", c))
-				}
-			}
+	// Register in project registry
+	var containers []string
+	if len(appSvcs) > 1 {
+		for _, app := range appSvcs {
+			containers = append(containers, docker.AppContainerName(projectName, app.ComposeName))
 		}
+	}
+	_ = registry.Register(registry.Project{Name: projectName, Dir: dir, Type: "docker", Containers: containers})
 
-		dockerCmd := exec.Command("docker", dockerArgs...)
-		out, err := dockerCmd.CombinedOutput()
-		if err != nil {
-			return fmt.Errorf("running %s: %s\n%s", appName, err, string(out))
-		}
+	if hookContainer != "" {
+		runDBHooks(dir, projectName, hookContainer, pf, dbCreated)
+	}
 
-		// File proxy backup
-		if port > 0 {
-			_ = createContainerProxy(appName, port, cfg.TLD)
+	for _, d := range deploys {
+		if finished[d] {
+			continue
+		}
+		if err := finishApp(ctx, cfg, d); err != nil {
+			abandonApps(ctx, cfg, deploys)
+			return err
 		}
 	}
 
 	// Print result
 	fmt.Println()
-	for _, app := range appSvcs {
-		appName := projectName
-		if len(appSvcs) > 1 {
-			appName = app.ComposeName
-		}
-		domain := fmt.Sprintf("%s.%s", appName, cfg.TLD)
-		fmt.Printf("  %s %s\n", green("✅"), bold(domain))
+	for _, d := range deploys {
+		fmt.Printf("  %s %s\n", green("✅"), bold(d.domain(cfg.TLD)))
 	}
 	fmt.Println()
 
@@ -472,12 +403,79 @@ func runUpCompose(ctx context.Context, dir, projectName string, cf *compose.Comp
 		fmt.Printf("  Database: %s (auto-created)\n", projectName)
 		fmt.Println()
 	}
-	// Register in project registry
-	_ = registry.Register(registry.Project{Name: projectName, Dir: dir, Type: "docker"})
 
 	return nil
 }
 
+// composeAppSpec is how a compose app service runs. Env precedence:
+// .pier/env (built apps only; sidecars get their compose env alone), then
+// env_file, then environment, then pier overrides.
+func composeAppSpec(dir, projectName, appName string, app compose.AppService, cfg *config.Config, pierEnvFile string, envOverrides []string, appPf *pierfile.Pierfile) (docker.RunSpec, error) {
+	var env []string
+	if app.Build != nil && pierEnvFile != "" {
+		fileEnv, err := docker.ReadEnvFile(pierEnvFile)
+		if err != nil {
+			return docker.RunSpec{}, fmt.Errorf("reading %s: %w", pierEnvFile, err)
+		}
+		env = append(env, fileEnv...)
+	}
+	fileEnv, err := app.FileEnv(dir)
+	if err != nil {
+		return docker.RunSpec{}, fmt.Errorf("%s %w", app.ComposeName, err)
+	}
+	env = append(env, fileEnv...)
+	for k, v := range app.Environment {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+	env = append(env, envOverrides...)
+
+	image := appName
+	if app.Build == nil && app.Image != "" {
+		image = app.Image
+	}
+	restart := app.Restart
+	if restart == "" {
+		restart = "unless-stopped"
+		if app.Task {
+			restart = "no"
+		}
+	}
+	// pier.* labels are Pier's to set
+	labels := map[string]string{}
+	for k, v := range app.Labels {
+		if !strings.HasPrefix(k, "pier.") {
+			labels[k] = v
+		}
+	}
+	healthcheck := appHealthcheck(appPf)
+	if hc := app.Healthcheck; hc != nil {
+		healthcheck = &docker.HealthConfig{
+			Test:        hc.Test,
+			Interval:    hc.Interval,
+			Timeout:     hc.Timeout,
+			StartPeriod: hc.StartPeriod,
+			Retries:     hc.Retries,
+		}
+	}
+
+	return docker.RunSpec{
+		Name:        docker.AppContainerName(projectName, appName),
+		Image:       image,
+		Network:     cfg.Network,
+		Restart:     restart,
+		Env:         env,
+		Labels:      labels,
+		Owner:       appOwner(projectName, appName),
+		Aliases:     append([]string{appName}, app.Aliases...),
+		Binds:       app.Binds(dir, projectName),
+		Entrypoint:  app.Entrypoint,
+		Cmd:         app.Command,
+		WorkingDir:  app.WorkingDir,
+		User:        app.User,
+		Healthcheck: healthcheck,
+	}, nil
+}
+
 // runUpBuild handles the build+run path when compose has no app services
 func runUpBuild(ctx context.Context, dir, projectName string, cfg *config.Config, sharedServices []infra.SharedService, dbCreated bool) error {
 	var pf *pierfile.Pierfile
@@ -485,92 +483,64 @@ func runUpBuild(ctx context.Context, dir, projectName string, cfg *config.Config
 		pf, _ = pierfile.Load(dir)
 	}
 
+	extraEnv, err := pierfileEnv(pf, projectName, cfg.TLD, sharedServices, nil)
+	if err != nil {
+		return err
+	}
+
 	port := 0
 	if pf != nil && pf.Port > 0 {
 		port = pf.Port
 	}
 
-	step(3, "Building application...")
-	dockerfile := filepath.Join(dir, "Dockerfile")
-	if _, err := os.Stat(dockerfile); os.IsNotExist(err) {
-		fw, fwErr := detect.DetectFramework(dir)
-		if fwErr != nil {
-			return fmt.Errorf("no Dockerfile found and could not detect framework: %w", fwErr)
-		}
-		if port == 0 {
-			port = fw.Port
-		}
-		tmpl := detect.GenerateDockerfile(fw)
-		if tmpl == "" {
-			return fmt.Errorf("no Dockerfile template for framework: %s", fw.Name)
-		}
-		pierDir := filepath.Join(dir, ".pier")
-		_ = os.MkdirAll(pierDir, 0755)
-		genDockerfile := filepath.Join(pierDir, "Dockerfile")
-		_ = os.WriteFile(genDockerfile, []byte(tmpl), 0644)
-		info(fmt.Sprintf("Generated Dockerfile for %s → .pier/Dockerfile", cyan(fw.Name)))
-
-		buildCmd := exec.Command("docker", "build", "-t", projectName, "-f", genDockerfile, ".")
-		buildCmd.Dir = dir
-		buildCmd.Stdout = os.Stdout
-		buildCmd.Stderr = os.Stderr
-		if err := buildCmd.Run(); err != nil {
-			return fmt.Errorf("docker build failed: %w", err)
-		}
+	var dev *devApp
+	if upDev {
+		step(3, "Preparing dev container...")
+		dev, port, err = prepareDev(dir, projectName, port)
 	} else {
-		if port == 0 {
-			if fw, fwErr := detect.DetectFramework(dir); fwErr == nil {
-				port = fw.Port
-			}
-		}
-		buildCmd := exec.Command("docker", "build", "-t", projectName, ".")
-		buildCmd.Dir = dir
-		buildCmd.Stdout = os.Stdout
-		buildCmd.Stderr = os.Stderr
-		if err := buildCmd.Run(); err != nil {
-			return fmt.Errorf("docker build failed: %w", err)
-		}
+		step(3, "Building application...")
+		port, err = buildApp(ctx, dir, projectName, pf, port)
+	}
+	if err != nil {
+		return err
 	}
-	success("Image built")
 
-	_ = docker.StopAndRemoveContainer(ctx, projectName)
+	container := docker.AppContainerName(projectName, "")
+	retireLegacyApp(ctx, projectName, projectName)
 
 	step(4, fmt.Sprintf("Starting %s...", cyan(projectName)))
-	envOverrides := runtime.BuildEnvOverrides(sharedServices)
-	dockerArgs := []string{"run", "-d", "--name", projectName, "--network", cfg.Network, "--restart", "unless-stopped"}
-	for _, e := range envOverrides {
-		dockerArgs = append(dockerArgs, "-e", e)
-	}
-	dockerArgs = append(dockerArgs, "-e", fmt.Sprintf("DATABASE_NAME=%s", strings.ReplaceAll(projectName, "-", "_")))
-	dockerArgs = append(dockerArgs, "-e", fmt.Sprintf("DB_DATABASE=%s", strings.ReplaceAll(projectName, "-", "_")))
-	if pf != nil {
-		for k, v := range pf.Env {
-			dockerArgs = append(dockerArgs, "-e", fmt.Sprintf("%s=%s", k, v))
-		}
-	}
-	dockerArgs = append(dockerArgs,
-		"-l", "traefik.enable=true",
-		"-l", fmt.Sprintf("traefik.http.routers.%s.rule=Host(`%s.%s`)", projectName, projectName, cfg.TLD),
-	)
-	if port > 0 {
-		dockerArgs = append(dockerArgs,
-			"-l", fmt.Sprintf("traefik.http.services.%s.loadbalancer.server.port=%d", projectName, port),
-		)
-	}
-	dockerArgs = append(dockerArgs, projectName)
-
-	dockerCmd := exec.Command("docker", dockerArgs...)
-	out, err := dockerCmd.CombinedOutput()
+	envOverrides := runtime.BuildEnvOverrides(infra.WithTelemetry(sharedServices), envOptions(dir, projectName, pf))
+	env := append([]string{}, envOverrides...)
+	for k, v := range extraEnv {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+	spec := docker.RunSpec{
+		Name:        container,
+		Image:       projectName,
+		Network:     cfg.Network,
+		Restart:     "unless-stopped",
+		Env:         env,
+		Owner:       appOwner(projectName, projectName),
+		Aliases:     []string{projectName},
+		Healthcheck: appHealthcheck(pf),
+	}
+	dev.apply(&spec)
+	deploy, err := startApp(ctx, cfg, spec, projectName, port, pf.ReplicaCount(), pf)
 	if err != nil {
-		return fmt.Errorf("docker run failed: %s\n%s", err, string(out))
+		return fmt.Errorf("docker run failed: %w", err)
 	}
 
-	if port > 0 {
-		_ = createContainerProxy(projectName, port, cfg.TLD)
+	// Register in project registry
+	_ = registry.Register(registry.Project{Name: projectName, Dir: dir, Type: "docker"})
+
+	runDBHooks(dir, projectName, deploy.hookContainer(), pf, dbCreated)
+
+	if err := finishApp(ctx, cfg, deploy); err != nil {
+		return err
 	}
+	domain := deploy.domain(cfg.TLD)
 
 	fmt.Println()
-	domain := fmt.Sprintf("%s.%s", projectName, cfg.TLD)
 	fmt.Printf("  %s %s\n", green("✅"), bold(domain))
 	fmt.Println()
 	if len(sharedServices) > 0 {
@@ -584,16 +554,185 @@ func runUpBuild(ctx context.Context, dir, projectName string, cfg *config.Config
 		fmt.Printf("  Database: %s (auto-created)\n", projectName)
 		fmt.Println()
 	}
-	// Register in project registry
-	_ = registry.Register(registry.Project{Name: projectName, Dir: dir, Type: "docker"})
 
 	return nil
 }
 
+// infraRequest is a shared service to start for a project
+type infraRequest struct {
+	Name    string
+	Version string
+	Note    string // printed next to the service, e.g. the compose service it replaces
+}
+
+// startInfra ensures each shared service is running, creates the project
+// database in postgres/mysql, provisions the project's slice of redis,
+// minio and mongo, and registers its users and clients with auth. Reports
+// whether a database was newly created.
+func startInfra(ctx context.Context, reqs []infraRequest, projectName string, pf *pierfile.Pierfile) ([]infra.SharedService, bool, error) {
+	var shared []infra.SharedService
+	var dbCreated bool
+
+	for _, r := range reqs {
+		label := fmt.Sprintf("%s:%s", cyan(r.Name), r.Version)
+		if r.Note != "" {
+			label += " " + r.Note
+		}
+
+		// Auth users and clients must be on disk before the provider starts
+		if r.Name == "auth" {
+			if err := infra.ProvisionAuth(r.Version, projectName, authConfig(pf)); err != nil {
+				warn(fmt.Sprintf("Could not register %s with auth: %s", projectName, err))
+			}
+		}
+
+		fmt.Printf("    → %s ", label)
+		err := traced(ctx, "infra.start "+r.Name+":"+r.Version, projectName, func() error {
+			return infra.EnsureService(r.Name, r.Version)
+		})
+		if err != nil {
+			fmt.Println(red("✗"))
+			return nil, false, fmt.Errorf("starting %s:%s: %w", r.Name, r.Version, err)
+		}
+		fmt.Println(green("✓"))
+
+		svc, _ := infra.ResolveService(r.Name, r.Version)
+		if svc != nil {
+			shared = append(shared, *svc)
+		}
+
+		// Auto-create database for postgres/mysql
+		if r.Name == "postgres" || r.Name == "mysql" {
+			if err := infra.WaitReady(r.Name, r.Version, 60*time.Second); err != nil {
+				warn(fmt.Sprintf("Could not create database: %s", err))
+				continue
+			}
+			created, err := infra.CreateDatabase(r.Name, r.Version, projectName)
+			if err != nil {
+				warn(fmt.Sprintf("Could not create database: %s", err))
+			} else if created {
+				dbCreated = true
+			}
+		}
+
+		if infra.IsolatesProjects(r.Name) {
+			if err := infra.ProvisionProject(r.Name, r.Version, projectName); err != nil {
+				warn(fmt.Sprintf("Could not provision %s for %s: %s", r.Name, projectName, err))
+			}
+		}
+	}
+
+	recordServiceUsage(projectName, shared)
+
+	return shared, dbCreated, nil
+}
+
+// authConfig returns the Pierfile auth block, if any
+func authConfig(pf *pierfile.Pierfile) *pierfile.AuthConfig {
+	if pf == nil {
+		return nil
+	}
+	return pf.Auth
+}
+
+// envOptions collects what shapes a project's injected env: its name, the
+// detected framework and the Pierfile env_map
+func envOptions(dir, projectName string, pf *pierfile.Pierfile) runtime.EnvOptions {
+	opts := runtime.EnvOptions{Project: projectName}
+	if fw, err := detect.DetectFramework(dir); err == nil {
+		opts.Framework = fw.Name
+	}
+	if pf != nil {
+		opts.EnvMap = pf.EnvMap
+	}
+	return opts
+}
+
+// pierfileEnv renders the Pierfile env templates against the project's
+// shared services, the registry and the TLD
+func pierfileEnv(pf *pierfile.Pierfile, projectName, tld string, shared []infra.SharedService, hostPorts map[string]int) (map[string]string, error) {
+	if pf == nil {
+		return nil, nil
+	}
+	env, err := runtime.RenderEnv(pf.Env, runtime.TemplateData{Project: projectName, TLD: tld, Services: shared, HostPorts: hostPorts})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", pierfile.FileName, err)
+	}
+	return env, nil
+}
+
+// recordServiceUsage remembers which shared services a project depends on
+func recordServiceUsage(projectName string, shared []infra.SharedService) {
+	specs := make([]string, len(shared))
+	for i, svc := range shared {
+		specs[i] = svc.Spec()
+	}
+	_ = registry.RecordServices(projectName, specs)
+}
+
+// runDBHooks runs the project's migrate and seed commands inside the app
+// container when its database was just created, or when --migrate is set.
+func runDBHooks(dir, projectName, container string, pf *pierfile.Pierfile, fresh bool) {
+	if !fresh && !upMigrate {
+		return
+	}
+
+	hooks := resolveDBHooks(dir, pf)
+	if hooks == nil {
+		if upMigrate {
+			warn("No migrate/seed commands found. Add a db block to the Pierfile.")
+		}
+		return
+	}
+
+	step(5, "Preparing database...")
+	run := registry.MigrationRun{Migrate: hooks.Migrate, Seed: hooks.Seed, Status: "ok"}
+	for _, c := range []string{hooks.Migrate, hooks.Seed} {
+		if c == "" {
+			continue
+		}
+		info(fmt.Sprintf("Running %s", dim(c)))
+		err := docker.ExecStream(context.Background(), container, []string{"sh", "-c", c}, docker.ExecOptions{Stdout: os.Stdout, Stderr: os.Stderr})
+		if err != nil {
+			warn(fmt.Sprintf("%s failed: %s", c, err))
+			run.Status = "failed"
+			break
+		}
+	}
+
+	_ = registry.RecordMigration(projectName, run)
+	if run.Status == "ok" {
+		success("Database ready")
+	} else {
+		info(fmt.Sprintf("Fix the error and retry with %s", cyan("pier up --migrate")))
+	}
+}
+
+// resolveDBHooks merges Pierfile db commands over detected tooling
+func resolveDBHooks(dir string, pf *pierfile.Pierfile) *pierfile.DBHooks {
+	hooks := &pierfile.DBHooks{}
+	if detected := detect.DetectDBCommands(dir); detected != nil {
+		hooks.Migrate = detected.Migrate
+		hooks.Seed = detected.Seed
+	}
+	if pf != nil && pf.DB != nil {
+		if pf.DB.Migrate != "" {
+			hooks.Migrate = pf.DB.Migrate
+		}
+		if pf.DB.Seed != "" {
+			hooks.Seed = pf.DB.Seed
+		}
+	}
+	if hooks.Migrate == "" && hooks.Seed == "" {
+		return nil
+	}
+	return hooks
+}
+
 func defaultVersion(name string) string {
 	defaults := map[string]string{
 		"postgres": "16", "redis": "7", "mongo": "7", "mysql": "8",
-		"minio": "latest", "kafka": "latest", "rabbitmq": "3", "elasticsearch": "8",
+		"minio": "latest", "mail": "latest", "auth": "latest", "telemetry": "latest", "kafka": "latest", "rabbitmq": "3", "elasticsearch": "8",
 	}
 	if v, ok := defaults[name]; ok {
 		return v
@@ -601,14 +740,98 @@ func defaultVersion(name string) string {
 	return "latest"
 }
 
-func parseFirstPort(ports []string) int {
-	if len(ports) == 0 {
-		return 0
+// buildApp builds the project's image, generating .pier/Dockerfile from the
+// detected framework when there is no Dockerfile. A zero port is filled in
+// from the framework.
+func buildApp(ctx context.Context, dir, projectName string, pf *pierfile.Pierfile, port int) (int, error) {
+	spec := docker.NewBuildSpec(dir, projectName, pierfileBuild(pf))
+	dockerfile := spec.Dockerfile
+	if dockerfile == "" {
+		dockerfile = filepath.Join(spec.Context, "Dockerfile")
+	}
+	if _, err := os.Stat(dockerfile); os.IsNotExist(err) {
+		// No Dockerfile — detect framework and generate one
+		fw, fwErr := detect.DetectFramework(dir)
+		if fwErr != nil {
+			return 0, fmt.Errorf("no Dockerfile found and could not detect framework: %w", fwErr)
+		}
+		if port == 0 {
+			port = fw.Port
+		}
+
+		tmpl := detect.GenerateDockerfile(fw)
+		if tmpl == "" {
+			return 0, fmt.Errorf("no Dockerfile template for framework: %s", fw.Name)
+		}
+
+		// Write to .pier/Dockerfile (don't touch project root)
+		pierDir := filepath.Join(dir, ".pier")
+		if err := os.MkdirAll(pierDir, 0755); err != nil {
+			return 0, fmt.Errorf("creating .pier directory: %w", err)
+		}
+		genDockerfile := filepath.Join(pierDir, "Dockerfile")
+		if err := os.WriteFile(genDockerfile, []byte(tmpl), 0644); err != nil {
+			return 0, fmt.Errorf("writing generated Dockerfile: %w", err)
+		}
+		info(fmt.Sprintf("Generated Dockerfile for %s → .pier/Dockerfile", cyan(fw.Name)))
+
+		// Build from generated Dockerfile with project dir as context
+		spec.Dockerfile = genDockerfile
+	} else if port == 0 {
+		// Dockerfile exists — detect port from framework if not set
+		if fw, fwErr := detect.DetectFramework(dir); fwErr == nil {
+			port = fw.Port
+		}
+	}
+	if err := buildImage(ctx, spec); err != nil {
+		return 0, fmt.Errorf("docker build failed: %w", err)
+	}
+	return port, nil
+}
+
+// pierfileBuild returns the Pierfile's build options, if any
+func pierfileBuild(pf *pierfile.Pierfile) *buildspec.Spec {
+	if pf == nil {
+		return nil
+	}
+	return pf.Build
+}
+
+// buildImage builds an image through the Docker API, streaming progress.
+// An image already built from the same sources and options is reused
+// unless --build is given.
+func buildImage(ctx context.Context, spec docker.BuildSpec) error {
+	upToDate, hash, err := docker.UpToDate(ctx, spec)
+	if err != nil {
+		warn(fmt.Sprintf("Could not fingerprint the build context: %s", err))
+	}
+	if upToDate && !upBuild {
+		success(fmt.Sprintf("Image %s is up to date %s", cyan(spec.Tag), dim("(--build to rebuild)")))
+		return nil
+	}
+	if hash != "" {
+		spec.Labels = map[string]string{docker.LabelContextHash: hash}
+	}
+	// The build takes the tag; keep the image it replaces for `pier rollback`
+	if err := docker.KeepPrevious(ctx, spec.Tag); err != nil {
+		warn(fmt.Sprintf("Could not keep the previous image: %s", err))
+	}
+	err = traced(ctx, "build", spec.Tag, func() error {
+		return docker.Build(ctx, spec, os.Stdout)
+	})
+	if err == nil {
+		success(fmt.Sprintf("Image %s built", cyan(spec.Tag)))
+	}
+	return err
+}
+
+// runContainer creates and starts a container through the Docker API
+func runContainer(ctx context.Context, spec docker.RunSpec) error {
+	if spec.PullOutput == nil {
+		spec.PullOutput = os.Stdout
 	}
-	p := ports[0]
-	p = strings.Split(p, "/")[0]
-	parts := strings.Split(p, ":")
-	var port int
-	fmt.Sscanf(parts[len(parts)-1], "%d", &port)
-	return port
+	return traced(ctx, "container.run", spec.Name, func() error {
+		_, err := docker.Run(ctx, spec)
+		return err
+	})
 }
@@ -0,0 +1,688 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/eshe-huli/pier/internal/config"
+	"github.com/eshe-huli/pier/internal/docker"
+	"github.com/eshe-huli/pier/internal/proxy"
+	"github.com/eshe-huli/pier/internal/registry"
+)
+
+// setupUp isolates HOME, leaves it and a fake runtime as `pier setup` would
+// (directories and the pier network), and chdirs into a project with files
+func setupUp(t *testing.T, files map[string]string) (*docker.Fake, string) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	if err := config.EnsureDirectories(); err != nil {
+		t.Fatal(err)
+	}
+
+	fake := docker.NewFake()
+	fake.Networks["pier"] = true
+	docker.SetRuntime(fake)
+	t.Cleanup(func() { docker.SetRuntime(nil) })
+
+	// There's no Traefik to probe through; apps answer as soon as they start
+	probe, loaded, settle := httpProbe, routeLoaded, readySettle
+	httpProbe = func(url, host string) func(context.Context) error {
+		return func(context.Context) error { return nil }
+	}
+	routeLoaded = func(int, string) error { return nil }
+	readySettle, routeGrace = time.Millisecond, 0
+	t.Cleanup(func() { httpProbe, routeLoaded, readySettle, routeGrace = probe, loaded, settle, time.Second })
+
+	dir := filepath.Join(t.TempDir(), "shop")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(dir)
+	return fake, dir
+}
+
+func runUpCmd(t *testing.T) error {
+	t.Helper()
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+	return runUp(cmd, nil)
+}
+
+// fileRoute returns the file provider route called name
+func fileRoute(t *testing.T, name string) proxy.FileProxy {
+	t.Helper()
+	routes, err := proxy.ListFileProxies("dock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range routes {
+		if r.Name == name {
+			return r
+		}
+	}
+	t.Fatalf("no route %s in %+v", name, routes)
+	return proxy.FileProxy{}
+}
+
+func hasEnv(env []string, prefix string) bool {
+	for _, e := range env {
+		if strings.HasPrefix(e, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRunUp_BuildsAndRoutesApp(t *testing.T) {
+	fake, dir := setupUp(t, map[string]string{
+		"Dockerfile": "FROM node:20\n",
+		"Pierfile":   "name: shop\nport: 3000\nservices:\n  - postgres:16\nenv:\n  GREETING: hi\n",
+	})
+
+	if err := runUpCmd(t); err != nil {
+		t.Fatalf("runUp: %v", err)
+	}
+
+	if len(fake.Builds) != 1 || fake.Builds[0].Tag != "shop" || fake.Builds[0].Context != dir {
+		t.Errorf("builds = %+v, want one build of shop from %s", fake.Builds, dir)
+	}
+
+	pg, ok := fake.Containers["pier-postgres-16"]
+	if !ok || !pg.Running {
+		t.Fatal("postgres was not started")
+	}
+	execs := strings.Join(fake.ExecCommands("pier-postgres-16"), "\n")
+	if !strings.Contains(execs, `CREATE DATABASE "shop"`) {
+		t.Errorf("database not created; execs:\n%s", execs)
+	}
+
+	app, ok := fake.Containers["pier-app-shop"]
+	if !ok || !app.Running {
+		t.Fatal("app container was not started")
+	}
+	if o, _ := docker.OwnerOf(app.Labels); o != (docker.Owner{Role: docker.RoleApp, Project: "shop", Service: "shop"}) {
+		t.Errorf("owner = %+v, want app shop/shop", o)
+	}
+	if o, _ := docker.OwnerOf(pg.Labels); o.Role != docker.RoleInfra || o.Service != "postgres" || o.Version != "16" {
+		t.Errorf("postgres owner = %+v, want infra postgres 16", o)
+	}
+	if !app.OnNetwork("pier") {
+		t.Errorf("app networks = %v, want pier", app.Networks)
+	}
+	// Routed by the file provider alone, so a redeploy can move the route
+	if _, ok := app.Labels["traefik.enable"]; ok {
+		t.Errorf("labels = %v, want no Traefik labels", app.Labels)
+	}
+	if r := fileRoute(t, "shop"); r.Container != "pier-app-shop" || r.ContainerPort != 3000 {
+		t.Errorf("route = %+v, want pier-app-shop:3000", r)
+	}
+	if !hasEnv(app.Env, "DATABASE_URL=postgres://") {
+		t.Errorf("DATABASE_URL missing from env %v", app.Env)
+	}
+	if !hasEnv(app.Env, "GREETING=hi") {
+		t.Errorf("Pierfile env missing from %v", app.Env)
+	}
+	if p, err := registry.Get("shop"); err != nil || p == nil || p.Dir != dir {
+		t.Errorf("registry entry = %+v, %v", p, err)
+	}
+}
+
+func TestRunUp_Dev(t *testing.T) {
+	fake, dir := setupUp(t, map[string]string{
+		"package.json": `{"dependencies":{"next":"14.0.0"}}`,
+		"Pierfile":     "name: shop\nservices:\n  - postgres:16\n",
+	})
+	upDev = true
+	timeout := upWaitTimeout
+	t.Cleanup(func() { upDev, upWaitTimeout = false, timeout })
+
+	if err := runUpCmd(t); err != nil {
+		t.Fatalf("runUp: %v", err)
+	}
+
+	if len(fake.Builds) != 0 {
+		t.Errorf("builds = %+v, want none under --dev", fake.Builds)
+	}
+	spec, ok := fake.Specs["pier-app-shop"]
+	if !ok {
+		t.Fatal("app container was not started")
+	}
+	if spec.Image != "node:22-alpine" || spec.WorkingDir != "/app" {
+		t.Errorf("image %q in %q, want node:22-alpine in /app", spec.Image, spec.WorkingDir)
+	}
+	if cmd := strings.Join(spec.Cmd, " "); !strings.Contains(cmd, "npm install && exec npx next dev -p 3000 -H 0.0.0.0") {
+		t.Errorf("cmd = %q, want install then the dev server", cmd)
+	}
+	wantBinds := []string{dir + ":/app", "pier-dev-shop-node_modules:/app/node_modules"}
+	if !slices.Equal(spec.Binds, wantBinds) {
+		t.Errorf("binds = %v, want %v", spec.Binds, wantBinds)
+	}
+	if !hasEnv(spec.Env, "DATABASE_URL=postgres://") || !hasEnv(spec.Env, "PORT=3000") {
+		t.Errorf("env = %v, want shared infra and PORT", spec.Env)
+	}
+	if r := fileRoute(t, "shop"); r.Container != "pier-app-shop" || r.ContainerPort != 3000 {
+		t.Errorf("route = %+v, want pier-app-shop:3000", r)
+	}
+}
+
+func TestRunUp_ReplacesRunningContainer(t *testing.T) {
+	fake, _ := setupUp(t, map[string]string{
+		"Dockerfile": "FROM node:20\n",
+		"Pierfile":   "name: shop\nport: 3000\n",
+	})
+
+	if err := runUpCmd(t); err != nil {
+		t.Fatalf("first up: %v", err)
+	}
+	first := fake.Containers["pier-app-shop"].ID
+	if err := os.WriteFile("index.js", []byte("console.log('v2')\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runUpCmd(t); err != nil {
+		t.Fatalf("second up: %v", err)
+	}
+	second, ok := fake.Containers["pier-app-shop"]
+	if !ok || !second.Running {
+		t.Fatal("app not running after second up")
+	}
+	if second.ID == first {
+		t.Error("second up reused the old container")
+	}
+	if len(fake.Builds) != 2 {
+		t.Errorf("builds = %d, want 2", len(fake.Builds))
+	}
+}
+
+func TestRunUp_BuildFailureStartsNothing(t *testing.T) {
+	fake, _ := setupUp(t, map[string]string{
+		"Dockerfile": "FROM node:20\nRUN false\n",
+		"Pierfile":   "name: shop\nport: 3000\n",
+	})
+	fake.BuildFunc = func(spec docker.BuildSpec) error {
+		return &docker.BuildError{Image: spec.Tag, Message: "RUN false: exit code 1"}
+	}
+
+	err := runUpCmd(t)
+	var buildErr *docker.BuildError
+	if !errors.As(err, &buildErr) {
+		t.Fatalf("err = %v, want a *docker.BuildError", err)
+	}
+	if _, ok := fake.Containers["pier-app-shop"]; ok {
+		t.Error("app container started despite failed build")
+	}
+}
+
+func TestRunUp_MigratesLegacyContainer(t *testing.T) {
+	fake, _ := setupUp(t, map[string]string{
+		"Dockerfile": "FROM node:20\n",
+		"Pierfile":   "name: shop\nport: 3000\n",
+	})
+	// What an earlier Pier left behind: the bare name, router labels only
+	fake.Images["shop"] = true
+	if _, err := docker.Run(context.Background(), docker.RunSpec{
+		Name: "shop", Image: "shop", Network: "pier",
+		Labels: map[string]string{"traefik.http.routers.shop.rule": "Host(`shop.dock`)"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runUpCmd(t); err != nil {
+		t.Fatalf("runUp: %v", err)
+	}
+	if _, ok := fake.Containers["shop"]; ok {
+		t.Error("legacy container was not removed")
+	}
+	if app, ok := fake.Containers["pier-app-shop"]; !ok || !app.Running {
+		t.Error("app not running under its namespaced name")
+	}
+}
+
+func TestRunUp_LeavesForeignContainerAlone(t *testing.T) {
+	fake, _ := setupUp(t, map[string]string{
+		"Dockerfile": "FROM node:20\n",
+		"Pierfile":   "name: redis\nport: 3000\n",
+	})
+	fake.Images["redis:7"] = true
+	if _, err := docker.Run(context.Background(), docker.RunSpec{Name: "redis", Image: "redis:7"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runUpCmd(t); err != nil {
+		t.Fatalf("runUp: %v", err)
+	}
+	if c, ok := fake.Containers["redis"]; !ok || !c.Running {
+		t.Error("a container Pier didn't create was removed")
+	}
+	if _, ok := fake.Containers["pier-app-redis"]; !ok {
+		t.Error("app not started alongside the unrelated redis container")
+	}
+}
+
+// captureStdout returns what fn prints
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	done := make(chan string)
+	go func() {
+		var b strings.Builder
+		_, _ = io.Copy(&b, r)
+		done <- b.String()
+	}()
+	fn()
+	w.Close()
+	return <-done
+}
+
+func TestRunUp_WaitsForHealthcheck(t *testing.T) {
+	fake, _ := setupUp(t, map[string]string{
+		"Dockerfile": "FROM node:20\n",
+		"Pierfile":   "name: shop\nport: 3000\nhealthcheck:\n  command: curl -fs localhost:3000/healthz\n  interval: 5s\n",
+	})
+	// The container is removed once it fails; keep its spec
+	var spec docker.RunSpec
+	fake.OnStart = func(c *docker.Container) {
+		c.Health = docker.HealthUnhealthy
+		spec = fake.Specs[c.Name]
+	}
+	upWaitTimeout = time.Second
+	t.Cleanup(func() { upWaitTimeout = time.Minute })
+
+	err := runUpCmd(t)
+	if err == nil || !strings.Contains(err.Error(), "did not become ready") {
+		t.Fatalf("err = %v, want a readiness failure", err)
+	}
+	if _, ok := fake.Containers["pier-app-shop"]; ok {
+		t.Error("a first deploy that never became ready left its container behind")
+	}
+	if proxy.FileProxyExists("shop") {
+		t.Error("a first deploy that never became ready left its route behind")
+	}
+
+	hc := spec.Healthcheck
+	if hc == nil || strings.Join(hc.Test, " ") != "CMD-SHELL curl -fs localhost:3000/healthz" || hc.Interval != 5*time.Second {
+		t.Errorf("healthcheck = %+v", hc)
+	}
+
+	fake.OnStart = func(c *docker.Container) { c.Health = docker.HealthHealthy }
+	if err := runUpCmd(t); err != nil {
+		t.Fatalf("healthy app: %v", err)
+	}
+}
+
+func TestRunUp_CrashPrintsLogs(t *testing.T) {
+	fake, _ := setupUp(t, map[string]string{
+		"Dockerfile": "FROM node:20\n",
+		"Pierfile":   "name: shop\nport: 3000\n",
+	})
+	fake.OnStart = func(c *docker.Container) {
+		c.State, c.Running, c.ExitCode = "exited", false, 1
+	}
+	fake.LogOutput["pier-app-shop"] = "Error: Cannot find module 'express'\n"
+
+	var err error
+	out := captureStdout(t, func() { err = runUpCmd(t) })
+	if err == nil {
+		t.Fatal("up succeeded although the app exited")
+	}
+	if !strings.Contains(out, "exited with code 1") {
+		t.Errorf("output doesn't give the exit code:\n%s", out)
+	}
+	if !strings.Contains(out, "Cannot find module 'express'") {
+		t.Errorf("output doesn't show the app's logs:\n%s", out)
+	}
+	if strings.Contains(out, "✅ shop.dock") {
+		t.Errorf("output reports success:\n%s", out)
+	}
+}
+
+func TestRunUp_NoWait(t *testing.T) {
+	fake, _ := setupUp(t, map[string]string{
+		"Dockerfile": "FROM node:20\n",
+		"Pierfile":   "name: shop\nport: 3000\n",
+	})
+	fake.OnStart = func(c *docker.Container) { c.Health = docker.HealthUnhealthy }
+	upWait = false
+	t.Cleanup(func() { upWait = true })
+
+	if err := runUpCmd(t); err != nil {
+		t.Fatalf("--wait=false still waited: %v", err)
+	}
+}
+
+func TestRunUp_SkipsUnchangedBuild(t *testing.T) {
+	fake, _ := setupUp(t, map[string]string{
+		"Dockerfile": "FROM node:20\n",
+		"Pierfile":   "name: shop\nport: 3000\nbuild:\n  target: runtime\n  args:\n    NODE_ENV: production\n",
+		"index.js":   "console.log('v1')\n",
+	})
+
+	if err := runUpCmd(t); err != nil {
+		t.Fatalf("first up: %v", err)
+	}
+	if len(fake.Builds) != 1 {
+		t.Fatalf("builds = %d, want 1", len(fake.Builds))
+	}
+	b := fake.Builds[0]
+	if b.Target != "runtime" || b.Args["NODE_ENV"] != "production" {
+		t.Errorf("build options not passed on: target %q, args %v", b.Target, b.Args)
+	}
+	if b.Labels[docker.LabelContextHash] == "" {
+		t.Error("image not stamped with its context hash")
+	}
+
+	if err := runUpCmd(t); err != nil {
+		t.Fatalf("second up: %v", err)
+	}
+	if len(fake.Builds) != 1 {
+		t.Errorf("unchanged sources were rebuilt: builds = %d", len(fake.Builds))
+	}
+
+	upBuild = true
+	t.Cleanup(func() { upBuild = false })
+	if err := runUpCmd(t); err != nil {
+		t.Fatalf("up --build: %v", err)
+	}
+	if len(fake.Builds) != 2 {
+		t.Errorf("--build didn't rebuild: builds = %d", len(fake.Builds))
+	}
+}
+
+func TestRunUp_RedeploysWithoutDowntime(t *testing.T) {
+	fake, _ := setupUp(t, map[string]string{
+		"Dockerfile": "FROM node:20\n",
+		"Pierfile":   "name: shop\nport: 3000\n",
+	})
+	if err := runUpCmd(t); err != nil {
+		t.Fatalf("first up: %v", err)
+	}
+	first := fake.Containers["pier-app-shop"].ID
+	if err := os.WriteFile("index.js", []byte("console.log('v2')\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// While the new version is probed, the old one still has the route
+	var probed []string
+	httpProbe = func(url, host string) func(context.Context) error {
+		probed = append(probed, host)
+		return func(ctx context.Context) error {
+			old, err := docker.GetContainer(ctx, "pier-app-shop")
+			if err != nil || old.ID != first || !old.Running {
+				t.Errorf("old container gone before the new one was ready: %+v, %v", old, err)
+			}
+			if r := fileRoute(t, "shop"); r.Container != "pier-app-shop" {
+				t.Errorf("route moved before the new version was ready: %+v", r)
+			}
+			return nil
+		}
+	}
+	if err := runUpCmd(t); err != nil {
+		t.Fatalf("second up: %v", err)
+	}
+
+	if !slices.Equal(probed, []string{"pier-app-shop-next.dock"}) {
+		t.Errorf("probed %v, want the staging route", probed)
+	}
+	app, ok := fake.Containers["pier-app-shop"]
+	if !ok || !app.Running || app.ID == first {
+		t.Fatalf("app = %+v, want the new container under the app's name", app)
+	}
+	if _, ok := fake.Containers["pier-app-shop-next"]; ok {
+		t.Error("staging container left behind")
+	}
+	if r := fileRoute(t, "shop"); r.Container != "pier-app-shop" {
+		t.Errorf("route = %+v, want pier-app-shop", r)
+	}
+	if proxy.FileProxyExists("pier-app-shop-next") {
+		t.Error("staging route left behind")
+	}
+	if !fake.Images["shop:previous"] {
+		t.Error("previous image not kept")
+	}
+}
+
+func TestRunUp_FailedRedeployKeepsOldVersion(t *testing.T) {
+	fake, _ := setupUp(t, map[string]string{
+		"Dockerfile": "FROM node:20\n",
+		"Pierfile":   "name: shop\nport: 3000\n",
+	})
+	if err := runUpCmd(t); err != nil {
+		t.Fatalf("first up: %v", err)
+	}
+	first := fake.Containers["pier-app-shop"].ID
+
+	fake.OnStart = func(c *docker.Container) {
+		if c.Name == "pier-app-shop-next" {
+			c.State, c.Running, c.ExitCode = "exited", false, 1
+		}
+	}
+	upBuild = true
+	t.Cleanup(func() { upBuild = false })
+	if err := runUpCmd(t); err == nil {
+		t.Fatal("second up succeeded with a crashing app")
+	}
+
+	app, ok := fake.Containers["pier-app-shop"]
+	if !ok || !app.Running || app.ID != first {
+		t.Errorf("app = %+v, want the first container still serving", app)
+	}
+	if _, ok := fake.Containers["pier-app-shop-next"]; ok {
+		t.Error("failed staging container left behind")
+	}
+	if r := fileRoute(t, "shop"); r.Container != "pier-app-shop" {
+		t.Errorf("route = %+v, want pier-app-shop", r)
+	}
+}
+
+func TestRunUp_RedeployWithoutPortKeepsServing(t *testing.T) {
+	fake, _ := setupUp(t, map[string]string{
+		"Dockerfile": "FROM node:20\n",
+		"Pierfile":   "name: shop\n",
+	})
+	if err := runUpCmd(t); err != nil {
+		t.Fatalf("first up: %v", err)
+	}
+	first := fake.Containers["pier-app-shop"].ID
+
+	// Traefik routes by labels: the new container shares the old one's
+	// and joins it while the old one keeps serving
+	var alongside bool
+	fake.OnStart = func(c *docker.Container) {
+		old, ok := fake.Containers["pier-app-shop"]
+		alongside = c.Name == "pier-app-shop-next" && ok && old.Running &&
+			fake.Specs[c.Name].Labels["traefik.http.routers.shop.rule"] == fake.Specs["pier-app-shop"].Labels["traefik.http.routers.shop.rule"]
+	}
+	upBuild = true
+	t.Cleanup(func() { upBuild = false })
+	if err := runUpCmd(t); err != nil {
+		t.Fatalf("second up: %v", err)
+	}
+	if !alongside {
+		t.Error("the new container didn't start alongside the running one")
+	}
+
+	app, ok := fake.Containers["pier-app-shop"]
+	if !ok || app.ID == first {
+		t.Errorf("app = %+v, want the new container under the app's name", app)
+	}
+	if _, ok := fake.Containers["pier-app-shop-next"]; ok {
+		t.Error("staging container left behind")
+	}
+	if proxy.FileProxyExists("shop") {
+		t.Error("an app without a port should be routed by labels, not a file route")
+	}
+}
+
+func TestRollback(t *testing.T) {
+	fake, _ := setupUp(t, map[string]string{
+		"Dockerfile": "FROM node:20\n",
+		"Pierfile":   "name: shop\nport: 3000\nenv:\n  GREETING: hi\n",
+	})
+	upBuild = true
+	t.Cleanup(func() { upBuild = false })
+	for i := 0; i < 2; i++ {
+		if err := runUpCmd(t); err != nil {
+			t.Fatalf("up: %v", err)
+		}
+	}
+
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+	for _, want := range []string{"shop:previous", "shop"} {
+		if err := runRollback(cmd, []string{"shop"}); err != nil {
+			t.Fatalf("rollback: %v", err)
+		}
+		spec := fake.Specs["pier-app-shop"]
+		if spec.Image != want {
+			t.Errorf("image = %q, want %q", spec.Image, want)
+		}
+		if !hasEnv(spec.Env, "GREETING=hi") || !slices.Equal(spec.Aliases, []string{"shop"}) {
+			t.Errorf("spec = %+v, want the env and aliases carried over", spec)
+		}
+		if r := fileRoute(t, "shop"); r.Container != "pier-app-shop" || r.ContainerPort != 3000 {
+			t.Errorf("route = %+v, want pier-app-shop:3000", r)
+		}
+	}
+}
+
+func TestRunUp_Compose(t *testing.T) {
+	fake, dir := setupUp(t, map[string]string{
+		"Dockerfile": "FROM node:20\n",
+		".env.web":   "# shared\nSECRET=from-file\nMODE=from-file\n",
+		"compose.yaml": `services:
+  web:
+    build: .
+    ports:
+      - target: 3000
+        published: "8080"
+    env_file:
+      - .env.web
+      - path: .env.local
+        required: false
+    environment:
+      - MODE=compose
+    depends_on:
+      migrate:
+        condition: service_completed_successfully
+      api:
+        condition: service_healthy
+    volumes:
+      - ./src:/app/src:ro
+      - type: volume
+        source: cache
+        target: /cache
+    networks:
+      default:
+        aliases: [frontend]
+    working_dir: /app
+    user: "1000:1000"
+    restart: on-failure:3
+    labels: [team=shop, pier.role=infra]
+    cap_add: [NET_ADMIN]
+  api:
+    image: ghcr.io/acme/api:1
+    expose: ["4000"]
+    healthcheck:
+      test: curl -fs localhost:4000/health
+      interval: 5s
+  migrate:
+    image: ghcr.io/acme/api:1
+    command: ./migrate --up "all tables"
+  debug:
+    image: busybox
+    profiles: [tools]
+volumes:
+  cache: {}
+`,
+	})
+	var started []string
+	var migrate docker.RunSpec
+	fake.OnStart = func(c *docker.Container) {
+		started = append(started, c.Name)
+		switch c.Name {
+		case "pier-app-shop-migrate":
+			// It runs to completion straight away; the fake's lock is held
+			migrate = fake.Specs[c.Name]
+			c.State, c.Running, c.ExitCode = "exited", false, 0
+		case "pier-app-shop-api":
+			c.Health = docker.HealthHealthy
+		}
+	}
+	out := captureStdout(t, func() {
+		if err := runUpCmd(t); err != nil {
+			t.Errorf("runUp: %v", err)
+		}
+	})
+
+	want := []string{"pier-app-shop-api", "pier-app-shop-migrate", "pier-app-shop-web"}
+	if !slices.Equal(started, want) {
+		t.Errorf("started %v, want dependencies first %v", started, want)
+	}
+	if _, ok := fake.Containers["pier-app-shop-migrate"]; ok {
+		t.Error("completed task container left behind")
+	}
+	if _, ok := fake.Containers["pier-app-shop-debug"]; ok {
+		t.Error("service of a disabled profile was started")
+	}
+	if !strings.Contains(out, "cap_add isn't supported") {
+		t.Errorf("no warning about cap_add in:\n%s", out)
+	}
+
+	if !slices.Equal(migrate.Cmd, []string{"./migrate", "--up", "all tables"}) || migrate.Restart != "no" {
+		t.Errorf("migrate = %+v", migrate)
+	}
+	api, _ := fake.Spec("pier-app-shop-api")
+	if hc := api.Healthcheck; hc == nil || strings.Join(hc.Test, " ") != "CMD-SHELL curl -fs localhost:4000/health" || hc.Interval != 5*time.Second {
+		t.Errorf("api healthcheck = %+v", hc)
+	}
+	if r := fileRoute(t, "api"); r.ContainerPort != 4000 {
+		t.Errorf("api route = %+v, want the exposed port", r)
+	}
+
+	web, _ := fake.Spec("pier-app-shop-web")
+	if !hasEnv(web.Env, "SECRET=from-file") {
+		t.Errorf("env %v is missing the env_file", web.Env)
+	}
+	if i, j := slices.Index(web.Env, "MODE=from-file"), slices.Index(web.Env, "MODE=compose"); i < 0 || j < i {
+		t.Errorf("env %v: environment should come after env_file", web.Env)
+	}
+	wantBinds := []string{filepath.Join(dir, "src") + ":/app/src:ro", "pier-shop-cache:/cache"}
+	if !slices.Equal(web.Binds, wantBinds) {
+		t.Errorf("binds = %v, want %v", web.Binds, wantBinds)
+	}
+	if web.WorkingDir != "/app" || web.User != "1000:1000" || web.Restart != "on-failure:3" {
+		t.Errorf("web = %+v", web)
+	}
+	if !slices.Contains(web.Aliases, "frontend") {
+		t.Errorf("aliases = %v, want the network alias", web.Aliases)
+	}
+	if web.Labels["team"] != "shop" {
+		t.Errorf("labels = %v, want team=shop", web.Labels)
+	}
+	if o, _ := docker.OwnerOf(fake.Containers["pier-app-shop-web"].Labels); o.Role != docker.RoleApp {
+		t.Errorf("owner = %+v: compose labels shouldn't override pier's", o)
+	}
+	if r := fileRoute(t, "web"); r.ContainerPort != 3000 {
+		t.Errorf("web route = %+v, want port 3000", r)
+	}
+}
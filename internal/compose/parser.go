@@ -1,29 +1,39 @@
 package compose
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/eshe-huli/pier/internal/buildspec"
+	"github.com/eshe-huli/pier/internal/interpolate"
 )
 
 // ComposeFile represents a docker-compose.yml
 type ComposeFile struct {
 	Services map[string]ComposeService `yaml:"services"`
+	Secrets  map[string]ComposeSecret  `yaml:"secrets"`
+	Volumes  map[string]ComposeVolume  `yaml:"volumes"`
+
+	vars interpolate.Lookup // what the file was interpolated with
 }
 
-// ComposeService represents a service in docker-compose.yml
-type ComposeService struct {
-	Image       string      `yaml:"image"`
-	Build       interface{} `yaml:"build"`
-	Ports       []string    `yaml:"ports"`
-	Environment interface{} `yaml:"environment"`
-	DependsOn   interface{} `yaml:"depends_on"`
-	Volumes     []string    `yaml:"volumes"`
-	Command     interface{} `yaml:"command"`
-	Entrypoint  interface{} `yaml:"entrypoint"`
+// ComposeSecret is a top-level secret, referenced by name from build.secrets
+type ComposeSecret struct {
+	File        string `yaml:"file"`
+	Environment string `yaml:"environment"`
+}
+
+// ComposeVolume is a top-level named volume
+type ComposeVolume struct {
+	Name     string `yaml:"name"`
+	External bool   `yaml:"external"`
+	Driver   string `yaml:"driver"`
 }
 
 // InfraService is a detected infrastructure service from compose
@@ -37,70 +47,510 @@ type InfraService struct {
 // AppService is an application service from compose
 type AppService struct {
 	ComposeName string
-	Build       string // build context path
+	Build       *buildspec.Spec // nil for image-only services
 	Image       string
 	Ports       []string
+	Expose      []string
 	Environment map[string]string
-	Volumes     []string
-	Command     interface{} // entrypoint/command override
-	Entrypoint  interface{}
+	EnvFile     []EnvFile
+	DependsOn   map[string]Dependency // on app and infra services
+	Volumes     []Volume
+	Aliases     []string // from its networks
+	Command     []string // entrypoint/command override
+	Entrypoint  []string
+	Healthcheck *Healthcheck
+	Labels      map[string]string
+	WorkingDir  string
+	User        string
+	Restart     string
+	// Task is set when another service waits for this one to complete
+	// (service_completed_successfully) rather than to serve
+	Task        bool
+	Unsupported []string
+
+	namedVolumes map[string]ComposeVolume
+	vars         interpolate.Lookup
 }
 
 var knownInfra = map[string]bool{
 	"postgres": true, "redis": true, "mongo": true, "mysql": true,
 	"minio": true, "kafka": true, "rabbitmq": true, "elasticsearch": true,
 	"mariadb": true, "memcached": true,
+	"mailpit": true, "mailhog": true,
 }
 
-// Parse reads and parses a docker-compose file from the given directory
-func Parse(dir string) (*ComposeFile, error) {
-	var data []byte
+// infraAliases maps compose images onto the pier shared service that replaces them
+var infraAliases = map[string]string{"mailpit": "mail", "mailhog": "mail"}
+
+// ErrNotFound is returned by Parse when the directory has no compose file
+var ErrNotFound = errors.New("no compose file found")
+
+// Parse reads and parses a docker-compose file from the given directory,
+// interpolating variables and resolving extends. Variables come from the
+// environment, then envFiles, or the directory's .env when none are given.
+func Parse(dir string, envFiles ...string) (*ComposeFile, error) {
+	var path string
 	var err error
 	for _, name := range []string{"docker-compose.yml", "docker-compose.yaml", "compose.yml", "compose.yaml"} {
-		data, err = os.ReadFile(filepath.Join(dir, name))
-		if err == nil {
+		path = filepath.Join(dir, name)
+		if _, err = os.Stat(path); err == nil {
 			break
 		}
 	}
 	if err != nil {
-		return nil, fmt.Errorf("no compose file found: %w", err)
+		return nil, fmt.Errorf("%w: %w", ErrNotFound, err)
 	}
 
+	vars, err := variables(dir, envFiles)
+	if err != nil {
+		return nil, err
+	}
+	cf, err := parseFile(path, vars)
+	if err != nil {
+		return nil, err
+	}
+	if err := resolveExtends(cf, dir, vars); err != nil {
+		return nil, err
+	}
+	if err := checkDependencies(cf); err != nil {
+		return nil, err
+	}
+	cf.vars = vars
+	return cf, nil
+}
+
+// variables is what the compose file is interpolated with: the environment,
+// then the env files. Only .env may be missing.
+func variables(dir string, envFiles []string) (interpolate.Lookup, error) {
+	optional := len(envFiles) == 0
+	if optional {
+		envFiles = []string{filepath.Join(dir, ".env")}
+	}
+	fromFiles := map[string]string{}
+	for _, path := range envFiles {
+		pairs, err := interpolate.ReadEnvFile(path, interpolate.Chain(os.LookupEnv, interpolate.Map(fromFiles)))
+		if os.IsNotExist(err) && optional {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading env file: %w", err)
+		}
+		for _, p := range pairs {
+			fromFiles[p.Key] = p.Value
+		}
+	}
+	return interpolate.Chain(os.LookupEnv, interpolate.Map(fromFiles)), nil
+}
+
+func parseFile(path string, vars interpolate.Lookup) (*ComposeFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading compose file: %w", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", filepath.Base(path), err)
+	}
+	if err := interpolateNode(&doc, vars); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", filepath.Base(path), err)
+	}
+	resolveBareEnv(&doc, vars)
 	var cf ComposeFile
-	if err := yaml.Unmarshal(data, &cf); err != nil {
-		return nil, fmt.Errorf("parsing compose file: %w", err)
+	if err := doc.Decode(&cf); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", filepath.Base(path), err)
 	}
 	return &cf, nil
 }
 
-// SeparateServices splits compose services into infra and app services
-func SeparateServices(cf *ComposeFile) (infra []InfraService, apps []AppService) {
+// interpolateNode expands the variables in every value of the document.
+// A plain scalar is re-typed from what it expands to, so `retries: ${N}`
+// still reads as a number.
+func interpolateNode(node *yaml.Node, vars interpolate.Lookup) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		v, err := interpolate.Expand(node.Value, vars)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		if v != node.Value {
+			node.Value = v
+			if node.Style == 0 {
+				node.Tag = ""
+			}
+		}
+	case yaml.MappingNode:
+		// Keys are left as they are
+		for i := 1; i < len(node.Content); i += 2 {
+			if err := interpolateNode(node.Content[i], vars); err != nil {
+				return err
+			}
+		}
+	default:
+		for _, n := range node.Content {
+			if err := interpolateNode(n, vars); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolveBareEnv fills in the environment entries given as a bare KEY,
+// which pass a variable through, from vars; unset ones are dropped
+func resolveBareEnv(doc *yaml.Node, vars interpolate.Lookup) {
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return
+	}
+	services := mappingValue(doc.Content[0], "services")
+	if services == nil || services.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 1; i < len(services.Content); i += 2 {
+		env := mappingValue(services.Content[i], "environment")
+		if env == nil {
+			continue
+		}
+		var kept []*yaml.Node
+		switch env.Kind {
+		case yaml.MappingNode:
+			for j := 0; j+1 < len(env.Content); j += 2 {
+				k, v := env.Content[j], env.Content[j+1]
+				if v.Kind == yaml.ScalarNode && v.Tag == "!!null" {
+					value, ok := vars(k.Value)
+					if !ok {
+						continue
+					}
+					v.Value, v.Tag = value, "!!str"
+				}
+				kept = append(kept, k, v)
+			}
+		case yaml.SequenceNode:
+			for _, item := range env.Content {
+				if item.Kind == yaml.ScalarNode && !strings.Contains(item.Value, "=") {
+					value, ok := vars(item.Value)
+					if !ok {
+						continue
+					}
+					item.Value += "=" + value
+				}
+				kept = append(kept, item)
+			}
+		default:
+			continue
+		}
+		env.Content = kept
+	}
+}
+
+// mappingValue returns the value of key in a mapping node, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// resolveExtends replaces each service that extends another with the two
+// merged. The other may live in another file, relative to dir.
+func resolveExtends(cf *ComposeFile, dir string, vars interpolate.Lookup) error {
+	files := map[string]*ComposeFile{}
+	var resolve func(f *ComposeFile, fileDir, name string, seen []string) (ComposeService, error)
+	resolve = func(f *ComposeFile, fileDir, name string, seen []string) (ComposeService, error) {
+		svc, ok := f.Services[name]
+		if !ok {
+			return ComposeService{}, fmt.Errorf("extends: no service %s", name)
+		}
+		if svc.Extends == nil {
+			return svc, nil
+		}
+		key := filepath.Join(fileDir, name)
+		if slices.Contains(seen, key) {
+			return ComposeService{}, fmt.Errorf("extends: %s extends itself", name)
+		}
+
+		base, baseDir := f, fileDir
+		if file := svc.Extends.File; file != "" {
+			if !filepath.IsAbs(file) {
+				file = filepath.Join(fileDir, file)
+			}
+			if base = files[file]; base == nil {
+				var err error
+				if base, err = parseFile(file, vars); err != nil {
+					return ComposeService{}, fmt.Errorf("extends: %w", err)
+				}
+				files[file] = base
+			}
+			baseDir = filepath.Dir(file)
+		}
+		parent, err := resolve(base, baseDir, svc.Extends.Service, append(seen, key))
+		if err != nil {
+			return ComposeService{}, err
+		}
+		if baseDir != fileDir {
+			if rel, err := filepath.Rel(fileDir, baseDir); err == nil {
+				parent = parent.rebase(rel)
+			}
+		}
+		return svc.extend(parent), nil
+	}
+
+	for name := range cf.Services {
+		svc, err := resolve(cf, dir, name, nil)
+		if err != nil {
+			return fmt.Errorf("service %s: %w", name, err)
+		}
+		cf.Services[name] = svc
+	}
+	return nil
+}
+
+// checkDependencies rejects depends_on entries naming undefined services,
+// unless they're optional, and dependency cycles
+func checkDependencies(cf *ComposeFile) error {
 	for name, svc := range cf.Services {
+		for dep, d := range svc.DependsOn {
+			if _, ok := cf.Services[dep]; !ok && d.Required {
+				return fmt.Errorf("service %s depends on undefined service %s", name, dep)
+			}
+		}
+	}
+	state := map[string]int{} // 1 visiting, 2 done
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case 1:
+			return fmt.Errorf("dependency cycle through service %s", name)
+		case 2:
+			return nil
+		}
+		state[name] = 1
+		for dep := range cf.Services[name].DependsOn {
+			if _, ok := cf.Services[dep]; ok {
+				if err := visit(dep); err != nil {
+					return err
+				}
+			}
+		}
+		state[name] = 2
+		return nil
+	}
+	for _, name := range sortedNames(cf.Services) {
+		if err := visit(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// activeServices are the services to run with the given profiles enabled:
+// those without profiles, those in an enabled one, and whatever they
+// depend on
+func activeServices(cf *ComposeFile, profiles []string) map[string]bool {
+	active := map[string]bool{}
+	var enable func(name string)
+	enable = func(name string) {
+		svc, ok := cf.Services[name]
+		if !ok || active[name] {
+			return
+		}
+		active[name] = true
+		for dep := range svc.DependsOn {
+			enable(dep)
+		}
+	}
+	for name, svc := range cf.Services {
+		if len(svc.Profiles) == 0 || slices.ContainsFunc(svc.Profiles, func(p string) bool {
+			return p == "*" || slices.Contains(profiles, p) || slices.Contains(profiles, "*")
+		}) {
+			enable(name)
+		}
+	}
+	return active
+}
+
+// SeparateServices splits the compose services enabled by profiles into
+// infra and app services. App services come in dependency order.
+func SeparateServices(cf *ComposeFile, profiles ...string) (infra []InfraService, apps []AppService) {
+	active := activeServices(cf, profiles)
+	for _, name := range dependencyOrder(cf) {
+		svc := cf.Services[name]
+		if !active[name] {
+			continue
+		}
 		if svc.Image != "" && isInfraImage(svc.Image) {
 			imgName, imgVersion := parseImageTag(svc.Image)
+			if alias, ok := infraAliases[imgName]; ok {
+				// mailhog tags don't exist for mailpit; only keep mailpit's own
+				if imgName != "mailpit" {
+					imgVersion = ""
+				}
+				imgName = alias
+			}
 			infra = append(infra, InfraService{
 				ComposeName: name,
 				Image:       svc.Image,
 				Name:        imgName,
 				Version:     imgVersion,
 			})
-		} else {
-			app := AppService{
-				ComposeName: name,
-				Image:       svc.Image,
-				Ports:       svc.Ports,
-				Environment: parseEnvironment(svc.Environment),
-				Volumes:     svc.Volumes,
-				Command:     svc.Command,
-				Entrypoint:  svc.Entrypoint,
+			continue
+		}
+
+		app := AppService{
+			ComposeName:  name,
+			Build:        resolveBuild(svc.Build, cf.Secrets),
+			Image:        svc.Image,
+			Ports:        svc.Ports,
+			Expose:       svc.Expose,
+			Environment:  svc.Environment,
+			EnvFile:      svc.EnvFile,
+			DependsOn:    svc.DependsOn,
+			Volumes:      svc.Volumes,
+			Command:      svc.Command,
+			Entrypoint:   svc.Entrypoint,
+			Healthcheck:  svc.Healthcheck,
+			Labels:       svc.Labels,
+			WorkingDir:   svc.WorkingDir,
+			User:         svc.User,
+			Restart:      svc.Restart,
+			Unsupported:  svc.Unsupported,
+			namedVolumes: cf.Volumes,
+			vars:         cf.vars,
+		}
+		if app.Environment == nil {
+			app.Environment = map[string]string{}
+		}
+		for _, net := range sortedNames(svc.Networks) {
+			app.Aliases = append(app.Aliases, svc.Networks[net].Aliases...)
+		}
+		for _, vol := range svc.Volumes {
+			if v, ok := cf.Volumes[vol.Source]; ok && vol.Type == "volume" && v.Driver != "" && v.Driver != "local" {
+				app.Unsupported = append(app.Unsupported, fmt.Sprintf("volume %s: driver %s isn't supported; a local volume is used", vol.Source, v.Driver))
+			}
+		}
+		for other, o := range cf.Services {
+			if active[other] && o.DependsOn[name].Condition == ServiceCompletedSuccessfully {
+				app.Task = true
 			}
-			app.Build = parseBuildContext(svc.Build)
-			apps = append(apps, app)
 		}
+		apps = append(apps, app)
 	}
 	return
 }
 
+// dependencyOrder lists the services so that each comes after those it
+// depends on, and otherwise by name
+func dependencyOrder(cf *ComposeFile) []string {
+	var order []string
+	done := map[string]bool{}
+	var visit func(name string)
+	visit = func(name string) {
+		if done[name] {
+			return
+		}
+		done[name] = true // checkDependencies has ruled out cycles
+		deps := cf.Services[name].DependsOn
+		for _, dep := range sortedNames(deps) {
+			if _, ok := cf.Services[dep]; ok {
+				visit(dep)
+			}
+		}
+		order = append(order, name)
+	}
+	for _, name := range sortedNames(cf.Services) {
+		visit(name)
+	}
+	return order
+}
+
+func sortedNames[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Binds are the service's volumes as bind mounts, relative host paths
+// resolved against dir. Named volumes are project-scoped unless external
+// or given a name.
+func (a AppService) Binds(dir, project string) []string {
+	var binds []string
+	for _, v := range a.Volumes {
+		source := v.Source
+		switch v.Type {
+		case "bind":
+			if rest, ok := strings.CutPrefix(source, "~/"); ok {
+				if home, err := os.UserHomeDir(); err == nil {
+					source = filepath.Join(home, rest)
+				}
+			}
+			if !filepath.IsAbs(source) {
+				source = filepath.Join(dir, source)
+			}
+		case "volume":
+			named := a.namedVolumes[source]
+			switch {
+			case named.Name != "":
+				source = named.Name
+			case !named.External:
+				source = "pier-" + project + "-" + source
+			}
+		default:
+			continue
+		}
+		bind := source + ":" + v.Target
+		if v.Mode != "" {
+			bind += ":" + v.Mode
+		}
+		binds = append(binds, bind)
+	}
+	return binds
+}
+
+// FileEnv reads the service's env_file entries, in order, as KEY=VALUE.
+// They follow the same rules as .env, and are interpolated with the same
+// variables as the compose file. Missing files that aren't required are
+// skipped.
+func (a AppService) FileEnv(dir string) ([]string, error) {
+	vars := a.vars
+	if vars == nil {
+		vars = os.LookupEnv
+	}
+	var env []string
+	for _, f := range a.EnvFile {
+		path := f.Path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		pairs, err := interpolate.ReadEnvFile(path, vars)
+		if os.IsNotExist(err) && !f.Required {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("env_file: %w", err)
+		}
+		for _, p := range pairs {
+			env = append(env, p.Key+"="+p.Value)
+		}
+	}
+	return env, nil
+}
+
+// ContainerPort is the port the service listens on: the first of its
+// ports, or else of expose
+func (a AppService) ContainerPort() int {
+	if port := ParseFirstPort(a.Ports); port > 0 {
+		return port
+	}
+	return ParseFirstPort(a.Expose)
+}
+
 func isInfraImage(image string) bool {
 	parts := strings.Split(image, "/")
 	nameTag := parts[len(parts)-1]
@@ -123,68 +573,31 @@ func parseImageTag(image string) (name, version string) {
 	return
 }
 
-func parseBuildContext(build interface{}) string {
+// resolveBuild defaults a service's build context and points build
+// secrets at the top-level secrets they name
+func resolveBuild(build *buildspec.Spec, secrets map[string]ComposeSecret) *buildspec.Spec {
 	if build == nil {
-		return ""
-	}
-	switch v := build.(type) {
-	case string:
-		return v
-	case map[string]interface{}:
-		if ctx, ok := v["context"]; ok {
-			if s, ok := ctx.(string); ok {
-				return s
-			}
-		}
-	}
-	return "."
-}
-
-func parseEnvironment(env interface{}) map[string]string {
-	result := make(map[string]string)
-	if env == nil {
-		return result
+		return nil
 	}
-	switch v := env.(type) {
-	case []interface{}:
-		for _, item := range v {
-			if s, ok := item.(string); ok {
-				parts := strings.SplitN(s, "=", 2)
-				if len(parts) == 2 {
-					result[parts[0]] = resolveEnvVar(parts[1])
-				}
-			}
-		}
-	case map[string]interface{}:
-		for k, val := range v {
-			result[k] = resolveEnvVar(fmt.Sprintf("%v", val))
-		}
+	spec := *build
+	if spec.Context == "" {
+		spec.Context = "."
 	}
-	return result
-}
-
-// resolveEnvVar resolves ${VAR:-default} and ${VAR} patterns
-// to their default values (for local dev, we don't have the host env)
-func resolveEnvVar(val string) string {
-	// Match ${VAR:-default} → use default
-	for strings.Contains(val, "${") {
-		start := strings.Index(val, "${")
-		end := strings.Index(val[start:], "}")
-		if end == -1 {
-			break
-		}
-		end += start
-		expr := val[start+2 : end]
-		var replacement string
-		if idx := strings.Index(expr, ":-"); idx != -1 {
-			replacement = expr[idx+2:]
-		} else {
-			// ${VAR} with no default → empty
-			replacement = ""
+	spec.Secrets = slices.Clone(build.Secrets)
+	for i, name := range spec.Secrets {
+		sec := secrets[name]
+		switch {
+		case strings.Contains(name, "="):
+			// Already docker's --secret syntax
+		case sec.File != "":
+			spec.Secrets[i] = fmt.Sprintf("id=%s,src=%s", name, sec.File)
+		case sec.Environment != "":
+			spec.Secrets[i] = fmt.Sprintf("id=%s,env=%s", name, sec.Environment)
+		default:
+			spec.Secrets[i] = "id=" + name
 		}
-		val = val[:start] + replacement + val[end+1:]
 	}
-	return val
+	return &spec
 }
 
 // ParseFirstPort extracts the container port from a docker-compose ports spec.
@@ -3,7 +3,12 @@ package compose
 import (
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/eshe-huli/pier/internal/buildspec"
 )
 
 func TestParse_BasicCompose(t *testing.T) {
@@ -44,10 +49,10 @@ func TestParse_NoComposeFile(t *testing.T) {
 func TestSeparateServices_InfraVsApp(t *testing.T) {
 	cf := &ComposeFile{
 		Services: map[string]ComposeService{
-			"api": {Build: "./api", Ports: []string{"3000:3000"}},
+			"api": {Build: &buildspec.Spec{Context: "./api"}, Ports: []string{"3000:3000"}},
 			"db":  {Image: "postgres:16"},
 			"redis": {Image: "redis:7-alpine"},
-			"web": {Build: "./web", Ports: []string{"5173:5173"}},
+			"web": {Build: &buildspec.Spec{Context: "./web"}, Ports: []string{"5173:5173"}},
 		},
 	}
 
@@ -90,3 +95,459 @@ func TestParseFirstPort(t *testing.T) {
 		}
 	}
 }
+
+func TestSeparateServices_BuildOptions(t *testing.T) {
+	dir := t.TempDir()
+	content := `services:
+  api:
+    build:
+      context: ./api
+      dockerfile: Dockerfile.dev
+      target: dev
+      args:
+        - NODE_VERSION=20
+      secrets: [npmrc, token, other]
+  worker:
+    build: ./worker
+  cache:
+    image: redis:7
+secrets:
+  npmrc:
+    file: ./.npmrc
+  token:
+    environment: GH_TOKEN
+`
+	if err := os.WriteFile(filepath.Join(dir, "compose.yaml"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cf, err := Parse(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, apps := SeparateServices(cf)
+	builds := map[string]*buildspec.Spec{}
+	for _, a := range apps {
+		builds[a.ComposeName] = a.Build
+	}
+
+	api := builds["api"]
+	if api == nil {
+		t.Fatal("api has no build")
+	}
+	if api.Context != "./api" || api.Dockerfile != "Dockerfile.dev" || api.Target != "dev" || api.Args["NODE_VERSION"] != "20" {
+		t.Errorf("api build = %+v", api)
+	}
+	wantSecrets := []string{"id=npmrc,src=./.npmrc", "id=token,env=GH_TOKEN", "id=other"}
+	if strings.Join(api.Secrets, " ") != strings.Join(wantSecrets, " ") {
+		t.Errorf("secrets = %v, want %v", api.Secrets, wantSecrets)
+	}
+	if w := builds["worker"]; w == nil || w.Context != "./worker" {
+		t.Errorf("worker build = %+v", w)
+	}
+}
+
+func writeCompose(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParse_ShortAndLongSyntax(t *testing.T) {
+	t.Setenv("FROM_HOST", "host-value")
+	dir := t.TempDir()
+	writeCompose(t, dir, "compose.yaml", `services:
+  short:
+    image: acme/short
+    ports: [3000, "127.0.0.1:8080:80/tcp"]
+    expose: [4000]
+    environment: [A=1, FROM_HOST, UNSET_ANYWHERE]
+    env_file: .env
+    depends_on: [long]
+    volumes: [./src:/app/src, data:/data:ro, /tmp/cache]
+    networks: [default, backend]
+    command: sh -c 'echo "hello world"'
+    labels: [team=shop, flag]
+    healthcheck:
+      test: [CMD, curl, -f, localhost]
+      start_period: 1m30s
+    privileged: true
+    x-notes: ignored
+  long:
+    image: acme/long
+    ports:
+      - target: 80
+        published: "8080"
+        host_ip: 127.0.0.1
+        protocol: udp
+    environment:
+      B: 2
+      FROM_HOST:
+    env_file:
+      - path: .env.local
+        required: false
+    depends_on:
+      db:
+        condition: service_healthy
+        restart: true
+      cache:
+        condition: service_started
+        required: false
+    volumes:
+      - type: bind
+        source: ./conf
+        target: /conf
+        read_only: true
+      - type: tmpfs
+        target: /run
+    networks:
+      default:
+        aliases: [api, backend-api]
+    entrypoint: [/entry.sh]
+    labels:
+      team: shop
+    healthcheck:
+      disable: true
+  db:
+    image: postgres:16
+`)
+	cf, err := Parse(dir)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	short, long := cf.Services["short"], cf.Services["long"]
+
+	if strings.Join(short.Ports, " ") != "3000 127.0.0.1:8080:80/tcp" || strings.Join(long.Ports, " ") != "127.0.0.1:8080:80/udp" {
+		t.Errorf("ports = %v / %v", short.Ports, long.Ports)
+	}
+	if strings.Join(short.Expose, " ") != "4000" {
+		t.Errorf("expose = %v", short.Expose)
+	}
+	if short.Environment["A"] != "1" || short.Environment["FROM_HOST"] != "host-value" || long.Environment["FROM_HOST"] != "host-value" || long.Environment["B"] != "2" {
+		t.Errorf("environment = %v / %v", short.Environment, long.Environment)
+	}
+	if _, ok := short.Environment["UNSET_ANYWHERE"]; ok {
+		t.Error("a bare key unset on the host should be left out")
+	}
+	if len(short.EnvFile) != 1 || !short.EnvFile[0].Required || len(long.EnvFile) != 1 || long.EnvFile[0].Required {
+		t.Errorf("env_file = %+v / %+v", short.EnvFile, long.EnvFile)
+	}
+	if short.DependsOn["long"] != (Dependency{Condition: ServiceStarted, Required: true}) ||
+		long.DependsOn["db"] != (Dependency{Condition: ServiceHealthy, Required: true}) ||
+		long.DependsOn["cache"] != (Dependency{Condition: ServiceStarted}) {
+		t.Errorf("depends_on = %+v / %+v", short.DependsOn, long.DependsOn)
+	}
+	wantVolumes := []Volume{
+		{Type: "bind", Source: "./src", Target: "/app/src"},
+		{Type: "volume", Source: "data", Target: "/data", Mode: "ro"},
+	}
+	if len(short.Volumes) != 2 || short.Volumes[0] != wantVolumes[0] || short.Volumes[1] != wantVolumes[1] {
+		t.Errorf("volumes = %+v, want %+v", short.Volumes, wantVolumes)
+	}
+	if len(long.Volumes) != 1 || long.Volumes[0] != (Volume{Type: "bind", Source: "./conf", Target: "/conf", Mode: "ro"}) {
+		t.Errorf("volumes = %+v", long.Volumes)
+	}
+	if _, ok := short.Networks["backend"]; !ok || strings.Join(long.Networks["default"].Aliases, " ") != "api backend-api" {
+		t.Errorf("networks = %+v / %+v", short.Networks, long.Networks)
+	}
+	if strings.Join(short.Command, "|") != "sh|-c|echo \"hello world\"" || strings.Join(long.Entrypoint, " ") != "/entry.sh" {
+		t.Errorf("command = %q, entrypoint = %q", short.Command, long.Entrypoint)
+	}
+	if short.Labels["team"] != "shop" || short.Labels["flag"] != "" || long.Labels["team"] != "shop" {
+		t.Errorf("labels = %v / %v", short.Labels, long.Labels)
+	}
+	if hc := short.Healthcheck; hc == nil || strings.Join(hc.Test, " ") != "CMD curl -f localhost" || hc.StartPeriod != 90*time.Second {
+		t.Errorf("healthcheck = %+v", hc)
+	}
+	if hc := long.Healthcheck; hc == nil || strings.Join(hc.Test, " ") != "NONE" {
+		t.Errorf("disabled healthcheck = %+v", hc)
+	}
+
+	for _, want := range []string{"privileged", "anonymous volume /tmp/cache", "network backend"} {
+		if !containsPrefix(short.Unsupported, want) {
+			t.Errorf("no warning about %s in %q", want, short.Unsupported)
+		}
+	}
+	for _, want := range []string{"depends_on restart", "tmpfs volume /run"} {
+		if !containsPrefix(long.Unsupported, want) {
+			t.Errorf("no warning about %s in %q", want, long.Unsupported)
+		}
+	}
+}
+
+func containsPrefix(list []string, prefix string) bool {
+	for _, s := range list {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestParse_Extends(t *testing.T) {
+	dir := t.TempDir()
+	writeCompose(t, dir, "common/base.yml", `services:
+  node:
+    build: ./app
+    environment:
+      NODE_ENV: production
+      PORT: "3000"
+    volumes: [./app:/app, modules:/app/node_modules]
+    env_file: base.env
+    restart: always
+`)
+	writeCompose(t, dir, "compose.yml", `services:
+  web:
+    extends:
+      file: common/base.yml
+      service: node
+    environment:
+      NODE_ENV: development
+    volumes: [./web:/app]
+  worker:
+    extends: web
+    command: npm run worker
+`)
+	cf, err := Parse(dir)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	web := cf.Services["web"]
+	if web.Build == nil || web.Build.Context != "common/app" {
+		t.Errorf("build = %+v, want the context relative to this file", web.Build)
+	}
+	if web.Environment["NODE_ENV"] != "development" || web.Environment["PORT"] != "3000" {
+		t.Errorf("environment = %v", web.Environment)
+	}
+	if len(web.Volumes) != 2 || web.Volumes[0].Source != "modules" || web.Volumes[1].Source != "./web" {
+		t.Errorf("volumes = %+v, want base's /app replaced", web.Volumes)
+	}
+	if len(web.EnvFile) != 1 || web.EnvFile[0].Path != "common/base.env" || web.Restart != "always" {
+		t.Errorf("web = %+v", web)
+	}
+	worker := cf.Services["worker"]
+	if worker.Extends != nil || worker.Restart != "always" || strings.Join(worker.Command, " ") != "npm run worker" || worker.Environment["NODE_ENV"] != "development" {
+		t.Errorf("worker = %+v", worker)
+	}
+
+	writeCompose(t, dir, "compose.yml", "services:\n  a:\n    extends: b\n  b:\n    extends: a\n")
+	if _, err := Parse(dir); err == nil {
+		t.Error("an extends cycle should fail")
+	}
+}
+
+func TestSeparateServices_ProfilesAndOrder(t *testing.T) {
+	dir := t.TempDir()
+	writeCompose(t, dir, "compose.yaml", `services:
+  web:
+    build: .
+    depends_on:
+      migrate:
+        condition: service_completed_successfully
+      api:
+        condition: service_healthy
+  api:
+    image: acme/api
+    depends_on: [db]
+  migrate:
+    image: acme/api
+  db:
+    image: postgres:16
+  admin:
+    image: acme/admin
+    profiles: [tools]
+    depends_on: [helper]
+  helper:
+    image: acme/helper
+    profiles: [other]
+`)
+	cf, err := Parse(dir)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	names := func(apps []AppService) string {
+		var n []string
+		for _, a := range apps {
+			n = append(n, a.ComposeName)
+		}
+		return strings.Join(n, " ")
+	}
+	infra, apps := SeparateServices(cf)
+	if len(infra) != 1 || names(apps) != "api migrate web" {
+		t.Errorf("infra = %+v, apps = %s; want db, then api migrate web", infra, names(apps))
+	}
+	for _, a := range apps {
+		if a.Task != (a.ComposeName == "migrate") {
+			t.Errorf("%s: Task = %v", a.ComposeName, a.Task)
+		}
+	}
+
+	// An enabled profile's services bring their dependencies along
+	if _, apps := SeparateServices(cf, "tools"); names(apps) != "helper admin api migrate web" {
+		t.Errorf("with tools: %s", names(apps))
+	}
+
+	writeCompose(t, dir, "compose.yaml", "services:\n  a:\n    image: x\n    depends_on: [b]\n  b:\n    image: x\n    depends_on: [a]\n")
+	if _, err := Parse(dir); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("err = %v, want a dependency cycle", err)
+	}
+	writeCompose(t, dir, "compose.yaml", "services:\n  a:\n    image: x\n    depends_on: [missing]\n")
+	if _, err := Parse(dir); err == nil {
+		t.Error("depending on an undefined service should fail")
+	}
+}
+
+func TestAppService_Binds(t *testing.T) {
+	app := AppService{
+		Volumes: []Volume{
+			{Type: "bind", Source: "./src", Target: "/app", Mode: "ro"},
+			{Type: "volume", Source: "data", Target: "/data"},
+			{Type: "volume", Source: "shared", Target: "/shared"},
+			{Type: "volume", Source: "named", Target: "/named"},
+		},
+		namedVolumes: map[string]ComposeVolume{
+			"shared": {External: true},
+			"named":  {Name: "my-volume"},
+		},
+	}
+	want := []string{"/work/src:/app:ro", "pier-shop-data:/data", "shared:/shared", "my-volume:/named"}
+	if got := app.Binds("/work", "shop"); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("Binds = %v, want %v", got, want)
+	}
+}
+
+func TestParse_Interpolation(t *testing.T) {
+	t.Setenv("TAG", "from-host")
+	dir := t.TempDir()
+	writeCompose(t, dir, ".env", "TAG=from-dotenv\nPORT=3000\nRETRIES=4\n")
+	writeCompose(t, dir, "staging.env", "PORT=4000\nDB=staging\n")
+	writeCompose(t, dir, "compose.yaml", `services:
+  api:
+    image: acme/api:${TAG}
+    ports: ["${PORT:-80}:${PORT:-80}"]
+    environment:
+      DATABASE: ${DB-dev}
+      OPTIONAL: ${MISSING:+set}
+      PRICE: $$5
+    command: echo ${PORT}
+    healthcheck:
+      test: curl localhost:${PORT}
+      retries: ${RETRIES:-3}
+`)
+
+	cf, err := Parse(dir)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	api := cf.Services["api"]
+	if api.Image != "acme/api:from-host" {
+		t.Errorf("image = %q, want the environment to win over .env", api.Image)
+	}
+	if strings.Join(api.Ports, " ") != "3000:3000" || strings.Join(api.Command, " ") != "echo 3000" {
+		t.Errorf("ports = %v, command = %v", api.Ports, api.Command)
+	}
+	if api.Environment["DATABASE"] != "dev" || api.Environment["OPTIONAL"] != "" || api.Environment["PRICE"] != "$5" {
+		t.Errorf("environment = %v", api.Environment)
+	}
+	if hc := api.Healthcheck; hc == nil || hc.Retries != 4 || hc.Test[1] != "curl localhost:3000" {
+		t.Errorf("healthcheck = %+v", hc)
+	}
+
+	// --env-file replaces .env
+	cf, err = Parse(dir, filepath.Join(dir, "staging.env"))
+	if err != nil {
+		t.Fatalf("Parse with env file: %v", err)
+	}
+	if api := cf.Services["api"]; strings.Join(api.Ports, " ") != "4000:4000" || api.Environment["DATABASE"] != "staging" || api.Healthcheck.Retries != 3 {
+		t.Errorf("with staging.env: %+v", api)
+	}
+	if _, err := Parse(dir, filepath.Join(dir, "missing.env")); err == nil {
+		t.Error("a missing --env-file should fail")
+	}
+
+	writeCompose(t, dir, "compose.yaml", "services:\n  api:\n    image: acme/api:${VERSION:?set VERSION}\n")
+	if _, err := Parse(dir); err == nil || !strings.Contains(err.Error(), "required variable VERSION is missing a value: set VERSION") {
+		t.Errorf("err = %v, want the required variable's message", err)
+	}
+}
+
+func TestAppService_FileEnv(t *testing.T) {
+	dir := t.TempDir()
+	writeCompose(t, dir, ".env", "HOST=db\n")
+	writeCompose(t, dir, "app.env", `export NAME="shop"
+URL=postgres://${HOST}/$NAME
+LITERAL='${HOST}'
+QUOTED="a b" # comment
+`)
+	writeCompose(t, dir, "compose.yaml", `services:
+  api:
+    build: .
+    env_file:
+      - app.env
+      - path: local.env
+        required: false
+`)
+
+	cf, err := Parse(dir)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	_, apps := SeparateServices(cf)
+	env, err := apps[0].FileEnv(dir)
+	if err != nil {
+		t.Fatalf("FileEnv: %v", err)
+	}
+	want := []string{"NAME=shop", "URL=postgres://db/shop", "LITERAL=${HOST}", "QUOTED=a b"}
+	if !slices.Equal(env, want) {
+		t.Errorf("env = %q, want %q", env, want)
+	}
+
+	writeCompose(t, dir, "compose.yaml", "services:\n  api:\n    build: .\n    env_file: missing.env\n")
+	cf, _ = Parse(dir)
+	_, apps = SeparateServices(cf)
+	if _, err := apps[0].FileEnv(dir); err == nil {
+		t.Error("a missing required env_file should fail")
+	}
+}
+
+func TestParse_BareEnvironmentFromEnvFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeCompose(t, dir, ".env", "FOO=from-dotenv\nBAR=bar\n")
+	writeCompose(t, dir, "other.env", "FOO=from-env-file\n")
+	writeCompose(t, dir, "compose.yaml", `services:
+  list:
+    image: acme/list
+    environment: [FOO, UNSET_ANYWHERE]
+  map:
+    image: acme/map
+    environment:
+      FOO:
+      BAR:
+`)
+
+	cf, err := Parse(dir)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	list, m := cf.Services["list"], cf.Services["map"]
+	if list.Environment["FOO"] != "from-dotenv" || m.Environment["FOO"] != "from-dotenv" || m.Environment["BAR"] != "bar" {
+		t.Errorf("list = %v, map = %v; want .env's values", list.Environment, m.Environment)
+	}
+	if _, ok := list.Environment["UNSET_ANYWHERE"]; ok {
+		t.Error("a bare key set nowhere should be left out")
+	}
+
+	cf, err = Parse(dir, filepath.Join(dir, "other.env"))
+	if err != nil {
+		t.Fatalf("Parse with env file: %v", err)
+	}
+	if got := cf.Services["list"].Environment["FOO"]; got != "from-env-file" {
+		t.Errorf("FOO = %q, want the --env-file's value", got)
+	}
+}
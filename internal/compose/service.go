@@ -0,0 +1,700 @@
+package compose
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/eshe-huli/pier/internal/buildspec"
+)
+
+// ComposeService represents a service in docker-compose.yml. Keys with a
+// short and a long syntax are read into one form.
+type ComposeService struct {
+	Image       string
+	Build       *buildspec.Spec
+	Ports       []string // short syntax: [host_ip:][published:]target[/protocol]
+	Expose      []string
+	Environment map[string]string
+	EnvFile     []EnvFile
+	DependsOn   map[string]Dependency
+	Volumes     []Volume
+	Networks    map[string]ServiceNetwork
+	Command     []string
+	Entrypoint  []string
+	Healthcheck *Healthcheck
+	Labels      map[string]string
+	WorkingDir  string
+	User        string
+	Restart     string
+	Profiles    []string
+	Extends     *Extends
+
+	// Unsupported describes the keys and options Pier ignores, to warn about
+	Unsupported []string
+}
+
+// EnvFile is an env_file entry
+type EnvFile struct {
+	Path     string
+	Required bool
+}
+
+// Dependency conditions of depends_on
+const (
+	ServiceStarted               = "service_started"
+	ServiceHealthy               = "service_healthy"
+	ServiceCompletedSuccessfully = "service_completed_successfully"
+)
+
+// Dependency is a depends_on entry
+type Dependency struct {
+	Condition string
+	Required  bool
+}
+
+// Volume is a service's volume
+type Volume struct {
+	Type   string // bind, volume or tmpfs
+	Source string // host path or volume name; empty for an anonymous volume
+	Target string
+	Mode   string // e.g. "ro", or "ro,z"
+}
+
+// ServiceNetwork is a network a service joins
+type ServiceNetwork struct {
+	Aliases []string `yaml:"aliases"`
+}
+
+// Extends names the service a service is based on, in another file or
+// this one
+type Extends struct {
+	Service string `yaml:"service"`
+	File    string `yaml:"file"`
+}
+
+// Healthcheck is a service's healthcheck, overriding the image's
+type Healthcheck struct {
+	Test        []string // {"CMD-SHELL", cmd}, {"CMD", args...}, or {"NONE"}
+	Interval    time.Duration
+	Timeout     time.Duration
+	StartPeriod time.Duration
+	Retries     int
+}
+
+// supportedKeys are the service keys Pier honours
+var supportedKeys = map[string]bool{
+	"image": true, "build": true, "ports": true, "expose": true,
+	"environment": true, "env_file": true, "depends_on": true, "volumes": true,
+	"networks": true, "command": true, "entrypoint": true, "healthcheck": true,
+	"labels": true, "working_dir": true, "user": true, "restart": true,
+	"profiles": true, "extends": true,
+}
+
+// UnmarshalYAML reads a service, accepting both syntaxes of each key
+func (s *ComposeService) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.MappingNode {
+		return fmt.Errorf("a service must be a map")
+	}
+	var raw struct {
+		Image       string          `yaml:"image"`
+		Build       *buildspec.Spec `yaml:"build"`
+		Ports       []yaml.Node     `yaml:"ports"`
+		Expose      []string        `yaml:"expose"`
+		Environment yaml.Node       `yaml:"environment"`
+		EnvFile     yaml.Node       `yaml:"env_file"`
+		DependsOn   yaml.Node       `yaml:"depends_on"`
+		Volumes     []yaml.Node     `yaml:"volumes"`
+		Networks    yaml.Node       `yaml:"networks"`
+		Command     yaml.Node       `yaml:"command"`
+		Entrypoint  yaml.Node       `yaml:"entrypoint"`
+		Healthcheck *Healthcheck    `yaml:"healthcheck"`
+		Labels      yaml.Node       `yaml:"labels"`
+		WorkingDir  string          `yaml:"working_dir"`
+		User        string          `yaml:"user"`
+		Restart     string          `yaml:"restart"`
+		Profiles    []string        `yaml:"profiles"`
+		Extends     yaml.Node       `yaml:"extends"`
+	}
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+	*s = ComposeService{
+		Image:       raw.Image,
+		Build:       raw.Build,
+		Expose:      raw.Expose,
+		Healthcheck: raw.Healthcheck,
+		WorkingDir:  raw.WorkingDir,
+		User:        raw.User,
+		Restart:     raw.Restart,
+		Profiles:    raw.Profiles,
+	}
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		key := value.Content[i].Value
+		if !supportedKeys[key] && !strings.HasPrefix(key, "x-") {
+			s.Unsupported = append(s.Unsupported, key+" isn't supported and is ignored")
+		}
+	}
+
+	var err error
+	for _, p := range raw.Ports {
+		port, err := decodePort(&p)
+		if err != nil {
+			return err
+		}
+		s.Ports = append(s.Ports, port)
+	}
+	env, err := decodeMapping(&raw.Environment, "environment")
+	if err != nil {
+		return err
+	}
+	if env != nil {
+		s.Environment = map[string]string{}
+		for k, v := range env {
+			// Parse fills in a bare KEY from the compose variables; one
+			// left bare is unset
+			if v != nil {
+				s.Environment[k] = *v
+			}
+		}
+	}
+	if s.EnvFile, err = decodeEnvFile(&raw.EnvFile); err != nil {
+		return err
+	}
+	if s.DependsOn, err = decodeDependsOn(&raw.DependsOn); err != nil {
+		return err
+	}
+	for name, dep := range s.DependsOn {
+		if dep.Condition != ServiceStarted && dep.Condition != ServiceHealthy && dep.Condition != ServiceCompletedSuccessfully {
+			return fmt.Errorf("depends_on %s: unknown condition %q", name, dep.Condition)
+		}
+	}
+	if restarts := dependsOnRestart(&raw.DependsOn); len(restarts) > 0 {
+		s.Unsupported = append(s.Unsupported, fmt.Sprintf("depends_on restart isn't supported (%s)", strings.Join(restarts, ", ")))
+	}
+	for _, v := range raw.Volumes {
+		vol, err := decodeVolume(&v)
+		if err != nil {
+			return err
+		}
+		switch {
+		case vol.Type == "tmpfs":
+			s.Unsupported = append(s.Unsupported, fmt.Sprintf("tmpfs volume %s isn't supported and is skipped", vol.Target))
+			continue
+		case vol.Source == "":
+			s.Unsupported = append(s.Unsupported, fmt.Sprintf("anonymous volume %s isn't supported and is skipped", vol.Target))
+			continue
+		}
+		s.Volumes = append(s.Volumes, vol)
+	}
+	if s.Networks, err = decodeNetworks(&raw.Networks); err != nil {
+		return err
+	}
+	for name := range s.Networks {
+		if name != "default" {
+			s.Unsupported = append(s.Unsupported, fmt.Sprintf("network %s doesn't isolate the service: all services share the pier network (its aliases are kept)", name))
+		}
+	}
+	if s.Command, err = decodeCommand(&raw.Command, "command"); err != nil {
+		return err
+	}
+	if s.Entrypoint, err = decodeCommand(&raw.Entrypoint, "entrypoint"); err != nil {
+		return err
+	}
+	labels, err := decodeMapping(&raw.Labels, "labels")
+	if err != nil {
+		return err
+	}
+	if labels != nil {
+		s.Labels = map[string]string{}
+		for k, v := range labels {
+			s.Labels[k] = ""
+			if v != nil {
+				s.Labels[k] = *v
+			}
+		}
+	}
+	s.Extends, err = decodeExtends(&raw.Extends)
+	return err
+}
+
+// UnmarshalYAML reads test as a shell command or an exec list, and
+// `disable: true` as {"NONE"}
+func (h *Healthcheck) UnmarshalYAML(value *yaml.Node) error {
+	var raw struct {
+		Test        yaml.Node `yaml:"test"`
+		Interval    string    `yaml:"interval"`
+		Timeout     string    `yaml:"timeout"`
+		StartPeriod string    `yaml:"start_period"`
+		Retries     int       `yaml:"retries"`
+		Disable     bool      `yaml:"disable"`
+	}
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+	*h = Healthcheck{Retries: raw.Retries}
+	switch {
+	case raw.Disable:
+		h.Test = []string{"NONE"}
+	case raw.Test.Kind == yaml.ScalarNode:
+		h.Test = []string{"CMD-SHELL", raw.Test.Value}
+	case raw.Test.Kind == yaml.SequenceNode:
+		if err := raw.Test.Decode(&h.Test); err != nil {
+			return fmt.Errorf("healthcheck test: %w", err)
+		}
+	}
+	for _, d := range []struct {
+		key string
+		in  string
+		out *time.Duration
+	}{
+		{"interval", raw.Interval, &h.Interval},
+		{"timeout", raw.Timeout, &h.Timeout},
+		{"start_period", raw.StartPeriod, &h.StartPeriod},
+	} {
+		if d.in == "" {
+			continue
+		}
+		v, err := time.ParseDuration(d.in)
+		if err != nil {
+			return fmt.Errorf("healthcheck %s: %w", d.key, err)
+		}
+		*d.out = v
+	}
+	return nil
+}
+
+// decodePort reads a port in the short syntax or the long one
+// ({target, published, host_ip, protocol}), returning the short syntax
+func decodePort(node *yaml.Node) (string, error) {
+	if node.Kind == yaml.ScalarNode {
+		return node.Value, nil
+	}
+	var long struct {
+		Target    string `yaml:"target"`
+		Published string `yaml:"published"`
+		HostIP    string `yaml:"host_ip"`
+		Protocol  string `yaml:"protocol"`
+	}
+	if err := node.Decode(&long); err != nil {
+		return "", fmt.Errorf("ports: %w", err)
+	}
+	if long.Target == "" {
+		return "", fmt.Errorf("ports: target is required")
+	}
+	port := long.Target
+	if long.Published != "" {
+		port = long.Published + ":" + port
+		if long.HostIP != "" {
+			port = long.HostIP + ":" + port
+		}
+	}
+	if long.Protocol != "" {
+		port += "/" + long.Protocol
+	}
+	return port, nil
+}
+
+// decodeMapping reads a map or a KEY=VALUE list. A key without a value
+// maps to nil.
+func decodeMapping(node *yaml.Node, key string) (map[string]*string, error) {
+	m := map[string]*string{}
+	switch node.Kind {
+	case 0:
+		return nil, nil
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			k, v := node.Content[i].Value, node.Content[i+1]
+			if v.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("%s: %s must be a string", key, k)
+			}
+			if v.Tag == "!!null" {
+				m[k] = nil
+				continue
+			}
+			val := v.Value
+			m[k] = &val
+		}
+	case yaml.SequenceNode:
+		var list []string
+		if err := node.Decode(&list); err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		for _, item := range list {
+			k, v, ok := strings.Cut(item, "=")
+			if !ok {
+				m[k] = nil
+				continue
+			}
+			m[k] = &v
+		}
+	default:
+		return nil, fmt.Errorf("%s must be a map or a list", key)
+	}
+	return m, nil
+}
+
+// decodeEnvFile reads env_file: a path, or a list of paths and
+// {path, required} entries
+func decodeEnvFile(node *yaml.Node) ([]EnvFile, error) {
+	switch node.Kind {
+	case 0:
+		return nil, nil
+	case yaml.ScalarNode:
+		return []EnvFile{{Path: node.Value, Required: true}}, nil
+	case yaml.SequenceNode:
+	default:
+		return nil, fmt.Errorf("env_file must be a path or a list")
+	}
+	var files []EnvFile
+	for _, item := range node.Content {
+		if item.Kind == yaml.ScalarNode {
+			files = append(files, EnvFile{Path: item.Value, Required: true})
+			continue
+		}
+		long := struct {
+			Path     string `yaml:"path"`
+			Required *bool  `yaml:"required"`
+		}{}
+		if err := item.Decode(&long); err != nil {
+			return nil, fmt.Errorf("env_file: %w", err)
+		}
+		files = append(files, EnvFile{Path: long.Path, Required: long.Required == nil || *long.Required})
+	}
+	return files, nil
+}
+
+// decodeDependsOn reads depends_on: a list of services, or a map of them to
+// {condition, required}
+func decodeDependsOn(node *yaml.Node) (map[string]Dependency, error) {
+	deps := map[string]Dependency{}
+	switch node.Kind {
+	case 0:
+		return nil, nil
+	case yaml.SequenceNode:
+		var names []string
+		if err := node.Decode(&names); err != nil {
+			return nil, fmt.Errorf("depends_on: %w", err)
+		}
+		for _, name := range names {
+			deps[name] = Dependency{Condition: ServiceStarted, Required: true}
+		}
+	case yaml.MappingNode:
+		var long map[string]struct {
+			Condition string `yaml:"condition"`
+			Required  *bool  `yaml:"required"`
+		}
+		if err := node.Decode(&long); err != nil {
+			return nil, fmt.Errorf("depends_on: %w", err)
+		}
+		for name, d := range long {
+			dep := Dependency{Condition: d.Condition, Required: d.Required == nil || *d.Required}
+			if dep.Condition == "" {
+				dep.Condition = ServiceStarted
+			}
+			deps[name] = dep
+		}
+	default:
+		return nil, fmt.Errorf("depends_on must be a list or a map")
+	}
+	return deps, nil
+}
+
+// dependsOnRestart lists the dependencies set to restart the service when
+// they're updated
+func dependsOnRestart(node *yaml.Node) []string {
+	var long map[string]struct {
+		Restart bool `yaml:"restart"`
+	}
+	if node.Kind != yaml.MappingNode || node.Decode(&long) != nil {
+		return nil
+	}
+	var names []string
+	for name, d := range long {
+		if d.Restart {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// decodeVolume reads a volume in the short syntax (SOURCE:TARGET[:MODE], or
+// a bare TARGET) or the long one
+func decodeVolume(node *yaml.Node) (Volume, error) {
+	if node.Kind == yaml.ScalarNode {
+		parts := strings.SplitN(node.Value, ":", 3)
+		if len(parts) == 1 {
+			return Volume{Type: "volume", Target: parts[0]}, nil
+		}
+		vol := Volume{Type: "volume", Source: parts[0], Target: parts[1]}
+		if len(parts) == 3 {
+			vol.Mode = parts[2]
+		}
+		if isPath(vol.Source) {
+			vol.Type = "bind"
+		}
+		return vol, nil
+	}
+
+	var long struct {
+		Type     string `yaml:"type"`
+		Source   string `yaml:"source"`
+		Target   string `yaml:"target"`
+		ReadOnly bool   `yaml:"read_only"`
+	}
+	if err := node.Decode(&long); err != nil {
+		return Volume{}, fmt.Errorf("volumes: %w", err)
+	}
+	if long.Target == "" {
+		return Volume{}, fmt.Errorf("volumes: target is required")
+	}
+	vol := Volume{Type: long.Type, Source: long.Source, Target: long.Target}
+	if vol.Type == "" {
+		vol.Type = "volume"
+	}
+	if long.ReadOnly {
+		vol.Mode = "ro"
+	}
+	return vol, nil
+}
+
+// isPath reports whether a volume source is a host path rather than a
+// volume name
+func isPath(source string) bool {
+	return strings.HasPrefix(source, ".") || strings.HasPrefix(source, "~") || filepath.IsAbs(source)
+}
+
+// decodeNetworks reads networks: a list of names, or a map of them to
+// null or {aliases}
+func decodeNetworks(node *yaml.Node) (map[string]ServiceNetwork, error) {
+	networks := map[string]ServiceNetwork{}
+	switch node.Kind {
+	case 0:
+		return nil, nil
+	case yaml.SequenceNode:
+		var names []string
+		if err := node.Decode(&names); err != nil {
+			return nil, fmt.Errorf("networks: %w", err)
+		}
+		for _, name := range names {
+			networks[name] = ServiceNetwork{}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			var n ServiceNetwork
+			if v := node.Content[i+1]; v.Tag != "!!null" {
+				if err := v.Decode(&n); err != nil {
+					return nil, fmt.Errorf("networks: %w", err)
+				}
+			}
+			networks[node.Content[i].Value] = n
+		}
+	default:
+		return nil, fmt.Errorf("networks must be a list or a map")
+	}
+	return networks, nil
+}
+
+// decodeCommand reads command or entrypoint: a list, or a string split
+// like a shell would
+func decodeCommand(node *yaml.Node, key string) ([]string, error) {
+	switch node.Kind {
+	case 0:
+		return nil, nil
+	case yaml.ScalarNode:
+		if node.Tag == "!!null" {
+			return nil, nil
+		}
+		args, err := splitCommand(node.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		return args, nil
+	case yaml.SequenceNode:
+		var args []string
+		if err := node.Decode(&args); err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		return args, nil
+	}
+	return nil, fmt.Errorf("%s must be a string or a list", key)
+}
+
+// splitCommand splits a command line into words, honouring quotes and
+// backslash escapes
+func splitCommand(s string) ([]string, error) {
+	var args []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				args = append(args, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape in %q", s)
+	}
+	if inWord {
+		args = append(args, word.String())
+	}
+	return args, nil
+}
+
+// decodeExtends reads extends: a service name, or {service, file}
+func decodeExtends(node *yaml.Node) (*Extends, error) {
+	switch node.Kind {
+	case 0:
+		return nil, nil
+	case yaml.ScalarNode:
+		return &Extends{Service: node.Value}, nil
+	}
+	var ext Extends
+	if err := node.Decode(&ext); err != nil {
+		return nil, fmt.Errorf("extends: %w", err)
+	}
+	if ext.Service == "" {
+		return nil, fmt.Errorf("extends: service is required")
+	}
+	return &ext, nil
+}
+
+// extend returns s based on base: s's own settings win, maps are merged,
+// and lists are appended to base's
+func (s ComposeService) extend(base ComposeService) ComposeService {
+	merged := base
+	merged.Extends = nil
+	if s.Image != "" {
+		merged.Image = s.Image
+	}
+	if s.Build != nil {
+		merged.Build = s.Build
+	}
+	merged.Ports = append(append([]string{}, base.Ports...), s.Ports...)
+	merged.Expose = append(append([]string{}, base.Expose...), s.Expose...)
+	merged.Environment = mergeMaps(base.Environment, s.Environment)
+	merged.EnvFile = append(append([]EnvFile{}, base.EnvFile...), s.EnvFile...)
+	merged.Labels = mergeMaps(base.Labels, s.Labels)
+	if s.DependsOn != nil {
+		merged.DependsOn = map[string]Dependency{}
+		for k, v := range base.DependsOn {
+			merged.DependsOn[k] = v
+		}
+		for k, v := range s.DependsOn {
+			merged.DependsOn[k] = v
+		}
+	}
+	if s.Networks != nil {
+		merged.Networks = map[string]ServiceNetwork{}
+		for k, v := range base.Networks {
+			merged.Networks[k] = v
+		}
+		for k, v := range s.Networks {
+			merged.Networks[k] = v
+		}
+	}
+	// A volume replaces base's one at the same target
+	merged.Volumes = nil
+	for _, v := range base.Volumes {
+		overridden := false
+		for _, o := range s.Volumes {
+			overridden = overridden || o.Target == v.Target
+		}
+		if !overridden {
+			merged.Volumes = append(merged.Volumes, v)
+		}
+	}
+	merged.Volumes = append(merged.Volumes, s.Volumes...)
+	if s.Command != nil {
+		merged.Command = s.Command
+	}
+	if s.Entrypoint != nil {
+		merged.Entrypoint = s.Entrypoint
+	}
+	if s.Healthcheck != nil {
+		merged.Healthcheck = s.Healthcheck
+	}
+	if s.WorkingDir != "" {
+		merged.WorkingDir = s.WorkingDir
+	}
+	if s.User != "" {
+		merged.User = s.User
+	}
+	if s.Restart != "" {
+		merged.Restart = s.Restart
+	}
+	if s.Profiles != nil {
+		merged.Profiles = s.Profiles
+	}
+	merged.Unsupported = append(append([]string{}, base.Unsupported...), s.Unsupported...)
+	return merged
+}
+
+func mergeMaps(base, over map[string]string) map[string]string {
+	if base == nil && over == nil {
+		return nil
+	}
+	m := make(map[string]string, len(base)+len(over))
+	for k, v := range base {
+		m[k] = v
+	}
+	for k, v := range over {
+		m[k] = v
+	}
+	return m
+}
+
+// rebase makes s's relative paths, written relative to another file's
+// directory, relative to the one rel leads to it from
+func (s ComposeService) rebase(rel string) ComposeService {
+	join := func(p string) string {
+		if p == "" || filepath.IsAbs(p) || strings.HasPrefix(p, "~") {
+			return p
+		}
+		return filepath.Join(rel, p)
+	}
+	if s.Build != nil {
+		build := *s.Build
+		build.Context = join(build.Context)
+		if build.Context == "" {
+			build.Context = rel
+		}
+		s.Build = &build
+	}
+	s.EnvFile = append([]EnvFile{}, s.EnvFile...)
+	for i := range s.EnvFile {
+		s.EnvFile[i].Path = join(s.EnvFile[i].Path)
+	}
+	s.Volumes = append([]Volume{}, s.Volumes...)
+	for i, v := range s.Volumes {
+		if v.Type == "bind" {
+			s.Volumes[i].Source = join(v.Source)
+		}
+	}
+	return s
+}
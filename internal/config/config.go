@@ -15,6 +15,7 @@ type Config struct {
 	Traefik TraefikConfig `yaml:"traefik"`
 	Network string        `yaml:"network"`
 	Nginx   NginxConfig   `yaml:"nginx"`
+	Runtime string        `yaml:"runtime,omitempty"` // docker, podman, or empty to detect
 }
 
 // TraefikConfig holds Traefik-specific settings
@@ -64,6 +65,33 @@ func LinksDir() string {
 	return filepath.Join(PierDir(), "links")
 }
 
+// BackupsDir returns the directory for shared-service database dumps
+func BackupsDir() string {
+	return filepath.Join(PierDir(), "backups")
+}
+
+// SecretsPath returns the path to secrets.yaml, a flat name: value map
+// referenced from Pierfile env templates
+func SecretsPath() string {
+	return filepath.Join(PierDir(), "secrets.yaml")
+}
+
+// LoadSecrets reads secrets.yaml. A missing file yields an empty map.
+func LoadSecrets() (map[string]string, error) {
+	secrets := map[string]string{}
+	data, err := os.ReadFile(SecretsPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return secrets, nil
+		}
+		return nil, fmt.Errorf("reading secrets: %w", err)
+	}
+	if err := yaml.Unmarshal(data, &secrets); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", SecretsPath(), err)
+	}
+	return secrets, nil
+}
+
 // LinkMeta describes a linked dev service
 type LinkMeta struct {
 	Name    string `json:"name"`
@@ -72,6 +100,16 @@ type LinkMeta struct {
 	Command string `json:"command"`
 }
 
+// DaemonSocketPath returns the unix socket pierd serves its API on
+func DaemonSocketPath() string {
+	return filepath.Join(PierDir(), "pierd.sock")
+}
+
+// DaemonLogPath returns pierd's log file
+func DaemonLogPath() string {
+	return filepath.Join(PierDir(), "logs", "pierd.log")
+}
+
 // NginxDir returns the nginx config directory
 func NginxDir() string {
 	return filepath.Join(PierDir(), "nginx")
@@ -153,6 +191,8 @@ func (c *Config) Get(key string) (string, error) {
 		return fmt.Sprintf("%t", c.Nginx.Managed), nil
 	case "nginx.valet_compatible":
 		return fmt.Sprintf("%t", c.Nginx.ValetCompatible), nil
+	case "runtime":
+		return c.Runtime, nil
 	default:
 		return "", fmt.Errorf("unknown config key: %s", key)
 	}
@@ -179,6 +219,11 @@ func (c *Config) Set(key, value string) error {
 		c.Nginx.Managed = value == "true"
 	case "nginx.valet_compatible":
 		c.Nginx.ValetCompatible = value == "true"
+	case "runtime":
+		if value != "" && value != "docker" && value != "podman" {
+			return fmt.Errorf("invalid runtime: %s (expected docker or podman)", value)
+		}
+		c.Runtime = value
 	default:
 		return fmt.Errorf("unknown config key: %s", key)
 	}
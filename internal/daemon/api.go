@@ -0,0 +1,104 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/eshe-huli/pier/internal/config"
+)
+
+// Handler serves the daemon API:
+//
+//	GET  /v1/health     liveness and pid
+//	GET  /v1/status     the last reconciled Snapshot
+//	POST /v1/reconcile  reconcile now and return the new Snapshot
+func (d *Daemon) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/health", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{"status": "ok", "pid": os.Getpid()})
+	})
+	mux.HandleFunc("GET /v1/status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, d.Snapshot(r.Context()))
+	})
+	mux.HandleFunc("POST /v1/reconcile", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, d.Reconcile(r.Context()))
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// clientTimeout keeps the CLI snappy when pierd is wedged; callers fall
+// back to scanning on error
+const clientTimeout = 2 * time.Second
+
+// client talks HTTP to pierd over its unix socket
+func client(socket string) *http.Client {
+	return &http.Client{
+		Timeout: clientTimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+}
+
+// Status fetches pierd's current snapshot. An error means pierd isn't
+// running (or isn't answering).
+func Status(ctx context.Context) (*Snapshot, error) {
+	return call(ctx, http.MethodGet, "/v1/status")
+}
+
+// Reconcile asks pierd to reconcile now and returns the new snapshot
+func Reconcile(ctx context.Context) (*Snapshot, error) {
+	return call(ctx, http.MethodPost, "/v1/reconcile")
+}
+
+// Running reports whether pierd answers on its socket
+func Running(ctx context.Context) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://pierd/v1/health", nil)
+	if err != nil {
+		return false
+	}
+	resp, err := client(config.DaemonSocketPath()).Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+func call(ctx context.Context, method, path string) (*Snapshot, error) {
+	socket := config.DaemonSocketPath()
+	if _, err := os.Stat(socket); err != nil {
+		return nil, fmt.Errorf("pierd is not running")
+	}
+	req, err := http.NewRequestWithContext(ctx, method, "http://pierd"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client(socket).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("contacting pierd: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("pierd returned status %d", resp.StatusCode)
+	}
+
+	var snap Snapshot
+	if err := json.NewDecoder(resp.Body).Decode(&snap); err != nil {
+		return nil, fmt.Errorf("parsing pierd response: %w", err)
+	}
+	return &snap, nil
+}
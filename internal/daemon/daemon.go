@@ -0,0 +1,199 @@
+// Package daemon implements pierd, the background process that watches
+// container events and keeps routes, the registry and linked dev servers in
+// line with what is actually running.
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/eshe-huli/pier/internal/config"
+	"github.com/eshe-huli/pier/internal/docker"
+	"github.com/eshe-huli/pier/internal/registry"
+)
+
+// Interval is how often pierd reconciles without an event; linked dev
+// servers are host processes, so nothing else reports their crashes
+const Interval = 15 * time.Second
+
+// debounce coalesces bursts of container events into one reconcile
+const debounce = 500 * time.Millisecond
+
+// Daemon is a running pierd
+type Daemon struct {
+	cfg *config.Config
+	log *log.Logger
+
+	// restart relaunches a crashed linked project; replaced in tests
+	restart func(ctx context.Context, p registry.Project) error
+
+	mu       sync.Mutex // guards snapshot and restarts; held for a whole reconcile
+	snapshot *Snapshot
+	restarts map[string][]time.Time
+
+	kick chan struct{}
+}
+
+// New returns a daemon for cfg that logs to logger
+func New(cfg *config.Config, logger *log.Logger) *Daemon {
+	return &Daemon{
+		cfg:      cfg,
+		log:      logger,
+		restart:  relink,
+		restarts: map[string][]time.Time{},
+		kick:     make(chan struct{}, 1),
+	}
+}
+
+// Run serves the API on the daemon socket and reconciles on every
+// container event and every Interval until ctx is done
+func (d *Daemon) Run(ctx context.Context) error {
+	ln, err := Listen(config.DaemonSocketPath())
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: d.Handler(), ReadTimeout: 5 * time.Second}
+	go func() { _ = srv.Serve(ln) }()
+	defer srv.Close()
+	defer os.Remove(config.DaemonSocketPath())
+
+	d.logf("pierd listening on %s", config.DaemonSocketPath())
+	d.Reconcile(ctx)
+
+	go d.watchEvents(ctx)
+
+	ticker := time.NewTicker(Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			d.logf("pierd stopping")
+			return nil
+		case <-ticker.C:
+			d.Reconcile(ctx)
+		case <-d.kick:
+			// Let the rest of a burst (die, stop, destroy) arrive first
+			time.Sleep(debounce)
+			d.Reconcile(ctx)
+		}
+	}
+}
+
+// Reconcile runs a reconcile pass now and returns the new snapshot
+func (d *Daemon) Reconcile(ctx context.Context) *Snapshot {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.snapshot = d.reconcile(ctx)
+	return d.snapshot
+}
+
+// Snapshot returns the last reconciled state, reconciling first if there
+// is none yet
+func (d *Daemon) Snapshot(ctx context.Context) *Snapshot {
+	d.mu.Lock()
+	snap := d.snapshot
+	d.mu.Unlock()
+	if snap == nil {
+		return d.Reconcile(ctx)
+	}
+	return snap
+}
+
+// watchEvents schedules a reconcile for each container lifecycle event,
+// resubscribing if the event stream drops (e.g. Docker restarted)
+func (d *Daemon) watchEvents(ctx context.Context) {
+	for ctx.Err() == nil {
+		rt, err := docker.Runtime()
+		if err != nil {
+			d.logf("waiting for container runtime: %v", err)
+			sleep(ctx, 5*time.Second)
+			continue
+		}
+		events, errs := rt.Events(ctx)
+	stream:
+		for {
+			select {
+			case ev, ok := <-events:
+				if !ok {
+					break stream
+				}
+				switch ev.Action {
+				case "start", "die", "destroy", "rename":
+					d.logf("container %s: %s", ev.Name, ev.Action)
+					d.schedule()
+				}
+			case err := <-errs:
+				d.logf("event stream: %v", err)
+				break stream
+			case <-ctx.Done():
+				return
+			}
+		}
+		sleep(ctx, 2*time.Second)
+	}
+}
+
+// schedule asks the run loop for a reconcile, coalescing pending requests
+func (d *Daemon) schedule() {
+	select {
+	case d.kick <- struct{}{}:
+	default:
+	}
+}
+
+func (d *Daemon) logf(format string, args ...interface{}) {
+	if d.log != nil {
+		d.log.Printf(format, args...)
+	}
+}
+
+// relink restarts a crashed dev server with `pier link`, so it gets the same
+// env, pid file and route as the original
+func relink(ctx context.Context, p registry.Project) error {
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	c := exec.CommandContext(ctx, self, "link", p.Name, "--port", strconv.Itoa(p.Port))
+	c.Dir = p.Dir
+	out, err := c.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, out)
+	}
+	return nil
+}
+
+// Listen opens the daemon socket, replacing a stale one left by a crashed
+// pierd but refusing to steal one that is still served
+func Listen(path string) (net.Listener, error) {
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return nil, errors.New("pierd is already running")
+	}
+	_ = os.Remove(path)
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("listening on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
@@ -0,0 +1,243 @@
+package daemon
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/eshe-huli/pier/internal/config"
+	"github.com/eshe-huli/pier/internal/docker"
+	"github.com/eshe-huli/pier/internal/proxy"
+	"github.com/eshe-huli/pier/internal/registry"
+)
+
+// setup isolates HOME and installs a fake runtime with the pier network
+func setup(t *testing.T) (*Daemon, *docker.Fake) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	if err := config.EnsureDirectories(); err != nil {
+		t.Fatal(err)
+	}
+
+	fake := docker.NewFake()
+	fake.Networks["pier"] = true
+	fake.Images["shop:latest"] = true
+	docker.SetRuntime(fake)
+	t.Cleanup(func() { docker.SetRuntime(nil) })
+
+	return New(config.Default(), nil), fake
+}
+
+func startContainer(t *testing.T, fake *docker.Fake, name string) {
+	t.Helper()
+	ctx := context.Background()
+	id, err := fake.CreateContainer(ctx, docker.RunSpec{Name: name, Image: "shop:latest", Network: "pier"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fake.StartContainer(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// linkProject registers a linked project whose dev.pid holds pid
+func linkProject(t *testing.T, name string, pid int) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), name)
+	if err := os.MkdirAll(filepath.Join(dir, ".pier"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if pid > 0 {
+		if err := os.WriteFile(filepath.Join(dir, ".pier", "dev.pid"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := registry.Register(registry.Project{Name: name, Dir: dir, Port: 3000, Command: "npm run dev", Type: "link"}); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// deadPID returns the pid of a process that has already exited
+func deadPID(t *testing.T) int {
+	t.Helper()
+	p, err := os.StartProcess("/bin/true", []string{"true"}, &os.ProcAttr{})
+	if err != nil {
+		t.Skip("cannot start /bin/true:", err)
+	}
+	if _, err := p.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	return p.Pid
+}
+
+func TestReconcileRemovesRouteToMissingContainer(t *testing.T) {
+	d, _ := setup(t)
+	if err := proxy.CreateContainerProxy("shop", "shop", 3000, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	snap := d.Reconcile(context.Background())
+
+	r, ok := snap.Route("shop")
+	if !ok || r.Status != RouteRemoved {
+		t.Fatalf("route = %+v, want removed", r)
+	}
+	if proxy.FileProxyExists("shop") {
+		t.Error("route file for a missing container was kept")
+	}
+}
+
+func TestReconcileMarksStoppedContainerStale(t *testing.T) {
+	d, fake := setup(t)
+	startContainer(t, fake, "shop")
+	if err := proxy.CreateContainerProxy("shop", "shop", 3000, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	if r, _ := d.Reconcile(context.Background()).Route("shop"); r.Status != RouteActive {
+		t.Fatalf("running container: status = %q, want active", r.Status)
+	}
+
+	fake.Crash("shop", 1)
+	if r, _ := d.Reconcile(context.Background()).Route("shop"); r.Status != RouteStale {
+		t.Fatalf("crashed container: status = %q, want stale", r.Status)
+	}
+	if !proxy.FileProxyExists("shop") {
+		t.Error("route to a stopped container was removed")
+	}
+}
+
+func TestReconcileRestartsCrashedLink(t *testing.T) {
+	d, _ := setup(t)
+	dir := linkProject(t, "blog", deadPID(t))
+
+	var restarted []string
+	d.restart = func(ctx context.Context, p registry.Project) error {
+		restarted = append(restarted, p.Name)
+		return nil
+	}
+
+	l, _ := d.Reconcile(context.Background()).Link("blog")
+	if l.Status != LinkRestarting || l.Restarts != 1 {
+		t.Fatalf("link = %+v, want restarting after 1 restart", l)
+	}
+	if len(restarted) != 1 {
+		t.Fatalf("restarts = %v, want [blog]", restarted)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".pier", "dev.pid")); !os.IsNotExist(err) {
+		t.Error("dead dev.pid was not removed")
+	}
+}
+
+func TestReconcileGivesUpOnCrashLoop(t *testing.T) {
+	d, _ := setup(t)
+	dir := linkProject(t, "blog", 0)
+	pidFile := filepath.Join(dir, ".pier", "dev.pid")
+	pid := deadPID(t)
+
+	calls := 0
+	d.restart = func(ctx context.Context, p registry.Project) error {
+		calls++
+		return nil
+	}
+
+	var l Link
+	for i := 0; i <= maxRestarts; i++ {
+		if err := os.WriteFile(pidFile, []byte(strconv.Itoa(pid)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		l, _ = d.Reconcile(context.Background()).Link("blog")
+	}
+	if l.Status != LinkCrashed {
+		t.Errorf("status = %q, want crashed", l.Status)
+	}
+	if calls != maxRestarts {
+		t.Errorf("restarted %d times, want %d", calls, maxRestarts)
+	}
+}
+
+func TestReconcileLeavesUnlinkedProjectStopped(t *testing.T) {
+	d, _ := setup(t)
+	linkProject(t, "blog", 0)
+	d.restart = func(ctx context.Context, p registry.Project) error {
+		t.Error("restarted a project that was stopped on purpose")
+		return nil
+	}
+
+	if l, _ := d.Reconcile(context.Background()).Link("blog"); l.Status != LinkStopped {
+		t.Errorf("status = %q, want stopped", l.Status)
+	}
+}
+
+func TestReconcilePrunesDeletedProjects(t *testing.T) {
+	d, _ := setup(t)
+	dir := linkProject(t, "blog", 0)
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	d.Reconcile(context.Background())
+
+	if p, _ := registry.Get("blog"); p != nil {
+		t.Errorf("registry still has %s after its directory was deleted", p.Name)
+	}
+}
+
+func TestReconcilePrunesStalePlaceholders(t *testing.T) {
+	d, _ := setup(t)
+	// A deploy that allocated shared resources but failed before registering
+	if err := registry.RecordServices("ghost", []string{"redis:7"}); err != nil {
+		t.Fatal(err)
+	}
+
+	d.Reconcile(context.Background())
+	if p, _ := registry.Get("ghost"); p == nil {
+		t.Fatal("a fresh placeholder was pruned while its deploy may still run")
+	}
+
+	ttl := placeholderTTL
+	placeholderTTL = 0
+	t.Cleanup(func() { placeholderTTL = ttl })
+	d.Reconcile(context.Background())
+	if p, _ := registry.Get("ghost"); p != nil {
+		t.Error("registry still has the stale placeholder")
+	}
+}
+
+func TestStatusOverSocket(t *testing.T) {
+	d, fake := setup(t)
+	startContainer(t, fake, "shop")
+
+	ctx := context.Background()
+	if _, err := Status(ctx); err == nil {
+		t.Fatal("Status succeeded with no pierd running")
+	}
+
+	ln, err := Listen(config.DaemonSocketPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = http.Serve(ln, d.Handler()) }()
+	t.Cleanup(func() { ln.Close() })
+
+	if _, err := Listen(config.DaemonSocketPath()); err == nil {
+		t.Error("second Listen stole a live socket")
+	}
+	if !Running(ctx) {
+		t.Fatal("Running = false with pierd serving")
+	}
+	snap, err := Reconcile(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snap.Containers) != 1 || snap.Containers[0].Name != "shop" {
+		t.Errorf("containers = %+v, want [shop]", snap.Containers)
+	}
+	if _, err := Status(ctx); err != nil {
+		t.Errorf("Status: %v", err)
+	}
+}
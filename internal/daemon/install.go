@@ -0,0 +1,155 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+
+	"github.com/eshe-huli/pier/internal/config"
+)
+
+// pierd's systemd unit and launchd label
+const (
+	systemdUnit  = "pierd.service"
+	launchdLabel = "dev.pier.pierd"
+)
+
+// Unit is a service definition for the host's init system
+type Unit struct {
+	Path    string   // where the file is installed
+	Content string   // the file contents
+	Enable  []string // command that loads and starts it
+	Disable []string // command that stops and unloads it
+	Logs    string   // where pierd's output ends up
+}
+
+// The templates write values through quote or command (systemd) or xml
+// (launchd): paths may hold spaces, quotes, % or &.
+var templateFuncs = template.FuncMap{
+	"quote": systemdQuote,
+	"command": func(s string) string {
+		// ExecStart expands $VAR, unlike Environment
+		return systemdQuote(strings.ReplaceAll(s, "$", "$$"))
+	},
+	"xml": func(s string) (string, error) {
+		var b strings.Builder
+		err := xml.EscapeText(&b, []byte(s))
+		return b.String(), err
+	},
+}
+
+var systemdTemplate = template.Must(template.New("systemd").Funcs(templateFuncs).Parse(`[Unit]
+Description=Pier daemon (pierd)
+After=default.target
+
+[Service]
+ExecStart={{command .Binary}} daemon run
+Restart=on-failure
+RestartSec=2
+Environment={{quote (print "PATH=" .Path)}}
+
+[Install]
+WantedBy=default.target
+`))
+
+var launchdTemplate = template.Must(template.New("launchd").Funcs(templateFuncs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>{{xml .Label}}</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>{{xml .Binary}}</string>
+		<string>daemon</string>
+		<string>run</string>
+	</array>
+	<key>EnvironmentVariables</key>
+	<dict>
+		<key>PATH</key>
+		<string>{{xml .Path}}</string>
+	</dict>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<true/>
+	<key>StandardOutPath</key>
+	<string>{{xml .Log}}</string>
+	<key>StandardErrorPath</key>
+	<string>{{xml .Log}}</string>
+</dict>
+</plist>
+`))
+
+// ServiceUnit renders the unit that runs `<binary> daemon run` at login: a
+// systemd user unit on Linux, a launchd agent on macOS logging to logPath.
+// PATH is captured so restarted dev servers find the same toolchains as the
+// shell.
+func ServiceUnit(goos, binary, home, path, logPath string) (*Unit, error) {
+	data := map[string]string{
+		"Binary": binary,
+		"Path":   path,
+		"Label":  launchdLabel,
+		"Log":    logPath,
+	}
+	var buf bytes.Buffer
+	switch goos {
+	case "linux":
+		if err := systemdTemplate.Execute(&buf, data); err != nil {
+			return nil, err
+		}
+		return &Unit{
+			Path:    filepath.Join(home, ".config", "systemd", "user", systemdUnit),
+			Content: buf.String(),
+			Enable:  []string{"systemctl", "--user", "enable", "--now", systemdUnit},
+			Disable: []string{"systemctl", "--user", "disable", "--now", systemdUnit},
+			Logs:    "journalctl --user -u pierd",
+		}, nil
+	case "darwin":
+		if err := launchdTemplate.Execute(&buf, data); err != nil {
+			return nil, err
+		}
+		plist := filepath.Join(home, "Library", "LaunchAgents", launchdLabel+".plist")
+		return &Unit{
+			Path:    plist,
+			Content: buf.String(),
+			Enable:  []string{"launchctl", "load", "-w", plist},
+			Disable: []string{"launchctl", "unload", "-w", plist},
+			Logs:    data["Log"],
+		}, nil
+	default:
+		return nil, fmt.Errorf("pierd install is not supported on %s; run `pier daemon run` yourself", goos)
+	}
+}
+
+// HostUnit is ServiceUnit for the running binary on this machine
+func HostUnit() (*Unit, error) {
+	binary, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("locating pier binary: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(binary); err == nil {
+		binary = resolved
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	logPath := config.DaemonLogPath()
+	if err := os.MkdirAll(filepath.Dir(logPath), 0755); err != nil {
+		return nil, err
+	}
+	return ServiceUnit(runtime.GOOS, binary, home, os.Getenv("PATH"), logPath)
+}
+
+// systemdQuote quotes s as one word of a systemd unit setting, doubling the %
+// that would start a specifier
+func systemdQuote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "%", "%%").Replace(s)
+	return `"` + s + `"`
+}
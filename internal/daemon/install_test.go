@@ -0,0 +1,47 @@
+package daemon
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestServiceUnitEscapesPaths(t *testing.T) {
+	binary := "/Users/Tom & Jerry/100% <bin>/pier"
+	path := "/usr/bin:/opt/a&b"
+	logPath := "/Users/Tom & Jerry/.pier/logs/pierd.log"
+
+	u, err := ServiceUnit("darwin", binary, "/Users/Tom & Jerry", path, logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var plist struct {
+		Strings []string `xml:"dict>string"`
+		Args    []string `xml:"dict>array>string"`
+	}
+	if err := xml.Unmarshal([]byte(u.Content), &plist); err != nil {
+		t.Fatalf("plist is not valid XML: %v\n%s", err, u.Content)
+	}
+	if len(plist.Args) == 0 || plist.Args[0] != binary {
+		t.Errorf("ProgramArguments = %q, want %q first", plist.Args, binary)
+	}
+	if want := []string{launchdLabel, logPath, logPath}; strings.Join(plist.Strings, "|") != strings.Join(want, "|") {
+		t.Errorf("strings = %q, want %q", plist.Strings, want)
+	}
+	if u.Logs != logPath {
+		t.Errorf("Logs = %q, want %q", u.Logs, logPath)
+	}
+
+	u, err = ServiceUnit("linux", "/home/a b/$HOME/pier", "/home/a b", "/bin:/50%", logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`ExecStart="/home/a b/$$HOME/pier" daemon run`,
+		`Environment="PATH=/bin:/50%%"`,
+	} {
+		if !strings.Contains(u.Content, want) {
+			t.Errorf("unit missing %s:\n%s", want, u.Content)
+		}
+	}
+}
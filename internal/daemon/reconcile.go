@@ -0,0 +1,246 @@
+package daemon
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/eshe-huli/pier/internal/docker"
+	"github.com/eshe-huli/pier/internal/proxy"
+	"github.com/eshe-huli/pier/internal/registry"
+)
+
+// Route statuses
+const (
+	RouteActive  = "active"
+	RouteStale   = "stale"   // backend down, route kept
+	RouteRemoved = "removed" // backend container gone, route deleted
+)
+
+// Link statuses
+const (
+	LinkRunning    = "running"
+	LinkStopped    = "stopped"    // no dev.pid: never started or unlinked
+	LinkRestarting = "restarting" // crashed, restart issued
+	LinkCrashed    = "crashed"    // crashed too often, given up
+)
+
+// Restart backoff for crashed linked processes
+const (
+	maxRestarts   = 5
+	restartWindow = 10 * time.Minute
+)
+
+// placeholderTTL is how long a project recorded before registering (by
+// `pier up` allocating its shared resources) may wait to be registered.
+// Overridable in tests.
+var placeholderTTL = time.Hour
+
+// Route is a Traefik file route and the state of its backend
+type Route struct {
+	Name      string `json:"name"`
+	Domain    string `json:"domain"`
+	Port      int    `json:"port,omitempty"`
+	Container string `json:"container,omitempty"`
+	Status    string `json:"status"`
+}
+
+// Link is a linked dev server (`pier link`) and its process state
+type Link struct {
+	Name     string `json:"name"`
+	Dir      string `json:"dir"`
+	Port     int    `json:"port"`
+	PID      int    `json:"pid,omitempty"`
+	Status   string `json:"status"`
+	Restarts int    `json:"restarts,omitempty"`
+}
+
+// Snapshot is the reconciled state pierd serves to clients
+type Snapshot struct {
+	Containers []docker.ContainerInfo `json:"containers"`
+	Routes     []Route                `json:"routes"`
+	Links      []Link                 `json:"links"`
+	UpdatedAt  time.Time              `json:"updatedAt"`
+}
+
+// Route returns the named route, if any
+func (s *Snapshot) Route(name string) (Route, bool) {
+	for _, r := range s.Routes {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return Route{}, false
+}
+
+// Link returns the named link, if any
+func (s *Snapshot) Link(name string) (Link, bool) {
+	for _, l := range s.Links {
+		if l.Name == name {
+			return l, true
+		}
+	}
+	return Link{}, false
+}
+
+// reconcile brings routes, the registry and dev.pid files in line with what
+// is actually running, and returns the resulting snapshot
+func (d *Daemon) reconcile(ctx context.Context) *Snapshot {
+	snap := &Snapshot{UpdatedAt: time.Now()}
+
+	// Without a container listing, no route can be judged dead
+	containers, err := docker.ListContainers(ctx, d.cfg.Network, d.cfg.TLD)
+	listed := err == nil
+	if err != nil {
+		d.logf("listing containers: %v", err)
+	}
+	snap.Containers = containers
+	running := map[string]bool{}
+	exists := map[string]bool{}
+	for _, c := range containers {
+		exists[c.Name] = true
+		running[c.Name] = c.State == "running"
+	}
+
+	snap.Links = d.reconcileLinks(ctx)
+	linked := map[int]bool{}
+	for _, l := range snap.Links {
+		if l.Status == LinkRunning || l.Status == LinkRestarting {
+			linked[l.Port] = true
+		}
+	}
+
+	proxies, err := proxy.ListFileProxies(d.cfg.TLD)
+	if err != nil {
+		d.logf("listing routes: %v", err)
+	}
+	for _, p := range proxies {
+		r := Route{Name: p.Name, Domain: p.Domain, Port: p.Port, Container: p.Container, Status: RouteActive}
+		switch {
+		case p.Container != "" && listed && !exists[p.Container]:
+			if err := proxy.RemoveFileProxy(p.Name); err != nil {
+				d.logf("removing route %s: %v", p.Name, err)
+				r.Status = RouteStale
+				break
+			}
+			d.logf("removed route %s: container %s is gone", p.Name, p.Container)
+			r.Status = RouteRemoved
+		case p.Container != "" && listed && !running[p.Container]:
+			r.Status = RouteStale
+		case p.Port > 0 && !linked[p.Port] && !proxy.IsProxyBackendAlive(p.Port):
+			r.Status = RouteStale
+		}
+		snap.Routes = append(snap.Routes, r)
+	}
+
+	d.pruneRegistry()
+	return snap
+}
+
+// reconcileLinks checks every linked project's dev.pid, clearing dead ones
+// and restarting processes that crashed
+func (d *Daemon) reconcileLinks(ctx context.Context) []Link {
+	projects, err := registry.Load()
+	if err != nil {
+		d.logf("loading registry: %v", err)
+		return nil
+	}
+
+	var links []Link
+	for _, p := range projects {
+		if p.Type != "link" {
+			continue
+		}
+		l := Link{Name: p.Name, Dir: p.Dir, Port: p.Port, Status: LinkStopped}
+		pidFile := filepath.Join(p.Dir, ".pier", "dev.pid")
+		pid, ok := readPID(pidFile)
+		switch {
+		case !ok:
+			// Not started, or stopped on purpose with `pier unlink`
+		case processAlive(pid):
+			l.PID, l.Status = pid, LinkRunning
+		default:
+			// The pid file outlived its process: it crashed
+			_ = os.Remove(pidFile)
+			l.Status = d.restartLink(ctx, p)
+		}
+		l.Restarts = len(d.restarts[p.Name])
+		links = append(links, l)
+	}
+	return links
+}
+
+// restartLink relaunches a crashed dev server, giving up after maxRestarts
+// within restartWindow
+func (d *Daemon) restartLink(ctx context.Context, p registry.Project) string {
+	if p.Command == "" {
+		d.logf("%s exited; no dev command to restart it with", p.Name)
+		return LinkStopped
+	}
+
+	now := time.Now()
+	var recent []time.Time
+	for _, t := range d.restarts[p.Name] {
+		if now.Sub(t) < restartWindow {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= maxRestarts {
+		d.restarts[p.Name] = recent
+		d.logf("%s crashed %d times in %s; not restarting", p.Name, len(recent), restartWindow)
+		return LinkCrashed
+	}
+	d.restarts[p.Name] = append(recent, now)
+
+	d.logf("%s crashed; restarting", p.Name)
+	if err := d.restart(ctx, p); err != nil {
+		d.logf("restarting %s: %v", p.Name, err)
+		return LinkCrashed
+	}
+	return LinkRestarting
+}
+
+// pruneRegistry forgets projects whose directory was deleted, and
+// placeholders whose deploy never got as far as registering them
+func (d *Daemon) pruneRegistry() {
+	projects, err := registry.Load()
+	if err != nil {
+		return
+	}
+	for _, p := range projects {
+		if p.Dir == "" {
+			recorded, err := time.Parse(time.RFC3339, p.LastUsed)
+			if err != nil || time.Since(recorded) > placeholderTTL {
+				if err := registry.Remove(p.Name); err == nil {
+					d.logf("forgot %s: it was never registered", p.Name)
+				}
+			}
+			continue
+		}
+		if _, err := os.Stat(p.Dir); os.IsNotExist(err) {
+			if err := registry.Remove(p.Name); err == nil {
+				d.logf("forgot %s: %s no longer exists", p.Name, p.Dir)
+			}
+		}
+	}
+}
+
+func readPID(path string) (int, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, false
+	}
+	return pid, true
+}
+
+func processAlive(pid int) bool {
+	return syscall.Kill(pid, 0) == nil
+}
@@ -1,6 +1,7 @@
 package dashboard
 
 import (
+	"context"
 	"embed"
 	"encoding/json"
 	"fmt"
@@ -17,6 +18,10 @@ import (
 	"time"
 
 	"github.com/eshe-huli/pier/internal/config"
+	"github.com/eshe-huli/pier/internal/daemon"
+	"github.com/eshe-huli/pier/internal/docker"
+	"github.com/eshe-huli/pier/internal/infra"
+	"github.com/eshe-huli/pier/internal/proxy"
 	"github.com/eshe-huli/pier/internal/registry"
 )
 
@@ -38,6 +43,7 @@ type ServiceInfo struct {
 	Dir       string `json:"dir,omitempty"`
 	Framework string `json:"framework,omitempty"`
 	LastUsed  string `json:"lastUsed,omitempty"`
+	DBAdmin   string `json:"dbAdmin,omitempty"` // Adminer link to the project database
 }
 
 // Handler returns an http.Handler for the full dashboard (static + API)
@@ -50,6 +56,7 @@ func Handler() http.Handler {
 	mux.HandleFunc("/api/services/stop", handleStopService)
 	mux.HandleFunc("/api/projects", handleProjects)
 	mux.HandleFunc("/api/health", handleHealth)
+	mux.HandleFunc("/api/stats", handleStats)
 
 	// Static files
 	sub, err := fs.Sub(staticFiles, "static")
@@ -107,6 +114,9 @@ func handleServices(w http.ResponseWriter, r *http.Request) {
 	linkedServices := getLinkedServices(cfg)
 	services = append(services, linkedServices...)
 
+	// 3. Type routes by the pier.role of the container behind them
+	services = applyOwnership(services, cfg)
+
 	// Merge registry projects that aren't already in services (stopped projects)
 	projects, _ := registry.Load()
 	activeNames := map[string]bool{}
@@ -118,6 +128,7 @@ func handleServices(w http.ResponseWriter, r *http.Request) {
 				services[i].Dir = p.Dir
 				services[i].Framework = p.Framework
 				services[i].LastUsed = p.LastUsed
+				services[i].DBAdmin = dbAdminLink(p, cfg.TLD)
 				break
 			}
 		}
@@ -134,6 +145,7 @@ func handleServices(w http.ResponseWriter, r *http.Request) {
 				Dir:       p.Dir,
 				Framework: p.Framework,
 				LastUsed:  p.LastUsed,
+				DBAdmin:   dbAdminLink(p, cfg.TLD),
 			})
 		}
 	}
@@ -145,6 +157,22 @@ func handleServices(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// dbAdminLink points at the project's database in `pier db ui`, if it's running
+func dbAdminLink(p registry.Project, tld string) string {
+	if !proxy.FileProxyExists(infra.DBAdminHost) {
+		return ""
+	}
+	for _, spec := range p.Services {
+		parts := strings.SplitN(spec, ":", 2)
+		if len(parts) == 2 && infra.SupportsDatabases(parts[0]) {
+			if link := infra.DBAdminURL(parts[0], parts[1], p.Name, tld); link != "" {
+				return link
+			}
+		}
+	}
+	return ""
+}
+
 func getTraefikRoutes(cfg *config.Config) []ServiceInfo {
 	client := &http.Client{Timeout: 2 * time.Second}
 	apiURL := fmt.Sprintf("http://127.0.0.1:%d/api/http/routers", cfg.Traefik.Port+1)
@@ -207,6 +235,49 @@ func getTraefikRoutes(cfg *config.Config) []ServiceInfo {
 	return services
 }
 
+// applyOwnership marks routes backed by a Pier-labelled container with its
+// role and container state, folding an app's docker and file routes into
+// one entry
+func applyOwnership(services []ServiceInfo, cfg *config.Config) []ServiceInfo {
+	var containers []docker.ContainerInfo
+	if snap, err := daemon.Status(context.Background()); err == nil {
+		containers = snap.Containers
+	} else if containers, err = docker.ListContainers(context.Background(), cfg.Network, cfg.TLD); err != nil {
+		return services
+	}
+
+	owned := map[string]docker.ContainerInfo{}
+	for _, c := range containers {
+		if c.Managed {
+			owned[c.Domain] = c
+		}
+	}
+
+	seen := map[string]bool{}
+	result := make([]ServiceInfo, 0, len(services))
+	for _, svc := range services {
+		c, ok := owned[svc.Domain]
+		if !ok {
+			result = append(result, svc)
+			continue
+		}
+		if seen[svc.Domain] {
+			continue
+		}
+		seen[svc.Domain] = true
+
+		svc.Type = c.Owner.Role
+		if c.Owner.Role == docker.RoleApp {
+			svc.Name = c.Owner.Service
+			svc.Type = "docker"
+		}
+		svc.Status = c.State
+		svc.Uptime = c.Status
+		result = append(result, svc)
+	}
+	return result
+}
+
 func getLinkedServices(cfg *config.Config) []ServiceInfo {
 	// Check for .pier/dev.pid files in common project directories
 	// Also check the Traefik dynamic config for file-based routes
@@ -216,6 +287,9 @@ func getLinkedServices(cfg *config.Config) []ServiceInfo {
 		return nil
 	}
 
+	// pierd tracks the dev servers already; probe only when it isn't running
+	snap, _ := daemon.Status(context.Background())
+
 	var services []ServiceInfo
 	for _, e := range entries {
 		if !strings.HasSuffix(e.Name(), ".yaml") && !strings.HasSuffix(e.Name(), ".yml") {
@@ -236,20 +310,12 @@ func getLinkedServices(cfg *config.Config) []ServiceInfo {
 			name := strings.TrimSuffix(e.Name(), filepath.Ext(e.Name()))
 			domain := fmt.Sprintf("%s.%s", name, cfg.TLD)
 
-			// Check if the service is actually responding
-			status := "stopped"
-			client := &http.Client{Timeout: 1 * time.Second}
-			if resp, err := client.Get(fmt.Sprintf("http://%s", domain)); err == nil {
-				resp.Body.Close()
-				status = "running"
-			}
-
 			services = append(services, ServiceInfo{
 				Name:   name,
 				Domain: domain,
 				URL:    fmt.Sprintf("http://%s", domain),
 				Type:   "linked",
-				Status: status,
+				Status: linkedStatus(snap, name, domain),
 			})
 		}
 	}
@@ -257,6 +323,28 @@ func getLinkedServices(cfg *config.Config) []ServiceInfo {
 	return services
 }
 
+// linkedStatus reports a linked service's state from pierd's snapshot, or
+// by requesting its domain when pierd isn't running
+func linkedStatus(snap *daemon.Snapshot, name, domain string) string {
+	if snap != nil {
+		if l, ok := snap.Link(name); ok {
+			return l.Status
+		}
+		if r, ok := snap.Route(name); ok && r.Status == daemon.RouteActive {
+			return "running"
+		}
+		return "stopped"
+	}
+
+	// Check if the service is actually responding
+	client := &http.Client{Timeout: 1 * time.Second}
+	if resp, err := client.Get(fmt.Sprintf("http://%s", domain)); err == nil {
+		resp.Body.Close()
+		return "running"
+	}
+	return "stopped"
+}
+
 func handleProjects(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -1,6 +1,7 @@
 // Pier Dashboard — Dev Hub
 
 let allServices = [];
+let statsByName = {};
 
 async function loadServices() {
     const grid = document.getElementById('services-grid');
@@ -14,6 +15,7 @@ async function loadServices() {
 
         const data = await resp.json();
         allServices = data.services || [];
+        await loadStats();
 
         // Update status
         pill.className = 'status-pill ok';
@@ -91,6 +93,7 @@ function renderServices(services) {
             actionBtn = `<button class="btn-stop" onclick="stopService('${esc(svc.name)}')" title="Stop">■ Stop</button>`;
         }
 
+        const usage = isUp ? renderUsage(statsByName[svc.name]) : '';
         const dirLabel = svc.dir ? `<div class="service-dir" title="${esc(svc.dir)}">${esc(shortenPath(svc.dir))}</div>` : '';
         const fwBadge = svc.framework ? `<span class="badge framework">${esc(svc.framework)}</span>` : '';
 
@@ -105,10 +108,12 @@ function renderServices(services) {
                     </div>
                 </div>
                 <div class="service-right">
+                    ${usage}
                     ${fwBadge}
                     <span class="badge ${svc.type}">${esc(svc.type)}</span>
                     <span class="badge ${statusBadge}">${statusLabel}</span>
                     ${actionBtn}
+                    ${svc.dbAdmin ? `<a class="btn-open" href="${esc(svc.dbAdmin)}" target="_blank" title="Open database in Adminer">DB ↗</a>` : ''}
                     ${isUp ? `<a class="btn-open" href="${esc(svc.url)}" target="_blank">Open ↗</a>` : ''}
                 </div>
             </div>
@@ -116,6 +121,48 @@ function renderServices(services) {
     }).join('');
 }
 
+// Container usage with CPU history, from /api/stats; best effort
+async function loadStats() {
+    try {
+        const resp = await fetch('/api/stats');
+        if (!resp.ok) return;
+        const data = await resp.json();
+        // One entry per app service: the server adds its replicas up
+        statsByName = {};
+        for (const c of data.containers || []) statsByName[c.name] = c;
+    } catch (err) {
+        statsByName = {};
+    }
+}
+
+function renderUsage(stats) {
+    if (!stats) return '';
+    const cur = stats.current;
+    const mem = formatBytes(cur.memUsage);
+    return `
+        <span class="usage" title="CPU over the last ${stats.history.length} samples · restarts: ${cur.restarts}">
+            ${sparkline(stats.history.map(p => p.cpu))}
+            ${cur.cpu.toFixed(1)}% · ${mem}
+        </span>
+    `;
+}
+
+function sparkline(values, width = 60, height = 16) {
+    if (values.length < 2) return '';
+    const max = Math.max(...values, 1);
+    const step = width / (values.length - 1);
+    const points = values.map((v, i) =>
+        `${(i * step).toFixed(1)},${(height - (v / max) * height).toFixed(1)}`).join(' ');
+    return `<svg class="sparkline" width="${width}" height="${height}" viewBox="0 0 ${width} ${height}"><polyline points="${points}"/></svg>`;
+}
+
+function formatBytes(n) {
+    const units = ['B', 'KB', 'MB', 'GB', 'TB'];
+    let i = 0;
+    while (n >= 1024 && i < units.length - 1) { n /= 1024; i++; }
+    return `${n.toFixed(i ? 1 : 0)} ${units[i]}`;
+}
+
 function getIcon(type) {
     switch (type) {
         case 'docker': return '🐳';
@@ -0,0 +1,174 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/eshe-huli/pier/internal/config"
+	"github.com/eshe-huli/pier/internal/docker"
+)
+
+// Stats history kept for sparklines: historyLen samples, taken no more
+// often than sampleEvery however many clients poll
+const (
+	historyLen  = 60
+	sampleEvery = 2 * time.Second
+)
+
+// StatsPoint is one sample in a container's history
+type StatsPoint struct {
+	Time       time.Time `json:"t"`
+	CPUPercent float64   `json:"cpu"`
+	MemUsage   uint64    `json:"mem"`
+	NetRx      uint64    `json:"rx"`
+	NetTx      uint64    `json:"tx"`
+}
+
+// ContainerStats is a container's current usage and recent history
+type ContainerStats struct {
+	Name    string       `json:"name"`
+	Role    string       `json:"role"`
+	Project string       `json:"project,omitempty"`
+	Current docker.Stats `json:"current"`
+	History []StatsPoint `json:"history"`
+}
+
+type statsHistory struct {
+	mu      sync.Mutex
+	sampled time.Time
+	latest  []ContainerStats
+	points  map[string][]StatsPoint
+}
+
+var history = &statsHistory{points: map[string][]StatsPoint{}}
+
+func handleStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	cfg, err := config.Load()
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	stats, err := history.sample(r.Context(), cfg)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"containers": stats,
+		"interval":   sampleEvery.Seconds(),
+	})
+}
+
+// sample takes a new sample of every Pier app and infra container, unless
+// the last one is recent, and returns them with their history
+func (h *statsHistory) sample(ctx context.Context, cfg *config.Config) ([]ContainerStats, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if time.Since(h.sampled) < sampleEvery {
+		return h.latest, nil
+	}
+
+	containers, err := docker.ListContainers(ctx, cfg.Network, cfg.TLD)
+	if err != nil {
+		return nil, err
+	}
+	owners := map[string]docker.Owner{}
+	var names []string
+	for _, c := range containers {
+		if !c.Managed || c.State != "running" {
+			continue
+		}
+		if c.Owner.Role != docker.RoleApp && c.Owner.Role != docker.RoleInfra {
+			continue
+		}
+		owners[c.Name] = c.Owner
+		names = append(names, c.Name)
+	}
+
+	sampled := docker.CollectStats(ctx, names)
+	// Replicas of an app service are shown as one entry, their usage added up
+	byService := map[string]*ContainerStats{}
+	for name, s := range sampled {
+		points := append(h.points[name], StatsPoint{
+			Time:       s.Time,
+			CPUPercent: s.CPUPercent,
+			MemUsage:   s.MemUsage,
+			NetRx:      s.NetRx,
+			NetTx:      s.NetTx,
+		})
+		if len(points) > historyLen {
+			points = points[len(points)-historyLen:]
+		}
+		h.points[name] = points
+
+		o := owners[name]
+		key, display := name, name
+		if o.Role == docker.RoleApp && o.Service != "" {
+			key, display = o.Project+"/"+o.Service, o.Service
+		}
+		if entry, ok := byService[key]; ok {
+			entry.add(*s, points)
+			continue
+		}
+		byService[key] = &ContainerStats{
+			Name:    display,
+			Role:    o.Role,
+			Project: o.Project,
+			Current: *s,
+			History: points,
+		}
+	}
+	result := []ContainerStats{}
+	for _, entry := range byService {
+		result = append(result, *entry)
+	}
+	// Forget containers that are gone
+	for name := range h.points {
+		if _, ok := sampled[name]; !ok {
+			delete(h.points, name)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Name != result[j].Name {
+			return result[i].Name < result[j].Name
+		}
+		return result[i].Project < result[j].Project
+	})
+
+	h.sampled = time.Now()
+	h.latest = result
+	return result, nil
+}
+
+// add folds another replica's usage into c. Replicas are sampled together,
+// so histories line up from the newest point; a younger replica's is shorter.
+func (c *ContainerStats) add(s docker.Stats, points []StatsPoint) {
+	c.Current.CPUPercent += s.CPUPercent
+	c.Current.MemUsage += s.MemUsage
+	c.Current.MemLimit += s.MemLimit
+	c.Current.NetRx += s.NetRx
+	c.Current.NetTx += s.NetTx
+	c.Current.Restarts += s.Restarts
+
+	if len(points) > len(c.History) {
+		c.History, points = points, c.History
+	}
+	merged := append([]StatsPoint(nil), c.History...)
+	offset := len(merged) - len(points)
+	for i, p := range points {
+		m := &merged[offset+i]
+		m.CPUPercent += p.CPUPercent
+		m.MemUsage += p.MemUsage
+		m.NetRx += p.NetRx
+		m.NetTx += p.NetTx
+	}
+	c.History = merged
+}
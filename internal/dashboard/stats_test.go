@@ -0,0 +1,33 @@
+package dashboard
+
+import (
+	"testing"
+
+	"github.com/eshe-huli/pier/internal/docker"
+)
+
+func TestContainerStatsAdd(t *testing.T) {
+	first := []StatsPoint{{CPUPercent: 1}, {CPUPercent: 2}, {CPUPercent: 3}}
+	c := ContainerStats{
+		Name:    "web",
+		Current: docker.Stats{CPUPercent: 3, MemUsage: 100, Restarts: 1},
+		History: first,
+	}
+
+	// A replica started later has a shorter history, ending at the same sample
+	c.add(docker.Stats{CPUPercent: 10, MemUsage: 50, Restarts: 1}, []StatsPoint{{CPUPercent: 20}, {CPUPercent: 30}})
+
+	if c.Current.CPUPercent != 13 || c.Current.MemUsage != 150 || c.Current.Restarts != 2 {
+		t.Errorf("current = %+v, want the replicas added up", c.Current)
+	}
+	var cpu []float64
+	for _, p := range c.History {
+		cpu = append(cpu, p.CPUPercent)
+	}
+	if len(cpu) != 3 || cpu[0] != 1 || cpu[1] != 22 || cpu[2] != 33 {
+		t.Errorf("history cpu = %v, want [1 22 33]", cpu)
+	}
+	if first[1].CPUPercent != 2 {
+		t.Error("adding changed the replica's own history")
+	}
+}
@@ -0,0 +1,137 @@
+package detect
+
+import "fmt"
+
+// DevCommand returns the framework's dev server command, run on the host
+// from the project directory. Empty if there is none.
+func DevCommand(fw *Framework, port int) string {
+	return devCommand(fw, port, false)
+}
+
+// devCommand returns the dev server command. In a container the server
+// must listen on all interfaces rather than loopback.
+func devCommand(fw *Framework, port int, container bool) string {
+	// bind is appended in containers only
+	bind := func(flag string) string {
+		if container {
+			return " " + flag
+		}
+		return ""
+	}
+	switch fw.Name {
+	case "nextjs":
+		return fmt.Sprintf("npx next dev -p %d", port) + bind("-H 0.0.0.0")
+	case "nuxt":
+		return fmt.Sprintf("npx nuxi dev --port %d", port) + bind("--host 0.0.0.0")
+	case "nestjs":
+		return "npm run start:dev"
+	case "express", "fastify":
+		return "npm run dev"
+	case "django":
+		return fmt.Sprintf("python manage.py runserver 0.0.0.0:%d", port)
+	case "fastapi":
+		return fmt.Sprintf("uvicorn main:app --reload --port %d", port) + bind("--host 0.0.0.0")
+	case "flask":
+		return fmt.Sprintf("flask run --port %d", port) + bind("--host 0.0.0.0")
+	case "go":
+		return "go run ."
+	case "rails":
+		if container {
+			return fmt.Sprintf("bundle exec rails server -p %d -b 0.0.0.0", port)
+		}
+		return fmt.Sprintf("rails server -p %d", port)
+	case "phoenix":
+		return "mix phx.server"
+	case "laravel":
+		return fmt.Sprintf("php artisan serve --port=%d", port) + bind("--host=0.0.0.0")
+	case "spring-boot":
+		return "./mvnw spring-boot:run"
+	default:
+		return ""
+	}
+}
+
+// DevContainer runs a framework's dev server in a container, with the
+// project's source bind-mounted at SourceDir
+type DevContainer struct {
+	Image   string
+	Install string   // installs dependencies; run before Command on every start
+	Command string   // the dev server, listening on all interfaces
+	DepDirs []string // kept in named volumes: relative to SourceDir, or absolute cache dirs
+	Env     []string
+	// PollEnv makes file watchers poll, for bind mounts that don't deliver
+	// inotify events (Docker Desktop on macOS and Windows)
+	PollEnv []string
+}
+
+// SourceDir is where DevContainer mounts the project
+const SourceDir = "/app"
+
+var (
+	nodeDev = DevContainer{
+		Image:   "node:22-alpine",
+		Install: "npm install",
+		DepDirs: []string{"node_modules"},
+		Env:     []string{"NODE_ENV=development"},
+		PollEnv: []string{"CHOKIDAR_USEPOLLING=true", "WATCHPACK_POLLING=true"},
+	}
+	pythonDev = DevContainer{
+		Image:   "python:3.13-slim",
+		Install: "python -m venv .venv && pip install -r requirements.txt",
+		DepDirs: []string{".venv"},
+		Env:     []string{"VIRTUAL_ENV=/app/.venv", "PATH=/app/.venv/bin:/usr/local/bin:/usr/bin:/bin"},
+		PollEnv: []string{"WATCHFILES_FORCE_POLLING=true"},
+	}
+	devContainers = map[string]DevContainer{
+		"nestjs":  nodeDev,
+		"nextjs":  nodeDev,
+		"nuxt":    nodeDev,
+		"express": nodeDev,
+		"fastify": nodeDev,
+		"django":  pythonDev,
+		"fastapi": pythonDev,
+		"flask": func() DevContainer {
+			d := pythonDev
+			d.Env = append([]string{"FLASK_DEBUG=1"}, d.Env...)
+			return d
+		}(),
+		"go": {
+			Image:   "golang:1.24-alpine",
+			Install: "go mod download",
+			DepDirs: []string{"/go/pkg/mod", "/root/.cache/go-build"},
+		},
+		"rails": {
+			Image:   "ruby:3.4",
+			Install: "bundle install",
+			DepDirs: []string{"vendor/bundle"},
+			Env:     []string{"BUNDLE_PATH=vendor/bundle", "RAILS_ENV=development"},
+		},
+		"phoenix": {
+			Image:   "elixir:1.18",
+			Install: "mix local.hex --force && mix local.rebar --force && mix deps.get",
+			DepDirs: []string{"deps", "_build"},
+			Env:     []string{"MIX_ENV=dev"},
+		},
+		"laravel": {
+			Image:   "composer:2",
+			Install: "composer install",
+			DepDirs: []string{"vendor"},
+		},
+		"spring-boot": {
+			Image:   "eclipse-temurin:21-jdk",
+			DepDirs: []string{"/root/.m2"},
+		},
+	}
+)
+
+// DevContainerFor returns how to run fw's dev server in a container on
+// port, or false if Pier doesn't know how
+func DevContainerFor(fw *Framework, port int) (DevContainer, bool) {
+	d, ok := devContainers[fw.Name]
+	if !ok {
+		return DevContainer{}, false
+	}
+	d.Command = devCommand(fw, port, true)
+	d.Env = append(append([]string{}, d.Env...), fmt.Sprintf("PORT=%d", port), "HOST=0.0.0.0")
+	return d, true
+}
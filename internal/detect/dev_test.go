@@ -0,0 +1,42 @@
+package detect
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDevCommand(t *testing.T) {
+	fw := &Framework{Name: "nextjs", Port: 3000}
+	if got := DevCommand(fw, 3000); got != "npx next dev -p 3000" {
+		t.Errorf("DevCommand = %q", got)
+	}
+	if got := DevCommand(&Framework{Name: "unknown"}, 80); got != "" {
+		t.Errorf("DevCommand for unknown framework = %q, want empty", got)
+	}
+}
+
+func TestDevContainerFor(t *testing.T) {
+	dc, ok := DevContainerFor(&Framework{Name: "nextjs", Port: 3000}, 3001)
+	if !ok {
+		t.Fatal("no dev container for nextjs")
+	}
+	if dc.Command != "npx next dev -p 3001 -H 0.0.0.0" {
+		t.Errorf("Command = %q, want it bound to all interfaces", dc.Command)
+	}
+	if !slices.Contains(dc.DepDirs, "node_modules") {
+		t.Errorf("DepDirs = %v, want node_modules", dc.DepDirs)
+	}
+	if !slices.Contains(dc.Env, "PORT=3001") || !slices.Contains(dc.Env, "HOST=0.0.0.0") {
+		t.Errorf("Env = %v, want PORT and HOST", dc.Env)
+	}
+
+	// The shared table isn't modified by a lookup
+	again, _ := DevContainerFor(&Framework{Name: "express"}, 4000)
+	if slices.Contains(again.Env, "PORT=3001") {
+		t.Errorf("Env = %v leaked from an earlier lookup", again.Env)
+	}
+
+	if _, ok := DevContainerFor(&Framework{Name: "unknown"}, 80); ok {
+		t.Error("unknown framework has a dev container")
+	}
+}
@@ -0,0 +1,69 @@
+package detect
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// DBCommands are the migrate/seed commands for a project's database tooling
+type DBCommands struct {
+	Tool    string
+	Migrate string
+	Seed    string
+}
+
+// DetectDBCommands finds the project's migration tooling: prisma, rails,
+// laravel, django or alembic. Returns nil if none is found.
+func DetectDBCommands(dir string) *DBCommands {
+	if (hasPkgDep(dir, "prisma") || hasPkgDep(dir, "@prisma/client")) && fileExists(dir, filepath.Join("prisma", "schema.prisma")) {
+		cmds := &DBCommands{Tool: "prisma", Migrate: "npx prisma migrate deploy"}
+		if hasPrismaSeed(dir) {
+			cmds.Seed = "npx prisma db seed"
+		}
+		return cmds
+	}
+
+	if fileContains(dir, "Gemfile", "rails") && fileExists(dir, filepath.Join("db", "migrate")) {
+		cmds := &DBCommands{Tool: "rails", Migrate: "bundle exec rails db:migrate"}
+		if fileExists(dir, filepath.Join("db", "seeds.rb")) {
+			cmds.Seed = "bundle exec rails db:seed"
+		}
+		return cmds
+	}
+
+	if fileExists(dir, "artisan") && fileContains(dir, "composer.json", "laravel/framework") {
+		cmds := &DBCommands{Tool: "laravel", Migrate: "php artisan migrate --force"}
+		if fileExists(dir, filepath.Join("database", "seeders")) {
+			cmds.Seed = "php artisan db:seed --force"
+		}
+		return cmds
+	}
+
+	if fileExists(dir, "manage.py") && fileContains(dir, "requirements.txt", "django") {
+		return &DBCommands{Tool: "django", Migrate: "python manage.py migrate --noinput"}
+	}
+
+	if fileExists(dir, "alembic.ini") {
+		return &DBCommands{Tool: "alembic", Migrate: "alembic upgrade head"}
+	}
+
+	return nil
+}
+
+// hasPrismaSeed checks package.json for a prisma.seed script
+func hasPrismaSeed(dir string) bool {
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return false
+	}
+	var pkg struct {
+		Prisma struct {
+			Seed string `json:"seed"`
+		} `json:"prisma"`
+	}
+	if json.Unmarshal(data, &pkg) != nil {
+		return false
+	}
+	return pkg.Prisma.Seed != ""
+}
@@ -0,0 +1,73 @@
+package detect
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectDBCommands_Prisma(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "package.json", `{"dependencies":{"@prisma/client":"^5.0.0"},"prisma":{"seed":"node prisma/seed.js"}}`)
+	if err := os.MkdirAll(filepath.Join(dir, "prisma"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, dir, "prisma/schema.prisma", `datasource db { provider = "postgresql" }`)
+
+	cmds := DetectDBCommands(dir)
+	if cmds == nil {
+		t.Fatal("expected prisma tooling, got nil")
+	}
+	if cmds.Migrate != "npx prisma migrate deploy" {
+		t.Errorf("got migrate %q", cmds.Migrate)
+	}
+	if cmds.Seed != "npx prisma db seed" {
+		t.Errorf("got seed %q", cmds.Seed)
+	}
+}
+
+func TestDetectDBCommands_Rails(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "Gemfile", `gem "rails", "~> 7.1"`)
+	if err := os.MkdirAll(filepath.Join(dir, "db", "migrate"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	cmds := DetectDBCommands(dir)
+	if cmds == nil || cmds.Tool != "rails" {
+		t.Fatalf("expected rails tooling, got %+v", cmds)
+	}
+	if cmds.Seed != "" {
+		t.Errorf("expected no seed without db/seeds.rb, got %q", cmds.Seed)
+	}
+}
+
+func TestDetectDBCommands_Django(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "requirements.txt", "Django==5.0\n")
+	writeFile(t, dir, "manage.py", "")
+
+	cmds := DetectDBCommands(dir)
+	if cmds == nil || cmds.Tool != "django" {
+		t.Fatalf("expected django tooling, got %+v", cmds)
+	}
+}
+
+func TestDetectDBCommands_Alembic(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "alembic.ini", "[alembic]\n")
+
+	cmds := DetectDBCommands(dir)
+	if cmds == nil || cmds.Migrate != "alembic upgrade head" {
+		t.Fatalf("expected alembic tooling, got %+v", cmds)
+	}
+}
+
+func TestDetectDBCommands_None(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "package.json", `{"dependencies":{"express":"^4.0.0"}}`)
+
+	if cmds := DetectDBCommands(dir); cmds != nil {
+		t.Errorf("expected nil, got %+v", cmds)
+	}
+}
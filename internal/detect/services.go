@@ -85,6 +85,9 @@ func parseImage(image string) *ServiceDep {
 	nameTag := parts[len(parts)-1]
 	split := strings.SplitN(nameTag, ":", 2)
 	name := split[0]
+	if name == "mailpit" || name == "mailhog" {
+		return &ServiceDep{Name: "mail"}
+	}
 	if !known[name] {
 		return nil
 	}
@@ -117,6 +120,7 @@ func DetectFromPackageJSON(dir string) ([]ServiceDep, error) {
 		"pg": "postgres", "typeorm": "postgres", "prisma": "postgres", "@prisma/client": "postgres",
 		"redis": "redis", "ioredis": "redis", "bullmq": "redis",
 		"mongoose": "mongo", "mongodb": "mongo",
+		"nodemailer": "mail", "@nestjs-modules/mailer": "mail",
 	}), nil
 }
 
@@ -132,11 +136,35 @@ func DetectFromPython(dir string) ([]ServiceDep, error) {
 		return nil, os.ErrNotExist
 	}
 	lower := strings.ToLower(content)
-	return matchContent(lower, map[string]string{
+	deps := matchContent(lower, map[string]string{
 		"psycopg2": "postgres", "sqlalchemy": "postgres",
 		"redis": "redis", "celery": "redis",
 		"pymongo": "mongo", "djongo": "mongo",
-	}), nil
+		"flask-mail": "mail", "fastapi-mail": "mail",
+	})
+	if djangoSendsMail(dir) {
+		deps = dedup(append(deps, ServiceDep{Name: "mail"}))
+	}
+	return deps, nil
+}
+
+// djangoSendsMail looks for EMAIL_* settings in a Django settings module
+func djangoSendsMail(dir string) bool {
+	patterns := []string{"settings.py", "*/settings.py", "*/settings/*.py", "config/settings/*.py"}
+	for _, pattern := range patterns {
+		matches, _ := filepath.Glob(filepath.Join(dir, pattern))
+		for _, path := range matches {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			content := string(data)
+			if strings.Contains(content, "EMAIL_BACKEND") || strings.Contains(content, "EMAIL_HOST") {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func DetectFromRuby(dir string) ([]ServiceDep, error) {
@@ -145,11 +173,31 @@ func DetectFromRuby(dir string) ([]ServiceDep, error) {
 		return nil, err
 	}
 	content := strings.ToLower(string(data))
-	return matchContent(content, map[string]string{
+	deps := matchContent(content, map[string]string{
 		"'pg'": "postgres", "\"pg\"": "postgres",
 		"'redis'": "redis", "\"redis\"": "redis",
 		"'mongoid'": "mongo", "\"mongoid\"": "mongo",
-	}), nil
+		"'actionmailer'": "mail", "\"actionmailer\"": "mail",
+	})
+	// Rails ships ActionMailer; a mailer beyond the generated base class means it's used
+	if fileExists(dir, filepath.Join("app", "mailers")) && hasCustomMailer(dir) {
+		deps = dedup(append(deps, ServiceDep{Name: "mail"}))
+	}
+	return deps, nil
+}
+
+// hasCustomMailer checks app/mailers for anything besides application_mailer.rb
+func hasCustomMailer(dir string) bool {
+	entries, err := os.ReadDir(filepath.Join(dir, "app", "mailers"))
+	if err != nil {
+		return false
+	}
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".rb") && e.Name() != "application_mailer.rb" {
+			return true
+		}
+	}
+	return false
 }
 
 func matchDeps(deps map[string]string, mapping map[string]string) []ServiceDep {
@@ -0,0 +1,70 @@
+package detect
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func hasService(deps []ServiceDep, name string) bool {
+	for _, d := range deps {
+		if d.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDetectServices_Nodemailer(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "package.json", `{"dependencies":{"express":"^4.0.0","nodemailer":"^6.9.0"}}`)
+
+	deps, _ := DetectServices(dir)
+	if !hasService(deps, "mail") {
+		t.Errorf("expected mail, got %v", deps)
+	}
+}
+
+func TestDetectServices_RailsMailer(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "Gemfile", `gem "rails"`+"\n"+`gem "pg"`)
+	if err := os.MkdirAll(filepath.Join(dir, "app", "mailers"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, dir, "app/mailers/application_mailer.rb", "class ApplicationMailer < ActionMailer::Base; end")
+
+	deps, _ := DetectServices(dir)
+	if hasService(deps, "mail") {
+		t.Errorf("generated ApplicationMailer alone should not need mail, got %v", deps)
+	}
+
+	writeFile(t, dir, "app/mailers/user_mailer.rb", "class UserMailer < ApplicationMailer; end")
+	deps, _ = DetectServices(dir)
+	if !hasService(deps, "mail") || !hasService(deps, "postgres") {
+		t.Errorf("expected mail and postgres, got %v", deps)
+	}
+}
+
+func TestDetectServices_DjangoEmailSettings(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "requirements.txt", "Django==5.0\n")
+	if err := os.MkdirAll(filepath.Join(dir, "mysite"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, dir, "mysite/settings.py", `EMAIL_BACKEND = "django.core.mail.backends.smtp.EmailBackend"`)
+
+	deps, _ := DetectServices(dir)
+	if !hasService(deps, "mail") {
+		t.Errorf("expected mail, got %v", deps)
+	}
+}
+
+func TestDetectFromCompose_Mailpit(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "docker-compose.yml", "services:\n  mail:\n    image: axllent/mailpit\n")
+
+	deps, _ := DetectServices(dir)
+	if !hasService(deps, "mail") {
+		t.Errorf("expected mail, got %v", deps)
+	}
+}
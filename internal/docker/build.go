@@ -0,0 +1,334 @@
+package docker
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/moby/patternmatcher"
+	"github.com/moby/patternmatcher/ignorefile"
+
+	"github.com/eshe-huli/pier/internal/buildspec"
+)
+
+// injectedDockerfile is where a Dockerfile from outside the build context
+// (e.g. the generated .pier/Dockerfile) is placed in the context tar
+const injectedDockerfile = ".pier.Dockerfile"
+
+// LabelContextHash is stamped on built images with ContextHash, so an
+// unchanged project needn't be rebuilt
+const LabelContextHash = "pier.context-hash"
+
+// BuildSpec describes an image build, like `docker build -t Tag -f Dockerfile Context`
+type BuildSpec struct {
+	Context    string // build context directory
+	Dockerfile string // path to the Dockerfile; empty means Context/Dockerfile
+	Tag        string
+	Args       map[string]string
+	Target     string   // stage to stop at
+	Platform   string   // e.g. linux/amd64
+	Secrets    []string // --secret syntax; see buildspec.ParseSecret
+	SSH        []string // --ssh syntax; see buildspec.ParseSSH
+	CacheFrom  []string // images (or type=registry,ref=...) to reuse layers from
+	CacheTo    []string // only type=inline is supported by the Engine API
+	Labels     map[string]string
+}
+
+// NewBuildSpec builds tag from the project in dir with opts, which may be
+// nil. Relative paths in opts are resolved against dir.
+func NewBuildSpec(dir, tag string, opts *buildspec.Spec) BuildSpec {
+	if opts == nil {
+		opts = &buildspec.Spec{}
+	}
+	contextDir, dockerfile := opts.Resolve(dir)
+	return BuildSpec{
+		Context:    contextDir,
+		Dockerfile: dockerfile,
+		Tag:        tag,
+		Args:       opts.Args,
+		Target:     opts.Target,
+		Platform:   opts.Platform,
+		Secrets:    opts.Secrets,
+		SSH:        opts.SSH,
+		CacheFrom:  opts.CacheFrom,
+		CacheTo:    opts.CacheTo,
+	}
+}
+
+// needsBuildKit reports whether the build uses features the classic
+// builder lacks
+func (s BuildSpec) needsBuildKit() bool {
+	return len(s.Secrets) > 0 || len(s.SSH) > 0 || len(s.CacheTo) > 0
+}
+
+// buildArgs returns the build args, plus BUILDKIT_INLINE_CACHE for
+// cache_to: type=inline. Other cache exporters need buildx.
+func (s BuildSpec) buildArgs() (map[string]*string, error) {
+	args := map[string]*string{}
+	for k, v := range s.Args {
+		args[k] = &v
+	}
+	for _, to := range s.CacheTo {
+		if to != "type=inline" {
+			return nil, fmt.Errorf("cache_to %q needs docker buildx; only type=inline works through the Engine API", to)
+		}
+		inline := "1"
+		args["BUILDKIT_INLINE_CACHE"] = &inline
+	}
+	return args, nil
+}
+
+// Build builds an image, streaming build progress to out. A failing build
+// step is returned as a *BuildError.
+func Build(ctx context.Context, spec BuildSpec, out io.Writer) error {
+	rt, err := Runtime()
+	if err != nil {
+		return err
+	}
+	return rt.BuildImage(ctx, spec, out)
+}
+
+// PreviousImage is where KeepPrevious moves an app image's last build, so
+// `pier rollback` can return to it
+func PreviousImage(image string) string {
+	return imageRepo(image) + ":previous"
+}
+
+// RollbackImage is the image `pier rollback` switches a container running
+// image to: its PreviousImage, or back again from one
+func RollbackImage(image string) string {
+	if previous := PreviousImage(image); image != previous {
+		return previous
+	}
+	return imageRepo(image)
+}
+
+// imageRepo strips the tag from an image reference
+func imageRepo(image string) string {
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[:i]
+	}
+	return image
+}
+
+// KeepPrevious tags image as PreviousImage before a build replaces it. An
+// image that doesn't exist yet has nothing to keep.
+func KeepPrevious(ctx context.Context, image string) error {
+	rt, err := Runtime()
+	if err != nil {
+		return err
+	}
+	if ok, err := rt.ImageExists(ctx, image); err != nil || !ok {
+		return err
+	}
+	return rt.TagImage(ctx, image, PreviousImage(image))
+}
+
+// ImageExists reports whether an image is present locally
+func ImageExists(ctx context.Context, ref string) (bool, error) {
+	rt, err := Runtime()
+	if err != nil {
+		return false, err
+	}
+	return rt.ImageExists(ctx, ref)
+}
+
+// ContextHash fingerprints what a build depends on: the files sent as its
+// context and the options that change the image. File times are left out,
+// so touching a file doesn't force a rebuild.
+func ContextHash(spec BuildSpec) (string, error) {
+	dockerfile := spec.Dockerfile
+	if dockerfile == "" {
+		dockerfile = filepath.Join(spec.Context, "Dockerfile")
+	}
+	bc, err := newBuildContext(spec.Context, dockerfile)
+	if err != nil {
+		return "", err
+	}
+	bc.stripTimes = true
+
+	h := sha256.New()
+	fmt.Fprintf(h, "target=%s\nplatform=%s\n", spec.Target, spec.Platform)
+	keys := make([]string, 0, len(spec.Args))
+	for k := range spec.Args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(h, "arg %s=%s\n", k, spec.Args[k])
+	}
+	// Secret contents don't change the image's recipe, only which are used
+	for _, s := range spec.Secrets {
+		fmt.Fprintf(h, "secret %s\n", s)
+	}
+	if err := bc.write(h); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// UpToDate reports whether the image spec.Tag was built from the same
+// context, and returns the context's hash to stamp on a new build
+func UpToDate(ctx context.Context, spec BuildSpec) (bool, string, error) {
+	rt, err := Runtime()
+	if err != nil {
+		return false, "", err
+	}
+	hash, err := ContextHash(spec)
+	if err != nil {
+		return false, "", err
+	}
+	labels, err := rt.ImageLabels(ctx, spec.Tag)
+	if IsNotFound(err) {
+		return false, hash, nil
+	}
+	if err != nil {
+		return false, "", err
+	}
+	return labels[LabelContextHash] == hash, hash, nil
+}
+
+// buildContext is a context directory filtered by its .dockerignore
+type buildContext struct {
+	dir        string
+	pm         *patternmatcher.PatternMatcher
+	dockerfile string // the Dockerfile's path inside the tar
+	inject     []byte // Dockerfile contents to add, when it isn't in dir
+	stripTimes bool   // zero file times and owners, for hashing
+}
+
+func newBuildContext(contextDir, dockerfile string) (*buildContext, error) {
+	excludes, err := readDockerignore(contextDir)
+	if err != nil {
+		return nil, err
+	}
+	pm, err := patternmatcher.New(excludes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing .dockerignore: %w", err)
+	}
+	dir, err := filepath.Abs(contextDir)
+	if err != nil {
+		return nil, err
+	}
+	absDockerfile, err := filepath.Abs(dockerfile)
+	if err != nil {
+		return nil, err
+	}
+	bc := &buildContext{dir: dir, pm: pm}
+
+	// Use the Dockerfile in place when the daemon will see it, else inject it
+	if rel, err := filepath.Rel(dir, absDockerfile); err == nil && !strings.HasPrefix(rel, "..") {
+		rel = filepath.ToSlash(rel)
+		if ignored, _ := pm.MatchesOrParentMatches(rel); !ignored {
+			bc.dockerfile = rel
+			return bc, nil
+		}
+	}
+	data, err := os.ReadFile(absDockerfile)
+	if err != nil {
+		return nil, fmt.Errorf("reading Dockerfile: %w", err)
+	}
+	bc.dockerfile, bc.inject = injectedDockerfile, data
+	return bc, nil
+}
+
+// write streams the context as a tar
+func (bc *buildContext) write(w io.Writer) error {
+	tw := tar.NewWriter(w)
+
+	if bc.inject != nil {
+		hdr := &tar.Header{Name: injectedDockerfile, Mode: 0644, Size: int64(len(bc.inject)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(bc.inject); err != nil {
+			return err
+		}
+	}
+
+	err := filepath.WalkDir(bc.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(bc.dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		// .dockerignore and the Dockerfile itself are always sent
+		if rel != ".dockerignore" && rel != bc.dockerfile {
+			ignored, err := bc.pm.MatchesOrParentMatches(rel)
+			if err != nil {
+				return err
+			}
+			if ignored {
+				if d.IsDir() && !bc.pm.Exclusions() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = rel
+		if d.IsDir() {
+			hdr.Name += "/"
+		}
+		if bc.stripTimes {
+			hdr.ModTime, hdr.AccessTime, hdr.ChangeTime = time.Time{}, time.Time{}, time.Time{}
+			hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+func readDockerignore(contextDir string) ([]string, error) {
+	f, err := os.Open(filepath.Join(contextDir, ".dockerignore"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ignorefile.ReadAll(f)
+}
@@ -0,0 +1,211 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func tarEntries(t *testing.T, bc *buildContext) map[string]string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := bc.write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	entries := map[string]string{}
+	tr := tar.NewReader(&buf)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(tr)
+		entries[hdr.Name] = string(data)
+	}
+	return entries
+}
+
+func TestBuildContext_Dockerignore(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"Dockerfile":              "FROM scratch",
+		".dockerignore":           "node_modules\n*.log\n",
+		"main.go":                 "package main",
+		"debug.log":               "noise",
+		"node_modules/x/index.js": "",
+	})
+
+	bc, err := newBuildContext(dir, filepath.Join(dir, "Dockerfile"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bc.dockerfile != "Dockerfile" {
+		t.Errorf("dockerfile = %q, want Dockerfile", bc.dockerfile)
+	}
+
+	entries := tarEntries(t, bc)
+	var names []string
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	want := []string{".dockerignore", "Dockerfile", "main.go"}
+	if len(names) != len(want) {
+		t.Fatalf("entries = %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("entries = %v, want %v", names, want)
+		}
+	}
+}
+
+func TestBuildContext_InjectsOutsideDockerfile(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"app/main.go": "package main"})
+	outside := filepath.Join(t.TempDir(), "Dockerfile")
+	writeFiles(t, filepath.Dir(outside), map[string]string{"Dockerfile": "FROM golang"})
+
+	bc, err := newBuildContext(filepath.Join(dir, "app"), outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bc.dockerfile != injectedDockerfile {
+		t.Fatalf("dockerfile = %q, want %q", bc.dockerfile, injectedDockerfile)
+	}
+	entries := tarEntries(t, bc)
+	if entries[injectedDockerfile] != "FROM golang" {
+		t.Errorf("injected Dockerfile = %q", entries[injectedDockerfile])
+	}
+	if _, ok := entries["main.go"]; !ok {
+		t.Error("main.go missing from context")
+	}
+}
+
+func TestContextHash(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"Dockerfile":    "FROM node:20",
+		".dockerignore": "*.log\n",
+		"index.js":      "console.log(1)",
+	})
+	spec := BuildSpec{Context: dir, Tag: "shop"}
+	hash := func(spec BuildSpec) string {
+		t.Helper()
+		h, err := ContextHash(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+	base := hash(spec)
+
+	// Touching a file or changing an ignored one isn't a change
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "index.js"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, dir, map[string]string{"debug.log": "noise"})
+	if got := hash(spec); got != base {
+		t.Error("hash changed with file times or ignored files")
+	}
+
+	withArg := spec
+	withArg.Args = map[string]string{"NODE_ENV": "production"}
+	if hash(withArg) == base {
+		t.Error("hash ignores build args")
+	}
+	withTarget := spec
+	withTarget.Target = "dev"
+	if hash(withTarget) == base {
+		t.Error("hash ignores the target")
+	}
+
+	writeFiles(t, dir, map[string]string{"index.js": "console.log(2)"})
+	if hash(spec) == base {
+		t.Error("hash ignores file contents")
+	}
+}
+
+func TestUpToDate(t *testing.T) {
+	f := UseFake()
+	t.Cleanup(func() { SetRuntime(nil) })
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"Dockerfile": "FROM node:20"})
+	spec := BuildSpec{Context: dir, Tag: "shop"}
+	ctx := context.Background()
+
+	ok, hash, err := UpToDate(ctx, spec)
+	if err != nil || ok || hash == "" {
+		t.Fatalf("missing image: ok = %v, hash = %q, err = %v", ok, hash, err)
+	}
+	spec.Labels = map[string]string{LabelContextHash: hash}
+	if err := Build(ctx, spec, nil); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _, _ := UpToDate(ctx, spec); !ok {
+		t.Error("image built from the same context isn't up to date")
+	}
+	f.ImageMeta["shop"] = nil
+	if ok, _, _ := UpToDate(ctx, spec); ok {
+		t.Error("image without a context hash counted as up to date")
+	}
+}
+
+func TestBuildSpec_BuildArgs(t *testing.T) {
+	spec := BuildSpec{Args: map[string]string{"A": "1"}, CacheTo: []string{"type=inline"}}
+	args, err := spec.buildArgs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *args["A"] != "1" || *args["BUILDKIT_INLINE_CACHE"] != "1" {
+		t.Errorf("args = %v", args)
+	}
+
+	spec.CacheTo = []string{"type=registry,ref=ghcr.io/acme/shop:cache"}
+	if _, err := spec.buildArgs(); err == nil {
+		t.Error("registry cache export accepted")
+	}
+
+	refs := cacheRefs([]string{"shop:latest", "type=registry,ref=ghcr.io/acme/shop:cache", "type=local,src=/tmp/cache"})
+	if strings.Join(refs, " ") != "shop:latest ghcr.io/acme/shop:cache" {
+		t.Errorf("cache refs = %v", refs)
+	}
+}
+
+func TestRollbackImage(t *testing.T) {
+	for image, want := range map[string]string{
+		"shop":                         "shop:previous",
+		"shop:previous":                "shop",
+		"shop:latest":                  "shop:previous",
+		"localhost:5000/shop":          "localhost:5000/shop:previous",
+		"localhost:5000/shop:previous": "localhost:5000/shop",
+	} {
+		if got := RollbackImage(image); got != want {
+			t.Errorf("RollbackImage(%q) = %q, want %q", image, got, want)
+		}
+	}
+}
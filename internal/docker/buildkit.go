@@ -0,0 +1,146 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/docker/docker/pkg/jsonmessage"
+	controlapi "github.com/moby/buildkit/api/services/control"
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/session/secrets/secretsprovider"
+	"github.com/moby/buildkit/session/sshforward/sshprovider"
+	"github.com/moby/term"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/eshe-huli/pier/internal/buildspec"
+)
+
+// buildKitSession starts a session that serves the build's secrets and SSH
+// agents to BuildKit over the Engine's /session endpoint. Close it once the
+// build is done.
+func (e *engine) buildKitSession(ctx context.Context, spec BuildSpec) (*session.Session, error) {
+	s, err := session.NewSession(ctx, spec.Tag)
+	if err != nil {
+		return nil, fmt.Errorf("starting build session: %w", err)
+	}
+
+	var sources []secretsprovider.Source
+	for _, raw := range spec.Secrets {
+		sec, err := buildspec.ParseSecret(raw)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, secretsprovider.Source{ID: sec.ID, FilePath: sec.Src, Env: sec.Env})
+	}
+	store, err := secretsprovider.NewStore(sources)
+	if err != nil {
+		return nil, fmt.Errorf("build secrets: %w", err)
+	}
+	s.Allow(secretsprovider.NewSecretProvider(store))
+
+	if len(spec.SSH) > 0 {
+		var agents []sshprovider.AgentConfig
+		for _, raw := range spec.SSH {
+			agent, err := buildspec.ParseSSH(raw)
+			if err != nil {
+				return nil, err
+			}
+			agents = append(agents, sshprovider.AgentConfig{ID: agent.ID, Paths: agent.Paths})
+		}
+		provider, err := sshprovider.NewSSHAgentProvider(agents)
+		if err != nil {
+			return nil, fmt.Errorf("build ssh: %w", err)
+		}
+		s.Allow(provider)
+	}
+
+	go func() {
+		_ = s.Run(ctx, func(ctx context.Context, proto string, meta map[string][]string) (net.Conn, error) {
+			return e.cli.DialHijack(ctx, "/session", proto, meta)
+		})
+	}()
+	return s, nil
+}
+
+// cacheRefs turns cache_from entries into the image references the Engine
+// API takes: "type=registry,ref=IMAGE" becomes IMAGE
+func cacheRefs(from []string) []string {
+	var refs []string
+	for _, f := range from {
+		if !strings.Contains(f, "=") {
+			refs = append(refs, f)
+			continue
+		}
+		for _, field := range strings.Split(f, ",") {
+			if ref, ok := strings.CutPrefix(field, "ref="); ok {
+				refs = append(refs, ref)
+			}
+		}
+	}
+	return refs
+}
+
+// displayBuildKitStream prints a BuildKit build's progress like
+// `docker build --progress=plain`: one numbered line per step, then its
+// output
+func displayBuildKitStream(in io.Reader, out io.Writer) error {
+	p := &buildKitProgress{out: out, steps: map[string]int{}, done: map[string]bool{}}
+	fd, isTerm := term.GetFdInfo(out)
+	return jsonmessage.DisplayJSONMessagesStream(in, out, fd, isTerm, p.handle)
+}
+
+type buildKitProgress struct {
+	out   io.Writer
+	steps map[string]int // vertex digest → step number
+	done  map[string]bool
+}
+
+func (p *buildKitProgress) handle(msg jsonmessage.JSONMessage) {
+	if msg.ID != "moby.buildkit.trace" || msg.Aux == nil {
+		return
+	}
+	var data []byte
+	if err := json.Unmarshal(*msg.Aux, &data); err != nil {
+		return
+	}
+	var status controlapi.StatusResponse
+	if err := proto.Unmarshal(data, &status); err != nil {
+		return
+	}
+
+	for _, v := range status.Vertexes {
+		n, seen := p.steps[v.Digest]
+		if !seen {
+			if v.Started == nil {
+				continue
+			}
+			n = len(p.steps) + 1
+			p.steps[v.Digest] = n
+			fmt.Fprintf(p.out, "#%d %s\n", n, v.Name)
+		}
+		if p.done[v.Digest] {
+			continue
+		}
+		switch {
+		case v.Error != "":
+			fmt.Fprintf(p.out, "#%d ERROR: %s\n", n, v.Error)
+			p.done[v.Digest] = true
+		case v.Cached:
+			fmt.Fprintf(p.out, "#%d CACHED\n", n)
+			p.done[v.Digest] = true
+		case v.Completed != nil:
+			fmt.Fprintf(p.out, "#%d DONE\n", n)
+			p.done[v.Digest] = true
+		}
+	}
+	for _, l := range status.Logs {
+		n := p.steps[l.Vertex]
+		for _, line := range strings.Split(strings.TrimRight(string(l.Msg), "\n"), "\n") {
+			fmt.Fprintf(p.out, "#%d %s\n", n, line)
+		}
+	}
+}
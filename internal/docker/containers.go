@@ -2,12 +2,7 @@ package docker
 
 import (
 	"context"
-	"fmt"
 	"strings"
-
-	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/client"
 )
 
 // ContainerInfo holds information about a container on the pier network
@@ -21,31 +16,32 @@ type ContainerInfo struct {
 	ComposeProject string
 	ComposeService string
 	PierDomain  string
+	Owner       Owner
+	Managed     bool // carries pier.* ownership labels
 }
 
 // ListContainers returns all containers on the pier network
 func ListContainers(ctx context.Context, networkName string, tld string) ([]ContainerInfo, error) {
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	rt, err := Runtime()
 	if err != nil {
-		return nil, fmt.Errorf("connecting to Docker: %w", err)
+		return nil, err
 	}
-	defer cli.Close()
 
-	containers, err := cli.ContainerList(ctx, container.ListOptions{All: true})
+	containers, err := rt.ListContainers(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("listing containers: %w", err)
+		return nil, err
 	}
 
 	var result []ContainerInfo
 	for _, c := range containers {
 		// Check if container is on the pier network
-		if !isOnNetwork(c, networkName) {
+		if !c.OnNetwork(networkName) {
 			continue
 		}
 
 		info := ContainerInfo{
-			ID:    c.ID[:12],
-			Name:  cleanName(c.Names),
+			ID:    shortID(c.ID),
+			Name:  c.Name,
 			Image: c.Image,
 			State: c.State,
 			Status: c.Status,
@@ -55,8 +51,9 @@ func ListContainers(ctx context.Context, networkName string, tld string) ([]Cont
 		info.ComposeProject = c.Labels["com.docker.compose.project"]
 		info.ComposeService = c.Labels["com.docker.compose.service"]
 		info.PierDomain = c.Labels["pier.domain"]
+		info.Owner, info.Managed = OwnerOf(c.Labels)
 
-		// Determine domain: pier.domain > compose service > container name
+		// Determine domain: pier.domain > app service > compose service > container name
 		info.Domain = resolveDomain(info, tld)
 
 		result = append(result, info)
@@ -66,74 +63,57 @@ func ListContainers(ctx context.Context, networkName string, tld string) ([]Cont
 }
 
 // GetContainer returns info about a specific container
-func GetContainer(ctx context.Context, nameOrID string) (*types.ContainerJSON, error) {
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+func GetContainer(ctx context.Context, nameOrID string) (*Container, error) {
+	rt, err := Runtime()
 	if err != nil {
-		return nil, fmt.Errorf("connecting to Docker: %w", err)
+		return nil, err
 	}
-	defer cli.Close()
 
-	info, err := cli.ContainerInspect(ctx, nameOrID)
-	if err != nil {
-		return nil, fmt.Errorf("inspecting container: %w", err)
-	}
-
-	return &info, nil
+	return rt.InspectContainer(ctx, nameOrID)
 }
 
 // IsContainerRunning checks if a specific container is running
 func IsContainerRunning(ctx context.Context, name string) bool {
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	info, err := GetContainer(ctx, name)
 	if err != nil {
 		return false
 	}
-	defer cli.Close()
 
-	info, err := cli.ContainerInspect(ctx, name)
-	if err != nil {
-		return false
-	}
-
-	return info.State.Running
+	return info.Running
 }
 
 // StopAndRemoveContainer stops and removes a container by name
 func StopAndRemoveContainer(ctx context.Context, name string) error {
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	rt, err := Runtime()
 	if err != nil {
-		return fmt.Errorf("connecting to Docker: %w", err)
-	}
-	defer cli.Close()
-
-	// Stop the container
-	if err := cli.ContainerStop(ctx, name, container.StopOptions{}); err != nil {
-		// Ignore "not found" or "not running" errors
-		if !strings.Contains(err.Error(), "No such container") &&
-			!strings.Contains(err.Error(), "is not running") {
-			return fmt.Errorf("stopping container: %w", err)
-		}
+		return err
 	}
 
-	// Remove the container
-	if err := cli.ContainerRemove(ctx, name, container.RemoveOptions{Force: true}); err != nil {
-		if !strings.Contains(err.Error(), "No such container") {
-			return fmt.Errorf("removing container: %w", err)
-		}
+	// A missing container is already gone
+	if err := rt.StopContainer(ctx, name); err != nil && !IsNotFound(err) {
+		return err
+	}
+	if err := rt.RemoveContainer(ctx, name); err != nil && !IsNotFound(err) {
+		return err
 	}
 
 	return nil
 }
 
-func isOnNetwork(c types.Container, networkName string) bool {
-	if c.NetworkSettings == nil {
-		return false
+// RenameContainer renames a container
+func RenameContainer(ctx context.Context, name, newName string) error {
+	rt, err := Runtime()
+	if err != nil {
+		return err
 	}
-	for name := range c.NetworkSettings.Networks {
-		if name == networkName {
-			return true
-		}
+	return rt.RenameContainer(ctx, name, newName)
+}
+
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
 	}
-	return false
+	return id
 }
 
 func cleanName(names []string) string {
@@ -149,6 +129,8 @@ func resolveDomain(info ContainerInfo, tld string) string {
 	switch {
 	case info.PierDomain != "":
 		name = info.PierDomain
+	case info.Owner.Role == RoleApp && info.Owner.Service != "":
+		name = info.Owner.Service
 	case info.ComposeService != "":
 		name = info.ComposeService
 	default:
@@ -0,0 +1,513 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/build"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-connections/nat"
+)
+
+const defaultDockerSocket = "/var/run/docker.sock"
+
+// engine drives the Docker Engine API. Podman serves the same API on its
+// own socket, so both runtimes share this implementation.
+type engine struct {
+	name string
+	cli  *client.Client
+}
+
+// NewDockerRuntime connects to the Docker Engine named by DOCKER_HOST, or
+// the default socket
+func NewDockerRuntime() (ContainerRuntime, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, &Error{Op: "connecting to", Target: "docker", Kind: ErrUnavailable, Err: err}
+	}
+	return &engine{name: "docker", cli: cli}, nil
+}
+
+// NewPodmanRuntime connects to Podman's Docker-compatible socket, rootless
+// first. CONTAINER_HOST overrides the socket.
+func NewPodmanRuntime() (ContainerRuntime, error) {
+	host := os.Getenv("CONTAINER_HOST")
+	if host == "" {
+		sock, ok := podmanSocket()
+		if !ok {
+			return nil, &Error{Op: "connecting to", Target: "podman", Kind: ErrUnavailable,
+				Err: errors.New("no podman socket found (run `systemctl --user start podman.socket`)")}
+		}
+		host = "unix://" + sock
+	}
+	cli, err := client.NewClientWithOpts(client.WithHost(host), client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, &Error{Op: "connecting to", Target: "podman", Kind: ErrUnavailable, Err: err}
+	}
+	return &engine{name: "podman", cli: cli}, nil
+}
+
+// podmanSocket finds the rootless or rootful Podman API socket
+func podmanSocket() (string, bool) {
+	var candidates []string
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		candidates = append(candidates, filepath.Join(dir, "podman", "podman.sock"))
+	}
+	candidates = append(candidates, fmt.Sprintf("/run/user/%d/podman/podman.sock", os.Getuid()), "/run/podman/podman.sock")
+	for _, c := range candidates {
+		if socketExists(c) {
+			return c, true
+		}
+	}
+	return "", false
+}
+
+func (e *engine) Name() string { return e.name }
+
+func (e *engine) Ping(ctx context.Context) error {
+	_, err := e.cli.Ping(ctx)
+	return wrap("connecting to", e.name, err)
+}
+
+func (e *engine) NetworkExists(ctx context.Context, name string) (bool, error) {
+	networks, err := e.cli.NetworkList(ctx, network.ListOptions{})
+	if err != nil {
+		return false, wrap("listing", "networks", err)
+	}
+	for _, n := range networks {
+		if n.Name == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (e *engine) CreateNetwork(ctx context.Context, name string) error {
+	_, err := e.cli.NetworkCreate(ctx, name, network.CreateOptions{Driver: "bridge"})
+	return wrap("creating network", name, err)
+}
+
+func (e *engine) ListContainers(ctx context.Context) ([]Container, error) {
+	list, err := e.cli.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		return nil, wrap("listing", "containers", err)
+	}
+	result := make([]Container, 0, len(list))
+	for _, c := range list {
+		ctr := Container{
+			ID:      c.ID,
+			Name:    cleanName(c.Names),
+			Image:   c.Image,
+			State:   string(c.State),
+			Status:  c.Status,
+			Running: c.State == container.StateRunning,
+			Labels:  c.Labels,
+		}
+		if c.NetworkSettings != nil {
+			for n := range c.NetworkSettings.Networks {
+				ctr.Networks = append(ctr.Networks, n)
+			}
+		}
+		result = append(result, ctr)
+	}
+	return result, nil
+}
+
+func (e *engine) InspectContainer(ctx context.Context, name string) (*Container, error) {
+	info, err := e.cli.ContainerInspect(ctx, name)
+	if err != nil {
+		return nil, wrap("inspecting container", name, err)
+	}
+	ctr := &Container{
+		ID:   info.ID,
+		Name: strings.TrimPrefix(info.Name, "/"),
+	}
+	if info.Config != nil {
+		ctr.Image = info.Config.Image
+		ctr.Labels = info.Config.Labels
+		ctr.Env = info.Config.Env
+		ctr.Tty = info.Config.Tty
+		ctr.Entrypoint = info.Config.Entrypoint
+		ctr.Cmd = info.Config.Cmd
+		ctr.WorkingDir = info.Config.WorkingDir
+		ctr.User = info.Config.User
+		if h := info.Config.Healthcheck; h != nil && len(h.Test) > 0 {
+			ctr.Healthcheck = &HealthConfig{
+				Test:        h.Test,
+				Interval:    h.Interval,
+				Timeout:     h.Timeout,
+				StartPeriod: h.StartPeriod,
+				Retries:     h.Retries,
+			}
+		}
+	}
+	if info.HostConfig != nil {
+		ctr.Binds = info.HostConfig.Binds
+		ctr.RestartPolicy = string(info.HostConfig.RestartPolicy.Name)
+		if n := info.HostConfig.RestartPolicy.MaximumRetryCount; n > 0 {
+			ctr.RestartPolicy += ":" + strconv.Itoa(n)
+		}
+	}
+	if info.State != nil {
+		ctr.State = string(info.State.Status)
+		ctr.Status = string(info.State.Status)
+		ctr.Running = info.State.Running
+		ctr.ExitCode = info.State.ExitCode
+		if info.State.Health != nil {
+			ctr.Health = string(info.State.Health.Status)
+		}
+	}
+	ctr.Restarts = info.RestartCount
+	if info.NetworkSettings != nil {
+		for n, ep := range info.NetworkSettings.Networks {
+			ctr.Networks = append(ctr.Networks, n)
+			if len(ctr.Networks) == 1 && ep != nil {
+				for _, a := range ep.Aliases {
+					// Older engines list the short ID among the aliases
+					if !strings.HasPrefix(info.ID, a) {
+						ctr.Aliases = append(ctr.Aliases, a)
+					}
+				}
+			}
+		}
+		for port, bindings := range info.NetworkSettings.Ports {
+			for _, b := range bindings {
+				if hostPort, err := strconv.Atoi(b.HostPort); err == nil && hostPort > 0 {
+					if ctr.Ports == nil {
+						ctr.Ports = map[int]int{}
+					}
+					ctr.Ports[port.Int()] = hostPort
+					break
+				}
+			}
+		}
+	}
+	return ctr, nil
+}
+
+func (e *engine) CreateContainer(ctx context.Context, spec RunSpec) (string, error) {
+	cfg := &container.Config{
+		Image:      spec.Image,
+		Env:        spec.Env,
+		Labels:     spec.Labels,
+		Entrypoint: spec.Entrypoint,
+		Cmd:        spec.Cmd,
+		WorkingDir: spec.WorkingDir,
+		User:       spec.User,
+	}
+	if h := spec.Healthcheck; h != nil {
+		cfg.Healthcheck = &container.HealthConfig{
+			Test:        h.Test,
+			Interval:    h.Interval,
+			Timeout:     h.Timeout,
+			StartPeriod: h.StartPeriod,
+			Retries:     h.Retries,
+		}
+	}
+	hostCfg := &container.HostConfig{
+		Binds:         spec.Binds,
+		RestartPolicy: restartPolicy(spec.Restart),
+	}
+	if len(spec.Ports) > 0 {
+		cfg.ExposedPorts = nat.PortSet{}
+		hostCfg.PortBindings = nat.PortMap{}
+		for _, p := range spec.Ports {
+			port := nat.Port(fmt.Sprintf("%d/tcp", p.Container))
+			binding := nat.PortBinding{HostIP: p.HostIP}
+			if p.Host > 0 {
+				binding.HostPort = strconv.Itoa(p.Host)
+			}
+			cfg.ExposedPorts[port] = struct{}{}
+			hostCfg.PortBindings[port] = append(hostCfg.PortBindings[port], binding)
+		}
+	}
+	var netCfg *network.NetworkingConfig
+	if spec.Network != "" {
+		netCfg = &network.NetworkingConfig{
+			EndpointsConfig: map[string]*network.EndpointSettings{spec.Network: {Aliases: spec.Aliases}},
+		}
+	}
+
+	resp, err := e.cli.ContainerCreate(ctx, cfg, hostCfg, netCfg, nil, spec.Name)
+	if err != nil {
+		return "", wrap("creating container", spec.Name, err)
+	}
+	return resp.ID, nil
+}
+
+// restartPolicy reads a restart policy as docker run's --restart takes it:
+// no, always, unless-stopped or on-failure[:max-retries]
+func restartPolicy(s string) container.RestartPolicy {
+	name, retries, _ := strings.Cut(s, ":")
+	policy := container.RestartPolicy{Name: container.RestartPolicyMode(name)}
+	if n, err := strconv.Atoi(retries); err == nil {
+		policy.MaximumRetryCount = n
+	}
+	return policy
+}
+
+func (e *engine) StartContainer(ctx context.Context, id string) error {
+	return wrap("starting container", id, e.cli.ContainerStart(ctx, id, container.StartOptions{}))
+}
+
+func (e *engine) StopContainer(ctx context.Context, name string) error {
+	return wrap("stopping container", name, e.cli.ContainerStop(ctx, name, container.StopOptions{}))
+}
+
+func (e *engine) RemoveContainer(ctx context.Context, name string) error {
+	return wrap("removing container", name, e.cli.ContainerRemove(ctx, name, container.RemoveOptions{Force: true}))
+}
+
+func (e *engine) RestartContainer(ctx context.Context, name string) error {
+	return wrap("restarting container", name, e.cli.ContainerRestart(ctx, name, container.StopOptions{}))
+}
+
+func (e *engine) RenameContainer(ctx context.Context, name, newName string) error {
+	return wrap("renaming container", name, e.cli.ContainerRename(ctx, name, newName))
+}
+
+func (e *engine) ImageExists(ctx context.Context, ref string) (bool, error) {
+	_, err := e.cli.ImageInspect(ctx, ref)
+	if cerrdefs.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, wrap("inspecting image", ref, err)
+	}
+	return true, nil
+}
+
+func (e *engine) ImageLabels(ctx context.Context, ref string) (map[string]string, error) {
+	info, err := e.cli.ImageInspect(ctx, ref)
+	if err != nil {
+		return nil, wrap("inspecting image", ref, err)
+	}
+	if info.Config == nil {
+		return nil, nil
+	}
+	return info.Config.Labels, nil
+}
+
+func (e *engine) PullImage(ctx context.Context, ref string, progress io.Writer) error {
+	reader, err := e.cli.ImagePull(ctx, ref, image.PullOptions{})
+	if err != nil {
+		return wrap("pulling image", ref, err)
+	}
+	defer reader.Close()
+
+	if progress == nil {
+		progress = io.Discard
+	}
+	return wrap("pulling image", ref, displayStream(reader, progress))
+}
+
+func (e *engine) TagImage(ctx context.Context, source, target string) error {
+	return wrap("tagging image", source, e.cli.ImageTag(ctx, source, target))
+}
+
+func (e *engine) BuildImage(ctx context.Context, spec BuildSpec, out io.Writer) error {
+	if out == nil {
+		out = io.Discard
+	}
+	dockerfile := spec.Dockerfile
+	if dockerfile == "" {
+		dockerfile = filepath.Join(spec.Context, "Dockerfile")
+	}
+
+	bc, err := newBuildContext(spec.Context, dockerfile)
+	if err != nil {
+		return &BuildError{Image: spec.Tag, Message: err.Error()}
+	}
+	pr, pw := io.Pipe()
+	go func() { pw.CloseWithError(bc.write(pw)) }()
+	defer pr.Close()
+
+	args, err := spec.buildArgs()
+	if err != nil {
+		return &BuildError{Image: spec.Tag, Message: err.Error()}
+	}
+	opts := build.ImageBuildOptions{
+		Tags:        []string{spec.Tag},
+		Dockerfile:  bc.dockerfile,
+		BuildArgs:   args,
+		Target:      spec.Target,
+		Platform:    spec.Platform,
+		CacheFrom:   cacheRefs(spec.CacheFrom),
+		Labels:      spec.Labels,
+		Remove:      true,
+		ForceRemove: true,
+	}
+	// Podman's compatible API has no BuildKit; its builder takes the
+	// common options but not secrets, SSH or cache export
+	display := displayStream
+	if e.name == "docker" {
+		sess, err := e.buildKitSession(ctx, spec)
+		if err != nil {
+			return &BuildError{Image: spec.Tag, Message: err.Error()}
+		}
+		defer sess.Close()
+		opts.Version = build.BuilderBuildKit
+		opts.SessionID = sess.ID()
+		display = displayBuildKitStream
+	} else if spec.needsBuildKit() {
+		return &BuildError{Image: spec.Tag, Message: fmt.Sprintf("build secrets, ssh and cache_to need BuildKit, which %s doesn't offer", e.name)}
+	}
+
+	resp, err := e.cli.ImageBuild(ctx, pr, opts)
+	if err != nil {
+		return wrap("building image", spec.Tag, err)
+	}
+	defer resp.Body.Close()
+
+	if err := display(resp.Body, out); err != nil {
+		var jsonErr *jsonmessage.JSONError
+		if errors.As(err, &jsonErr) {
+			return &BuildError{Image: spec.Tag, Message: jsonErr.Message}
+		}
+		return wrap("building image", spec.Tag, err)
+	}
+	return nil
+}
+
+func (e *engine) Exec(ctx context.Context, containerName string, cmd []string, opts ExecOptions) error {
+	created, err := e.cli.ContainerExecCreate(ctx, containerName, container.ExecOptions{
+		Cmd:          cmd,
+		AttachStdin:  opts.Stdin != nil,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return wrap("exec in", containerName, err)
+	}
+
+	resp, err := e.cli.ContainerExecAttach(ctx, created.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return wrap("attaching to exec in", containerName, err)
+	}
+	defer resp.Close()
+
+	if opts.Stdin != nil {
+		go func() {
+			_, _ = io.Copy(resp.Conn, opts.Stdin)
+			_ = resp.CloseWrite()
+		}()
+	}
+
+	stdout, stderr := discardNil(opts.Stdout), discardNil(opts.Stderr)
+	if _, err := stdcopy.StdCopy(stdout, stderr, resp.Reader); err != nil {
+		return wrap("reading exec output from", containerName, err)
+	}
+
+	inspect, err := e.cli.ContainerExecInspect(ctx, created.ID)
+	if err != nil {
+		return wrap("inspecting exec in", containerName, err)
+	}
+	if inspect.ExitCode != 0 {
+		return &ExecError{Container: containerName, Cmd: cmd, ExitCode: inspect.ExitCode}
+	}
+	return nil
+}
+
+func (e *engine) Logs(ctx context.Context, name string, opts LogOptions, stdout, stderr io.Writer) error {
+	info, err := e.cli.ContainerInspect(ctx, name)
+	if err != nil {
+		return wrap("reading logs of", name, err)
+	}
+	rc, err := e.cli.ContainerLogs(ctx, name, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     opts.Follow,
+		Tail:       opts.Tail,
+	})
+	if err != nil {
+		return wrap("reading logs of", name, err)
+	}
+	defer rc.Close()
+
+	// TTY containers have a raw stream; others are multiplexed
+	if info.Config != nil && info.Config.Tty {
+		_, err = io.Copy(discardNil(stdout), rc)
+	} else {
+		_, err = stdcopy.StdCopy(discardNil(stdout), discardNil(stderr), rc)
+	}
+	if err != nil && ctx.Err() == nil {
+		return wrap("reading logs of", name, err)
+	}
+	return nil
+}
+
+func (e *engine) Events(ctx context.Context) (<-chan Event, <-chan error) {
+	msgs, errs := e.cli.Events(ctx, events.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("type", string(events.ContainerEventType))),
+	})
+	out := make(chan Event)
+	outErr := make(chan error, 1)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case m := <-msgs:
+				attrs := m.Actor.Attributes
+				ev := Event{
+					Action:    string(m.Action),
+					ID:        m.Actor.ID,
+					Name:      attrs["name"],
+					Labels:    attrs,
+					ExitCode:  attrs["exitCode"],
+					Timestamp: time.Unix(0, m.TimeNano),
+				}
+				select {
+				case out <- ev:
+				case <-ctx.Done():
+					return
+				}
+			case err := <-errs:
+				if ctx.Err() == nil {
+					outErr <- wrap("watching", "events", err)
+				}
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, outErr
+}
+
+func discardNil(w io.Writer) io.Writer {
+	if w == nil {
+		return io.Discard
+	}
+	return w
+}
+
+func (e *engine) Stats(ctx context.Context, name string) (*Stats, error) {
+	// A non-streaming read waits for a second sample, so CPU has a delta
+	resp, err := e.cli.ContainerStats(ctx, name, false)
+	if err != nil {
+		return nil, wrap("reading stats of", name, err)
+	}
+	defer resp.Body.Close()
+
+	var stats container.StatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return nil, wrap("reading stats of", name, err)
+	}
+	return statsFromResponse(stats), nil
+}
@@ -0,0 +1,85 @@
+package docker
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/client"
+)
+
+// Error kinds, matched with errors.Is
+var (
+	ErrNotFound    = errors.New("not found")
+	ErrConflict    = errors.New("already exists")
+	ErrUnavailable = errors.New("container runtime is not reachable")
+)
+
+// Error is a failed Docker operation on one container, image or network
+type Error struct {
+	Op     string // e.g. "creating container"
+	Target string // container, image or network name
+	Kind   error  // ErrNotFound, ErrConflict, ErrUnavailable or nil
+	Err    error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Op, e.Target, e.Err)
+}
+
+// Unwrap exposes both the kind and the underlying SDK error
+func (e *Error) Unwrap() []error {
+	if e.Kind == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// wrap classifies an SDK error. Returns nil for a nil err.
+func wrap(op, target string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var kind error
+	switch {
+	case cerrdefs.IsNotFound(err):
+		kind = ErrNotFound
+	case cerrdefs.IsConflict(err), cerrdefs.IsAlreadyExists(err):
+		kind = ErrConflict
+	case client.IsErrConnectionFailed(err):
+		kind = ErrUnavailable
+	}
+	return &Error{Op: op, Target: target, Kind: kind, Err: err}
+}
+
+// IsNotFound reports whether err means the container/image doesn't exist
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+// ExecError is a command that exited non-zero inside a container
+type ExecError struct {
+	Container string
+	Cmd       []string
+	ExitCode  int
+	Output    string // combined output, when it was captured
+}
+
+func (e *ExecError) Error() string {
+	msg := fmt.Sprintf("%s in %s exited with code %d", strings.Join(e.Cmd, " "), e.Container, e.ExitCode)
+	if out := strings.TrimSpace(e.Output); out != "" {
+		msg += "\n" + out
+	}
+	return msg
+}
+
+// BuildError is a failed image build, with the builder's message
+type BuildError struct {
+	Image   string
+	Message string
+}
+
+func (e *BuildError) Error() string {
+	return fmt.Sprintf("building %s: %s", e.Image, e.Message)
+}
@@ -0,0 +1,44 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"io"
+)
+
+// ExecOptions wires a command's streams. Nil writers discard output.
+type ExecOptions struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Exec runs a command in a running container and returns its combined
+// output. A non-zero exit is an *ExecError carrying that output.
+func Exec(ctx context.Context, containerName string, cmd ...string) ([]byte, error) {
+	var out bytes.Buffer
+	err := ExecStream(ctx, containerName, cmd, ExecOptions{Stdout: &out, Stderr: &out})
+	if execErr, ok := err.(*ExecError); ok {
+		execErr.Output = out.String()
+	}
+	return out.Bytes(), err
+}
+
+// ExecStream runs a command in a running container with the given streams,
+// like `docker exec -i`
+func ExecStream(ctx context.Context, containerName string, cmd []string, opts ExecOptions) error {
+	rt, err := Runtime()
+	if err != nil {
+		return err
+	}
+	return rt.Exec(ctx, containerName, cmd, opts)
+}
+
+// Logs copies a container's logs to stdout and stderr, like `docker logs`
+func Logs(ctx context.Context, containerName string, opts LogOptions, stdout, stderr io.Writer) error {
+	rt, err := Runtime()
+	if err != nil {
+		return err
+	}
+	return rt.Logs(ctx, containerName, opts, stdout, stderr)
+}
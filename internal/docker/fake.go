@@ -0,0 +1,410 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fake is an in-memory ContainerRuntime for tests. Containers, networks and
+// images live in maps; pulls and builds just mark images present; execs are
+// recorded and answered by ExecFunc.
+type Fake struct {
+	mu sync.Mutex
+
+	Networks   map[string]bool
+	Images     map[string]bool
+	ImageMeta  map[string]map[string]string // image → labels it was built with
+	Containers map[string]*Container        // by name
+	Specs      map[string]RunSpec           // the spec each container was created from
+	Builds     []BuildSpec
+	Pulls      []string
+	Execs      []FakeExec
+	LogOutput  map[string]string // container name → log output
+	StatsData  map[string]Stats  // container name → what Stats reports
+
+	// ExecFunc answers Exec calls; nil means every command succeeds
+	ExecFunc func(container string, cmd []string, opts ExecOptions) error
+	// BuildFunc, when set, can fail a build
+	BuildFunc func(spec BuildSpec) error
+	// OnStart, when set, runs after a container starts and may change its
+	// state, e.g. to report health or exit straight away
+	OnStart func(c *Container)
+
+	nextID   int
+	nextPort int
+	watchers []chan Event
+}
+
+// FakeExec is one recorded Exec call
+type FakeExec struct {
+	Container string
+	Cmd       []string
+}
+
+// NewFake returns an empty fake runtime
+func NewFake() *Fake {
+	return &Fake{
+		Networks:   map[string]bool{},
+		Images:     map[string]bool{},
+		ImageMeta:  map[string]map[string]string{},
+		Containers: map[string]*Container{},
+		Specs:      map[string]RunSpec{},
+		LogOutput:  map[string]string{},
+		StatsData:  map[string]Stats{},
+		nextPort:   49152,
+	}
+}
+
+// UseFake installs a new Fake as the active runtime and returns it
+func UseFake() *Fake {
+	f := NewFake()
+	SetRuntime(f)
+	return f
+}
+
+func (f *Fake) Name() string                   { return "docker" }
+func (f *Fake) Ping(ctx context.Context) error { return nil }
+
+func (f *Fake) NetworkExists(ctx context.Context, name string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.Networks[name], nil
+}
+
+func (f *Fake) CreateNetwork(ctx context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Networks[name] {
+		return &Error{Op: "creating network", Target: name, Kind: ErrConflict, Err: errors.New("network exists")}
+	}
+	f.Networks[name] = true
+	return nil
+}
+
+func (f *Fake) ListContainers(ctx context.Context) ([]Container, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	names := make([]string, 0, len(f.Containers))
+	for name := range f.Containers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	list := make([]Container, 0, len(names))
+	for _, name := range names {
+		list = append(list, *f.Containers[name])
+	}
+	return list, nil
+}
+
+func (f *Fake) InspectContainer(ctx context.Context, name string) (*Container, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.lookup("inspecting container", name)
+	if err != nil {
+		return nil, err
+	}
+	cp := *c
+	spec := f.Specs[c.Name]
+	cp.Entrypoint, cp.Cmd, cp.WorkingDir, cp.User = spec.Entrypoint, spec.Cmd, spec.WorkingDir, spec.User
+	cp.Binds, cp.RestartPolicy, cp.Aliases = spec.Binds, spec.Restart, spec.Aliases
+	cp.Healthcheck = spec.Healthcheck
+	return &cp, nil
+}
+
+func (f *Fake) CreateContainer(ctx context.Context, spec RunSpec) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.Containers[spec.Name]; ok {
+		return "", &Error{Op: "creating container", Target: spec.Name, Kind: ErrConflict, Err: errors.New("name in use")}
+	}
+	if !f.Images[spec.Image] {
+		return "", &Error{Op: "creating container", Target: spec.Name, Kind: ErrNotFound, Err: fmt.Errorf("no such image: %s", spec.Image)}
+	}
+	if spec.Network != "" && !f.Networks[spec.Network] {
+		return "", &Error{Op: "creating container", Target: spec.Name, Kind: ErrNotFound, Err: fmt.Errorf("no such network: %s", spec.Network)}
+	}
+
+	f.nextID++
+	c := &Container{
+		ID:     fmt.Sprintf("%064x", f.nextID),
+		Name:   spec.Name,
+		Image:  spec.Image,
+		State:  "created",
+		Status: "Created",
+		Labels: spec.Labels,
+		Env:    spec.Env,
+	}
+	if spec.Network != "" {
+		c.Networks = []string{spec.Network}
+	}
+	for _, p := range spec.Ports {
+		if c.Ports == nil {
+			c.Ports = map[int]int{}
+		}
+		host := p.Host
+		if host == 0 {
+			host = f.nextPort
+			f.nextPort++
+		}
+		c.Ports[p.Container] = host
+	}
+	f.Containers[spec.Name] = c
+	f.Specs[spec.Name] = spec
+	f.emit("create", c)
+	return c.ID, nil
+}
+
+func (f *Fake) StartContainer(ctx context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.lookup("starting container", id)
+	if err != nil {
+		return err
+	}
+	c.State, c.Status, c.Running, c.ExitCode = "running", "Up", true, 0
+	f.emit("start", c)
+	if f.OnStart != nil {
+		f.OnStart(c)
+		if !c.Running {
+			f.emit("die", c)
+		}
+	}
+	return nil
+}
+
+func (f *Fake) StopContainer(ctx context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.lookup("stopping container", name)
+	if err != nil {
+		return err
+	}
+	if c.Running {
+		c.State, c.Status, c.Running = "exited", "Exited (0)", false
+		f.emit("die", c)
+	}
+	return nil
+}
+
+func (f *Fake) RemoveContainer(ctx context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.lookup("removing container", name)
+	if err != nil {
+		return err
+	}
+	if c.Running {
+		c.Running, c.State = false, "exited"
+		f.emit("die", c)
+	}
+	delete(f.Containers, c.Name)
+	delete(f.Specs, c.Name)
+	f.emit("destroy", c)
+	return nil
+}
+
+func (f *Fake) RestartContainer(ctx context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.lookup("restarting container", name)
+	if err != nil {
+		return err
+	}
+	c.State, c.Status, c.Running = "running", "Up", true
+	c.Restarts++
+	f.emit("restart", c)
+	return nil
+}
+
+func (f *Fake) RenameContainer(ctx context.Context, name, newName string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.lookup("renaming container", name)
+	if err != nil {
+		return err
+	}
+	if _, ok := f.Containers[newName]; ok {
+		return &Error{Op: "renaming container", Target: name, Kind: ErrConflict, Err: errors.New("name in use")}
+	}
+	old := c.Name
+	c.Name = newName
+	f.Containers[newName], f.Specs[newName] = c, f.Specs[old]
+	delete(f.Containers, old)
+	delete(f.Specs, old)
+	f.emit("rename", c)
+	return nil
+}
+
+func (f *Fake) ImageExists(ctx context.Context, ref string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.Images[ref], nil
+}
+
+func (f *Fake) ImageLabels(ctx context.Context, ref string) (map[string]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.Images[ref] {
+		return nil, &Error{Op: "inspecting image", Target: ref, Kind: ErrNotFound, Err: errors.New("no such image")}
+	}
+	return f.ImageMeta[ref], nil
+}
+
+func (f *Fake) PullImage(ctx context.Context, ref string, progress io.Writer) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Pulls = append(f.Pulls, ref)
+	f.Images[ref] = true
+	return nil
+}
+
+func (f *Fake) BuildImage(ctx context.Context, spec BuildSpec, out io.Writer) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Builds = append(f.Builds, spec)
+	if f.BuildFunc != nil {
+		if err := f.BuildFunc(spec); err != nil {
+			return err
+		}
+	}
+	f.Images[spec.Tag] = true
+	f.ImageMeta[spec.Tag] = spec.Labels
+	return nil
+}
+
+func (f *Fake) TagImage(ctx context.Context, source, target string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.Images[source] {
+		return &Error{Op: "tagging image", Target: source, Kind: ErrNotFound, Err: errors.New("no such image")}
+	}
+	f.Images[target] = true
+	f.ImageMeta[target] = f.ImageMeta[source]
+	return nil
+}
+
+func (f *Fake) Exec(ctx context.Context, container string, cmd []string, opts ExecOptions) error {
+	f.mu.Lock()
+	c, err := f.lookup("exec in", container)
+	if err == nil && !c.Running {
+		err = &Error{Op: "exec in", Target: container, Kind: ErrConflict, Err: errors.New("container is not running")}
+	}
+	f.Execs = append(f.Execs, FakeExec{Container: container, Cmd: cmd})
+	fn := f.ExecFunc
+	f.mu.Unlock()
+
+	if err != nil || fn == nil {
+		return err
+	}
+	return fn(container, cmd, opts)
+}
+
+func (f *Fake) Logs(ctx context.Context, container string, opts LogOptions, stdout, stderr io.Writer) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := f.lookup("reading logs of", container); err != nil {
+		return err
+	}
+	_, err := io.WriteString(discardNil(stdout), f.LogOutput[container])
+	return err
+}
+
+func (f *Fake) Events(ctx context.Context) (<-chan Event, <-chan error) {
+	ch := make(chan Event, 64)
+	f.mu.Lock()
+	f.watchers = append(f.watchers, ch)
+	f.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		for i, w := range f.watchers {
+			if w == ch {
+				f.watchers = append(f.watchers[:i], f.watchers[i+1:]...)
+				break
+			}
+		}
+		close(ch)
+	}()
+	return ch, make(chan error)
+}
+
+func (f *Fake) Stats(ctx context.Context, container string) (*Stats, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.lookup("reading stats of", container)
+	if err != nil {
+		return nil, err
+	}
+	if !c.Running {
+		return nil, &Error{Op: "reading stats of", Target: container, Kind: ErrConflict, Err: errors.New("container is not running")}
+	}
+	s := f.StatsData[c.Name]
+	s.Name, s.Time = c.Name, time.Now()
+	return &s, nil
+}
+
+// Crash marks a running container exited with code, as if its process died
+func (f *Fake) Crash(name string, code int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if c, ok := f.Containers[name]; ok && c.Running {
+		c.State, c.Status, c.Running, c.ExitCode = "exited", fmt.Sprintf("Exited (%d)", code), false, code
+		f.emit("die", c)
+	}
+}
+
+// Spec returns the RunSpec a container was created from
+func (f *Fake) Spec(name string) (RunSpec, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	spec, ok := f.Specs[name]
+	return spec, ok
+}
+
+// ExecCommands returns the recorded exec command lines for a container
+func (f *Fake) ExecCommands(container string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var cmds []string
+	for _, e := range f.Execs {
+		if e.Container == container {
+			cmds = append(cmds, strings.Join(e.Cmd, " "))
+		}
+	}
+	return cmds
+}
+
+// lookup finds a container by name or ID. Callers hold f.mu.
+func (f *Fake) lookup(op, nameOrID string) (*Container, error) {
+	if c, ok := f.Containers[nameOrID]; ok {
+		return c, nil
+	}
+	for _, c := range f.Containers {
+		if c.ID == nameOrID || (len(nameOrID) >= 12 && strings.HasPrefix(c.ID, nameOrID)) {
+			return c, nil
+		}
+	}
+	return nil, &Error{Op: op, Target: nameOrID, Kind: ErrNotFound, Err: errors.New("no such container")}
+}
+
+// emit notifies event watchers. Callers hold f.mu.
+func (f *Fake) emit(action string, c *Container) {
+	ev := Event{Action: action, ID: c.ID, Name: c.Name, Labels: c.Labels, Timestamp: time.Now()}
+	if action == "die" {
+		ev.ExitCode = fmt.Sprintf("%d", c.ExitCode)
+	}
+	for _, w := range f.watchers {
+		select {
+		case w <- ev:
+		default:
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// HealthConfig is a container HEALTHCHECK. A zero field keeps the image's
+// (or Docker's) default.
+type HealthConfig struct {
+	Test        []string // {"CMD-SHELL", cmd}, {"CMD", args...}, or {"NONE"} to disable the image's
+	Interval    time.Duration
+	Timeout     time.Duration
+	StartPeriod time.Duration
+	Retries     int
+}
+
+// Health states reported by InspectContainer; "" means no HEALTHCHECK
+const (
+	HealthStarting  = "starting"
+	HealthHealthy   = "healthy"
+	HealthUnhealthy = "unhealthy"
+)
+
+// ReadyCheck configures WaitReady
+type ReadyCheck struct {
+	// Probe reports readiness of containers without a HEALTHCHECK; nil
+	// means ready once the container has stayed up for Settle
+	Probe    func(ctx context.Context) error
+	Timeout  time.Duration
+	Interval time.Duration
+	Settle   time.Duration
+}
+
+// NotReadyError is returned by WaitReady when a container never became ready
+type NotReadyError struct {
+	Container string
+	Reason    string // e.g. "exited with code 1", "unhealthy", "not ready after 1m0s"
+	Exited    bool   // the container stopped or is crash-looping
+}
+
+func (e *NotReadyError) Error() string {
+	return fmt.Sprintf("%s %s", e.Container, e.Reason)
+}
+
+// WaitReady waits for a started container to become ready: healthy by its
+// HEALTHCHECK if it has one, otherwise passing check.Probe. It fails as soon
+// as the container exits, restarts or turns unhealthy.
+func WaitReady(ctx context.Context, name string, check ReadyCheck) error {
+	rt, err := Runtime()
+	if err != nil {
+		return err
+	}
+	if check.Interval == 0 {
+		check.Interval = 500 * time.Millisecond
+	}
+	if check.Settle == 0 {
+		check.Settle = 2 * time.Second
+	}
+
+	start := time.Now()
+	deadline := time.NewTimer(check.Timeout)
+	defer deadline.Stop()
+	restarts := -1
+	for {
+		c, err := rt.InspectContainer(ctx, name)
+		if err != nil {
+			if IsNotFound(err) {
+				return &NotReadyError{Container: name, Reason: "was removed", Exited: true}
+			}
+			return err
+		}
+		if restarts < 0 {
+			restarts = c.Restarts
+		}
+
+		switch {
+		case !c.Running || c.State == "restarting" || c.Restarts > restarts:
+			return &NotReadyError{Container: name, Reason: fmt.Sprintf("exited with code %d", c.ExitCode), Exited: true}
+		case c.Health == HealthHealthy:
+			return nil
+		case c.Health == HealthUnhealthy:
+			return &NotReadyError{Container: name, Reason: "is unhealthy"}
+		case c.Health == HealthStarting:
+			// The HEALTHCHECK decides; don't probe around it
+		case check.Probe != nil:
+			if check.Probe(ctx) == nil {
+				return nil
+			}
+		case time.Since(start) >= check.Settle:
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline.C:
+			return &NotReadyError{Container: name, Reason: fmt.Sprintf("not ready after %s", check.Timeout)}
+		case <-time.After(check.Interval):
+		}
+	}
+}
+
+// WaitExit waits for a started container to stop, such as a one-off task,
+// and returns its exit code. A container that restarts never counts as
+// stopped.
+func WaitExit(ctx context.Context, name string, timeout, interval time.Duration) (int, error) {
+	rt, err := Runtime()
+	if err != nil {
+		return 0, err
+	}
+	if interval == 0 {
+		interval = 500 * time.Millisecond
+	}
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		c, err := rt.InspectContainer(ctx, name)
+		if err != nil {
+			return 0, err
+		}
+		if !c.Running && c.State != "restarting" {
+			return c.ExitCode, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-deadline.C:
+			return 0, &NotReadyError{Container: name, Reason: fmt.Sprintf("still running after %s", timeout)}
+		case <-time.After(interval):
+		}
+	}
+}
+
+// HTTPProbe returns a probe that requests url with the given Host header,
+// e.g. an app through Traefik. Any response below 500 counts as ready, so
+// the route must already be loaded: until then Traefik answers 404 itself.
+func HTTPProbe(url, host string) func(ctx context.Context) error {
+	client := &http.Client{
+		Timeout: 2 * time.Second,
+		// A redirect (to a login page, say) already proves the app is up
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		req.Host = host
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 500 {
+			return fmt.Errorf("%s returned %s", url, resp.Status)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,142 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func startFake(t *testing.T, f *Fake, name string) {
+	t.Helper()
+	f.Images["shop"] = true
+	if _, err := Run(context.Background(), RunSpec{Name: name, Image: "shop"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWaitReady(t *testing.T) {
+	ready := func(context.Context) error { return nil }
+	notYet := func(context.Context) error { return errors.New("connection refused") }
+
+	tests := []struct {
+		name    string
+		health  string
+		exit    bool
+		probe   func(context.Context) error
+		wantErr string // NotReadyError reason; "" means ready
+		exited  bool
+	}{
+		{name: "healthy", health: HealthHealthy},
+		{name: "unhealthy", health: HealthUnhealthy, wantErr: "is unhealthy"},
+		{name: "healthcheck still starting", health: HealthStarting, probe: ready, wantErr: "not ready after 50ms"},
+		{name: "probe passes", probe: ready},
+		{name: "probe never passes", probe: notYet, wantErr: "not ready after 50ms"},
+		{name: "stays up", probe: nil},
+		{name: "exits", exit: true, wantErr: "exited with code 1", exited: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := UseFake()
+			t.Cleanup(func() { SetRuntime(nil) })
+			f.OnStart = func(c *Container) {
+				c.Health = tt.health
+				if tt.exit {
+					c.State, c.Running, c.ExitCode = "exited", false, 1
+				}
+			}
+			startFake(t, f, "pier-app-shop")
+
+			err := WaitReady(context.Background(), "pier-app-shop", ReadyCheck{
+				Probe:    tt.probe,
+				Timeout:  50 * time.Millisecond,
+				Interval: 5 * time.Millisecond,
+				Settle:   10 * time.Millisecond,
+			})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var notReady *NotReadyError
+			if !errors.As(err, &notReady) {
+				t.Fatalf("err = %v, want a *NotReadyError", err)
+			}
+			if notReady.Reason != tt.wantErr || notReady.Exited != tt.exited {
+				t.Errorf("got %q (exited %v), want %q (exited %v)", notReady.Reason, notReady.Exited, tt.wantErr, tt.exited)
+			}
+		})
+	}
+}
+
+func TestWaitReady_Restarted(t *testing.T) {
+	f := UseFake()
+	t.Cleanup(func() { SetRuntime(nil) })
+	startFake(t, f, "pier-app-shop")
+
+	// The restart policy brings a crashing app straight back up; a bumped
+	// restart count is the only trace
+	probe := func(ctx context.Context) error {
+		_ = f.RestartContainer(ctx, "pier-app-shop")
+		return errors.New("connection refused")
+	}
+	err := WaitReady(context.Background(), "pier-app-shop", ReadyCheck{Probe: probe, Timeout: time.Second, Interval: 5 * time.Millisecond})
+	var notReady *NotReadyError
+	if !errors.As(err, &notReady) || !notReady.Exited {
+		t.Fatalf("err = %v, want an exited *NotReadyError", err)
+	}
+}
+
+func TestWaitExit(t *testing.T) {
+	f := UseFake()
+	t.Cleanup(func() { SetRuntime(nil) })
+	startFake(t, f, "pier-app-migrate")
+
+	ctx := context.Background()
+	if _, err := WaitExit(ctx, "pier-app-migrate", 20*time.Millisecond, 5*time.Millisecond); err == nil {
+		t.Fatal("a running container should time out")
+	}
+	go f.Crash("pier-app-migrate", 3)
+	code, err := WaitExit(ctx, "pier-app-migrate", time.Second, 5*time.Millisecond)
+	if err != nil || code != 3 {
+		t.Fatalf("WaitExit = %d, %v; want 3", code, err)
+	}
+}
+
+func TestHTTPProbe(t *testing.T) {
+	var status int
+	var host string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+		if status == http.StatusNotFound {
+			http.NotFound(w, r) // an app without a page at /
+			return
+		}
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	probe := HTTPProbe(srv.URL+"/healthz", "shop.dock")
+	for _, tt := range []struct {
+		status int
+		ready  bool
+	}{
+		{http.StatusOK, true},
+		{http.StatusFound, true},
+		{http.StatusUnauthorized, true},
+		{http.StatusNotFound, true},
+		{http.StatusBadGateway, false},
+	} {
+		status = tt.status
+		err := probe(context.Background())
+		if (err == nil) != tt.ready {
+			t.Errorf("status %d: err = %v, want ready %v", tt.status, err, tt.ready)
+		}
+	}
+	if host != "shop.dock" {
+		t.Errorf("Host = %q, want shop.dock", host)
+	}
+}
@@ -0,0 +1,155 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Labels Pier stamps on every container it creates, so ownership comes from
+// the container itself rather than from its name
+const (
+	LabelManaged = "pier.managed"
+	LabelRole    = "pier.role"
+	LabelProject = "pier.project"
+	LabelService = "pier.service"
+	LabelVersion = "pier.version"
+	LabelReplica = "pier.replica"
+	LabelStaging = "pier.staging" // app: the name a new version takes over once ready
+)
+
+// Container roles
+const (
+	RoleApp    = "app"    // a project's own containers
+	RoleInfra  = "infra"  // shared services: postgres, redis, ...
+	RoleSystem = "system" // Pier itself: Traefik, Adminer
+)
+
+// appPrefix namespaces app containers so a project called "redis" can't
+// collide with anything else on the host
+const appPrefix = "pier-app-"
+
+// Owner says which part of Pier a container belongs to
+type Owner struct {
+	Role    string
+	Project string // app containers: the project
+	Service string // app: the routed service; infra/system: the service name
+	Version string // infra: the service version
+	Replica int    // app: the replica's number when scaled, else 0
+}
+
+// Labels returns the ownership labels for o, leaving out empty fields
+func (o Owner) Labels() map[string]string {
+	labels := map[string]string{LabelManaged: "true", LabelRole: o.Role}
+	for k, v := range map[string]string{LabelProject: o.Project, LabelService: o.Service, LabelVersion: o.Version} {
+		if v != "" {
+			labels[k] = v
+		}
+	}
+	if o.Replica > 0 {
+		labels[LabelReplica] = strconv.Itoa(o.Replica)
+	}
+	return labels
+}
+
+// OwnerOf reads ownership labels. ok is false for containers Pier didn't
+// create, or created before it labelled them.
+func OwnerOf(labels map[string]string) (o Owner, ok bool) {
+	if labels[LabelManaged] != "true" {
+		return Owner{}, false
+	}
+	replica, _ := strconv.Atoi(labels[LabelReplica])
+	return Owner{
+		Role:    labels[LabelRole],
+		Project: labels[LabelProject],
+		Service: labels[LabelService],
+		Version: labels[LabelVersion],
+		Replica: replica,
+	}, true
+}
+
+// AppContainerName is the container name for a project's service:
+// pier-app-<project>, or pier-app-<project>-<service> when a project runs
+// several services
+func AppContainerName(project, service string) string {
+	if service == "" || service == project {
+		return appPrefix + project
+	}
+	return appPrefix + project + "-" + service
+}
+
+// ReplicaName is the name of replica n of app container name
+func ReplicaName(name string, n int) string {
+	return fmt.Sprintf("%s-%d", name, n)
+}
+
+// StagingName is where a new version of container name runs while the old
+// one still serves
+func StagingName(name string) string {
+	return name + "-next"
+}
+
+// IsStaging reports whether c is a new version that has yet to take over
+// its name. Names can't tell: a project may well be called "next".
+func IsStaging(c Container) bool {
+	name := c.Labels[LabelStaging]
+	return name != "" && name != c.Name
+}
+
+// Replicas returns the containers of an app service, found by the owner's
+// project and service: its container, its replicas, and their staging
+// containers
+func Replicas(ctx context.Context, owner Owner) ([]Container, error) {
+	rt, err := Runtime()
+	if err != nil {
+		return nil, err
+	}
+	containers, err := rt.ListContainers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var result []Container
+	for _, c := range containers {
+		o, ok := OwnerOf(c.Labels)
+		if ok && o.Role == RoleApp && o.Project == owner.Project && o.Service == owner.Service {
+			result = append(result, c)
+		}
+	}
+	return result, nil
+}
+
+// IsAppContainerName reports whether name is in the app namespace
+func IsAppContainerName(name string) bool {
+	return strings.HasPrefix(name, appPrefix)
+}
+
+// AppContainers returns the app containers of project name, or the app
+// service called name when no project matches
+func AppContainers(ctx context.Context, name string) ([]Container, error) {
+	rt, err := Runtime()
+	if err != nil {
+		return nil, err
+	}
+	containers, err := rt.ListContainers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var byProject, byService []Container
+	for _, c := range containers {
+		o, ok := OwnerOf(c.Labels)
+		if !ok || o.Role != RoleApp {
+			continue
+		}
+		if o.Project == name {
+			byProject = append(byProject, c)
+		} else if o.Service == name {
+			byService = append(byService, c)
+		}
+	}
+	if len(byProject) > 0 {
+		return byProject, nil
+	}
+	return byService, nil
+}
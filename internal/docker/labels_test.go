@@ -0,0 +1,97 @@
+package docker
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRun_StampsOwnerLabels(t *testing.T) {
+	f := UseFake()
+	t.Cleanup(func() { SetRuntime(nil) })
+	f.Images["shop"] = true
+	f.Networks["pier"] = true
+
+	ctx := context.Background()
+	spec := RunSpec{
+		Name:    AppContainerName("shop", "web"),
+		Image:   "shop",
+		Network: "pier",
+		Labels:  map[string]string{"traefik.enable": "true"},
+		Owner:   Owner{Role: RoleApp, Project: "shop", Service: "web"},
+	}
+	if _, err := Run(ctx, spec); err != nil {
+		t.Fatal(err)
+	}
+
+	c := f.Containers["pier-app-shop-web"]
+	if c == nil {
+		t.Fatal("container not created under its namespaced name")
+	}
+	if o, ok := OwnerOf(c.Labels); !ok || o != spec.Owner {
+		t.Errorf("owner = %+v, %v; want %+v", o, ok, spec.Owner)
+	}
+	if c.Labels["traefik.enable"] != "true" {
+		t.Error("caller labels were dropped")
+	}
+	if _, ok := c.Labels[LabelVersion]; ok {
+		t.Error("empty version was stamped")
+	}
+
+	list, err := ListContainers(ctx, "pier", "dock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Domain != "web.dock" || !list[0].Managed {
+		t.Errorf("containers = %+v, want managed web.dock", list)
+	}
+}
+
+func TestAppContainers(t *testing.T) {
+	f := UseFake()
+	t.Cleanup(func() { SetRuntime(nil) })
+	f.Images["img"] = true
+
+	ctx := context.Background()
+	for _, spec := range []RunSpec{
+		{Name: AppContainerName("shop", "api"), Owner: Owner{Role: RoleApp, Project: "shop", Service: "api"}},
+		{Name: AppContainerName("shop", "web"), Owner: Owner{Role: RoleApp, Project: "shop", Service: "web"}},
+		{Name: "pier-redis-7", Owner: Owner{Role: RoleInfra, Service: "shop"}},
+		{Name: "shop"},
+	} {
+		spec.Image = "img"
+		if _, err := Run(ctx, spec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	byProject, err := AppContainers(ctx, "shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byProject) != 2 {
+		t.Errorf("project shop: %d containers, want 2", len(byProject))
+	}
+
+	byService, _ := AppContainers(ctx, "web")
+	if len(byService) != 1 || byService[0].Name != "pier-app-shop-web" {
+		t.Errorf("service web: %+v, want pier-app-shop-web", byService)
+	}
+}
+
+func TestIsStaging(t *testing.T) {
+	tests := []struct {
+		c    Container
+		want bool
+	}{
+		{Container{Name: "pier-app-next"}, false},
+		{Container{Name: "pier-app-shop-next"}, false},
+		{Container{Name: "pier-app-shop-next", Labels: map[string]string{LabelStaging: "pier-app-shop"}}, true},
+		// Renamed into place, it has taken over
+		{Container{Name: "pier-app-shop", Labels: map[string]string{LabelStaging: "pier-app-shop"}}, false},
+	}
+	for _, tt := range tests {
+		if got := IsStaging(tt.c); got != tt.want {
+			t.Errorf("IsStaging(%s, %v) = %v, want %v", tt.c.Name, tt.c.Labels, got, tt.want)
+		}
+	}
+}
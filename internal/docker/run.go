@@ -0,0 +1,169 @@
+package docker
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/moby/term"
+)
+
+// RunSpec describes a container to create and start, like `docker run -d`
+type RunSpec struct {
+	Name       string
+	Image      string
+	Network    string
+	Env        []string // KEY=VALUE; later entries win
+	Labels     map[string]string
+	Owner      Owner    // stamped on as pier.* labels when Role is set
+	Aliases    []string // extra DNS names on Network
+	Binds      []string // host:container[:ro], or volume:container
+	Ports      []PortMapping
+	Entrypoint []string
+	Cmd        []string
+	WorkingDir string
+	User       string // user[:group] the process runs as; empty keeps the image's
+	Restart    string // restart policy, e.g. "unless-stopped" or "on-failure:3"
+	// Healthcheck overrides the image's HEALTHCHECK; nil keeps it
+	Healthcheck *HealthConfig
+	// PullOutput receives pull progress when the image isn't present.
+	// Nil discards it.
+	PullOutput io.Writer
+}
+
+// PortMapping publishes a container port on the host. A zero Host picks a
+// free port.
+type PortMapping struct {
+	Container int
+	HostIP    string
+	Host      int
+}
+
+// Run creates and starts a container, pulling its image first if needed.
+// Returns the container ID.
+func Run(ctx context.Context, spec RunSpec) (string, error) {
+	rt, err := Runtime()
+	if err != nil {
+		return "", err
+	}
+
+	if err := EnsureImage(ctx, spec.Image, spec.PullOutput); err != nil {
+		return "", err
+	}
+
+	if spec.Owner.Role != "" {
+		labels := spec.Owner.Labels()
+		for k, v := range spec.Labels {
+			labels[k] = v
+		}
+		spec.Labels = labels
+	}
+
+	id, err := rt.CreateContainer(ctx, spec)
+	if err != nil {
+		return "", err
+	}
+	if err := rt.StartContainer(ctx, id); err != nil {
+		// Don't leave a created-but-dead container holding the name
+		_ = rt.RemoveContainer(ctx, id)
+		return "", err
+	}
+	return id, nil
+}
+
+// EnsureImage pulls an image unless it is already present locally
+func EnsureImage(ctx context.Context, ref string, progress io.Writer) error {
+	rt, err := Runtime()
+	if err != nil {
+		return err
+	}
+	if ok, err := rt.ImageExists(ctx, ref); err != nil || ok {
+		return err
+	}
+	return rt.PullImage(ctx, ref, progress)
+}
+
+// Restart restarts a container
+func Restart(ctx context.Context, name string) error {
+	rt, err := Runtime()
+	if err != nil {
+		return err
+	}
+	return rt.RestartContainer(ctx, name)
+}
+
+// ReadEnvFile parses a docker --env-file: KEY=VALUE lines, # comments, and
+// bare KEY lines that take their value from the current environment.
+func ReadEnvFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var env []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimLeft(scanner.Text(), " \t")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.Contains(line, "=") {
+			env = append(env, line)
+			continue
+		}
+		if v, ok := os.LookupEnv(line); ok {
+			env = append(env, line+"="+v)
+		}
+	}
+	return env, scanner.Err()
+}
+
+// displayStream renders a JSON progress stream (pull, build) to out,
+// redrawing progress bars in place when out is a terminal
+func displayStream(in io.Reader, out io.Writer) error {
+	fd, isTerm := term.GetFdInfo(out)
+	return jsonmessage.DisplayJSONMessagesStream(in, out, fd, isTerm, nil)
+}
+
+// ArgList converts a compose-style entrypoint or command (a string or a
+// list) into exec form. A string is passed as a single argument.
+func ArgList(v interface{}) []string {
+	switch val := v.(type) {
+	case string:
+		return []string{val}
+	case []interface{}:
+		args := make([]string, len(val))
+		for i, a := range val {
+			args[i] = fmt.Sprintf("%v", a)
+		}
+		return args
+	case []string:
+		return val
+	}
+	return nil
+}
+
+// BindMounts turns compose-style "host:container[:mode]" volumes into binds,
+// resolving relative host paths against baseDir. Entries without a host
+// side are skipped.
+func BindMounts(volumes []string, baseDir string) []string {
+	var binds []string
+	for _, v := range volumes {
+		if !strings.Contains(v, ":") {
+			continue
+		}
+		parts := strings.SplitN(v, ":", 3)
+		hostPath := parts[0]
+		if !filepath.IsAbs(hostPath) {
+			hostPath = filepath.Join(baseDir, hostPath)
+		}
+		binds = append(binds, hostPath+":"+strings.Join(parts[1:], ":"))
+	}
+	return binds
+}
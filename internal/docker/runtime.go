@@ -0,0 +1,223 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ContainerRuntime is the container engine pier drives. The package-level
+// helpers (Run, Exec, Build, ListContainers, ...) all go through the active
+// runtime, so tests can swap in a Fake with SetRuntime.
+type ContainerRuntime interface {
+	// Name is the engine's name, also the CLI binary used for interactive
+	// commands ("docker" or "podman")
+	Name() string
+	Ping(ctx context.Context) error
+
+	// Networks
+	NetworkExists(ctx context.Context, name string) (bool, error)
+	CreateNetwork(ctx context.Context, name string) error
+
+	// Containers
+	ListContainers(ctx context.Context) ([]Container, error)
+	InspectContainer(ctx context.Context, name string) (*Container, error)
+	CreateContainer(ctx context.Context, spec RunSpec) (string, error)
+	StartContainer(ctx context.Context, id string) error
+	StopContainer(ctx context.Context, name string) error
+	RemoveContainer(ctx context.Context, name string) error
+	RestartContainer(ctx context.Context, name string) error
+	RenameContainer(ctx context.Context, name, newName string) error
+
+	// Images
+	ImageExists(ctx context.Context, ref string) (bool, error)
+	// ImageLabels returns an image's labels; a missing image is ErrNotFound
+	ImageLabels(ctx context.Context, ref string) (map[string]string, error)
+	PullImage(ctx context.Context, ref string, progress io.Writer) error
+	BuildImage(ctx context.Context, spec BuildSpec, out io.Writer) error
+	// TagImage points target at the image source refers to
+	TagImage(ctx context.Context, source, target string) error
+
+	// Exec runs cmd in a running container; a non-zero exit is an *ExecError
+	Exec(ctx context.Context, container string, cmd []string, opts ExecOptions) error
+	// Logs copies a container's logs to stdout and stderr
+	Logs(ctx context.Context, container string, opts LogOptions, stdout, stderr io.Writer) error
+	// Events streams container lifecycle events until ctx is done
+	Events(ctx context.Context) (<-chan Event, <-chan error)
+	// Stats samples a running container's resource usage
+	Stats(ctx context.Context, container string) (*Stats, error)
+}
+
+// Container is a runtime-neutral view of a container
+type Container struct {
+	ID       string
+	Name     string
+	Image    string
+	State    string // created, running, exited, ...
+	Status   string // human-readable, e.g. "Up 3 minutes"
+	Running  bool
+	ExitCode int
+	Labels   map[string]string
+	Env      []string
+	Networks []string
+	Ports    map[int]int // container port → published host port
+	Tty      bool
+	Restarts int    // restarts by the restart policy; set by InspectContainer
+	Health   string // HEALTHCHECK status, "" without one; set by InspectContainer
+
+	// How the container was created; set by InspectContainer
+	Entrypoint    []string
+	Cmd           []string
+	WorkingDir    string
+	User          string
+	Binds         []string
+	RestartPolicy string
+	Aliases       []string // on the first network
+	Healthcheck   *HealthConfig
+}
+
+// OnNetwork reports whether the container is attached to a network
+func (c Container) OnNetwork(name string) bool {
+	for _, n := range c.Networks {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Spec returns a RunSpec that recreates the container. Its pier.* labels
+// are carried over as they are, so Owner is left empty.
+func (c Container) Spec() RunSpec {
+	spec := RunSpec{
+		Name:        c.Name,
+		Image:       c.Image,
+		Env:         c.Env,
+		Labels:      c.Labels,
+		Aliases:     c.Aliases,
+		Binds:       c.Binds,
+		Entrypoint:  c.Entrypoint,
+		Cmd:         c.Cmd,
+		WorkingDir:  c.WorkingDir,
+		User:        c.User,
+		Restart:     c.RestartPolicy,
+		Healthcheck: c.Healthcheck,
+	}
+	if len(c.Networks) > 0 {
+		spec.Network = c.Networks[0]
+	}
+	for port, host := range c.Ports {
+		spec.Ports = append(spec.Ports, PortMapping{Container: port, Host: host})
+	}
+	return spec
+}
+
+// Event is a container lifecycle event (start, die, destroy, ...)
+type Event struct {
+	Action    string
+	ID        string
+	Name      string
+	Labels    map[string]string
+	ExitCode  string // set on "die"
+	Timestamp time.Time
+}
+
+// LogOptions selects which logs to read
+type LogOptions struct {
+	Tail   string // number of lines from the end, or "all"
+	Follow bool
+}
+
+var (
+	runtimeMu sync.Mutex
+	active    ContainerRuntime
+	preferred string
+)
+
+// Runtime returns the active container runtime, connecting on first use.
+// PIER_RUNTIME (docker or podman) picks the engine, then the configured
+// preference; otherwise a reachable Docker socket wins over a Podman one.
+func Runtime() (ContainerRuntime, error) {
+	runtimeMu.Lock()
+	defer runtimeMu.Unlock()
+
+	if active != nil {
+		return active, nil
+	}
+	name := os.Getenv("PIER_RUNTIME")
+	if name == "" {
+		name = preferred
+	}
+	rt, err := NewRuntime(name)
+	if err != nil {
+		return nil, err
+	}
+	active = rt
+	return active, nil
+}
+
+// SetRuntime replaces the active runtime, e.g. with a Fake in tests
+func SetRuntime(rt ContainerRuntime) {
+	runtimeMu.Lock()
+	defer runtimeMu.Unlock()
+	active = rt
+}
+
+// Prefer sets the engine Runtime connects to when PIER_RUNTIME is unset
+func Prefer(name string) {
+	runtimeMu.Lock()
+	defer runtimeMu.Unlock()
+	preferred = name
+}
+
+// NewRuntime connects to the named engine: "docker", "podman", or "" to
+// detect one
+func NewRuntime(name string) (ContainerRuntime, error) {
+	switch name {
+	case "docker":
+		return NewDockerRuntime()
+	case "podman":
+		return NewPodmanRuntime()
+	case "":
+		if os.Getenv("DOCKER_HOST") == "" && !socketExists(defaultDockerSocket) {
+			if _, ok := podmanSocket(); ok {
+				return NewPodmanRuntime()
+			}
+		}
+		return NewDockerRuntime()
+	default:
+		return nil, fmt.Errorf("unknown container runtime %q (expected docker or podman)", name)
+	}
+}
+
+// CLIBinary is the command-line tool for the active runtime, used for
+// interactive commands that need a TTY
+func CLIBinary() string {
+	if rt, err := Runtime(); err == nil {
+		return rt.Name()
+	}
+	return "docker"
+}
+
+func socketExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode()&os.ModeSocket != 0
+}
+
+// SocketPath is the host path of the active runtime's API socket, for
+// containers (like Traefik) that watch the engine themselves
+func SocketPath() string {
+	if rt, err := Runtime(); err == nil && rt.Name() == "podman" {
+		if sock, ok := podmanSocket(); ok {
+			return sock
+		}
+	}
+	if host := os.Getenv("DOCKER_HOST"); strings.HasPrefix(host, "unix://") {
+		return strings.TrimPrefix(host, "unix://")
+	}
+	return defaultDockerSocket
+}
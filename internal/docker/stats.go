@@ -0,0 +1,109 @@
+package docker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// Stats is a container's resource usage at one moment
+type Stats struct {
+	Name       string    `json:"name"`
+	CPUPercent float64   `json:"cpu"`      // of one core; 200 is two cores busy
+	MemUsage   uint64    `json:"memUsage"` // bytes, page cache excluded
+	MemLimit   uint64    `json:"memLimit"` // bytes; the host's memory when unlimited
+	NetRx      uint64    `json:"netRx"`    // bytes received since start
+	NetTx      uint64    `json:"netTx"`    // bytes sent since start
+	Restarts   int       `json:"restarts"`
+	Time       time.Time `json:"time"`
+}
+
+// MemPercent is memory usage as a share of the limit
+func (s Stats) MemPercent() float64 {
+	if s.MemLimit == 0 {
+		return 0
+	}
+	return float64(s.MemUsage) / float64(s.MemLimit) * 100
+}
+
+// ContainerStats samples a container's resource usage and restart count
+func ContainerStats(ctx context.Context, name string) (*Stats, error) {
+	rt, err := Runtime()
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := rt.Stats(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := rt.InspectContainer(ctx, name); err == nil {
+		stats.Restarts = info.Restarts
+	}
+	stats.Name = name
+	return stats, nil
+}
+
+// CollectStats samples several containers at once; each sample takes about
+// a second, so they run in parallel. Containers that can't be sampled
+// (stopped, removed meanwhile) are left out.
+func CollectStats(ctx context.Context, names []string) map[string]*Stats {
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		result = map[string]*Stats{}
+	)
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			s, err := ContainerStats(ctx, name)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			result[name] = s
+			mu.Unlock()
+		}(name)
+	}
+	wg.Wait()
+	return result
+}
+
+// statsFromResponse computes usage the way `docker stats` does: CPU from the
+// delta against the previous sample, memory without inactive page cache
+func statsFromResponse(resp container.StatsResponse) *Stats {
+	s := &Stats{
+		Name:     resp.Name,
+		MemLimit: resp.MemoryStats.Limit,
+		Time:     resp.Read,
+	}
+
+	cpuDelta := float64(resp.CPUStats.CPUUsage.TotalUsage) - float64(resp.PreCPUStats.CPUUsage.TotalUsage)
+	sysDelta := float64(resp.CPUStats.SystemUsage) - float64(resp.PreCPUStats.SystemUsage)
+	cpus := float64(resp.CPUStats.OnlineCPUs)
+	if cpus == 0 {
+		cpus = float64(len(resp.CPUStats.CPUUsage.PercpuUsage))
+	}
+	// Without a previous sample the delta would be the whole lifetime
+	if resp.PreCPUStats.SystemUsage > 0 && cpuDelta > 0 && sysDelta > 0 {
+		s.CPUPercent = cpuDelta / sysDelta * cpus * 100
+	}
+
+	// cgroup v2 reports inactive_file, v1 total_inactive_file
+	cache := resp.MemoryStats.Stats["inactive_file"]
+	if v, ok := resp.MemoryStats.Stats["total_inactive_file"]; ok {
+		cache = v
+	}
+	if resp.MemoryStats.Usage > cache {
+		s.MemUsage = resp.MemoryStats.Usage - cache
+	}
+
+	for _, n := range resp.Networks {
+		s.NetRx += n.RxBytes
+		s.NetTx += n.TxBytes
+	}
+	return s
+}
@@ -0,0 +1,81 @@
+package docker
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestStatsFromResponse(t *testing.T) {
+	resp := container.StatsResponse{
+		CPUStats: container.CPUStats{
+			CPUUsage:    container.CPUUsage{TotalUsage: 3_000_000},
+			SystemUsage: 20_000_000,
+			OnlineCPUs:  4,
+		},
+		PreCPUStats: container.CPUStats{
+			CPUUsage:    container.CPUUsage{TotalUsage: 1_000_000},
+			SystemUsage: 10_000_000,
+		},
+		MemoryStats: container.MemoryStats{
+			Usage: 300 << 20,
+			Limit: 1 << 30,
+			Stats: map[string]uint64{"inactive_file": 100 << 20},
+		},
+		Networks: map[string]container.NetworkStats{
+			"eth0": {RxBytes: 1000, TxBytes: 200},
+			"eth1": {RxBytes: 24, TxBytes: 56},
+		},
+	}
+
+	s := statsFromResponse(resp)
+	// 2M of 10M system time across 4 CPUs
+	if math.Abs(s.CPUPercent-80) > 0.001 {
+		t.Errorf("cpu = %v, want 80", s.CPUPercent)
+	}
+	if s.MemUsage != 200<<20 || s.MemLimit != 1<<30 {
+		t.Errorf("mem = %d / %d, want 200MiB / 1GiB without page cache", s.MemUsage, s.MemLimit)
+	}
+	if s.NetRx != 1024 || s.NetTx != 256 {
+		t.Errorf("net = %d / %d, want totals across interfaces", s.NetRx, s.NetTx)
+	}
+}
+
+func TestStatsFromResponse_FirstSample(t *testing.T) {
+	resp := container.StatsResponse{
+		CPUStats: container.CPUStats{CPUUsage: container.CPUUsage{TotalUsage: 5}, SystemUsage: 50, OnlineCPUs: 2},
+	}
+	if s := statsFromResponse(resp); s.CPUPercent != 0 {
+		t.Errorf("cpu = %v without a previous sample, want 0", s.CPUPercent)
+	}
+}
+
+func TestCollectStats(t *testing.T) {
+	f := UseFake()
+	t.Cleanup(func() { SetRuntime(nil) })
+	f.Images["img"] = true
+	ctx := context.Background()
+	for _, name := range []string{"a", "b", "c"} {
+		if _, err := Run(ctx, RunSpec{Name: name, Image: "img"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.StatsData["a"] = Stats{CPUPercent: 12.5, MemUsage: 64 << 20}
+	f.Crash("c", 1)
+	if err := f.RestartContainer(ctx, "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := CollectStats(ctx, []string{"a", "b", "c", "gone"})
+	if len(stats) != 2 {
+		t.Fatalf("sampled %d containers, want the 2 running ones", len(stats))
+	}
+	if stats["a"].CPUPercent != 12.5 || stats["a"].Name != "a" {
+		t.Errorf("a = %+v", stats["a"])
+	}
+	if stats["b"].Restarts != 1 {
+		t.Errorf("b restarts = %d, want 1", stats["b"].Restarts)
+	}
+}
@@ -0,0 +1,231 @@
+package infra
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/eshe-huli/pier/internal/config"
+	"github.com/eshe-huli/pier/internal/docker"
+	"github.com/eshe-huli/pier/internal/pierfile"
+)
+
+// The auth service is an OIDC provider (soluto/oidc-server-mock) whose users
+// and clients are the union of every project's Pierfile auth block. Each
+// project's resolved block lives in ~/.pier/auth/<project>.json; the merged
+// config is written into the service's data dir, mounted at /pier.
+
+func authDir() string {
+	return filepath.Join(config.PierDir(), "auth")
+}
+
+// AuthIssuer is the issuer URL browsers and apps see
+func AuthIssuer(tld string) string {
+	return fmt.Sprintf("http://auth.%s", tld)
+}
+
+// ProvisionAuth records the project's users and clients and reloads the
+// auth service if the merged config changed. Without a Pierfile auth block
+// the project gets a client named after it and a dev/dev user.
+func ProvisionAuth(version, project string, cfg *pierfile.AuthConfig) error {
+	tld := "dock"
+	if c, err := config.Load(); err == nil {
+		tld = c.TLD
+	}
+
+	resolved := resolveAuthConfig(project, tld, cfg)
+	data, err := json.MarshalIndent(resolved, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(authDir(), 0755); err != nil {
+		return fmt.Errorf("creating auth dir: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(authDir(), project+".json"), data, 0644); err != nil {
+		return fmt.Errorf("writing auth config: %w", err)
+	}
+
+	changed, err := writeAuthServerConfig(version)
+	if err != nil {
+		return err
+	}
+
+	// The provider reads its config at startup
+	cname := containerName("auth", version)
+	if changed && docker.IsContainerRunning(context.Background(), cname) {
+		if err := docker.Restart(context.Background(), cname); err != nil {
+			return fmt.Errorf("reloading %s: %w", cname, err)
+		}
+	}
+	return nil
+}
+
+// projectAuth loads a project's resolved auth block
+func projectAuth(project string) *pierfile.AuthConfig {
+	data, err := os.ReadFile(filepath.Join(authDir(), project+".json"))
+	if err != nil {
+		return nil
+	}
+	var cfg pierfile.AuthConfig
+	if json.Unmarshal(data, &cfg) != nil {
+		return nil
+	}
+	return &cfg
+}
+
+func resolveAuthConfig(project, tld string, cfg *pierfile.AuthConfig) pierfile.AuthConfig {
+	resolved := pierfile.AuthConfig{}
+	if cfg != nil {
+		resolved.Users = append(resolved.Users, cfg.Users...)
+		resolved.Clients = append(resolved.Clients, cfg.Clients...)
+	}
+	if len(resolved.Users) == 0 {
+		resolved.Users = []pierfile.AuthUser{{Username: "dev", Password: "dev", Email: "dev@example.com", Name: "Dev User"}}
+	}
+	if len(resolved.Clients) == 0 {
+		resolved.Clients = []pierfile.AuthClient{{ID: project}}
+	}
+	for i := range resolved.Clients {
+		c := &resolved.Clients[i]
+		if c.Secret == "" {
+			c.Secret = c.ID + "-secret"
+		}
+		if len(c.RedirectURIs) == 0 {
+			c.RedirectURIs = []string{fmt.Sprintf("http://%s.%s/*", project, tld)}
+		}
+	}
+	return resolved
+}
+
+// writeAuthServerConfig merges every project's auth block into the files
+// the provider reads. Reports whether anything changed.
+func writeAuthServerConfig(version string) (bool, error) {
+	tld := "dock"
+	if c, err := config.Load(); err == nil {
+		tld = c.TLD
+	}
+
+	entries, _ := os.ReadDir(authDir())
+	var projects []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+			projects = append(projects, strings.TrimSuffix(e.Name(), ".json"))
+		}
+	}
+	sort.Strings(projects)
+
+	users := []map[string]interface{}{}
+	clients := []map[string]interface{}{}
+	seenUsers := map[string]bool{}
+	seenClients := map[string]bool{}
+	for _, project := range projects {
+		cfg := projectAuth(project)
+		if cfg == nil {
+			continue
+		}
+		for _, u := range cfg.Users {
+			if seenUsers[u.Username] {
+				continue
+			}
+			seenUsers[u.Username] = true
+			users = append(users, authUserJSON(u))
+		}
+		for _, c := range cfg.Clients {
+			if seenClients[c.ID] {
+				continue
+			}
+			seenClients[c.ID] = true
+			clients = append(clients, authClientJSON(c))
+		}
+	}
+
+	files := map[string]interface{}{
+		"users.json":   users,
+		"clients.json": clients,
+		"server-options.json": map[string]interface{}{
+			"IssuerUri":          AuthIssuer(tld),
+			"AccessTokenJwtType": "JWT",
+			"Authentication": map[string]string{
+				"CookieSameSiteMode":             "Lax",
+				"CheckSessionCookieSameSiteMode": "Lax",
+			},
+		},
+	}
+
+	dir := dataDir("auth", version)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return false, fmt.Errorf("creating data dir: %w", err)
+	}
+	changed := false
+	for name, v := range files {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return false, err
+		}
+		path := filepath.Join(dir, name)
+		if old, err := os.ReadFile(path); err == nil && bytes.Equal(old, data) {
+			continue
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return false, fmt.Errorf("writing %s: %w", name, err)
+		}
+		changed = true
+	}
+	return changed, nil
+}
+
+func authUserJSON(u pierfile.AuthUser) map[string]interface{} {
+	claims := []map[string]string{}
+	add := func(t, v string) {
+		if v != "" {
+			claims = append(claims, map[string]string{"Type": t, "Value": v})
+		}
+	}
+	add("name", u.Name)
+	add("email", u.Email)
+	keys := make([]string, 0, len(u.Claims))
+	for k := range u.Claims {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		add(k, u.Claims[k])
+	}
+	return map[string]interface{}{
+		"SubjectId": u.Username,
+		"Username":  u.Username,
+		"Password":  u.Password,
+		"Claims":    claims,
+	}
+}
+
+func authClientJSON(c pierfile.AuthClient) map[string]interface{} {
+	return map[string]interface{}{
+		"ClientId":                         c.ID,
+		"ClientSecrets":                    []string{c.Secret},
+		"AllowedGrantTypes":                []string{"authorization_code", "client_credentials", "password", "refresh_token"},
+		"RedirectUris":                     c.RedirectURIs,
+		"PostLogoutRedirectUris":           c.RedirectURIs,
+		"AllowedScopes":                    []string{"openid", "profile", "email", "offline_access"},
+		"AllowOfflineAccess":               true,
+		"RequirePkce":                      false,
+		"AlwaysIncludeUserClaimsInIdToken": true,
+	}
+}
+
+// authProjectEnv points a project at its first OIDC client
+func authProjectEnv(project string) map[string]string {
+	cfg := projectAuth(project)
+	if cfg == nil || len(cfg.Clients) == 0 {
+		return nil
+	}
+	return map[string]string{
+		"OIDC_CLIENT_ID":     cfg.Clients[0].ID,
+		"OIDC_CLIENT_SECRET": cfg.Clients[0].Secret,
+	}
+}
@@ -0,0 +1,72 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/eshe-huli/pier/internal/docker"
+)
+
+// DumpExtension returns the file extension used for a service's dumps
+func DumpExtension(serviceName string) string {
+	if serviceName == "mongo" {
+		return "archive"
+	}
+	return "sql"
+}
+
+// DumpDatabase streams a logical dump of a database to w
+func DumpDatabase(serviceName, version, dbName string, w io.Writer) error {
+	cname := containerName(serviceName, version)
+
+	var args []string
+	switch serviceName {
+	case "postgres":
+		args = []string{"pg_dump", "-U", "pier", "-h", "localhost", "--no-owner", "--no-acl", dbName}
+	case "mysql":
+		args = []string{"mysqldump", "-uroot", "-ppier", "--single-transaction", "--routines", "--triggers", dbName}
+	case "mongo":
+		args = []string{"mongodump", "--quiet", "--archive", "--db=" + dbName}
+	default:
+		return fmt.Errorf("DumpDatabase not supported for service: %s", serviceName)
+	}
+
+	var stderr strings.Builder
+	err := docker.ExecStream(context.Background(), cname, args, docker.ExecOptions{Stdout: w, Stderr: &stderr})
+	if err != nil {
+		return fmt.Errorf("dumping '%s' from %s: %s\n%s", dbName, cname, err, stderr.String())
+	}
+	return nil
+}
+
+// RestoreDatabase creates a database and loads a dump produced by DumpDatabase into it
+func RestoreDatabase(serviceName, version, dbName string, r io.Reader) error {
+	cname := containerName(serviceName, version)
+
+	var args []string
+	switch serviceName {
+	case "postgres":
+		if _, err := CreateDatabase(serviceName, version, dbName); err != nil {
+			return err
+		}
+		args = []string{"psql", "-U", "pier", "-h", "localhost", "-v", "ON_ERROR_STOP=1", "-q", "-d", dbName}
+	case "mysql":
+		if _, err := CreateDatabase(serviceName, version, dbName); err != nil {
+			return err
+		}
+		args = []string{"mysql", "-uroot", "-ppier", dbName}
+	case "mongo":
+		args = []string{"mongorestore", "--quiet", "--archive", "--nsInclude=" + dbName + ".*"}
+	default:
+		return fmt.Errorf("RestoreDatabase not supported for service: %s", serviceName)
+	}
+
+	var stderr strings.Builder
+	err := docker.ExecStream(context.Background(), cname, args, docker.ExecOptions{Stdin: r, Stderr: &stderr})
+	if err != nil {
+		return fmt.Errorf("restoring '%s' into %s: %s\n%s", dbName, cname, err, stderr.String())
+	}
+	return nil
+}
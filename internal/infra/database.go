@@ -1,46 +1,80 @@
 package infra
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"os/exec"
 	"strings"
+	"time"
+
+	"github.com/eshe-huli/pier/internal/docker"
 )
 
-// CreateDatabase creates a database in a shared postgres/mysql instance
-func CreateDatabase(serviceName, version, dbName string) error {
+// CreateDatabase creates a database in a shared postgres/mysql instance.
+// Returns true if the database was newly created, false if it already existed.
+func CreateDatabase(serviceName, version, dbName string) (bool, error) {
 	// Sanitize: replace dashes with underscores, strip dangerous chars
-	dbName = strings.ReplaceAll(dbName, "-", "_")
-	dbName = sanitizeIdentifier(dbName)
+	dbName = DatabaseName(dbName)
 	if dbName == "" {
-		return fmt.Errorf("invalid database name after sanitization")
+		return false, fmt.Errorf("invalid database name after sanitization")
 	}
 	cname := containerName(serviceName, version)
 
-	var cmd *exec.Cmd
+	var cmd []string
 	switch serviceName {
 	case "postgres":
 		// Use quoted identifier to prevent SQL injection
 		sql := fmt.Sprintf(`CREATE DATABASE "%s";`, strings.ReplaceAll(dbName, `"`, `""`))
-		cmd = exec.Command("docker", "exec", cname, "psql", "-U", "pier", "-h", "localhost", "-c", sql)
+		cmd = []string{"psql", "-U", "pier", "-h", "localhost", "-c", sql}
 	case "mysql":
 		// Backtick-quoted identifier with escaping
 		escaped := strings.ReplaceAll(dbName, "`", "``")
-		sql := fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s`;", escaped)
-		cmd = exec.Command("docker", "exec", cname, "mysql", "-uroot", "-ppier", "-e", sql)
+		sql := fmt.Sprintf("CREATE DATABASE `%s`;", escaped)
+		cmd = []string{"mysql", "-uroot", "-ppier", "-e", sql}
 	default:
-		return fmt.Errorf("CreateDatabase not supported for service: %s", serviceName)
+		return false, fmt.Errorf("CreateDatabase not supported for service: %s", serviceName)
 	}
 
-	out, err := cmd.CombinedOutput()
+	out, err := docker.Exec(context.Background(), cname, cmd...)
 	if err != nil {
-		// Ignore "already exists" errors
-		if strings.Contains(string(out), "already exists") {
-			return nil
+		// "already exists" (postgres) / "database exists" (mysql) mean nothing to do
+		var execErr *docker.ExecError
+		if errors.As(err, &execErr) && (strings.Contains(string(out), "already exists") || strings.Contains(string(out), "database exists")) {
+			return false, nil
 		}
-		return fmt.Errorf("creating database '%s' in %s: %s\n%s", dbName, cname, err, string(out))
+		return false, fmt.Errorf("creating database '%s' in %s: %w", dbName, cname, err)
 	}
 
-	return nil
+	return true, nil
+}
+
+// WaitReady blocks until a shared database service accepts connections
+func WaitReady(serviceName, version string, timeout time.Duration) error {
+	cname := containerName(serviceName, version)
+
+	var probe []string
+	switch serviceName {
+	case "postgres":
+		probe = []string{"pg_isready", "-U", "pier", "-h", "localhost"}
+	case "mysql":
+		probe = []string{"mysqladmin", "ping", "-uroot", "-ppier", "--silent"}
+	case "mongo":
+		probe = []string{"mongosh", "--quiet", "--eval", "db.runCommand({ping: 1}).ok"}
+	default:
+		return nil
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		if _, err := docker.Exec(context.Background(), cname, probe...); err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s not ready after %s", cname, timeout)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
 }
 
 // sanitizeIdentifier removes characters that aren't safe for database identifiers.
@@ -53,3 +87,132 @@ func sanitizeIdentifier(s string) string {
 	}
 	return b.String()
 }
+
+// DatabaseName returns the database name pier uses for a project.
+func DatabaseName(project string) string {
+	return sanitizeIdentifier(strings.ReplaceAll(project, "-", "_"))
+}
+
+// SupportsDatabases reports whether a service hosts per-project databases.
+func SupportsDatabases(serviceName string) bool {
+	switch serviceName {
+	case "postgres", "mysql", "mongo":
+		return true
+	}
+	return false
+}
+
+// ListDatabases returns the user databases in a shared postgres/mysql/mongo instance
+func ListDatabases(serviceName, version string) ([]string, error) {
+	cname := containerName(serviceName, version)
+
+	var cmd []string
+	switch serviceName {
+	case "postgres":
+		cmd = []string{"psql", "-U", "pier", "-h", "localhost", "-Atc",
+			"SELECT datname FROM pg_database WHERE NOT datistemplate AND datname <> 'postgres' ORDER BY datname;"}
+	case "mysql":
+		cmd = []string{"mysql", "-uroot", "-ppier", "-N", "-e", "SHOW DATABASES;"}
+	case "mongo":
+		cmd = []string{"mongosh", "--quiet", "--eval",
+			"db.adminCommand({listDatabases: 1}).databases.map(d => d.name).join('\\n')"}
+	default:
+		return nil, fmt.Errorf("ListDatabases not supported for service: %s", serviceName)
+	}
+
+	var out bytes.Buffer
+	err := docker.ExecStream(context.Background(), cname, cmd, docker.ExecOptions{Stdout: &out})
+	if err != nil {
+		return nil, fmt.Errorf("listing databases in %s: %w", cname, err)
+	}
+
+	var dbs []string
+	for _, line := range strings.Split(out.String(), "\n") {
+		name := strings.TrimSpace(line)
+		if name == "" || systemDatabases[name] {
+			continue
+		}
+		dbs = append(dbs, name)
+	}
+	return dbs, nil
+}
+
+// systemDatabases are built-in databases hidden from ListDatabases
+var systemDatabases = map[string]bool{
+	"postgres": true, "information_schema": true, "mysql": true,
+	"performance_schema": true, "sys": true, "admin": true, "config": true, "local": true,
+}
+
+// DropDatabase drops a database from a shared postgres/mysql/mongo instance
+func DropDatabase(serviceName, version, dbName string) error {
+	dbName = DatabaseName(dbName)
+	if dbName == "" {
+		return fmt.Errorf("invalid database name after sanitization")
+	}
+	cname := containerName(serviceName, version)
+
+	var cmd []string
+	switch serviceName {
+	case "postgres":
+		sql := fmt.Sprintf(`DROP DATABASE IF EXISTS "%s" WITH (FORCE);`, strings.ReplaceAll(dbName, `"`, `""`))
+		cmd = []string{"psql", "-U", "pier", "-h", "localhost", "-c", sql}
+	case "mysql":
+		escaped := strings.ReplaceAll(dbName, "`", "``")
+		sql := fmt.Sprintf("DROP DATABASE IF EXISTS `%s`;", escaped)
+		cmd = []string{"mysql", "-uroot", "-ppier", "-e", sql}
+	case "mongo":
+		cmd = []string{"mongosh", "--quiet", dbName, "--eval", "db.dropDatabase()"}
+	default:
+		return fmt.Errorf("DropDatabase not supported for service: %s", serviceName)
+	}
+
+	if _, err := docker.Exec(context.Background(), cname, cmd...); err != nil {
+		return fmt.Errorf("dropping database '%s' in %s: %w", dbName, cname, err)
+	}
+	return nil
+}
+
+// ResetDatabase drops and recreates a project database
+func ResetDatabase(serviceName, version, dbName string) error {
+	if err := DropDatabase(serviceName, version, dbName); err != nil {
+		return err
+	}
+	if serviceName == "mongo" {
+		// Mongo creates databases lazily on first write
+		return nil
+	}
+	_, err := CreateDatabase(serviceName, version, dbName)
+	return err
+}
+
+// ShellArgs returns the docker arguments for an interactive database shell
+func ShellArgs(serviceName, version, dbName string) ([]string, error) {
+	dbName = DatabaseName(dbName)
+	cname := containerName(serviceName, version)
+
+	switch serviceName {
+	case "postgres":
+		return []string{"exec", "-it", cname, "psql", "-U", "pier", "-h", "localhost", "-d", dbName}, nil
+	case "mysql":
+		return []string{"exec", "-it", cname, "mysql", "-uroot", "-ppier", dbName}, nil
+	case "mongo":
+		return []string{"exec", "-it", cname, "mongosh", dbName}, nil
+	default:
+		return nil, fmt.Errorf("no database shell for service: %s", serviceName)
+	}
+}
+
+// ConnectionURL builds a connection string for a project database at host:port
+func ConnectionURL(serviceName, dbName, host string, port int) string {
+	dbName = DatabaseName(dbName)
+	switch serviceName {
+	case "postgres":
+		return fmt.Sprintf("postgres://pier:pier@%s:%d/%s", host, port, dbName)
+	case "mysql":
+		return fmt.Sprintf("mysql://root:pier@%s:%d/%s", host, port, dbName)
+	case "mongo":
+		return fmt.Sprintf("mongodb://%s:%d/%s", host, port, dbName)
+	default:
+		return ""
+	}
+}
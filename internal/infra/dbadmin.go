@@ -0,0 +1,127 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/eshe-huli/pier/internal/config"
+	"github.com/eshe-huli/pier/internal/docker"
+	"github.com/eshe-huli/pier/internal/proxy"
+)
+
+const (
+	// DBAdminContainer is the Adminer container behind `pier db ui`
+	DBAdminContainer = "pier-adminer"
+	// DBAdminHost is the subdomain Adminer is routed at
+	DBAdminHost = "db"
+
+	dbAdminImage = "adminer:latest"
+	dbAdminPort  = 8080
+)
+
+// adminerDriver maps a shared service to Adminer's driver name. The adminer
+// image has no MongoDB driver, so mongo is left out.
+var adminerDriver = map[string]string{
+	"postgres": "pgsql",
+	"mysql":    "server",
+}
+
+// dbUser is the login for each database service's pier credentials
+var dbUser = map[string]string{
+	"postgres": "pier",
+	"mysql":    "root",
+}
+
+// EnsureDBAdmin (re)starts Adminer on the pier network with a login entry for
+// every running postgres/mysql, and routes it at db.<tld>. Returns the
+// services it was configured for.
+func EnsureDBAdmin() ([]SharedService, error) {
+	ctx := context.Background()
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("loading config: %w", err)
+	}
+
+	var dbs []SharedService
+	for _, svc := range ListRunning() {
+		if _, ok := adminerDriver[svc.Name]; ok {
+			dbs = append(dbs, svc)
+		}
+	}
+
+	// Adminer's login-servers plugin turns the server field into a dropdown
+	dir := filepath.Join(config.PierDir(), "adminer")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating adminer dir: %w", err)
+	}
+	pluginPath := filepath.Join(dir, "login-servers.php")
+	if err := os.WriteFile(pluginPath, []byte(loginServersPlugin(dbs)), 0644); err != nil {
+		return nil, fmt.Errorf("writing adminer config: %w", err)
+	}
+
+	if _, err := docker.EnsureNetwork(ctx, cfg.Network); err != nil {
+		return nil, fmt.Errorf("ensuring network: %w", err)
+	}
+
+	// Recreate so the server list is always current
+	_ = docker.StopAndRemoveContainer(ctx, DBAdminContainer)
+	_, err = docker.Run(ctx, docker.RunSpec{
+		Name:    DBAdminContainer,
+		Image:   dbAdminImage,
+		Network: cfg.Network,
+		Restart: "unless-stopped",
+		Owner:   docker.Owner{Role: docker.RoleSystem, Service: "adminer"},
+		Binds:   []string{fmt.Sprintf("%s:/var/www/html/plugins-enabled/login-servers.php:ro", pluginPath)},
+		Env:     []string{"ADMINER_DESIGN=pepa-linha"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("starting %s: %w", DBAdminContainer, err)
+	}
+
+	if err := proxy.CreateContainerProxy(DBAdminHost, DBAdminContainer, dbAdminPort, cfg.TLD); err != nil {
+		return nil, fmt.Errorf("routing %s: %w", DBAdminHost, err)
+	}
+	return dbs, nil
+}
+
+// StopDBAdmin removes the Adminer container and its route
+func StopDBAdmin() error {
+	if proxy.FileProxyExists(DBAdminHost) {
+		_ = proxy.RemoveFileProxy(DBAdminHost)
+	}
+	return docker.StopAndRemoveContainer(context.Background(), DBAdminContainer)
+}
+
+// DBAdminURL links straight to a project's database in Adminer; "" for
+// services Adminer can't open
+func DBAdminURL(serviceName, version, project, tld string) string {
+	driver, ok := adminerDriver[serviceName]
+	if !ok {
+		return ""
+	}
+	q := url.Values{}
+	q.Set(driver, containerName(serviceName, version))
+	q.Set("username", dbUser[serviceName])
+	q.Set("db", DatabaseName(project))
+	return fmt.Sprintf("http://%s.%s/?%s", DBAdminHost, tld, q.Encode())
+}
+
+// DBCredentials returns the user and password pier configures for a database service
+func DBCredentials(serviceName string) (user, password string) {
+	return dbUser[serviceName], "pier"
+}
+
+func loginServersPlugin(dbs []SharedService) string {
+	var b strings.Builder
+	b.WriteString("<?php\nrequire_once('plugins/login-servers.php');\n\nreturn new AdminerLoginServers([\n")
+	for _, svc := range dbs {
+		fmt.Fprintf(&b, "    '%s' => ['server' => '%s', 'driver' => '%s'],\n",
+			svc.Spec(), svc.Container, adminerDriver[svc.Name])
+	}
+	b.WriteString("]);\n")
+	return b.String()
+}
@@ -0,0 +1,215 @@
+package infra
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/eshe-huli/pier/internal/docker"
+	"github.com/eshe-huli/pier/internal/registry"
+)
+
+// MinIO root credentials (the image defaults)
+const (
+	minioRootUser     = "minioadmin"
+	minioRootPassword = "minioadmin"
+)
+
+// IsolatesProjects reports whether a shared service gets a per-project
+// namespace provisioned by ProvisionProject
+func IsolatesProjects(serviceName string) bool {
+	switch serviceName {
+	case "redis", "minio", "mongo":
+		return true
+	}
+	return false
+}
+
+// ProvisionProject carves out the project's slice of a shared service and
+// records it in the registry: a Redis DB index, a MinIO bucket with its own
+// access key, or a Mongo database. Safe to call on every start.
+func ProvisionProject(serviceName, version, project string) error {
+	switch serviceName {
+	case "redis":
+		_, err := registry.AllocateRedisDB(project)
+		return err
+	case "minio":
+		return provisionMinio(version, project)
+	case "mongo":
+		return provisionMongo(version, project)
+	}
+	return nil
+}
+
+// ProjectEnv returns the env vars pointing a project at its own slice of a
+// shared service. Empty until ProvisionProject has run for the project.
+func ProjectEnv(serviceName, version, project string) map[string]string {
+	if serviceName == "auth" {
+		return authProjectEnv(project)
+	}
+
+	p, err := registry.Get(project)
+	if err != nil || p.Allocations == nil {
+		return nil
+	}
+	a := p.Allocations
+	cname := containerName(serviceName, version)
+	def := serviceDefs[serviceName]
+
+	env := map[string]string{}
+	switch serviceName {
+	case "redis":
+		if a.RedisDB == 0 {
+			return nil
+		}
+		env["REDIS_DB"] = fmt.Sprintf("%d", a.RedisDB)
+		env["REDIS_URL"] = fmt.Sprintf("redis://%s:%d/%d", cname, def.Port, a.RedisDB)
+	case "minio":
+		if a.S3Bucket == "" {
+			return nil
+		}
+		env["S3_BUCKET"] = a.S3Bucket
+		env["AWS_ACCESS_KEY_ID"] = a.S3AccessKey
+		env["AWS_SECRET_ACCESS_KEY"] = a.S3SecretKey
+		env["AWS_REGION"] = "us-east-1"
+		env["AWS_ENDPOINT_URL"] = fmt.Sprintf("http://%s:%d", cname, def.Port)
+		env["AWS_ENDPOINT_URL_S3"] = env["AWS_ENDPOINT_URL"]
+	case "mongo":
+		if a.MongoDB == "" {
+			return nil
+		}
+		url := ConnectionURL(serviceName, a.MongoDB, cname, def.Port)
+		env["MONGO_URL"] = url
+		env["MONGODB_URI"] = url
+		env["MONGO_DATABASE"] = a.MongoDB
+	}
+	return env
+}
+
+// BucketName turns a project name into a valid S3 bucket name
+func BucketName(project string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(project) {
+		switch {
+		case (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9'):
+			b.WriteRune(r)
+		case r == '-' || r == '_' || r == '.' || r == ' ':
+			b.WriteRune('-')
+		}
+	}
+	name := strings.Trim(b.String(), "-")
+	if len(name) > 63 {
+		name = strings.TrimRight(name[:63], "-")
+	}
+	for len(name) < 3 {
+		name += "0"
+	}
+	return name
+}
+
+func provisionMinio(version, project string) error {
+	cname := containerName("minio", version)
+	bucket := BucketName(project)
+
+	alloc, err := registry.UpdateAllocations(project, func(a *registry.Allocations) error {
+		if a.S3Bucket == "" {
+			a.S3Bucket = bucket
+		}
+		if a.S3AccessKey == "" {
+			key := "pier-" + bucket
+			if len(key) > 20 {
+				key = strings.TrimRight(key[:20], "-")
+			}
+			a.S3AccessKey = key
+		}
+		if a.S3SecretKey == "" {
+			secret, err := randomSecret()
+			if err != nil {
+				return err
+			}
+			a.S3SecretKey = secret
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// The server takes a moment to accept requests after the container starts
+	alias := []string{"alias", "set", "pier", "http://localhost:9000", minioRootUser, minioRootPassword}
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		out, err := mc(cname, alias...)
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("minio not ready: %s", out)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	policy := "pier-" + alloc.S3Bucket
+	doc := fmt.Sprintf(`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:*"],"Resource":["arn:aws:s3:::%s","arn:aws:s3:::%s/*"]}]}`,
+		alloc.S3Bucket, alloc.S3Bucket)
+	policyFile := "/tmp/" + policy + ".json"
+
+	err = docker.ExecStream(context.Background(), cname, []string{"sh", "-c", "cat > " + policyFile},
+		docker.ExecOptions{Stdin: strings.NewReader(doc)})
+	if err != nil {
+		return fmt.Errorf("writing bucket policy: %w", err)
+	}
+
+	steps := [][]string{
+		{"mb", "--ignore-existing", "pier/" + alloc.S3Bucket},
+		{"admin", "user", "add", "pier", alloc.S3AccessKey, alloc.S3SecretKey},
+		{"admin", "policy", "create", "pier", policy, policyFile},
+		{"admin", "policy", "attach", "pier", policy, "--user", alloc.S3AccessKey},
+	}
+	for _, args := range steps {
+		if out, err := mc(cname, args...); err != nil && !strings.Contains(out, "already") {
+			return fmt.Errorf("provisioning bucket '%s' in %s: mc %s: %w", alloc.S3Bucket, cname, strings.Join(args[:2], " "), err)
+		}
+	}
+	return nil
+}
+
+func provisionMongo(version, project string) error {
+	dbName := DatabaseName(project)
+	if dbName == "" {
+		return fmt.Errorf("invalid database name after sanitization")
+	}
+	if err := WaitReady("mongo", version, 60*time.Second); err != nil {
+		return err
+	}
+
+	// Mongo creates databases lazily; a marker collection makes it stick
+	cname := containerName("mongo", version)
+	script := `db.getCollectionNames().includes("_pier") || db.createCollection("_pier")`
+	if _, err := docker.Exec(context.Background(), cname, "mongosh", "--quiet", dbName, "--eval", script); err != nil {
+		return fmt.Errorf("creating database '%s' in %s: %w", dbName, cname, err)
+	}
+
+	_, err := registry.UpdateAllocations(project, func(a *registry.Allocations) error {
+		a.MongoDB = dbName
+		return nil
+	})
+	return err
+}
+
+// mc runs the MinIO client inside the minio container
+func mc(cname string, args ...string) (string, error) {
+	out, err := docker.Exec(context.Background(), cname, append([]string{"mc", "--quiet"}, args...)...)
+	return string(out), err
+}
+
+func randomSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -3,13 +3,14 @@ package infra
 import (
 	"context"
 	"fmt"
+	"io/fs"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 
 	"github.com/eshe-huli/pier/internal/config"
 	"github.com/eshe-huli/pier/internal/docker"
+	"github.com/eshe-huli/pier/internal/proxy"
 )
 
 // SharedService represents a managed infrastructure service
@@ -21,14 +22,23 @@ type SharedService struct {
 	Port      int
 	DataDir   string
 	EnvVars   map[string]string
+	UIHost    string // web UI subdomain, routed at <UIHost>.<tld>
+}
+
+// Spec returns the "name:version" form used in Pierfiles and the registry
+func (s SharedService) Spec() string {
+	return fmt.Sprintf("%s:%s", s.Name, s.Version)
 }
 
 // ServiceDef defines a supported infrastructure service
 type ServiceDef struct {
-	Image    func(version string) string
-	Port     int
-	EnvVars  map[string]string
-	RunArgs  func(version string) []string // extra docker run args
+	Image   func(version string) string
+	Port    int
+	EnvVars map[string]string
+	RunArgs func(version string) []string // extra docker run args
+	UIHost  string                        // subdomain for the web UI, if any
+	UIPort  int                           // container port of the web UI
+	Prepare func(version string) error    // writes config into the data dir before start
 }
 
 var serviceDefs = map[string]ServiceDef{
@@ -55,9 +65,45 @@ var serviceDefs = map[string]ServiceDef{
 	"minio": {
 		Image:   func(v string) string { return "minio/minio" },
 		Port:    9000,
-		EnvVars: map[string]string{},
+		EnvVars: map[string]string{"MINIO_ROOT_USER": minioRootUser, "MINIO_ROOT_PASSWORD": minioRootPassword},
 		RunArgs: func(v string) []string { return []string{"server", "/data", "--console-address", ":9001"} },
 	},
+	"mail": {
+		Image: func(v string) string { return fmt.Sprintf("axllent/mailpit:%s", v) },
+		Port:  1025,
+		EnvVars: map[string]string{
+			"MP_DATABASE":                 "/data/mailpit.db",
+			"MP_SMTP_AUTH_ACCEPT_ANY":     "1",
+			"MP_SMTP_AUTH_ALLOW_INSECURE": "1",
+		},
+		UIHost: "mail",
+		UIPort: 8025,
+	},
+	"auth": {
+		Image: func(v string) string { return fmt.Sprintf("ghcr.io/soluto/oidc-server-mock:%s", v) },
+		Port:  80,
+		EnvVars: map[string]string{
+			"ASPNETCORE_ENVIRONMENT":     "Development",
+			"ASPNETCORE_URLS":            "http://+:80",
+			"USERS_CONFIGURATION_PATH":   "/pier/users.json",
+			"CLIENTS_CONFIGURATION_PATH": "/pier/clients.json",
+			"SERVER_OPTIONS_PATH":        "/pier/server-options.json",
+		},
+		UIHost: "auth",
+		UIPort: 80,
+		Prepare: func(v string) error {
+			_, err := writeAuthServerConfig(v)
+			return err
+		},
+	},
+	// Jaeger all-in-one: an OTLP collector (gRPC 4317, HTTP 4318) plus the trace UI
+	"telemetry": {
+		Image:   func(v string) string { return fmt.Sprintf("jaegertracing/all-in-one:%s", v) },
+		Port:    4318,
+		EnvVars: map[string]string{"COLLECTOR_OTLP_ENABLED": "true"},
+		UIHost:  "traces",
+		UIPort:  16686,
+	},
 }
 
 func containerName(name, version string) string {
@@ -72,7 +118,7 @@ func dataDir(name, version string) string {
 func ResolveService(name, version string) (*SharedService, error) {
 	def, ok := serviceDefs[name]
 	if !ok {
-		return nil, fmt.Errorf("unsupported service: %s (supported: postgres, redis, mongo, mysql, minio)", name)
+		return nil, fmt.Errorf("unsupported service: %s (supported: postgres, redis, mongo, mysql, minio, mail, auth, telemetry)", name)
 	}
 
 	return &SharedService{
@@ -83,6 +129,7 @@ func ResolveService(name, version string) (*SharedService, error) {
 		Port:      def.Port,
 		DataDir:   dataDir(name, version),
 		EnvVars:   def.EnvVars,
+		UIHost:    def.UIHost,
 	}, nil
 }
 
@@ -92,7 +139,7 @@ func EnsureService(name, version string) error {
 	cname := containerName(name, version)
 
 	if docker.IsContainerRunning(ctx, cname) {
-		return nil
+		return routeUI(name, version)
 	}
 
 	svc, err := ResolveService(name, version)
@@ -116,11 +163,24 @@ func EnsureService(name, version string) error {
 		return fmt.Errorf("creating data dir: %w", err)
 	}
 
+	if def.Prepare != nil {
+		if err := def.Prepare(version); err != nil {
+			return fmt.Errorf("preparing %s: %w", cname, err)
+		}
+	}
+
 	// Remove stopped container if exists
 	_ = docker.StopAndRemoveContainer(ctx, cname)
 
-	// Build docker run command
-	args := []string{"run", "-d", "--name", cname, "--network", cfg.Network, "--restart", "unless-stopped"}
+	spec := docker.RunSpec{
+		Name:    cname,
+		Image:   svc.Image,
+		Network: cfg.Network,
+		Restart: "unless-stopped",
+		Owner:   docker.Owner{Role: docker.RoleInfra, Service: name, Version: version},
+		// Publish on a random loopback port so host tools can connect too
+		Ports: []docker.PortMapping{{Container: svc.Port, HostIP: "127.0.0.1"}},
+	}
 
 	// Mount data volume
 	var mountTarget string
@@ -133,42 +193,151 @@ func EnsureService(name, version string) error {
 		mountTarget = "/data/db"
 	case "mysql":
 		mountTarget = "/var/lib/mysql"
-	case "minio":
+	case "minio", "mail":
 		mountTarget = "/data"
+	case "auth":
+		mountTarget = "/pier"
 	}
 	if mountTarget != "" {
-		args = append(args, "-v", fmt.Sprintf("%s:%s", svc.DataDir, mountTarget))
+		spec.Binds = append(spec.Binds, fmt.Sprintf("%s:%s", svc.DataDir, mountTarget))
 	}
 
 	// Env vars
 	for k, v := range svc.EnvVars {
-		args = append(args, "-e", fmt.Sprintf("%s=%s", k, v))
+		spec.Env = append(spec.Env, fmt.Sprintf("%s=%s", k, v))
 	}
 
-	args = append(args, svc.Image)
-
 	// Extra run args (e.g. minio server command)
 	if def.RunArgs != nil {
-		args = append(args, def.RunArgs(version)...)
+		spec.Cmd = def.RunArgs(version)
 	}
 
-	cmd := exec.Command("docker", args...)
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("starting %s: %s\n%s", cname, err, string(out))
+	if _, err := docker.Run(ctx, spec); err != nil {
+		return fmt.Errorf("starting %s: %w", cname, err)
 	}
 
+	return routeUI(name, version)
+}
+
+// routeUI exposes a service's web UI at <UIHost>.<tld>
+func routeUI(name, version string) error {
+	def := serviceDefs[name]
+	if def.UIHost == "" {
+		return nil
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	if err := proxy.CreateContainerProxy(def.UIHost, containerName(name, version), def.UIPort, cfg.TLD); err != nil {
+		return fmt.Errorf("routing %s UI: %w", name, err)
+	}
 	return nil
 }
 
+// HostPort returns the loopback port a shared service is published on
+func HostPort(name, version string) (int, error) {
+	cname := containerName(name, version)
+	def, ok := serviceDefs[name]
+	if !ok {
+		return 0, fmt.Errorf("unsupported service: %s", name)
+	}
+
+	info, err := docker.GetContainer(context.Background(), cname)
+	if err != nil {
+		return 0, err
+	}
+	if hostPort, ok := info.Ports[def.Port]; ok {
+		return hostPort, nil
+	}
+	return 0, fmt.Errorf("%s is not published to the host (recreate it to publish)", cname)
+}
+
+// RunningHostPort returns the host port of a shared service's running
+// container. It asks the runtime about nothing else, and gives up when ctx
+// does.
+func RunningHostPort(ctx context.Context, name string) (int, bool) {
+	def, ok := serviceDefs[name]
+	if !ok {
+		return 0, false
+	}
+	rt, err := docker.Runtime()
+	if err != nil {
+		return 0, false
+	}
+	containers, err := rt.ListContainers(ctx)
+	if err != nil {
+		return 0, false
+	}
+	for _, c := range containers {
+		o, ok := docker.OwnerOf(c.Labels)
+		if !ok || o.Role != docker.RoleInfra || o.Service != name || !c.Running {
+			continue
+		}
+		info, err := rt.InspectContainer(ctx, c.Name)
+		if err != nil {
+			return 0, false
+		}
+		port, ok := info.Ports[def.Port]
+		return port, ok
+	}
+	return 0, false
+}
+
+// Running returns the running instance of a shared service, if any
+func Running(name string) (*SharedService, bool) {
+	for _, svc := range ListRunning() {
+		if svc.Name == name {
+			return &svc, true
+		}
+	}
+	return nil, false
+}
+
+// WithTelemetry adds the running telemetry service to a project's services,
+// so apps export traces whenever it is up, Pierfile or not.
+func WithTelemetry(services []SharedService) []SharedService {
+	for _, svc := range services {
+		if svc.Name == "telemetry" {
+			return services
+		}
+	}
+	if svc, ok := Running("telemetry"); ok {
+		return append(services, *svc)
+	}
+	return services
+}
+
 // StopService stops a shared service
 func StopService(name, version string) error {
 	ctx := context.Background()
+	if def := serviceDefs[name]; def.UIHost != "" && proxy.FileProxyExists(def.UIHost) {
+		_ = proxy.RemoveFileProxy(def.UIHost)
+	}
 	return docker.StopAndRemoveContainer(ctx, containerName(name, version))
 }
 
-// ListRunning returns all running pier-* infrastructure containers
+// Instance is a shared service container (or leftover data dir) and its state
+type Instance struct {
+	SharedService
+	State  string // docker state: running, exited, ... or "absent" for data only
+	Uptime string // docker status, e.g. "Up 3 hours"
+}
+
+// ListRunning returns all running infrastructure containers
 func ListRunning() []SharedService {
+	var services []SharedService
+	for _, inst := range ListInstances() {
+		if inst.State == "running" {
+			services = append(services, inst.SharedService)
+		}
+	}
+	return services
+}
+
+// ListInstances returns every infrastructure container (pier.role=infra) on
+// the pier network, plus services that only have a data dir left in ~/.pier/data.
+func ListInstances() []Instance {
 	ctx := context.Background()
 	cfg, _ := config.Load()
 	if cfg == nil {
@@ -180,21 +349,18 @@ func ListRunning() []SharedService {
 		return nil
 	}
 
-	var services []SharedService
+	var instances []Instance
+	seen := map[string]bool{}
 	for _, c := range containers {
-		if !strings.HasPrefix(c.Name, "pier-") || c.Name == "pier-traefik" {
-			continue
+		svcName, svcVersion, ok := c.Owner.Service, c.Owner.Version, c.Owner.Role == docker.RoleInfra
+		if !c.Managed {
+			// Created before Pier labelled containers: parse pier-{name}-{version}
+			svcName, svcVersion, ok = parseContainerName(c.Name)
 		}
-
-		// Parse pier-{name}-{version}
-		parts := strings.SplitN(strings.TrimPrefix(c.Name, "pier-"), "-", 2)
-		if len(parts) != 2 {
+		if !ok {
 			continue
 		}
 
-		svcName := parts[0]
-		svcVersion := parts[1]
-
 		if _, ok := serviceDefs[svcName]; !ok {
 			continue
 		}
@@ -204,76 +370,182 @@ func ListRunning() []SharedService {
 			continue
 		}
 
-		if c.State == "running" {
-			services = append(services, *svc)
+		seen[svc.Container] = true
+		instances = append(instances, Instance{SharedService: *svc, State: c.State, Uptime: c.Status})
+	}
+
+	// Data dirs without a container
+	entries, _ := os.ReadDir(filepath.Join(config.PierDir(), "data"))
+	for _, e := range entries {
+		parts := strings.SplitN(e.Name(), "-", 2)
+		if !e.IsDir() || len(parts) != 2 {
+			continue
 		}
+		svc, err := ResolveService(parts[0], parts[1])
+		if err != nil || seen[svc.Container] {
+			continue
+		}
+		instances = append(instances, Instance{SharedService: *svc, State: "absent"})
 	}
 
-	return services
+	return instances
+}
+
+// DataDirSize returns the size in bytes of a service's data dir
+func DataDirSize(svc SharedService) int64 {
+	var size int64
+	_ = filepath.WalkDir(svc.DataDir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // skip unreadable entries (e.g. root-owned db files)
+		}
+		if !d.IsDir() {
+			if fi, err := d.Info(); err == nil {
+				size += fi.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
+
+// PurgeData deletes a service's data dir under ~/.pier/data
+func PurgeData(name, version string) error {
+	dir := dataDir(name, version)
+	if filepath.Dir(dir) != filepath.Join(config.PierDir(), "data") {
+		return fmt.Errorf("refusing to delete %s", dir)
+	}
+	return os.RemoveAll(dir)
 }
 
-// IsInfraContainer checks if a container name matches a known pier infra service
+// IsInfraContainer checks if a container name matches a known pier infra
+// service. Labelled containers carry pier.role=infra instead; this is for
+// ones created before Pier labelled them.
 func IsInfraContainer(name string) bool {
-	if !strings.HasPrefix(name, "pier-") {
+	svcName, _, ok := parseContainerName(name)
+	if !ok {
 		return false
 	}
-	trimmed := strings.TrimPrefix(name, "pier-")
-	for svcName := range serviceDefs {
-		if strings.HasPrefix(trimmed, svcName+"-") {
-			return true
-		}
+	_, known := serviceDefs[svcName]
+	return known
+}
+
+// parseContainerName splits pier-{name}-{version}
+func parseContainerName(name string) (svcName, version string, ok bool) {
+	if !strings.HasPrefix(name, "pier-") || name == "pier-traefik" {
+		return "", "", false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(name, "pier-"), "-", 2)
+	if len(parts) != 2 {
+		return "", "", false
 	}
-	return false
+	return parts[0], parts[1], true
 }
 
-// GetConnectionEnv returns env vars for connecting to a shared service
-func GetConnectionEnv(name, version string) map[string]string {
+// GetConnectionEnv returns env vars for connecting a project to a shared
+// service. URLs carry the project's database, and per-project allocations
+// (see ProjectEnv) override the shared defaults. An empty project leaves the
+// database out.
+func GetConnectionEnv(name, version, project string) map[string]string {
 	cname := containerName(name, version)
 	def, ok := serviceDefs[name]
 	if !ok {
 		return nil
 	}
 
+	dbName := ""
+	if project != "" {
+		dbName = DatabaseName(project)
+	}
+	port := fmt.Sprintf("%d", def.Port)
+
 	env := map[string]string{}
 
 	switch name {
 	case "postgres":
+		url := fmt.Sprintf("postgres://pier:pier@%s:%d/%s", cname, def.Port, dbName)
 		env["DB_HOST"] = cname
-		env["DB_PORT"] = fmt.Sprintf("%d", def.Port)
+		env["DB_PORT"] = port
 		env["DB_USER"] = "pier"
 		env["DB_USERNAME"] = "pier"
 		env["DB_PASSWORD"] = "pier"
-		env["DB_DATABASE"] = "" // set by caller with project name
+		env["DB_DATABASE"] = dbName
 		env["DB_SYNC"] = "true"
 		env["DATABASE_HOST"] = cname
-		env["DATABASE_PORT"] = fmt.Sprintf("%d", def.Port)
+		env["DATABASE_PORT"] = port
 		env["DATABASE_USER"] = "pier"
 		env["DATABASE_PASSWORD"] = "pier"
-		env["DATABASE_NAME"] = "" // set by caller with project name
+		env["DATABASE_NAME"] = dbName
 		env["DATABASE_SYNCHRONIZE"] = "true"
-		env["DATABASE_URL"] = fmt.Sprintf("postgres://pier:pier@%s:%d", cname, def.Port)
+		env["DATABASE_URL"] = url
 		env["POSTGRES_HOST"] = cname
-		env["POSTGRES_PORT"] = fmt.Sprintf("%d", def.Port)
+		env["POSTGRES_PORT"] = port
 		env["POSTGRES_USER"] = "pier"
 		env["POSTGRES_PASSWORD"] = "pier"
+		env["POSTGRES_DB"] = dbName
+		env["POSTGRES_URL"] = url
 	case "redis":
 		env["REDIS_HOST"] = cname
-		env["REDIS_PORT"] = fmt.Sprintf("%d", def.Port)
+		env["REDIS_PORT"] = port
+		env["REDIS_DB"] = "0"
+		env["REDIS_URL"] = fmt.Sprintf("redis://%s:%d/0", cname, def.Port)
 		env["CACHE_HOST"] = cname
-		env["CACHE_PORT"] = fmt.Sprintf("%d", def.Port)
+		env["CACHE_PORT"] = port
 	case "mongo":
+		url := fmt.Sprintf("mongodb://%s:%d/%s", cname, def.Port, dbName)
 		env["MONGO_HOST"] = cname
-		env["MONGO_PORT"] = fmt.Sprintf("%d", def.Port)
+		env["MONGO_PORT"] = port
+		env["MONGO_DATABASE"] = dbName
+		env["MONGO_URL"] = url
+		env["MONGODB_URI"] = url
 	case "mysql":
+		url := fmt.Sprintf("mysql://root:pier@%s:%d/%s", cname, def.Port, dbName)
 		env["DB_HOST"] = cname
-		env["DB_PORT"] = fmt.Sprintf("%d", def.Port)
+		env["DB_PORT"] = port
 		env["DB_USER"] = "root"
+		env["DB_USERNAME"] = "root"
 		env["DB_PASSWORD"] = "pier"
+		env["DB_DATABASE"] = dbName
+		env["DATABASE_NAME"] = dbName
+		env["DATABASE_URL"] = url
 		env["MYSQL_HOST"] = cname
-		env["MYSQL_PORT"] = fmt.Sprintf("%d", def.Port)
+		env["MYSQL_PORT"] = port
+		env["MYSQL_DATABASE"] = dbName
+		env["MYSQL_URL"] = url
 	case "minio":
 		env["MINIO_ENDPOINT"] = fmt.Sprintf("%s:9000", cname)
 		env["S3_ENDPOINT"] = fmt.Sprintf("http://%s:9000", cname)
+	case "mail":
+		env["SMTP_HOST"] = cname
+		env["SMTP_PORT"] = port
+		env["SMTP_URL"] = fmt.Sprintf("smtp://%s:%d", cname, def.Port)
+		env["MAIL_MAILER"] = "smtp"
+		env["MAIL_HOST"] = cname
+		env["MAIL_PORT"] = port
+		env["MAIL_URL"] = env["SMTP_URL"]
+	case "auth":
+		tld := "dock"
+		if cfg, err := config.Load(); err == nil {
+			tld = cfg.TLD
+		}
+		env["OIDC_ISSUER"] = AuthIssuer(tld)
+		env["OIDC_DISCOVERY_URL"] = AuthIssuer(tld) + "/.well-known/openid-configuration"
+		env["OIDC_INTERNAL_URL"] = fmt.Sprintf("http://%s:%d", cname, def.Port)
+	case "telemetry":
+		env["OTEL_EXPORTER_OTLP_ENDPOINT"] = fmt.Sprintf("http://%s:%d", cname, def.Port)
+		env["OTEL_EXPORTER_OTLP_PROTOCOL"] = "http/protobuf"
+		env["OTEL_TRACES_EXPORTER"] = "otlp"
+		env["OTEL_METRICS_EXPORTER"] = "none"
+		env["OTEL_LOGS_EXPORTER"] = "none"
+		env["OTEL_RESOURCE_ATTRIBUTES"] = "deployment.environment=development"
+		if project != "" {
+			env["OTEL_SERVICE_NAME"] = project
+		}
+	}
+
+	if project != "" {
+		for k, v := range ProjectEnv(name, version, project) {
+			env[k] = v
+		}
 	}
 
 	return env
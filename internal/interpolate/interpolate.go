@@ -0,0 +1,259 @@
+// Package interpolate expands variable references the way compose does,
+// and reads the .env files that supply their values. Compose files and the
+// project's .env (for .pier/env) share it.
+package interpolate
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Lookup returns a variable's value, and whether it is set
+type Lookup func(name string) (string, bool)
+
+// Map looks variables up in m
+func Map(m map[string]string) Lookup {
+	return func(name string) (string, bool) {
+		v, ok := m[name]
+		return v, ok
+	}
+}
+
+// Chain looks variables up in each Lookup in turn; the first that has one
+// wins
+func Chain(lookups ...Lookup) Lookup {
+	return func(name string) (string, bool) {
+		for _, l := range lookups {
+			if v, ok := l(name); ok {
+				return v, true
+			}
+		}
+		return "", false
+	}
+}
+
+// Expand replaces variable references in s:
+//
+//	$VAR, ${VAR}     the value, or empty when unset
+//	${VAR:-default}  default when VAR is unset or empty
+//	${VAR-default}   default when VAR is unset
+//	${VAR:?message}  an error when VAR is unset or empty
+//	${VAR?message}   an error when VAR is unset
+//	${VAR:+alt}      alt when VAR is set and not empty
+//	${VAR+alt}       alt when VAR is set
+//	$$               a literal $
+//
+// Defaults, messages and alternatives may hold references themselves.
+func Expand(s string, lookup Lookup) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+	var out strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			out.WriteByte(s[i])
+			continue
+		}
+		switch next := s[i+1]; {
+		case next == '$':
+			out.WriteByte('$')
+			i++
+		case next == '{':
+			end := closingBrace(s, i+2)
+			if end < 0 {
+				return "", fmt.Errorf("invalid interpolation format for %q: unclosed ${", s)
+			}
+			v, err := expandBraced(s[i+2:end], lookup)
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(v)
+			i = end
+		case isNameStart(next):
+			j := i + 1
+			for j < len(s) && isNameChar(s[j]) {
+				j++
+			}
+			v, _ := lookup(s[i+1 : j])
+			out.WriteString(v)
+			i = j - 1
+		default:
+			out.WriteByte('$')
+		}
+	}
+	return out.String(), nil
+}
+
+// expandBraced expands the inside of ${...}
+func expandBraced(expr string, lookup Lookup) (string, error) {
+	n := 0
+	for n < len(expr) && isNameChar(expr[n]) {
+		n++
+	}
+	name, op := expr[:n], expr[n:]
+	if name == "" || !isNameStart(name[0]) {
+		return "", fmt.Errorf("invalid interpolation format for ${%s}", expr)
+	}
+	value, set := lookup(name)
+	if op == "" {
+		return value, nil
+	}
+
+	// Everything after the operator is itself expanded, but only when used
+	colon := strings.HasPrefix(op, ":")
+	if colon {
+		op = op[1:]
+	}
+	if op == "" {
+		return "", fmt.Errorf("invalid interpolation format for ${%s}", expr)
+	}
+	arg := op[1:]
+	present := set && (!colon || value != "")
+	switch op[0] {
+	case '-':
+		if present {
+			return value, nil
+		}
+		return Expand(arg, lookup)
+	case '?':
+		if present {
+			return value, nil
+		}
+		msg, err := Expand(arg, lookup)
+		if err != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("required variable %s is missing a value: %s", name, msg)
+	case '+':
+		if present {
+			return Expand(arg, lookup)
+		}
+		return "", nil
+	}
+	return "", fmt.Errorf("invalid interpolation format for ${%s}", expr)
+}
+
+// closingBrace returns the index of the } closing a ${ whose contents start
+// at i, skipping nested references; -1 if there is none
+func closingBrace(s string, i int) int {
+	depth := 1
+	for ; i < len(s); i++ {
+		switch {
+		case s[i] == '$' && i+1 < len(s) && s[i+1] == '{':
+			depth++
+			i++
+		case s[i] == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || c >= '0' && c <= '9'
+}
+
+// Pair is a variable set by an env file
+type Pair struct {
+	Key   string
+	Value string
+}
+
+// ReadEnvFile reads a .env file: KEY=VALUE lines, optionally starting with
+// `export`, and # comments. Values may be 'single-quoted', taken as is, or
+// "double-quoted", with \n, \t, \" and \\ escapes. Unquoted and
+// double-quoted values are expanded, with lookup first and then the file's
+// own earlier variables. A KEY without = takes its value from lookup, and
+// is skipped when unset.
+func ReadEnvFile(path string, lookup Lookup) ([]Pair, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var pairs []Pair
+	own := map[string]string{}
+	vars := Chain(lookup, Map(own))
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+		key, raw, hasValue := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return nil, fmt.Errorf("%s:%d: missing variable name", path, n)
+		}
+		if !hasValue {
+			if v, ok := lookup(key); ok {
+				pairs = append(pairs, Pair{key, v})
+				own[key] = v
+			}
+			continue
+		}
+
+		value, err := parseValue(strings.TrimSpace(raw), vars)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		pairs = append(pairs, Pair{key, value})
+		own[key] = value
+	}
+	return pairs, scanner.Err()
+}
+
+// parseValue unquotes and expands a .env value
+func parseValue(raw string, lookup Lookup) (string, error) {
+	switch {
+	case raw == "":
+		return "", nil
+	case raw[0] == '\'':
+		end := strings.IndexByte(raw[1:], '\'')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated quote in %s", raw)
+		}
+		return raw[1 : end+1], nil
+	case raw[0] == '"':
+		var b strings.Builder
+		for i := 1; i < len(raw); i++ {
+			c := raw[i]
+			switch {
+			case c == '"':
+				return Expand(b.String(), lookup)
+			case c == '\\' && i+1 < len(raw):
+				i++
+				switch raw[i] {
+				case 'n':
+					b.WriteByte('\n')
+				case 't':
+					b.WriteByte('\t')
+				case '$':
+					// Stays escaped for Expand
+					b.WriteString("$$")
+				default:
+					b.WriteByte(raw[i])
+				}
+			default:
+				b.WriteByte(c)
+			}
+		}
+		return "", fmt.Errorf("unterminated quote in %s", raw)
+	}
+	// An unquoted value ends at a comment
+	if i := strings.Index(raw, " #"); i >= 0 {
+		raw = strings.TrimSpace(raw[:i])
+	}
+	return Expand(raw, lookup)
+}
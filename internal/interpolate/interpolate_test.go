@@ -0,0 +1,103 @@
+package interpolate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	lookup := Map(map[string]string{"HOST": "db", "PORT": "5432", "EMPTY": ""})
+	tests := []struct {
+		in      string
+		want    string
+		wantErr string
+	}{
+		{in: "plain", want: "plain"},
+		{in: "${HOST}:$PORT", want: "db:5432"},
+		{in: "${MISSING}", want: ""},
+		{in: "${MISSING:-fallback}", want: "fallback"},
+		{in: "${EMPTY:-fallback}", want: "fallback"},
+		{in: "${EMPTY-fallback}", want: ""},
+		{in: "${MISSING-fallback}", want: "fallback"},
+		{in: "${HOST:-fallback}", want: "db"},
+		{in: "${HOST:+set}", want: "set"},
+		{in: "${EMPTY:+set}", want: ""},
+		{in: "${EMPTY+set}", want: "set"},
+		{in: "${MISSING+set}", want: ""},
+		{in: "${MISSING:-${HOST}:${PORT}}", want: "db:5432"},
+		{in: "${HOST:?is required}", want: "db"},
+		{in: "${EMPTY?is required}", want: ""},
+		{in: "${EMPTY:?is required}", wantErr: "required variable EMPTY is missing a value: is required"},
+		{in: "${MISSING?set ${HOST}}", wantErr: "required variable MISSING is missing a value: set db"},
+		{in: "cost: $$5 and $", want: "cost: $5 and $"},
+		{in: "$$HOST", want: "$HOST"},
+		{in: "a $1 b", want: "a $1 b"},
+		{in: "${HOST", wantErr: "unclosed"},
+		{in: "${1BAD}", wantErr: "invalid interpolation format"},
+		{in: "${HOST:}", wantErr: "invalid interpolation format"},
+	}
+	for _, tt := range tests {
+		got, err := Expand(tt.in, lookup)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expand(%q) error = %v, want %q", tt.in, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Expand(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestReadEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	content := `# comment
+export NAME=shop
+HOST=localhost # inline comment
+URL=postgres://${HOST}/${NAME}
+LITERAL='${HOST} stays'
+QUOTED="line\nbreak \"q\" ${NAME}"
+ESCAPED="\$HOST"
+FROM_LOOKUP
+UNSET_BARE
+WINS=${OVERRIDDEN}
+EMPTY=
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lookup := Map(map[string]string{"FROM_LOOKUP": "outside", "OVERRIDDEN": "env", "HOST": "db"})
+	pairs, err := ReadEnvFile(path, lookup)
+	if err != nil {
+		t.Fatalf("ReadEnvFile: %v", err)
+	}
+	var got []string
+	for _, p := range pairs {
+		got = append(got, p.Key+"="+p.Value)
+	}
+	want := []string{
+		"NAME=shop",
+		"HOST=localhost",
+		"URL=postgres://db/shop", // the lookup wins over the file
+		"LITERAL=${HOST} stays",
+		"QUOTED=line\nbreak \"q\" shop",
+		"ESCAPED=$HOST",
+		"FROM_LOOKUP=outside",
+		"WINS=env",
+		"EMPTY=",
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("pairs =\n%q\nwant\n%q", got, want)
+	}
+
+	if err := os.WriteFile(path, []byte("A=\"unterminated\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadEnvFile(path, lookup); err == nil || !strings.Contains(err.Error(), ":1:") {
+		t.Errorf("err = %v, want the line of the bad value", err)
+	}
+}
@@ -0,0 +1,164 @@
+package mock
+
+import (
+	"sort"
+)
+
+// maxDepth stops generation on recursive schemas
+const maxDepth = 8
+
+// Example returns the media object's example, the first of its named
+// examples, or a value generated from its schema.
+func (s *Spec) Example(media map[string]interface{}) interface{} {
+	if media == nil {
+		return nil
+	}
+	if ex, ok := media["example"]; ok {
+		return ex
+	}
+	if examples, ok := media["examples"].(map[string]interface{}); ok && len(examples) > 0 {
+		names := make([]string, 0, len(examples))
+		for n := range examples {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		if ex, ok := s.resolve(examples[names[0]]).(map[string]interface{}); ok {
+			if v, ok := ex["value"]; ok {
+				return v
+			}
+		}
+	}
+	return s.Generate(media["schema"])
+}
+
+// Generate builds a plausible value for a JSON schema
+func (s *Spec) Generate(schema interface{}) interface{} {
+	return s.generate(schema, 0)
+}
+
+func (s *Spec) generate(raw interface{}, depth int) interface{} {
+	schema, ok := s.resolve(raw).(map[string]interface{})
+	if !ok || depth > maxDepth {
+		return nil
+	}
+
+	if ex, ok := schema["example"]; ok {
+		return ex
+	}
+	if def, ok := schema["default"]; ok {
+		return def
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok && len(enum) > 0 {
+		return enum[0]
+	}
+	if all, ok := schema["allOf"].([]interface{}); ok {
+		merged := map[string]interface{}{}
+		for _, sub := range all {
+			if m, ok := s.generate(sub, depth+1).(map[string]interface{}); ok {
+				for k, v := range m {
+					merged[k] = v
+				}
+			}
+		}
+		return merged
+	}
+	for _, key := range []string{"oneOf", "anyOf"} {
+		if alts, ok := schema[key].([]interface{}); ok && len(alts) > 0 {
+			return s.generate(alts[0], depth+1)
+		}
+	}
+
+	switch schemaType(schema) {
+	case "object":
+		obj := map[string]interface{}{}
+		props, _ := schema["properties"].(map[string]interface{})
+		for name, prop := range props {
+			obj[name] = s.generate(prop, depth+1)
+		}
+		return obj
+	case "array":
+		item := s.generate(schema["items"], depth+1)
+		if item == nil {
+			return []interface{}{}
+		}
+		return []interface{}{item}
+	case "integer":
+		if min, ok := number(schema["minimum"]); ok {
+			return int(min)
+		}
+		return 1
+	case "number":
+		if min, ok := number(schema["minimum"]); ok {
+			return min
+		}
+		return 1.5
+	case "boolean":
+		return true
+	case "string":
+		return exampleString(schema)
+	}
+	return nil
+}
+
+func exampleString(schema map[string]interface{}) string {
+	format, _ := schema["format"].(string)
+	switch format {
+	case "date-time":
+		return "2024-01-01T00:00:00Z"
+	case "date":
+		return "2024-01-01"
+	case "time":
+		return "12:00:00"
+	case "email":
+		return "user@example.com"
+	case "uuid":
+		return "3fa85f64-5717-4562-b3fc-2c963f66afa6"
+	case "uri", "url":
+		return "https://example.com"
+	case "hostname":
+		return "example.com"
+	case "ipv4":
+		return "192.0.2.1"
+	case "ipv6":
+		return "2001:db8::1"
+	case "byte":
+		return "ZXhhbXBsZQ=="
+	case "password":
+		return "********"
+	}
+	return "string"
+}
+
+// schemaType returns the schema's type, inferring object/array from shape
+func schemaType(schema map[string]interface{}) string {
+	switch t := schema["type"].(type) {
+	case string:
+		return t
+	case []interface{}:
+		// OpenAPI 3.1: first non-null type
+		for _, v := range t {
+			if s, ok := v.(string); ok && s != "null" {
+				return s
+			}
+		}
+	}
+	if _, ok := schema["properties"]; ok {
+		return "object"
+	}
+	if _, ok := schema["items"]; ok {
+		return "array"
+	}
+	return ""
+}
+
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
@@ -0,0 +1,231 @@
+package mock
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const petstore = `openapi: 3.0.3
+info:
+  title: Pets
+servers:
+  - url: http://localhost/v1
+paths:
+  /pets:
+    get:
+      parameters:
+        - name: limit
+          in: query
+          schema: {type: integer}
+      responses:
+        "200":
+          content:
+            application/json:
+              schema:
+                type: array
+                items: {$ref: '#/components/schemas/Pet'}
+    post:
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/Pet'}
+      responses:
+        "201":
+          content:
+            application/json:
+              example: {id: 7, name: Rex}
+  /pets/{id}:
+    parameters:
+      - name: id
+        in: path
+        required: true
+        schema: {type: integer}
+    get:
+      responses:
+        "200":
+          content:
+            application/json:
+              schema: {$ref: '#/components/schemas/Pet'}
+  /pets/mine:
+    get:
+      responses:
+        "204":
+          description: nothing
+components:
+  schemas:
+    Pet:
+      type: object
+      required: [name]
+      properties:
+        id: {type: integer}
+        name: {type: string}
+        born: {type: string, format: date}
+        kind: {type: string, enum: [dog, cat]}
+`
+
+func newTestServer(t *testing.T, overrides string) (*Server, string) {
+	t.Helper()
+	dir := t.TempDir()
+	specPath := filepath.Join(dir, "openapi.yaml")
+	if err := os.WriteFile(specPath, []byte(petstore), 0644); err != nil {
+		t.Fatal(err)
+	}
+	overridesPath := filepath.Join(dir, "overrides.yaml")
+	if overrides != "" {
+		if err := os.WriteFile(overridesPath, []byte(overrides), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, err := NewServer(specPath, overridesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, specPath
+}
+
+func do(s *Server, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServer_GeneratesFromSchema(t *testing.T) {
+	s, _ := newTestServer(t, "")
+
+	rec := do(s, "GET", "/v1/pets/3", "")
+	if rec.Code != 200 {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	var pet map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &pet); err != nil {
+		t.Fatal(err)
+	}
+	if pet["name"] != "string" || pet["born"] != "2024-01-01" || pet["kind"] != "dog" {
+		t.Errorf("pet = %v", pet)
+	}
+
+	rec = do(s, "GET", "/pets", "")
+	if rec.Code != 200 || !strings.HasPrefix(strings.TrimSpace(rec.Body.String()), "[{") {
+		t.Errorf("list = %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestServer_PrefersExampleAndLiteralPaths(t *testing.T) {
+	s, _ := newTestServer(t, "")
+
+	rec := do(s, "POST", "/pets", `{"name":"Rex"}`)
+	if rec.Code != 201 || !strings.Contains(rec.Body.String(), `"id":7`) {
+		t.Errorf("post = %d %s", rec.Code, rec.Body)
+	}
+
+	if rec := do(s, "GET", "/pets/mine", ""); rec.Code != 204 {
+		t.Errorf("/pets/mine should match the literal path, got %d", rec.Code)
+	}
+}
+
+func TestServer_ValidatesRequests(t *testing.T) {
+	s, _ := newTestServer(t, "")
+
+	tests := []struct {
+		method, target, body, want string
+	}{
+		{"GET", "/pets/abc", "", "path parameter 'id' must be an integer"},
+		{"GET", "/pets?limit=ten", "", "query parameter 'limit' must be an integer"},
+		{"POST", "/pets", "", "request body is required"},
+		{"POST", "/pets", `{"id":1}`, "body.name is required"},
+		{"POST", "/pets", `{"name":"x","kind":"fish"}`, "body.kind must be one of"},
+	}
+	for _, tt := range tests {
+		rec := do(s, tt.method, tt.target, tt.body)
+		if rec.Code != 400 || !strings.Contains(rec.Body.String(), tt.want) {
+			t.Errorf("%s %s %s: got %d %s, want %q", tt.method, tt.target, tt.body, rec.Code, rec.Body, tt.want)
+		}
+	}
+
+	if rec := do(s, "DELETE", "/pets", ""); rec.Code != 405 {
+		t.Errorf("DELETE /pets = %d, want 405", rec.Code)
+	}
+	if rec := do(s, "GET", "/owners", ""); rec.Code != 404 {
+		t.Errorf("GET /owners = %d, want 404", rec.Code)
+	}
+}
+
+func TestServer_Overrides(t *testing.T) {
+	s, _ := newTestServer(t, `
+"GET /pets/{id}":
+  status: 404
+  body: {error: not found}
+"GET /pets/1":
+  body: {id: 1, name: Fido}
+  headers: {X-Mock: "yes"}
+`)
+
+	if rec := do(s, "GET", "/pets/2", ""); rec.Code != 404 || !strings.Contains(rec.Body.String(), "not found") {
+		t.Errorf("template override = %d %s", rec.Code, rec.Body)
+	}
+	rec := do(s, "GET", "/pets/1", "")
+	if rec.Code != 200 || !strings.Contains(rec.Body.String(), "Fido") || rec.Header().Get("X-Mock") != "yes" {
+		t.Errorf("concrete override = %d %s %v", rec.Code, rec.Body, rec.Header())
+	}
+}
+
+func TestServer_ReloadsOnChange(t *testing.T) {
+	s, specPath := newTestServer(t, "")
+	stop := make(chan struct{})
+	defer close(stop)
+	go s.Watch(10*time.Millisecond, stop)
+
+	updated := strings.Replace(petstore, "  /pets/mine:", "  /owners:\n    get:\n      responses:\n        \"200\": {description: ok}\n  /pets/mine:", 1)
+	if err := os.WriteFile(specPath, []byte(updated), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Make sure the mtime moves even on coarse filesystems
+	future := time.Now().Add(time.Second)
+	_ = os.Chtimes(specPath, future, future)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if do(s, "GET", "/owners", "").Code == 200 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("spec change was not picked up")
+}
+
+func TestLoadSpec_UnquotedStatusCodes(t *testing.T) {
+	specPath := filepath.Join(t.TempDir(), "openapi.yaml")
+	spec := `openapi: 3.0.3
+paths:
+  /health:
+    get:
+      responses:
+        200:
+          content:
+            application/json:
+              example:
+                status: ok
+                codes: {1: one}
+        503:
+          description: down
+`
+	if err := os.WriteFile(specPath, []byte(spec), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewServer(specPath, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := do(s, "GET", "/health", "")
+	if rec.Code != 200 || !strings.Contains(rec.Body.String(), `"status":"ok"`) || !strings.Contains(rec.Body.String(), `"1":"one"`) {
+		t.Errorf("health = %d %s, want the example", rec.Code, rec.Body)
+	}
+}
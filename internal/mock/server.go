@@ -0,0 +1,247 @@
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Override replaces the generated response for one endpoint
+type Override struct {
+	Status  int               `yaml:"status"`
+	Body    interface{}       `yaml:"body"`
+	Headers map[string]string `yaml:"headers"`
+	Delay   string            `yaml:"delay"` // e.g. 250ms
+}
+
+// LoadOverrides reads an overrides file keyed by "METHOD /path". The path
+// can be the spec's template (/users/{id}) or a concrete one (/users/42).
+// A missing file is not an error.
+func LoadOverrides(path string) (map[string]Override, error) {
+	overrides := map[string]Override{}
+	if path == "" {
+		return overrides, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return overrides, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]Override
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	for key, o := range raw {
+		method, p, ok := strings.Cut(strings.TrimSpace(key), " ")
+		if !ok {
+			return nil, fmt.Errorf("override key %q must be \"METHOD /path\"", key)
+		}
+		if o.Delay != "" {
+			if _, err := time.ParseDuration(o.Delay); err != nil {
+				return nil, fmt.Errorf("override %q: invalid delay %q", key, o.Delay)
+			}
+		}
+		o.Body = stringKeys(o.Body)
+		overrides[strings.ToUpper(method)+" "+strings.TrimSpace(p)] = o
+	}
+	return overrides, nil
+}
+
+// Server answers requests from a spec, reloading it when files change
+type Server struct {
+	SpecPath      string
+	OverridesPath string
+	// Log receives one line per request and reload
+	Log func(format string, args ...interface{})
+
+	mu        sync.RWMutex
+	spec      *Spec
+	overrides map[string]Override
+	modTimes  map[string]time.Time
+}
+
+// NewServer loads the spec and overrides
+func NewServer(specPath, overridesPath string) (*Server, error) {
+	s := &Server{
+		SpecPath:      specPath,
+		OverridesPath: overridesPath,
+		Log:           func(string, ...interface{}) {},
+	}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Spec returns the currently loaded spec
+func (s *Server) Spec() *Spec {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.spec
+}
+
+// Reload re-reads the spec and overrides. On error the previous versions
+// keep being served.
+func (s *Server) Reload() error {
+	spec, err := LoadSpec(s.SpecPath)
+	if err != nil {
+		return err
+	}
+	overrides, err := LoadOverrides(s.OverridesPath)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.spec = spec
+	s.overrides = overrides
+	s.modTimes = s.currentModTimes()
+	s.mu.Unlock()
+	return nil
+}
+
+// Watch polls the spec and overrides for changes until stop is closed
+func (s *Server) Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if !s.changed() {
+				continue
+			}
+			if err := s.Reload(); err != nil {
+				s.Log("reload failed: %s", err)
+				// Don't retry until the files change again
+				s.mu.Lock()
+				s.modTimes = s.currentModTimes()
+				s.mu.Unlock()
+				continue
+			}
+			s.Log("reloaded %s (%d operations)", s.SpecPath, len(s.Spec().Operations))
+		}
+	}
+}
+
+func (s *Server) changed() bool {
+	current := s.currentModTimes()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for path, t := range current {
+		if !s.modTimes[path].Equal(t) {
+			return true
+		}
+	}
+	return len(current) != len(s.modTimes)
+}
+
+func (s *Server) currentModTimes() map[string]time.Time {
+	times := map[string]time.Time{}
+	for _, p := range []string{s.SpecPath, s.OverridesPath} {
+		if p == "" {
+			continue
+		}
+		if info, err := os.Stat(p); err == nil {
+			times[p] = info.ModTime()
+		}
+	}
+	return times
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", r.Header.Get("Access-Control-Request-Headers"))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	s.mu.RLock()
+	spec, overrides := s.spec, s.overrides
+	s.mu.RUnlock()
+
+	op, params := spec.Match(r.Method, r.URL.Path)
+	if op == nil {
+		if spec.PathExists(r.URL.Path) {
+			s.respond(w, r, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		} else {
+			s.respond(w, r, http.StatusNotFound, map[string]string{"error": "no operation for " + r.URL.Path})
+		}
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.respond(w, r, http.StatusBadRequest, map[string]string{"error": "reading body: " + err.Error()})
+		return
+	}
+	if problems := spec.ValidateRequest(op, params, r.URL.Query(), r.Header.Get, body); len(problems) > 0 {
+		s.respond(w, r, http.StatusBadRequest, map[string]interface{}{
+			"error":   "request does not match " + op.Key(),
+			"details": problems,
+		})
+		return
+	}
+
+	if o, ok := overrides[r.Method+" "+r.URL.Path]; ok {
+		s.override(w, r, op, o)
+		return
+	}
+	if o, ok := overrides[op.Key()]; ok {
+		s.override(w, r, op, o)
+		return
+	}
+
+	status, media := spec.Response(op)
+	s.respond(w, r, status, spec.Example(media))
+}
+
+func (s *Server) override(w http.ResponseWriter, r *http.Request, op *Operation, o Override) {
+	if o.Delay != "" {
+		d, _ := time.ParseDuration(o.Delay)
+		time.Sleep(d)
+	}
+	for k, v := range o.Headers {
+		w.Header().Set(k, v)
+	}
+	status, media := s.Spec().Response(op)
+	if o.Status != 0 {
+		status = o.Status
+	}
+	body := o.Body
+	if body == nil && o.Status == 0 {
+		body = s.Spec().Example(media)
+	}
+	s.respond(w, r, status, body)
+}
+
+func (s *Server) respond(w http.ResponseWriter, r *http.Request, status int, body interface{}) {
+	s.Log("%s %s → %d", r.Method, r.URL.Path, status)
+	if body == nil || status == http.StatusNoContent {
+		w.WriteHeader(status)
+		return
+	}
+	if str, ok := body.(string); ok && w.Header().Get("Content-Type") != "" {
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, str)
+		return
+	}
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
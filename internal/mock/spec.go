@@ -0,0 +1,317 @@
+// Package mock serves plausible responses from an OpenAPI 3 spec.
+package mock
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// Spec is a parsed OpenAPI document
+type Spec struct {
+	Title      string
+	BasePath   string // path of servers[0].url, e.g. /v1
+	Operations []*Operation
+	doc        map[string]interface{}
+}
+
+// Operation is one path + method
+type Operation struct {
+	Method      string // upper case
+	Path        string // template, e.g. /users/{id}
+	Parameters  []Parameter
+	Body        *RequestBody
+	Responses   map[string]interface{}
+	segments    []string
+	literalSegs int
+}
+
+// Key identifies the operation in override files: "GET /users/{id}"
+func (o *Operation) Key() string {
+	return o.Method + " " + o.Path
+}
+
+// Parameter is a path/query/header parameter
+type Parameter struct {
+	Name     string
+	In       string
+	Required bool
+	Schema   map[string]interface{}
+}
+
+// RequestBody is an operation's expected body
+type RequestBody struct {
+	Required bool
+	Schema   map[string]interface{} // application/json schema, if any
+}
+
+// LoadSpec reads an OpenAPI 3 document (YAML or JSON)
+func LoadSpec(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	doc, ok := stringKeys(raw).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("parsing %s: not a YAML or JSON object", path)
+	}
+	return parseSpec(doc)
+}
+
+// stringKeys converts the maps YAML decodes with non-string keys, such as
+// unquoted status codes (200:), to string-keyed ones, recursively
+func stringKeys(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			m[fmt.Sprint(k)] = stringKeys(item)
+		}
+		return m
+	case map[string]interface{}:
+		for k, item := range val {
+			val[k] = stringKeys(item)
+		}
+		return val
+	case []interface{}:
+		for i, item := range val {
+			val[i] = stringKeys(item)
+		}
+		return val
+	}
+	return v
+}
+
+func parseSpec(doc map[string]interface{}) (*Spec, error) {
+	if _, ok := doc["openapi"]; !ok {
+		return nil, fmt.Errorf("not an OpenAPI 3 document (missing 'openapi' field)")
+	}
+
+	spec := &Spec{doc: doc}
+	if info, ok := doc["info"].(map[string]interface{}); ok {
+		spec.Title, _ = info["title"].(string)
+	}
+	if servers, ok := doc["servers"].([]interface{}); ok && len(servers) > 0 {
+		if s, ok := servers[0].(map[string]interface{}); ok {
+			if raw, ok := s["url"].(string); ok {
+				if u, err := url.Parse(raw); err == nil {
+					spec.BasePath = strings.TrimSuffix(u.Path, "/")
+				}
+			}
+		}
+	}
+
+	paths, _ := doc["paths"].(map[string]interface{})
+	for path, rawItem := range paths {
+		item, ok := spec.resolve(rawItem).(map[string]interface{})
+		if !ok {
+			continue
+		}
+		shared := spec.parameters(item["parameters"])
+		for _, m := range methods {
+			rawOp, ok := item[m].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			op := &Operation{
+				Method:     strings.ToUpper(m),
+				Path:       path,
+				Parameters: mergeParameters(shared, spec.parameters(rawOp["parameters"])),
+				Body:       spec.requestBody(rawOp["requestBody"]),
+			}
+			op.Responses, _ = rawOp["responses"].(map[string]interface{})
+			op.segments = splitPath(path)
+			for _, seg := range op.segments {
+				if !isParam(seg) {
+					op.literalSegs++
+				}
+			}
+			spec.Operations = append(spec.Operations, op)
+		}
+	}
+
+	sort.Slice(spec.Operations, func(i, j int) bool {
+		return spec.Operations[i].Key() < spec.Operations[j].Key()
+	})
+	return spec, nil
+}
+
+// Match finds the operation for a request path, preferring literal segments
+// over templated ones. Returns the operation and its path parameters.
+func (s *Spec) Match(method, path string) (*Operation, map[string]string) {
+	if s.BasePath != "" && strings.HasPrefix(path, s.BasePath) {
+		path = strings.TrimPrefix(path, s.BasePath)
+	}
+	segs := splitPath(path)
+
+	var best *Operation
+	var bestParams map[string]string
+	for _, op := range s.Operations {
+		if op.Method != method || len(op.segments) != len(segs) {
+			continue
+		}
+		params := map[string]string{}
+		ok := true
+		for i, seg := range op.segments {
+			if isParam(seg) {
+				params[seg[1:len(seg)-1]] = segs[i]
+			} else if seg != segs[i] {
+				ok = false
+				break
+			}
+		}
+		if ok && (best == nil || op.literalSegs > best.literalSegs) {
+			best, bestParams = op, params
+		}
+	}
+	return best, bestParams
+}
+
+// PathExists reports whether any method is defined for the path
+func (s *Spec) PathExists(path string) bool {
+	for _, m := range methods {
+		if op, _ := s.Match(strings.ToUpper(m), path); op != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Response picks the documented success response: the lowest 2xx, then
+// "2XX", then "default". Returns the status and the JSON-ish media object.
+func (s *Spec) Response(op *Operation) (int, map[string]interface{}) {
+	var codes []int
+	for code := range op.Responses {
+		if n, err := strconv.Atoi(code); err == nil && n >= 200 && n < 300 {
+			codes = append(codes, n)
+		}
+	}
+	sort.Ints(codes)
+
+	status, key := 200, ""
+	switch {
+	case len(codes) > 0:
+		status, key = codes[0], strconv.Itoa(codes[0])
+	case op.Responses["2XX"] != nil:
+		key = "2XX"
+	case op.Responses["default"] != nil:
+		key = "default"
+	default:
+		return status, nil
+	}
+
+	resp, _ := s.resolve(op.Responses[key]).(map[string]interface{})
+	content, _ := resp["content"].(map[string]interface{})
+	if len(content) == 0 {
+		return status, nil
+	}
+	if media, ok := content["application/json"].(map[string]interface{}); ok {
+		return status, media
+	}
+	types := make([]string, 0, len(content))
+	for t := range content {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	media, _ := content[types[0]].(map[string]interface{})
+	return status, media
+}
+
+func (s *Spec) parameters(raw interface{}) []Parameter {
+	list, _ := raw.([]interface{})
+	var params []Parameter
+	for _, r := range list {
+		p, ok := s.resolve(r).(map[string]interface{})
+		if !ok {
+			continue
+		}
+		param := Parameter{}
+		param.Name, _ = p["name"].(string)
+		param.In, _ = p["in"].(string)
+		param.Required, _ = p["required"].(bool)
+		param.Schema, _ = s.resolve(p["schema"]).(map[string]interface{})
+		params = append(params, param)
+	}
+	return params
+}
+
+func (s *Spec) requestBody(raw interface{}) *RequestBody {
+	rb, ok := s.resolve(raw).(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	body := &RequestBody{}
+	body.Required, _ = rb["required"].(bool)
+	if content, ok := rb["content"].(map[string]interface{}); ok {
+		if media, ok := content["application/json"].(map[string]interface{}); ok {
+			body.Schema, _ = s.resolve(media["schema"]).(map[string]interface{})
+		}
+	}
+	return body
+}
+
+// resolve follows a local $ref ("#/components/schemas/User")
+func (s *Spec) resolve(v interface{}) interface{} {
+	for i := 0; i < 16; i++ {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return v
+		}
+		ref, ok := m["$ref"].(string)
+		if !ok || !strings.HasPrefix(ref, "#/") {
+			return v
+		}
+		var cur interface{} = s.doc
+		for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+			next, ok := cur.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			cur = next[part]
+		}
+		v = cur
+	}
+	return v
+}
+
+// mergeParameters lets operation parameters override path-level ones
+func mergeParameters(shared, own []Parameter) []Parameter {
+	out := append([]Parameter{}, own...)
+	for _, p := range shared {
+		found := false
+		for _, o := range own {
+			if o.Name == p.Name && o.In == p.In {
+				found = true
+				break
+			}
+		}
+		if !found {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func splitPath(p string) []string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+func isParam(seg string) bool {
+	return strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}")
+}
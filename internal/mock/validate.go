@@ -0,0 +1,190 @@
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+// ValidateRequest checks path/query/header parameters and the JSON body
+// against the operation. Returns one message per problem.
+func (s *Spec) ValidateRequest(op *Operation, pathParams map[string]string, query url.Values, header func(string) string, body []byte) []string {
+	var problems []string
+
+	for _, p := range op.Parameters {
+		var value string
+		var present bool
+		switch p.In {
+		case "path":
+			value, present = pathParams[p.Name]
+		case "query":
+			_, present = query[p.Name]
+			value = query.Get(p.Name)
+		case "header":
+			value = header(p.Name)
+			present = value != ""
+		default:
+			continue
+		}
+		if !present {
+			if p.Required {
+				problems = append(problems, fmt.Sprintf("%s parameter '%s' is required", p.In, p.Name))
+			}
+			continue
+		}
+		if msg := checkScalar(value, p.Schema); msg != "" {
+			problems = append(problems, fmt.Sprintf("%s parameter '%s' %s", p.In, p.Name, msg))
+		}
+	}
+
+	if op.Body == nil {
+		return problems
+	}
+	if len(body) == 0 {
+		if op.Body.Required {
+			problems = append(problems, "request body is required")
+		}
+		return problems
+	}
+	if op.Body.Schema == nil {
+		return problems
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return append(problems, fmt.Sprintf("request body is not valid JSON: %s", err))
+	}
+	return append(problems, s.validate("body", v, op.Body.Schema, 0)...)
+}
+
+// checkScalar validates a string parameter against a primitive schema
+func checkScalar(value string, schema map[string]interface{}) string {
+	if schema == nil {
+		return ""
+	}
+	switch schemaType(schema) {
+	case "integer":
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return "must be an integer"
+		}
+	case "number":
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return "must be a number"
+		}
+	case "boolean":
+		if _, err := strconv.ParseBool(value); err != nil {
+			return "must be a boolean"
+		}
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok && len(enum) > 0 {
+		for _, e := range enum {
+			if fmt.Sprint(e) == value {
+				return ""
+			}
+		}
+		return fmt.Sprintf("must be one of %v", enum)
+	}
+	return ""
+}
+
+func (s *Spec) validate(path string, v interface{}, raw interface{}, depth int) []string {
+	schema, ok := s.resolve(raw).(map[string]interface{})
+	if !ok || depth > maxDepth {
+		return nil
+	}
+
+	if all, ok := schema["allOf"].([]interface{}); ok {
+		var problems []string
+		for _, sub := range all {
+			problems = append(problems, s.validate(path, v, sub, depth+1)...)
+		}
+		return problems
+	}
+	for _, key := range []string{"oneOf", "anyOf"} {
+		if alts, ok := schema[key].([]interface{}); ok && len(alts) > 0 {
+			for _, alt := range alts {
+				if len(s.validate(path, v, alt, depth+1)) == 0 {
+					return nil
+				}
+			}
+			return []string{fmt.Sprintf("%s does not match any allowed schema", path)}
+		}
+	}
+
+	if v == nil {
+		if nullable, _ := schema["nullable"].(bool); nullable {
+			return nil
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok && len(enum) > 0 {
+		found := false
+		for _, e := range enum {
+			if fmt.Sprint(e) == fmt.Sprint(v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return []string{fmt.Sprintf("%s must be one of %v", path, enum)}
+		}
+	}
+
+	switch schemaType(schema) {
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s must be an object", path)}
+		}
+		var problems []string
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, r := range required {
+				name, _ := r.(string)
+				if _, present := obj[name]; !present {
+					problems = append(problems, fmt.Sprintf("%s.%s is required", path, name))
+				}
+			}
+		}
+		props, _ := schema["properties"].(map[string]interface{})
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if prop, ok := props[name]; ok {
+				problems = append(problems, s.validate(path+"."+name, obj[name], prop, depth+1)...)
+			}
+		}
+		return problems
+	case "array":
+		arr, ok := v.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s must be an array", path)}
+		}
+		var problems []string
+		for i, item := range arr {
+			problems = append(problems, s.validate(fmt.Sprintf("%s[%d]", path, i), item, schema["items"], depth+1)...)
+		}
+		return problems
+	case "string":
+		if _, ok := v.(string); !ok {
+			return []string{fmt.Sprintf("%s must be a string", path)}
+		}
+	case "integer":
+		n, ok := v.(float64)
+		if !ok || n != float64(int64(n)) {
+			return []string{fmt.Sprintf("%s must be an integer", path)}
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			return []string{fmt.Sprintf("%s must be a number", path)}
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return []string{fmt.Sprintf("%s must be a boolean", path)}
+		}
+	}
+	return nil
+}
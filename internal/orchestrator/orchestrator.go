@@ -5,14 +5,17 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/eshe-huli/pier/internal/buildspec"
 	"github.com/eshe-huli/pier/internal/config"
 	"github.com/eshe-huli/pier/internal/detect"
 	"github.com/eshe-huli/pier/internal/docker"
 	"github.com/eshe-huli/pier/internal/infra"
+	"github.com/eshe-huli/pier/internal/pierfile"
+	"github.com/eshe-huli/pier/internal/proxy"
 	"github.com/eshe-huli/pier/internal/registry"
 	"github.com/eshe-huli/pier/internal/runtime"
 )
@@ -22,8 +25,7 @@ type AppSpec struct {
 	Name        string
 	Dir         string
 	Image       string            // Pre-built image (skip build if set)
-	BuildCtx    string            // Build context path
-	Dockerfile  string            // Explicit Dockerfile path (empty = auto-detect)
+	Build       *buildspec.Spec   // Build options; context and Dockerfile default to Dir
 	Port        int               // Container port
 	Env         map[string]string // Extra env vars
 	Volumes     []string          // Volume mounts
@@ -47,17 +49,10 @@ func BuildImage(ctx context.Context, spec AppSpec) (string, int, error) {
 		return spec.Image, port, nil
 	}
 
-	buildCtx := spec.Dir
-	if spec.BuildCtx != "" {
-		buildCtx = spec.BuildCtx
-		if !filepath.IsAbs(buildCtx) {
-			buildCtx = filepath.Join(spec.Dir, buildCtx)
-		}
-	}
-
-	dockerfile := spec.Dockerfile
+	build := docker.NewBuildSpec(spec.Dir, imageName, spec.Build)
+	dockerfile := build.Dockerfile
 	if dockerfile == "" {
-		dockerfile = filepath.Join(buildCtx, "Dockerfile")
+		dockerfile = filepath.Join(build.Context, "Dockerfile")
 	}
 
 	// If no Dockerfile, auto-detect framework and generate one
@@ -79,8 +74,8 @@ func BuildImage(ctx context.Context, spec AppSpec) (string, int, error) {
 		if err := os.MkdirAll(pierDir, 0755); err != nil {
 			return "", 0, fmt.Errorf("creating .pier directory: %w", err)
 		}
-		dockerfile = filepath.Join(pierDir, "Dockerfile")
-		if err := os.WriteFile(dockerfile, []byte(tmpl), 0644); err != nil {
+		build.Dockerfile = filepath.Join(pierDir, "Dockerfile")
+		if err := os.WriteFile(build.Dockerfile, []byte(tmpl), 0644); err != nil {
 			return "", 0, fmt.Errorf("writing generated Dockerfile: %w", err)
 		}
 	} else if port == 0 {
@@ -89,25 +84,17 @@ func BuildImage(ctx context.Context, spec AppSpec) (string, int, error) {
 		}
 	}
 
-	buildArgs := []string{"build", "-t", imageName}
-	if dockerfile != filepath.Join(buildCtx, "Dockerfile") {
-		buildArgs = append(buildArgs, "-f", dockerfile)
-	}
-	buildArgs = append(buildArgs, buildCtx)
-
-	cmd := exec.CommandContext(ctx, "docker", buildArgs...)
-	cmd.Dir = spec.Dir
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		return "", 0, fmt.Errorf("docker build failed: %w", err)
+	err := docker.Build(ctx, build, os.Stdout)
+	if err != nil {
+		return "", 0, err
 	}
 
 	return imageName, port, nil
 }
 
-// EnsureInfra starts shared infrastructure and returns the services + env overrides.
-func EnsureInfra(ctx context.Context, services []string, projectName string) ([]infra.SharedService, []string, bool, error) {
+// EnsureInfra starts shared infrastructure and returns the services + env overrides
+// for the project in dir.
+func EnsureInfra(ctx context.Context, services []string, projectName, dir string) ([]infra.SharedService, []string, bool, error) {
 	var shared []infra.SharedService
 	var dbCreated bool
 
@@ -118,6 +105,16 @@ func EnsureInfra(ctx context.Context, services []string, projectName string) ([]
 		}
 		svcName, svcVersion := parts[0], parts[1]
 
+		if svcName == "auth" {
+			var authCfg *pierfile.AuthConfig
+			if pf, err := pierfile.Load(dir); err == nil {
+				authCfg = pf.Auth
+			}
+			if err := infra.ProvisionAuth(svcVersion, projectName, authCfg); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: could not register %s with auth: %v\n", projectName, err)
+			}
+		}
+
 		if err := infra.EnsureService(svcName, svcVersion); err != nil {
 			return nil, nil, false, fmt.Errorf("starting %s: %w", svcSpec, err)
 		}
@@ -128,20 +125,37 @@ func EnsureInfra(ctx context.Context, services []string, projectName string) ([]
 		}
 
 		if svcName == "postgres" || svcName == "mysql" {
-			if err := infra.CreateDatabase(svcName, svcVersion, projectName); err != nil {
-				// Non-fatal, just warn
-			} else {
+			if err := infra.WaitReady(svcName, svcVersion, 60*time.Second); err != nil {
+				continue
+			}
+			if created, err := infra.CreateDatabase(svcName, svcVersion, projectName); err == nil && created {
 				dbCreated = true
 			}
 		}
+
+		if infra.IsolatesProjects(svcName) {
+			if err := infra.ProvisionProject(svcName, svcVersion, projectName); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: could not provision %s for %s: %v\n", svcName, projectName, err)
+			}
+		}
+	}
+
+	specs := make([]string, len(shared))
+	for i, svc := range shared {
+		specs[i] = svc.Spec()
+	}
+	if err := registry.RecordServices(projectName, specs); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: could not record service usage: %v\n", err)
 	}
 
-	envOverrides := runtime.BuildEnvOverrides(shared)
-	dbName := strings.ReplaceAll(projectName, "-", "_")
-	envOverrides = append(envOverrides,
-		fmt.Sprintf("DATABASE_NAME=%s", dbName),
-		fmt.Sprintf("DB_DATABASE=%s", dbName),
-	)
+	opts := runtime.EnvOptions{Project: projectName}
+	if fw, err := detect.DetectFramework(dir); err == nil {
+		opts.Framework = fw.Name
+	}
+	if pf, err := pierfile.Load(dir); err == nil {
+		opts.EnvMap = pf.EnvMap
+	}
+	envOverrides := runtime.BuildEnvOverrides(infra.WithTelemetry(shared), opts)
 
 	return shared, envOverrides, dbCreated, nil
 }
@@ -149,88 +163,35 @@ func EnsureInfra(ctx context.Context, services []string, projectName string) ([]
 // RunContainer stops the old container, starts a new one with Traefik labels, and registers it.
 func RunContainer(ctx context.Context, spec AppSpec, image string, port int, cfg *config.Config, envOverrides []string) error {
 	// Stop old container
-	if err := docker.StopAndRemoveContainer(ctx, spec.Name); err != nil {
+	container := docker.AppContainerName(spec.Name, "")
+	if err := docker.StopAndRemoveContainer(ctx, container); err != nil {
 		// Non-fatal, container might not exist
 	}
 
-	dockerArgs := []string{"run", "-d", "--name", spec.Name, "--network", cfg.Network, "--restart", "unless-stopped"}
-
-	// Env overrides from shared services
-	for _, e := range envOverrides {
-		dockerArgs = append(dockerArgs, "-e", e)
-	}
-
-	// App-specific env
+	env := append([]string{}, envOverrides...)
 	for k, v := range spec.Env {
-		dockerArgs = append(dockerArgs, "-e", fmt.Sprintf("%s=%s", k, v))
-	}
-
-	// Traefik labels
-	dockerArgs = append(dockerArgs,
-		"-l", "traefik.enable=true",
-		"-l", fmt.Sprintf("traefik.http.routers.%s.rule=Host(`%s.%s`)", spec.Name, spec.Name, cfg.TLD),
-	)
-	if port > 0 {
-		dockerArgs = append(dockerArgs,
-			"-l", fmt.Sprintf("traefik.http.services.%s.loadbalancer.server.port=%d", spec.Name, port),
-		)
-	}
-
-	// Volumes
-	for _, v := range spec.Volumes {
-		if strings.Contains(v, ":") {
-			parts := strings.SplitN(v, ":", 3)
-			hostPath := parts[0]
-			if !filepath.IsAbs(hostPath) {
-				hostPath = filepath.Join(spec.Dir, hostPath)
-			}
-			mount := hostPath + ":" + strings.Join(parts[1:], ":")
-			dockerArgs = append(dockerArgs, "-v", mount)
-		}
-	}
-
-	// Entrypoint override
-	if spec.Entrypoint != nil {
-		switch ep := spec.Entrypoint.(type) {
-		case string:
-			dockerArgs = append(dockerArgs, "--entrypoint", ep)
-		case []interface{}:
-			if len(ep) > 0 {
-				dockerArgs = append(dockerArgs, "--entrypoint", fmt.Sprintf("%v", ep[0]))
-			}
-		}
-	}
-
-	dockerArgs = append(dockerArgs, image)
-
-	// Entrypoint args (remaining elements)
-	if spec.Entrypoint != nil {
-		if ep, ok := spec.Entrypoint.([]interface{}); ok && len(ep) > 1 {
-			for _, e := range ep[1:] {
-				dockerArgs = append(dockerArgs, fmt.Sprintf("%v", e))
-			}
-		}
-	}
-
-	// Command override
-	if spec.Command != nil {
-		switch cmd := spec.Command.(type) {
-		case string:
-			dockerArgs = append(dockerArgs, cmd)
-		case []interface{}:
-			for _, c := range cmd {
-				dockerArgs = append(dockerArgs, fmt.Sprintf("%v", c))
-			}
-		}
-	}
-
-	dockerCmd := exec.CommandContext(ctx, "docker", dockerArgs...)
-	out, err := dockerCmd.CombinedOutput()
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	_, err := docker.Run(ctx, docker.RunSpec{
+		Name:       container,
+		Image:      image,
+		Network:    cfg.Network,
+		Restart:    "unless-stopped",
+		Env:        env,
+		Labels:     proxy.RouterLabels(spec.Name, cfg.TLD, port),
+		Owner:      docker.Owner{Role: docker.RoleApp, Project: spec.Name, Service: spec.Name},
+		Aliases:    []string{spec.Name},
+		Binds:      docker.BindMounts(spec.Volumes, spec.Dir),
+		Entrypoint: docker.ArgList(spec.Entrypoint),
+		Cmd:        docker.ArgList(spec.Command),
+		PullOutput: os.Stdout,
+	})
 	if err != nil {
-		return fmt.Errorf("docker run failed: %s\n%s", err, string(out))
+		return err
 	}
 
-	// File proxy backup — caller should handle this via cli createContainerProxy
+	// File proxy backup — caller should handle this via proxy.CreateContainerProxy
 
 	// Register project
 	if err := registry.Register(registry.Project{Name: spec.Name, Dir: spec.Dir, Type: "docker"}); err != nil {
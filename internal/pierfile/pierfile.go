@@ -6,8 +6,11 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/eshe-huli/pier/internal/buildspec"
 )
 
 const FileName = "Pierfile"
@@ -70,11 +73,87 @@ func (s ServiceEntry) String() string {
 }
 
 type Pierfile struct {
-	Name     string            `yaml:"name"`
-	Services []ServiceEntry    `yaml:"services,omitempty"`
-	Port     int               `yaml:"port,omitempty"`
-	Build    bool              `yaml:"build,omitempty"`
-	Env      map[string]string `yaml:"env,omitempty"`
+	Name        string            `yaml:"name"`
+	Services    []ServiceEntry    `yaml:"services,omitempty"`
+	Port        int               `yaml:"port,omitempty"`
+	Replicas    int               `yaml:"replicas,omitempty"` // app containers behind the route; default 1
+	Build       *buildspec.Spec   `yaml:"build,omitempty"`    // true, or build options
+	Env         map[string]string `yaml:"env,omitempty"`
+	EnvMap      map[string]string `yaml:"env_map,omitempty"` // rename (OLD: NEW) or suppress (OLD: "") injected keys
+	DB          *DBHooks          `yaml:"db,omitempty"`
+	Auth        *AuthConfig       `yaml:"auth,omitempty"`
+	Healthcheck *Healthcheck      `yaml:"healthcheck,omitempty"`
+}
+
+// Healthcheck says when the app is ready. `pier up` waits for it before
+// reporting success:
+//
+//	healthcheck:
+//	  path: /healthz              # probed through the app's route
+//	  command: curl -fs localhost:3000/healthz   # or a Docker HEALTHCHECK
+//	  interval: 5s
+//	  timeout: 3s
+//	  start_period: 10s
+//	  retries: 3
+//	  wait: 2m                    # how long pier up waits (default 1m)
+type Healthcheck struct {
+	Path        string        `yaml:"path,omitempty" json:"path,omitempty"`
+	Command     string        `yaml:"command,omitempty" json:"command,omitempty"`
+	Interval    time.Duration `yaml:"interval,omitempty" json:"interval,omitempty"`
+	Timeout     time.Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	StartPeriod time.Duration `yaml:"start_period,omitempty" json:"startPeriod,omitempty"`
+	Retries     int           `yaml:"retries,omitempty" json:"retries,omitempty"`
+	Wait        time.Duration `yaml:"wait,omitempty" json:"wait,omitempty"`
+}
+
+// AuthConfig declares test users and OIDC clients for the shared auth service:
+//
+//	auth:
+//	  users:
+//	    - username: alice
+//	      password: alice
+//	      email: alice@example.com
+//	  clients:
+//	    - id: web
+//	      secret: web-secret
+//	      redirect_uris: ["http://web.dock/auth/callback"]
+type AuthConfig struct {
+	Users   []AuthUser   `yaml:"users,omitempty" json:"users,omitempty"`
+	Clients []AuthClient `yaml:"clients,omitempty" json:"clients,omitempty"`
+}
+
+// AuthUser is a test login
+type AuthUser struct {
+	Username string            `yaml:"username" json:"username"`
+	Password string            `yaml:"password" json:"password"`
+	Email    string            `yaml:"email,omitempty" json:"email,omitempty"`
+	Name     string            `yaml:"name,omitempty" json:"name,omitempty"`
+	Claims   map[string]string `yaml:"claims,omitempty" json:"claims,omitempty"`
+}
+
+// AuthClient is an OIDC client (relying party)
+type AuthClient struct {
+	ID           string   `yaml:"id" json:"id"`
+	Secret       string   `yaml:"secret,omitempty" json:"secret,omitempty"`
+	RedirectURIs []string `yaml:"redirect_uris,omitempty" json:"redirectUris,omitempty"`
+}
+
+// DBHooks are commands run in the app container once its database is ready:
+//
+//	db:
+//	  migrate: npx prisma migrate deploy
+//	  seed: npx prisma db seed
+type DBHooks struct {
+	Migrate string `yaml:"migrate,omitempty" json:"migrate,omitempty"`
+	Seed    string `yaml:"seed,omitempty" json:"seed,omitempty"`
+}
+
+// ReplicaCount is how many app containers `pier up` runs
+func (pf *Pierfile) ReplicaCount() int {
+	if pf == nil || pf.Replicas < 1 {
+		return 1
+	}
+	return pf.Replicas
 }
 
 // ServiceNames returns just the service names (for backwards compat).
@@ -121,6 +200,9 @@ func Load(dir string) (*Pierfile, error) {
 	if err := yaml.Unmarshal(data, &pf); err != nil {
 		return nil, fmt.Errorf("parsing %s: %w", FileName, err)
 	}
+	if pf.Replicas < 0 {
+		return nil, fmt.Errorf("parsing %s: replicas must be at least 1", FileName)
+	}
 	return &pf, nil
 }
 
@@ -139,3 +221,78 @@ func Save(dir string, pf *Pierfile) error {
 	}
 	return os.WriteFile(filepath.Join(dir, FileName), data, 0644)
 }
+
+// ReplaceServiceVersion rewrites name:oldVersion service entries to newVersion
+// in the Pierfile in dir, editing only the matching values so comments and
+// layout are kept as written. Returns true if the file was changed.
+func ReplaceServiceVersion(dir, name, oldVersion, newVersion string) (bool, error) {
+	path := filepath.Join(dir, FileName)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return false, fmt.Errorf("parsing %s: %w", FileName, err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return false, nil
+	}
+
+	type edit struct {
+		node  *yaml.Node
+		value string
+	}
+	var edits []edit
+	root := doc.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != "services" || root.Content[i+1].Kind != yaml.SequenceNode {
+			continue
+		}
+		for _, item := range root.Content[i+1].Content {
+			switch item.Kind {
+			case yaml.ScalarNode:
+				dep := ParseService(item.Value)
+				if dep.Name == name && dep.Version == oldVersion {
+					edits = append(edits, edit{item, FormatService(name, newVersion)})
+				}
+			case yaml.MappingNode:
+				var nameNode, versionNode *yaml.Node
+				for j := 0; j+1 < len(item.Content); j += 2 {
+					switch item.Content[j].Value {
+					case "name":
+						nameNode = item.Content[j+1]
+					case "version":
+						versionNode = item.Content[j+1]
+					}
+				}
+				if nameNode != nil && versionNode != nil && nameNode.Value == name && versionNode.Value == oldVersion {
+					edits = append(edits, edit{versionNode, newVersion})
+				}
+			}
+		}
+	}
+
+	if len(edits) == 0 {
+		return false, nil
+	}
+
+	// Re-encoding the document would re-indent it and drop blank lines, so
+	// the new values are spliced into the source lines instead, last first
+	// so earlier columns on a line stay put.
+	lines := strings.SplitAfter(string(data), "\n")
+	for i := len(edits) - 1; i >= 0; i-- {
+		n := edits[i].node
+		line := lines[n.Line-1]
+		// Columns count characters, not bytes
+		col := len(string([]rune(line)[:n.Column-1]))
+		at := strings.Index(line[col:], n.Value)
+		if at < 0 {
+			return false, fmt.Errorf("%s line %d: can't rewrite %q in place", FileName, n.Line, n.Value)
+		}
+		at += col
+		lines[n.Line-1] = line[:at] + edits[i].value + line[at+len(n.Value):]
+	}
+	return true, os.WriteFile(path, []byte(strings.Join(lines, "")), 0644)
+}
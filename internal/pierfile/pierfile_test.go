@@ -0,0 +1,98 @@
+package pierfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReplaceServiceVersion(t *testing.T) {
+	dir := t.TempDir()
+	content := `name: shop
+
+# shared services
+services:
+  - postgres:15   # primary
+  - redis:7
+  - name: postgres
+    version: "15"
+    port: 5432
+`
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := ReplaceServiceVersion(dir, "postgres", "15", "16")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !changed {
+		t.Fatal("expected Pierfile to change")
+	}
+
+	pf, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range pf.Services {
+		if s.Name == "postgres" && s.Version != "16" {
+			t.Errorf("got postgres version %q, want 16", s.Version)
+		}
+		if s.Name == "redis" && s.Version != "7" {
+			t.Errorf("redis should be untouched, got %q", s.Version)
+		}
+	}
+
+	data, _ := os.ReadFile(filepath.Join(dir, FileName))
+	want := strings.NewReplacer("postgres:15", "postgres:16", `"15"`, `"16"`).Replace(content)
+	if string(data) != want {
+		t.Errorf("layout not preserved:\n%s\nwant:\n%s", data, want)
+	}
+}
+
+func TestReplaceServiceVersion_NoMatch(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte("name: shop\nservices:\n  - postgres:16\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := ReplaceServiceVersion(dir, "postgres", "15", "16")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if changed {
+		t.Error("expected no change")
+	}
+}
+
+func TestLoadHealthcheck(t *testing.T) {
+	dir := t.TempDir()
+	content := `name: shop
+healthcheck:
+  path: /healthz
+  command: curl -fs localhost:3000/healthz
+  interval: 5s
+  retries: 3
+  wait: 2m
+`
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pf, err := Load(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	hc := pf.Healthcheck
+	if hc == nil {
+		t.Fatal("expected a healthcheck")
+	}
+	if hc.Path != "/healthz" || hc.Command != "curl -fs localhost:3000/healthz" || hc.Retries != 3 {
+		t.Errorf("healthcheck = %+v", hc)
+	}
+	if hc.Interval != 5*time.Second || hc.Wait != 2*time.Minute {
+		t.Errorf("interval = %s, wait = %s; want 5s, 2m", hc.Interval, hc.Wait)
+	}
+}
@@ -23,3 +23,58 @@ func WriteTraefikConfig(name string, cfg map[string]interface{}) error {
 
 	return nil
 }
+
+// CreateContainerProxy routes <name>.<tld> to a container on the pier network
+// (by container name, not host.docker.internal)
+func CreateContainerProxy(name, target string, port int, tld string) error {
+	return CreateServiceProxy(name, []string{target}, port, tld)
+}
+
+// CreateServiceProxy routes <name>.<tld> to containers on the pier network,
+// load-balancing across them
+func CreateServiceProxy(name string, targets []string, port int, tld string) error {
+	domain := fmt.Sprintf("%s.%s", name, tld)
+	servers := make([]map[string]interface{}, 0, len(targets))
+	for _, target := range targets {
+		servers = append(servers, map[string]interface{}{"url": fmt.Sprintf("http://%s:%d", target, port)})
+	}
+
+	cfg := map[string]interface{}{
+		"http": map[string]interface{}{
+			"routers": map[string]interface{}{
+				name: map[string]interface{}{
+					"rule":        fmt.Sprintf("Host(`%s`)", domain),
+					"service":     name,
+					"entryPoints": []string{"web"},
+				},
+			},
+			"services": map[string]interface{}{
+				name: map[string]interface{}{
+					"loadBalancer": map[string]interface{}{
+						"servers": servers,
+					},
+				},
+			},
+		},
+	}
+
+	return WriteTraefikConfig(name, cfg)
+}
+
+// RouterLabels are the Traefik docker-provider labels routing <name>.<tld>
+// to a container. A zero port leaves the port for Traefik to guess. The
+// service is named after the route, so Traefik load-balances across every
+// container carrying the same labels.
+func RouterLabels(name, tld string, port int) map[string]string {
+	labels := map[string]string{
+		"traefik.enable": "true",
+		fmt.Sprintf("traefik.http.routers.%s.rule", name):    fmt.Sprintf("Host(`%s.%s`)", name, tld),
+		fmt.Sprintf("traefik.http.routers.%s.service", name): name,
+	}
+	if port > 0 {
+		labels[fmt.Sprintf("traefik.http.services.%s.loadbalancer.server.port", name)] = fmt.Sprintf("%d", port)
+	} else {
+		labels[fmt.Sprintf("traefik.http.services.%s.loadbalancer.passhostheader", name)] = "true"
+	}
+	return labels
+}
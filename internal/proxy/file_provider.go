@@ -3,8 +3,10 @@ package proxy
 import (
 	"fmt"
 	"net"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,9 +16,11 @@ import (
 
 // FileProxy represents a bare-metal proxy entry
 type FileProxy struct {
-	Name   string
-	Port   int
-	Domain string
+	Name          string
+	Port          int // host port, for routes to bare-metal processes
+	Domain        string
+	Container     string // backend container, for routes into the pier network
+	ContainerPort int    // and the port it serves on
 }
 
 // CreateFileProxy creates a Traefik dynamic config file for a bare-metal proxy
@@ -105,6 +109,7 @@ func ListFileProxies(tld string) ([]FileProxy, error) {
 		data, err := os.ReadFile(filepath.Join(dynamicDir, entry.Name()))
 		if err == nil {
 			proxy.Port = extractPort(data)
+			proxy.Container, proxy.ContainerPort = extractContainer(data)
 		}
 
 		proxies = append(proxies, proxy)
@@ -155,3 +160,38 @@ func extractPort(data []byte) int {
 	fmt.Sscanf(portStr, "%d", &port)
 	return port
 }
+
+// extractContainer returns the backend container and port of a route
+// written by CreateContainerProxy, or "" for routes to the host
+func extractContainer(data []byte) (string, int) {
+	var route struct {
+		HTTP struct {
+			Services map[string]struct {
+				LoadBalancer struct {
+					Servers []struct {
+						URL string `yaml:"url"`
+					} `yaml:"servers"`
+				} `yaml:"loadBalancer"`
+			} `yaml:"services"`
+		} `yaml:"http"`
+	}
+	if err := yaml.Unmarshal(data, &route); err != nil {
+		return "", 0
+	}
+	for _, svc := range route.HTTP.Services {
+		for _, srv := range svc.LoadBalancer.Servers {
+			u, err := url.Parse(srv.URL)
+			if err != nil {
+				continue
+			}
+			switch host := u.Hostname(); host {
+			case "", "host.docker.internal", "127.0.0.1", "localhost":
+				return "", 0
+			default:
+				port, _ := strconv.Atoi(u.Port())
+				return host, port
+			}
+		}
+	}
+	return "", 0
+}
@@ -4,21 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"path/filepath"
-	"strings"
 	"time"
 
-	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/api/types/image"
-	"github.com/docker/docker/api/types/mount"
-	"github.com/docker/docker/api/types/network"
-	"github.com/docker/docker/client"
-	"github.com/docker/go-connections/nat"
-
 	"github.com/eshe-huli/pier/internal/config"
+	"github.com/eshe-huli/pier/internal/docker"
 )
 
 const (
@@ -78,31 +70,14 @@ providers:
 
 // StartTraefik starts the Traefik container
 func StartTraefik(ctx context.Context, cfg *config.Config) error {
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
-	if err != nil {
-		return fmt.Errorf("connecting to Docker: %w", err)
-	}
-	defer cli.Close()
-
 	// Check if container already exists and is running
-	info, err := cli.ContainerInspect(ctx, traefikContainerName)
+	info, err := docker.GetContainer(ctx, traefikContainerName)
 	if err == nil {
-		if info.State.Running {
+		if info.Running {
 			return nil // Already running
 		}
 		// Container exists but stopped — remove and recreate
-		_ = cli.ContainerRemove(ctx, traefikContainerName, container.RemoveOptions{Force: true})
-	}
-
-	// Pull image if needed
-	_, _, err = cli.ImageInspectWithRaw(ctx, cfg.Traefik.Image)
-	if err != nil {
-		reader, pullErr := cli.ImagePull(ctx, cfg.Traefik.Image, image.PullOptions{})
-		if pullErr != nil {
-			return fmt.Errorf("pulling Traefik image: %w", pullErr)
-		}
-		defer reader.Close()
-		_, _ = io.Copy(io.Discard, reader) // Wait for pull to complete
+		_ = docker.StopAndRemoveContainer(ctx, traefikContainerName)
 	}
 
 	// Resolve paths
@@ -110,60 +85,27 @@ func StartTraefik(ctx context.Context, cfg *config.Config) error {
 	traefikYaml := filepath.Join(home, ".pier", "traefik", "traefik.yaml")
 	dynamicDir := filepath.Join(home, ".pier", "traefik", "dynamic")
 
-	// Container config
-	containerCfg := &container.Config{
-		Image: cfg.Traefik.Image,
+	_, err = docker.Run(ctx, docker.RunSpec{
+		Name:    traefikContainerName,
+		Image:   cfg.Traefik.Image,
+		Network: cfg.Network,
+		Restart: "unless-stopped",
+		Owner:   docker.Owner{Role: docker.RoleSystem, Service: "traefik"},
 		Labels: map[string]string{
 			"pier.domain":    "traefik",
 			"traefik.enable": "true",
 		},
-		ExposedPorts: nat.PortSet{
-			"80/tcp":   {},
-			"8080/tcp": {},
-		},
-	}
-
-	hostCfg := &container.HostConfig{
-		RestartPolicy: container.RestartPolicy{Name: "unless-stopped"},
-		PortBindings: nat.PortMap{
-			"80/tcp": []nat.PortBinding{
-				{HostIP: "0.0.0.0", HostPort: fmt.Sprintf("%d", cfg.Traefik.Port)},
-			},
-			"8080/tcp": []nat.PortBinding{
-				{HostIP: "0.0.0.0", HostPort: fmt.Sprintf("%d", cfg.Traefik.Port+1)},
-			},
+		Ports: []docker.PortMapping{
+			{Container: 80, HostIP: "0.0.0.0", Host: cfg.Traefik.Port},
+			{Container: 8080, HostIP: "0.0.0.0", Host: cfg.Traefik.Port + 1},
 		},
-		Mounts: []mount.Mount{
-			{
-				Type:   mount.TypeBind,
-				Source: "/var/run/docker.sock",
-				Target: "/var/run/docker.sock",
-			},
-			{
-				Type:   mount.TypeBind,
-				Source: traefikYaml,
-				Target: "/etc/traefik/traefik.yaml",
-			},
-			{
-				Type:   mount.TypeBind,
-				Source: dynamicDir,
-				Target: "/etc/traefik/dynamic",
-			},
+		Binds: []string{
+			docker.SocketPath() + ":/var/run/docker.sock",
+			traefikYaml + ":/etc/traefik/traefik.yaml",
+			dynamicDir + ":/etc/traefik/dynamic",
 		},
-	}
-
-	networkCfg := &network.NetworkingConfig{
-		EndpointsConfig: map[string]*network.EndpointSettings{
-			cfg.Network: {},
-		},
-	}
-
-	resp, err := cli.ContainerCreate(ctx, containerCfg, hostCfg, networkCfg, nil, traefikContainerName)
+	})
 	if err != nil {
-		return fmt.Errorf("creating Traefik container: %w", err)
-	}
-
-	if err := cli.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
 		return fmt.Errorf("starting Traefik container: %w", err)
 	}
 
@@ -172,42 +114,12 @@ func StartTraefik(ctx context.Context, cfg *config.Config) error {
 
 // StopTraefik stops and removes the Traefik container
 func StopTraefik(ctx context.Context) error {
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
-	if err != nil {
-		return fmt.Errorf("connecting to Docker: %w", err)
-	}
-	defer cli.Close()
-
-	if err := cli.ContainerStop(ctx, traefikContainerName, container.StopOptions{}); err != nil {
-		if !strings.Contains(err.Error(), "No such container") &&
-			!strings.Contains(err.Error(), "is not running") {
-			return fmt.Errorf("stopping Traefik: %w", err)
-		}
-	}
-
-	if err := cli.ContainerRemove(ctx, traefikContainerName, container.RemoveOptions{Force: true}); err != nil {
-		if !strings.Contains(err.Error(), "No such container") {
-			return fmt.Errorf("removing Traefik: %w", err)
-		}
-	}
-
-	return nil
+	return docker.StopAndRemoveContainer(ctx, traefikContainerName)
 }
 
 // IsTraefikRunning checks if the Traefik container is running
 func IsTraefikRunning(ctx context.Context) bool {
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
-	if err != nil {
-		return false
-	}
-	defer cli.Close()
-
-	info, err := cli.ContainerInspect(ctx, traefikContainerName)
-	if err != nil {
-		return false
-	}
-
-	return info.State.Running
+	return docker.IsContainerRunning(ctx, traefikContainerName)
 }
 
 // GetTraefikRouters fetches active routes from the Traefik API
@@ -233,6 +145,23 @@ func GetTraefikRouters(dashboardPort int) ([]TraefikRouter, error) {
 	return routers, nil
 }
 
+// RouteLoaded reports whether Traefik serves domain yet: a route written
+// to its dynamic config, or a container just started, takes it a moment to
+// pick up, and until then requests for domain get Traefik's own 404
+func RouteLoaded(dashboardPort int, domain string) error {
+	routers, err := GetTraefikRouters(dashboardPort)
+	if err != nil {
+		return err
+	}
+	rule := fmt.Sprintf("Host(`%s`)", domain)
+	for _, r := range routers {
+		if r.Rule == rule && r.Status == "enabled" {
+			return nil
+		}
+	}
+	return fmt.Errorf("route to %s is not loaded yet", domain)
+}
+
 // GetTraefikRouteCount returns the number of active routes
 func GetTraefikRouteCount(dashboardPort int) int {
 	routers, err := GetTraefikRouters(dashboardPort)
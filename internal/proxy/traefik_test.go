@@ -0,0 +1,34 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+func TestRouteLoaded(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/http/routers" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`[
+			{"name": "shop@file", "rule": "Host(` + "`shop.dock`" + `)", "status": "enabled"},
+			{"name": "blog@file", "rule": "Host(` + "`blog.dock`" + `)", "status": "disabled"}
+		]`))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	if err := RouteLoaded(port, "shop.dock"); err != nil {
+		t.Errorf("shop.dock: %v", err)
+	}
+	for _, domain := range []string{"blog.dock", "shop.dock.dock", "api.dock"} {
+		if err := RouteLoaded(port, domain); err == nil {
+			t.Errorf("%s should not be loaded", domain)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package registry
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"sync"
@@ -19,6 +20,34 @@ type Project struct {
 	Type      string `json:"type"` // "link", "docker", "run"
 	Framework string `json:"framework,omitempty"`
 	LastUsed  string `json:"lastUsed"`
+
+	// Containers lists app containers when they aren't named after the project (compose)
+	Containers []string `json:"containers,omitempty"`
+
+	// Pier-managed state, preserved across Register calls
+	Services      []string      `json:"services,omitempty"` // shared services in use, as name:version
+	LastMigration *MigrationRun `json:"lastMigration,omitempty"`
+	Allocations   *Allocations  `json:"allocations,omitempty"`
+}
+
+// Allocations are the per-project resources carved out of shared services
+type Allocations struct {
+	RedisDB     int    `json:"redisDb,omitempty"` // 1-15; DB 0 stays unscoped
+	S3Bucket    string `json:"s3Bucket,omitempty"`
+	S3AccessKey string `json:"s3AccessKey,omitempty"`
+	S3SecretKey string `json:"s3SecretKey,omitempty"`
+	MongoDB     string `json:"mongoDb,omitempty"`
+}
+
+// MaxRedisDB is the highest Redis DB index handed out (redis ships 16 DBs)
+const MaxRedisDB = 15
+
+// MigrationRun records the last database migrate/seed run for a project
+type MigrationRun struct {
+	Migrate   string `json:"migrate,omitempty"`
+	Seed      string `json:"seed,omitempty"`
+	Status    string `json:"status"` // "ok", "failed"
+	AppliedAt string `json:"appliedAt"`
 }
 
 var mu sync.Mutex
@@ -64,6 +93,9 @@ func Register(p Project) error {
 	found := false
 	for i, existing := range projects {
 		if existing.Name == p.Name || existing.Dir == p.Dir {
+			if existing.Name == p.Name {
+				preserveState(&p, existing)
+			}
 			projects[i] = p
 			found = true
 			break
@@ -76,6 +108,19 @@ func Register(p Project) error {
 	return save(projects)
 }
 
+// preserveState carries pier-managed state over from an existing entry
+func preserveState(p *Project, existing Project) {
+	if p.Services == nil {
+		p.Services = existing.Services
+	}
+	if p.LastMigration == nil {
+		p.LastMigration = existing.LastMigration
+	}
+	if p.Allocations == nil {
+		p.Allocations = existing.Allocations
+	}
+}
+
 // Remove removes a project from the registry by name
 func Remove(name string) error {
 	mu.Lock()
@@ -105,3 +150,133 @@ func Touch(name string) error {
 	}
 	return nil
 }
+
+// RecordMigration stores the last migrate/seed run for a registered project
+func RecordMigration(name string, run MigrationRun) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	projects, _ := Load()
+	for i, p := range projects {
+		if p.Name == name {
+			run.AppliedAt = time.Now().UTC().Format(time.RFC3339)
+			projects[i].LastMigration = &run
+			return save(projects)
+		}
+	}
+	return fmt.Errorf("project '%s' is not registered", name)
+}
+
+// RecordServices stores the shared services a project uses. Projects that
+// are not registered yet get a placeholder entry that Register fills in;
+// pierd forgets placeholders that are never registered.
+func RecordServices(name string, services []string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	projects, _ := Load()
+	for i, p := range projects {
+		if p.Name == name {
+			projects[i].Services = services
+			return save(projects)
+		}
+	}
+	projects = append(projects, Project{
+		Name:     name,
+		Services: services,
+		LastUsed: time.Now().UTC().Format(time.RFC3339),
+	})
+	return save(projects)
+}
+
+// Consumers returns the registered projects that use a name:version service
+func Consumers(service string) []Project {
+	projects, _ := Load()
+	var users []Project
+	for _, p := range projects {
+		for _, s := range p.Services {
+			if s == service {
+				users = append(users, p)
+				break
+			}
+		}
+	}
+	return users
+}
+
+// Get returns a registered project by name
+func Get(name string) (*Project, error) {
+	projects, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range projects {
+		if p.Name == name {
+			return &p, nil
+		}
+	}
+	return nil, fmt.Errorf("project '%s' is not registered", name)
+}
+
+// UpdateAllocations applies fn to a project's allocations and saves them.
+// Projects that are not registered yet get a placeholder entry.
+func UpdateAllocations(name string, fn func(a *Allocations) error) (Allocations, error) {
+	return updateAllocations(name, func(a *Allocations, _ []Project) error { return fn(a) })
+}
+
+// updateAllocations is UpdateAllocations, with fn also seeing every project
+// as saved, for carving out what the others haven't taken
+func updateAllocations(name string, fn func(a *Allocations, projects []Project) error) (Allocations, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	projects, err := Load()
+	if err != nil {
+		return Allocations{}, err
+	}
+	idx := -1
+	for i, p := range projects {
+		if p.Name == name {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		projects = append(projects, Project{Name: name, LastUsed: time.Now().UTC().Format(time.RFC3339)})
+		idx = len(projects) - 1
+	}
+
+	alloc := Allocations{}
+	if projects[idx].Allocations != nil {
+		alloc = *projects[idx].Allocations
+	}
+	if err := fn(&alloc, projects); err != nil {
+		return alloc, err
+	}
+	projects[idx].Allocations = &alloc
+	return alloc, save(projects)
+}
+
+// AllocateRedisDB returns the project's Redis DB index, assigning the lowest
+// free one on first use.
+func AllocateRedisDB(name string) (int, error) {
+	alloc, err := updateAllocations(name, func(a *Allocations, projects []Project) error {
+		if a.RedisDB != 0 {
+			return nil
+		}
+		used := map[int]bool{}
+		for _, p := range projects {
+			if p.Name != name && p.Allocations != nil {
+				used[p.Allocations.RedisDB] = true
+			}
+		}
+		for i := 1; i <= MaxRedisDB; i++ {
+			if !used[i] {
+				a.RedisDB = i
+				return nil
+			}
+		}
+		return fmt.Errorf("all %d Redis databases are allocated; remove unused projects from the registry", MaxRedisDB)
+	})
+	return alloc.RedisDB, err
+}
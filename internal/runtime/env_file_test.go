@@ -0,0 +1,54 @@
+package runtime
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateEnvFile(t *testing.T) {
+	t.Setenv("API_KEY", "from-host")
+	dir := t.TempDir()
+	dotenv := `# app settings
+APP_NAME="shop"
+API_KEY=${API_KEY:-dev-key}
+REDIS_HOST=localhost
+CACHE_URL=redis://${REDIS_HOST}:6379
+LOG_LEVEL=${LOG_LEVEL:-info}
+PRICE_FORMAT='$$%d'
+`
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte(dotenv), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := GenerateEnvFile(dir, []string{"REDIS_HOST=pier-redis-7", "DATABASE_URL=postgres://pier-postgres-16/shop"})
+	if err != nil {
+		t.Fatalf("GenerateEnvFile: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if k, v, ok := strings.Cut(line, "="); ok {
+			env[k] = v
+		}
+	}
+
+	want := map[string]string{
+		"APP_NAME":     "shop",
+		"API_KEY":      "from-host",
+		"REDIS_HOST":   "pier-redis-7",
+		"CACHE_URL":    "redis://pier-redis-7:6379", // overrides win in references too
+		"LOG_LEVEL":    "info",
+		"PRICE_FORMAT": "$$%d",
+		"DATABASE_URL": "postgres://pier-postgres-16/shop",
+	}
+	for k, v := range want {
+		if env[k] != v {
+			t.Errorf("%s = %q, want %q", k, env[k], v)
+		}
+	}
+}
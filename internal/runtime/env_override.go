@@ -2,20 +2,92 @@ package runtime
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/eshe-huli/pier/internal/infra"
 )
 
-// BuildEnvOverrides generates environment variable overrides from shared services
-func BuildEnvOverrides(services []infra.SharedService) []string {
-	var envs []string
+// EnvOptions shapes the env injected into a project
+type EnvOptions struct {
+	Project   string            // project name; selects its database and allocations
+	Framework string            // detect.Framework name; adds framework-specific keys
+	EnvMap    map[string]string // Pierfile env_map: rename (OLD: NEW) or suppress (OLD: "") keys
+	// HostPorts maps service containers to their loopback ports. Set it for
+	// processes running on the host (pier link), which can't resolve
+	// container names.
+	HostPorts map[string]int
+}
+
+// BuildEnvOverrides generates environment variable overrides from shared
+// services. When several set the same key, as postgres and mysql both set
+// DATABASE_URL and DB_*, the one listed first keeps it; the others are still
+// reachable through their own keys (MYSQL_URL, ...).
+func BuildEnvOverrides(services []infra.SharedService, opts EnvOptions) []string {
+	env := map[string]string{}
+	setBy := map[string]string{}
 
 	for _, svc := range services {
-		connEnv := infra.GetConnectionEnv(svc.Name, svc.Version)
+		connEnv := infra.GetConnectionEnv(svc.Name, svc.Version, opts.Project)
+		if hostPort, ok := opts.HostPorts[svc.Container]; ok {
+			hostAddress(connEnv, svc, hostPort)
+		}
+		if fn, ok := frameworkEnv[opts.Framework]; ok {
+			for k, v := range fn(svc.Name, connEnv) {
+				connEnv[k] = v
+			}
+		}
 		for k, v := range connEnv {
-			envs = append(envs, fmt.Sprintf("%s=%s", k, v))
+			if by, ok := setBy[k]; ok && by != svc.Container {
+				continue
+			}
+			env[k] = v
+			setBy[k] = svc.Container
 		}
 	}
 
+	applyEnvMap(env, opts.EnvMap)
+
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	envs := make([]string, len(keys))
+	for i, k := range keys {
+		envs[i] = fmt.Sprintf("%s=%s", k, env[k])
+	}
 	return envs
 }
+
+// hostAddress points connection env at a service's loopback port
+func hostAddress(env map[string]string, svc infra.SharedService, hostPort int) {
+	inner := fmt.Sprintf("%s:%d", svc.Container, svc.Port)
+	outer := fmt.Sprintf("127.0.0.1:%d", hostPort)
+	for k, v := range env {
+		switch {
+		case v == svc.Container:
+			env[k] = "127.0.0.1"
+		case v == strconv.Itoa(svc.Port) && strings.HasSuffix(k, "_PORT"):
+			env[k] = strconv.Itoa(hostPort)
+		default:
+			env[k] = strings.ReplaceAll(v, inner, outer)
+		}
+	}
+}
+
+// applyEnvMap renames or drops injected keys
+func applyEnvMap(env map[string]string, envMap map[string]string) {
+	for from, to := range envMap {
+		val, ok := env[from]
+		if !ok {
+			continue
+		}
+		delete(env, from)
+		if to != "" {
+			env[to] = val
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package runtime
+
+import "strings"
+
+// frameworkEnv adds the variables a framework reads by convention, derived
+// from a service's generic connection env. Keyed by detect.Framework name.
+var frameworkEnv = map[string]func(service string, conn map[string]string) map[string]string{
+	"rails":   railsEnv,
+	"django":  djangoEnv,
+	"laravel": laravelEnv,
+	"fastapi": sqlalchemyEnv,
+	"flask":   sqlalchemyEnv,
+	"nestjs":  nodeEnv,
+	"nextjs":  nodeEnv,
+	"nuxt":    nodeEnv,
+	"express": nodeEnv,
+	"fastify": nodeEnv,
+}
+
+// railsEnv: ActiveRecord picks the adapter from the URL scheme
+func railsEnv(service string, conn map[string]string) map[string]string {
+	switch service {
+	case "mysql":
+		return map[string]string{"DATABASE_URL": withScheme(conn["DATABASE_URL"], "mysql2")}
+	case "redis":
+		return map[string]string{"REDIS_CACHE_URL": conn["REDIS_URL"]}
+	case "mail":
+		return map[string]string{"SMTP_ADDRESS": conn["SMTP_HOST"]}
+	}
+	return nil
+}
+
+// djangoEnv covers dj-database-url, django-environ and Celery
+func djangoEnv(service string, conn map[string]string) map[string]string {
+	switch service {
+	case "redis":
+		return map[string]string{
+			"CACHE_URL":         conn["REDIS_URL"],
+			"CELERY_BROKER_URL": conn["REDIS_URL"],
+		}
+	case "minio":
+		return map[string]string{
+			"AWS_STORAGE_BUCKET_NAME": conn["S3_BUCKET"],
+			"AWS_S3_ENDPOINT_URL":     conn["AWS_ENDPOINT_URL"],
+		}
+	case "mail":
+		return map[string]string{
+			"EMAIL_HOST": conn["SMTP_HOST"],
+			"EMAIL_PORT": conn["SMTP_PORT"],
+			"EMAIL_URL":  conn["SMTP_URL"],
+		}
+	}
+	return nil
+}
+
+// laravelEnv matches the keys in Laravel's stock .env
+func laravelEnv(service string, conn map[string]string) map[string]string {
+	switch service {
+	case "postgres":
+		return map[string]string{"DB_CONNECTION": "pgsql"}
+	case "mysql":
+		return map[string]string{"DB_CONNECTION": "mysql"}
+	case "minio":
+		return map[string]string{
+			"AWS_BUCKET":                  conn["S3_BUCKET"],
+			"AWS_ENDPOINT":                conn["AWS_ENDPOINT_URL"],
+			"AWS_DEFAULT_REGION":          conn["AWS_REGION"],
+			"AWS_USE_PATH_STYLE_ENDPOINT": "true",
+		}
+	}
+	return nil
+}
+
+// sqlalchemyEnv: SQLAlchemy rejects the postgres:// scheme and needs a driver for mysql
+func sqlalchemyEnv(service string, conn map[string]string) map[string]string {
+	switch service {
+	case "postgres":
+		url := withScheme(conn["DATABASE_URL"], "postgresql")
+		return map[string]string{"DATABASE_URL": url, "SQLALCHEMY_DATABASE_URI": url}
+	case "mysql":
+		url := withScheme(conn["DATABASE_URL"], "mysql+pymysql")
+		return map[string]string{"DATABASE_URL": url, "SQLALCHEMY_DATABASE_URI": url}
+	case "redis":
+		return map[string]string{"CELERY_BROKER_URL": conn["REDIS_URL"]}
+	}
+	return nil
+}
+
+// nodeEnv: Prisma and TypeORM read DATABASE_URL already; mongoose setups often use MONGO_URI
+func nodeEnv(service string, conn map[string]string) map[string]string {
+	if service == "mongo" {
+		return map[string]string{"MONGO_URI": conn["MONGODB_URI"]}
+	}
+	return nil
+}
+
+// withScheme swaps the scheme of a URL
+func withScheme(url, scheme string) string {
+	if i := strings.Index(url, "://"); i >= 0 {
+		return scheme + url[i:]
+	}
+	return url
+}
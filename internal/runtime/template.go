@@ -0,0 +1,136 @@
+package runtime
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/eshe-huli/pier/internal/config"
+	"github.com/eshe-huli/pier/internal/infra"
+	"github.com/eshe-huli/pier/internal/registry"
+)
+
+// TemplateData is what Pierfile env templates can reference:
+//
+//	env:
+//	  API_URL: "http://{{ project \"auth\" }}"   # auth.<tld>
+//	  CACHE: "{{ .services.redis.url }}"         # also .host .port .version .container
+//	  STRIPE_KEY: "{{ secret \"stripe\" }}"       # from ~/.pier/secrets.yaml
+//	  SELF: "{{ .project }}.{{ .tld }}"
+type TemplateData struct {
+	Project  string
+	TLD      string
+	Services []infra.SharedService
+	// HostPorts maps service containers to their loopback ports, as in
+	// EnvOptions; .url, .host and .port then point there
+	HostPorts map[string]int
+}
+
+// serviceURLKey is the connection env var used for .services.<name>.url
+var serviceURLKey = map[string]string{
+	"postgres":  "DATABASE_URL",
+	"mysql":     "MYSQL_URL",
+	"redis":     "REDIS_URL",
+	"mongo":     "MONGODB_URI",
+	"minio":     "S3_ENDPOINT",
+	"mail":      "SMTP_URL",
+	"auth":      "OIDC_ISSUER",
+	"telemetry": "OTEL_EXPORTER_OTLP_ENDPOINT",
+}
+
+// RenderEnv expands templates in Pierfile env values. Values without "{{"
+// are passed through untouched.
+func RenderEnv(env map[string]string, data TemplateData) (map[string]string, error) {
+	if len(env) == 0 {
+		return env, nil
+	}
+
+	services := map[string]interface{}{}
+	for _, svc := range data.Services {
+		conn := infra.GetConnectionEnv(svc.Name, svc.Version, data.Project)
+		host, port := svc.Container, svc.Port
+		if hostPort, ok := data.HostPorts[svc.Container]; ok {
+			hostAddress(conn, svc, hostPort)
+			host, port = "127.0.0.1", hostPort
+		}
+		services[svc.Name] = map[string]interface{}{
+			"url":       conn[serviceURLKey[svc.Name]],
+			"host":      host,
+			"port":      port,
+			"version":   svc.Version,
+			"container": svc.Container,
+		}
+	}
+	root := map[string]interface{}{
+		"project":  data.Project,
+		"tld":      data.TLD,
+		"services": services,
+	}
+
+	var secrets map[string]string
+	funcs := template.FuncMap{
+		"project": func(name string) (string, error) {
+			if _, err := registry.Get(name); err != nil {
+				return "", fmt.Errorf("unknown project %q (run pier up or pier link in it first)", name)
+			}
+			return fmt.Sprintf("%s.%s", name, data.TLD), nil
+		},
+		"secret": func(name string) (string, error) {
+			if secrets == nil {
+				s, err := config.LoadSecrets()
+				if err != nil {
+					return "", err
+				}
+				secrets = s
+			}
+			v, ok := secrets[name]
+			if !ok {
+				return "", fmt.Errorf("unknown secret %q (add it to %s)", name, config.SecretsPath())
+			}
+			return v, nil
+		},
+	}
+
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := make(map[string]string, len(env))
+	for _, k := range keys {
+		v := env[k]
+		if !strings.Contains(v, "{{") {
+			out[k] = v
+			continue
+		}
+
+		tmpl, err := template.New(k).Funcs(funcs).Option("missingkey=error").Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("env %s: %w", k, err)
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, root); err != nil {
+			return nil, fmt.Errorf("env %s: %w%s", k, err, serviceHint(v, services))
+		}
+		out[k] = b.String()
+	}
+	return out, nil
+}
+
+// serviceHint lists the available services when a template references .services
+func serviceHint(value string, services map[string]interface{}) string {
+	if !strings.Contains(value, ".services") {
+		return ""
+	}
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return " (this project has no shared services)"
+	}
+	sort.Strings(names)
+	return fmt.Sprintf(" (available services: %s)", strings.Join(names, ", "))
+}